@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strconv"
+	"time"
+)
+
+// synchronized output (DEC mode 2026): the terminal holds back drawing
+// between begin and end, so a frame never shows half updated. terminals
+// that don't know the mode ignore it
+const (
+	syncBegin  = "\033[?2026h"
+	syncEnd    = "\033[?2026l"
+	hideCursor = "\033[?25l"
+	showCursor = "\033[?25h"
+)
+
+// frameSource hands out the frames of an animation in playback order
+type frameSource interface {
+	// next returns the next frame and how long to show it, or io.EOF once
+	// the animation is over. the frame may be reused by the following call
+	next() (image.Image, time.Duration, error)
+}
+
+// prescaledSource marks sources that draw their frames at the renderer's
+// sample resolution already, so playback skips scaling them. sources
+// wrapping them pass their frames on unchanged
+type prescaledSource interface {
+	prescaled()
+}
+
+// captionSource marks sources that draw text (subtitles) along with each
+// frame. appendCaption adds it after the frame's bytes, the cursor sitting
+// on the line below the frame, and says how many lines it added there
+type captionSource interface {
+	appendCaption(buf []byte, grid *CellGrid) ([]byte, int)
+}
+
+// gifSource composites GIF frames onto an off-screen canvas, honoring each
+// frame's disposal method and the loop count
+type gifSource struct {
+	gif      *gif.GIF
+	canvas   *image.RGBA
+	previous *image.RGBA // canvas saved for a DisposalPrevious frame
+	index    int
+	passes   int
+	// where in a pass the shown frame and the one before it start, and
+	// where the next one does
+	at, before, clock time.Duration
+}
+
+func newGIFSource(g *gif.GIF) *gifSource {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+	return &gifSource{gif: g, canvas: image.NewRGBA(bounds), previous: image.NewRGBA(bounds)}
+}
+
+func (s *gifSource) next() (image.Image, time.Duration, error) {
+	if len(s.gif.Image) == 0 {
+		return nil, 0, io.EOF
+	}
+	if s.index > 0 {
+		s.dispose(s.index - 1)
+	}
+	if s.index == len(s.gif.Image) {
+		s.passes++
+		// LoopCount is the number of restarts, -1 plays once and 0 loops forever
+		if s.gif.LoopCount < 0 || (s.gif.LoopCount > 0 && s.passes > s.gif.LoopCount) {
+			return nil, 0, io.EOF
+		}
+		s.index, s.clock = 0, 0
+		clear(s.canvas.Pix)
+	}
+
+	frame := s.gif.Image[s.index]
+	if s.disposal(s.index) == gif.DisposalPrevious {
+		copy(s.previous.Pix, s.canvas.Pix)
+	}
+	drawPalettedOver(s.canvas, frame)
+
+	delay := s.delay(s.index)
+	s.before, s.at = s.at, s.clock
+	s.clock += delay
+	s.index++
+	return s.canvas, delay, nil
+}
+
+func (s *gifSource) delay(i int) time.Duration {
+	if i < len(s.gif.Delay) && s.gif.Delay[i] > 1 {
+		return time.Duration(s.gif.Delay[i]) * 10 * time.Millisecond
+	}
+	return 100 * time.Millisecond // what browsers use for a missing or 0 delay
+}
+
+func (s *gifSource) position() time.Duration      { return s.at }
+func (s *gifSource) previousFrame() time.Duration { return s.before }
+func (s *gifSource) pause()                       {}
+
+// length is how long one pass takes
+func (s *gifSource) length() time.Duration {
+	var length time.Duration
+	for i := range s.gif.Image {
+		length += s.delay(i)
+	}
+	return length
+}
+
+// seek composites the frames before the one showing at to from scratch
+func (s *gifSource) seek(to time.Duration) {
+	s.index, s.clock = 0, 0
+	clear(s.canvas.Pix)
+	for s.index < len(s.gif.Image)-1 && s.clock+s.delay(s.index) <= to {
+		s.next()
+	}
+}
+
+func (s *gifSource) disposal(i int) byte {
+	if i < len(s.gif.Disposal) {
+		return s.gif.Disposal[i]
+	}
+	return gif.DisposalNone
+}
+
+// dispose cleans up after frame i was shown
+func (s *gifSource) dispose(i int) {
+	switch s.disposal(i) {
+	case gif.DisposalBackground:
+		r := s.gif.Image[i].Bounds().Intersect(s.canvas.Rect)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			clear(s.canvas.Pix[s.canvas.PixOffset(r.Min.X, y):s.canvas.PixOffset(r.Max.X, y)])
+		}
+	case gif.DisposalPrevious:
+		copy(s.canvas.Pix, s.previous.Pix)
+	}
+}
+
+// drawPalettedOver draws a GIF frame onto the canvas, leaving the canvas
+// alone where the frame is transparent
+func drawPalettedOver(dst *image.RGBA, frame *image.Paletted) {
+	r := frame.Bounds().Intersect(dst.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			index := int(frame.ColorIndexAt(x, y))
+			if index >= len(frame.Palette) {
+				continue
+			}
+			cr, cg, cb, ca := frame.Palette[index].RGBA()
+			if ca == 0 {
+				continue
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(cr >> 8), G: uint8(cg >> 8), B: uint8(cb >> 8), A: uint8(ca >> 8)})
+		}
+	}
+}
+
+// playFrames renders frames until the source runs dry or ctx is cancelled.
+// every frame is built in one buffer and written at once, wrapped in a
+// synchronized update, then the cursor goes back up to draw the next one
+// over it. with keys, playback follows the keyboard and a progress line
+// goes under the frame
+func playFrames(ctx context.Context, out io.Writer, source frameSource, fr *FrameRenderer, sync bool, keys <-chan playKey) error {
+	var buf []byte
+	rows := 0
+	deadline := time.Now()
+	seeker := findSeeker(source)
+	_, prescaled := findSource[prescaledSource](source)
+	canSeek := seeker != nil && seeker.length() > 0
+	paused := false
+
+	io.WriteString(out, hideCursor)
+	defer io.WriteString(out, showCursor)
+
+	draw := func(img image.Image) error {
+		buf = buf[:0]
+		if sync {
+			buf = append(buf, syncBegin...)
+		}
+		if rows > 0 {
+			buf = append(buf, "\033["...)
+			buf = strconv.AppendInt(buf, int64(rows), 10)
+			buf = append(buf, 'F') // CPL: start of the frame's first line
+		}
+		if prescaled {
+			buf = append(buf, fr.RenderScaledFrame(img.(*image.RGBA))...)
+		} else {
+			buf = append(buf, fr.RenderFrame(img)...)
+		}
+		extra := 0
+		if captions, ok := source.(captionSource); ok {
+			buf, extra = captions.appendCaption(buf, fr.Grid())
+		}
+		if keys != nil {
+			buf = appendProgress(buf, fr.Grid().Width, paused, seeker)
+			extra++
+		}
+		if sync {
+			buf = append(buf, syncEnd...)
+		}
+		if _, err := out.Write(buf); err != nil {
+			return err
+		}
+		rows = fr.Grid().Height + extra
+		return nil
+	}
+
+	for {
+		img, delay, err := source.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if paused && seeker != nil {
+			seeker.pause() // stepped or seeked while paused, stay there
+		}
+		if err := draw(img); err != nil {
+			return err
+		}
+
+		// sleep against a running deadline so slow frames don't add up to drift
+		deadline = deadline.Add(delay)
+	wait:
+		for {
+			var tick <-chan time.Time
+			if !paused {
+				tick = time.After(time.Until(deadline))
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-tick:
+				break wait
+			case key := <-keys:
+				switch {
+				case key == keyQuit:
+					return nil
+				case key == keyPause:
+					paused = !paused
+					if !paused {
+						deadline = time.Now()
+						break wait
+					}
+					if seeker != nil {
+						seeker.pause()
+					}
+					if err := draw(img); err != nil {
+						return err
+					}
+				case key == keyStep:
+					paused = true
+					deadline = time.Now()
+					break wait
+				case key == keyStepBack && canSeek:
+					paused = true
+					seeker.seek(seeker.previousFrame())
+					deadline = time.Now()
+					break wait
+				case seekKeys[key] != 0 && canSeek:
+					seeker.seek(min(max(seeker.position()+seekKeys[key], 0), seeker.length()))
+					deadline = time.Now()
+					break wait
+				}
+			}
+		}
+	}
+}
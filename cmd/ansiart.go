@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"bytes"
+	"strconv"
+	"unicode/utf8"
+)
+
+// cp437 maps the IBM PC character set to Unicode, control characters
+// included: ANSI art uses ☺ and ► as glyphs, not as controls
+var cp437 = []rune("" +
+	"\x00☺☻♥♦♣♠•◘○◙♂♀♪♫☼►◄↕‼¶§▬↨↑↓→←∟↔▲▼" +
+	" !\"#$%&'()*+,-./0123456789:;<=>?" +
+	"@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_" +
+	"`abcdefghijklmnopqrstuvwxyz{|}~⌂" +
+	"ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜ¢£¥₧ƒ" +
+	"áíóúñÑªº¿⌐¬½¼¡«»░▒▓│┤╡╢╖╕╣║╗╝╜╛┐" +
+	"└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀" +
+	"αßΓπΣσµτΦΘΩδ∞φε∩≡±≥≤⌠⌡÷≈°∙·√ⁿ²■ ")
+
+// ansiArtWidth is the screen width ANSI art is drawn for, unless its SAUCE
+// record says otherwise
+const ansiArtWidth = 80
+
+// limits for the virtual screen, so a broken SAUCE record or a cursor
+// moving down forever can't make the grid huge. what's drawn past them is
+// dropped
+const (
+	ansiArtMaxWidth  = 1000
+	ansiArtMaxHeight = 10000
+)
+
+// rgbFlag marks a parsed color as 0xRRGGBB instead of a palette index,
+// until the grid's depth is known
+const rgbFlag = 1 << 24
+
+// ParseANSIArt reads an ANSI or ASCII art file (CP437 or UTF-8 text with
+// SGR colors and cursor movements, like .ans files) into a cell grid, the
+// way a terminal width columns wide would show it. width 0 takes the width
+// from the file's SAUCE record, or 80. the grid gets the color depth the
+// file needs: 16 colors for classic art, 256 or truecolor when it uses
+// those
+func ParseANSIArt(data []byte, width int) *CellGrid {
+	data, sauceWidth := stripSAUCE(data)
+	if width <= 0 {
+		width = sauceWidth
+	}
+	if width <= 0 {
+		width = ansiArtWidth
+	}
+	width = min(width, ansiArtMaxWidth)
+	if i := bytes.IndexByte(data, 0x1a); i >= 0 {
+		data = data[:i] // DOS end of file
+	}
+	// modern art is UTF-8, classic art is CP437, which is rarely valid UTF-8
+	// once it goes past ASCII
+	utf8Text := utf8.Valid(data)
+
+	p := ansiArtParser{width: width, fg: NoColor, bg: NoColor}
+	for i := 0; i < len(data); {
+		b := data[i]
+		switch {
+		case b == 0x1b && i+1 < len(data) && data[i+1] == '[':
+			end := i + 2
+			for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+				end++
+			}
+			if end == len(data) {
+				i = end // cut off in the middle of a sequence
+				continue
+			}
+			p.csi(data[i+2:end], data[end])
+			i = end + 1
+			continue
+		case b == 0x1b:
+		case b == '\r':
+			p.x = 0
+		case b == '\n':
+			p.x, p.y = 0, p.y+1
+		case b == '\t':
+			p.x = min((p.x/8+1)*8, width-1)
+		default:
+			r, size := rune(b), 1
+			if utf8Text && b >= 0x80 {
+				r, size = utf8.DecodeRune(data[i:])
+			} else if !utf8Text || b < 0x20 || b == 0x7f {
+				r = cp437[b]
+			}
+			p.put(r)
+			i += size
+			continue
+		}
+		i++
+	}
+	return p.grid()
+}
+
+// stripSAUCE takes the SAUCE metadata record off the end of data, returning
+// the width it gives for character art, 0 when there's none
+func stripSAUCE(data []byte) ([]byte, int) {
+	const recordSize = 128
+	if len(data) < recordSize || !bytes.HasPrefix(data[len(data)-recordSize:], []byte("SAUCE00")) {
+		return data, 0
+	}
+	record := data[len(data)-recordSize:]
+	width := 0
+	// character art (data type 1) keeps its width in TInfo1
+	if record[94] == 1 {
+		width = int(record[96]) | int(record[97])<<8
+	}
+	data = data[:len(data)-recordSize]
+	// a comment block can sit before the record
+	if comments := int(record[104]); comments > 0 {
+		size := 5 + comments*64
+		if len(data) >= size && bytes.HasPrefix(data[len(data)-size:], []byte("COMNT")) {
+			data = data[:len(data)-size]
+		}
+	}
+	return data, width
+}
+
+// ansiArtParser is a minimal virtual terminal: a cursor, the SGR state and
+// the cells written so far
+type ansiArtParser struct {
+	width     int
+	rows      [][]Cell
+	x, y      int
+	savedX    int
+	savedY    int
+	fg, bg    int
+	bold      bool
+	blink     bool // bright backgrounds in iCE color art
+	inverse   bool
+	extended  bool // 256 color codes were used
+	truecolor bool
+}
+
+func (p *ansiArtParser) row(y int) []Cell {
+	for len(p.rows) <= y {
+		row := make([]Cell, p.width)
+		for i := range row {
+			row[i] = Cell{Glyph: ' ', FG: NoColor, BG: NoColor}
+		}
+		p.rows = append(p.rows, row)
+	}
+	return p.rows[y]
+}
+
+// put writes r at the cursor with the current colors and moves on,
+// wrapping at the right edge like a terminal does
+func (p *ansiArtParser) put(r rune) {
+	if p.x >= p.width {
+		p.x, p.y = 0, p.y+1
+	}
+	if p.y >= ansiArtMaxHeight {
+		return
+	}
+	fg, bg := p.fg, p.bg
+	if p.bold && fg >= 0 && fg < 8 {
+		fg += 8
+	}
+	if p.blink && bg >= 0 && bg < 8 {
+		bg += 8
+	}
+	if p.inverse {
+		if fg == NoColor {
+			fg = 7
+		}
+		if bg == NoColor {
+			bg = 0
+		}
+		fg, bg = bg, fg
+	}
+	p.row(p.y)[p.x] = Cell{Glyph: r, FG: fg, BG: bg}
+	p.x++
+}
+
+// csi runs a control sequence: params up to the final byte
+func (p *ansiArtParser) csi(params []byte, final byte) {
+	if len(params) > 0 && params[0] >= 0x3c && params[0] <= 0x3f {
+		return // private sequences like ?7h don't draw anything
+	}
+	var args []int
+	for _, field := range bytes.Split(params, []byte{';'}) {
+		n, _ := strconv.Atoi(string(field))
+		args = append(args, n)
+	}
+	arg := func(i, fallback int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return fallback
+	}
+
+	switch final {
+	case 'm':
+		p.sgr(args)
+	case 'A':
+		p.y = max(p.y-arg(0, 1), 0)
+	case 'B':
+		p.y = min(p.y+arg(0, 1), ansiArtMaxHeight)
+	case 'C':
+		p.x = min(p.x+arg(0, 1), p.width-1)
+	case 'D':
+		p.x = max(min(p.x, p.width-1)-arg(0, 1), 0)
+	case 'H', 'f':
+		p.y = min(arg(0, 1)-1, ansiArtMaxHeight)
+		p.x = min(arg(1, 1)-1, p.width-1)
+	case 'G':
+		p.x = min(arg(0, 1)-1, p.width-1)
+	case 's':
+		p.savedX, p.savedY = p.x, p.y
+	case 'u':
+		p.x, p.y = p.savedX, p.savedY
+	case 'J':
+		if arg(0, 0) == 2 {
+			p.rows, p.x, p.y = nil, 0, 0
+		}
+	case 'K':
+		if p.y < len(p.rows) {
+			for x := min(p.x, p.width); x < p.width; x++ {
+				p.rows[p.y][x] = Cell{Glyph: ' ', FG: NoColor, BG: p.bg}
+			}
+		}
+	}
+}
+
+func (p *ansiArtParser) sgr(args []int) {
+	for i := 0; i < len(args); i++ {
+		switch n := args[i]; {
+		case n == 0:
+			p.fg, p.bg, p.bold, p.blink, p.inverse = NoColor, NoColor, false, false, false
+		case n == 1:
+			p.bold = true
+		case n == 5 || n == 6:
+			p.blink = true
+		case n == 7:
+			p.inverse = true
+		case n == 22:
+			p.bold = false
+		case n == 25:
+			p.blink = false
+		case n == 27:
+			p.inverse = false
+		case n >= 30 && n <= 37:
+			p.fg = n - 30
+		case n == 39:
+			p.fg = NoColor
+		case n >= 40 && n <= 47:
+			p.bg = n - 40
+		case n == 49:
+			p.bg = NoColor
+		case n >= 90 && n <= 97:
+			p.fg = n - 90 + 8
+		case n >= 100 && n <= 107:
+			p.bg = n - 100 + 8
+		case (n == 38 || n == 48) && i+1 < len(args):
+			code := NoColor
+			switch {
+			case args[i+1] == 5 && i+2 < len(args):
+				code = args[i+2] & 0xff
+				p.extended = p.extended || code > 15
+				i += 2
+			case args[i+1] == 2 && i+4 < len(args):
+				code = rgbFlag | (args[i+2]&0xff)<<16 | (args[i+3]&0xff)<<8 | args[i+4]&0xff
+				p.truecolor = true
+				i += 4
+			default:
+				i = len(args) // unknown, the rest can't be trusted
+				continue
+			}
+			if n == 38 {
+				p.fg = code
+			} else {
+				p.bg = code
+			}
+		}
+	}
+}
+
+// grid turns the rows into a CellGrid at the depth the colors need
+func (p *ansiArtParser) grid() *CellGrid {
+	depth := Color16
+	switch {
+	case p.truecolor:
+		depth = TrueColor
+	case p.extended:
+		depth = Color256
+	}
+	g := NewCellGrid(p.width, max(len(p.rows), 1), depth)
+	convert := func(code int) int {
+		switch {
+		case code == NoColor:
+			return NoColor
+		case code&rgbFlag != 0:
+			return code &^ rgbFlag
+		case depth == TrueColor:
+			c := xtermRGB(code)
+			return int(c.R)<<16 | int(c.G)<<8 | int(c.B)
+		}
+		return code
+	}
+	for y, row := range p.rows {
+		for x, c := range row {
+			g.Set(x, y, Cell{Glyph: c.Glyph, FG: convert(c.FG), BG: convert(c.BG)})
+		}
+	}
+	return g
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// archiveSeparator splits an archive from the entry inside it, like
+// photos.zip!/2024/img01.jpg
+const archiveSeparator = "!/"
+
+// archiveKind is zip, tar, tar.gz or rar by path's extension, comic book
+// archives (.cbz, .cbt, .cbr) and EPUB books included, empty for anything
+// that isn't an archive
+func archiveKind(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".cbz"), strings.HasSuffix(lower, ".epub"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".cbt"):
+		return "tar"
+	case strings.HasSuffix(lower, ".rar"), strings.HasSuffix(lower, ".cbr"):
+		return "rar"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// splitArchivePath splits source at the first "!/" that follows an archive
+func splitArchivePath(source string) (archive, entry string, ok bool) {
+	for i := 0; ; {
+		j := strings.Index(source[i:], archiveSeparator)
+		if j < 0 {
+			return "", "", false
+		}
+		archive = source[:i+j]
+		if archiveKind(archive) != "" {
+			return archive, source[i+j+len(archiveSeparator):], true
+		}
+		i += j + len(archiveSeparator)
+	}
+}
+
+// imageExtensions are what gallery picks out of directories and archives
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+
+func isImageName(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range imageExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveEntry is a file in an archive as walkArchive comes by it. Open
+// only works until the walk moves on
+type archiveEntry struct {
+	Name string
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// errStopWalk ends walkArchive early without an error
+var errStopWalk = errors.New("stop walking")
+
+// walkArchive calls fn for every regular file in the archive, in the order
+// they're stored. zip entries are read on Open only, tar and rar ones
+// stream by and are skipped over unless opened
+func walkArchive(archive string, fn func(archiveEntry) error) error {
+	if archiveKind(archive) == "zip" {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return fmt.Errorf("couldn't open archive: %w", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			if err := fn(archiveEntry{Name: entryName(f.Name), Size: int64(f.UncompressedSize64), Open: f.Open}); err != nil {
+				return ignoreStop(err)
+			}
+		}
+		return nil
+	}
+	if archiveKind(archive) == "rar" {
+		rr, err := rardecode.OpenReader(archive)
+		if err != nil {
+			return fmt.Errorf("couldn't open archive: %w", err)
+		}
+		defer rr.Close()
+		for {
+			header, err := rr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("couldn't read archive: %w", err)
+			}
+			if header.IsDir {
+				continue
+			}
+			open := func() (io.ReadCloser, error) { return io.NopCloser(rr), nil }
+			if err := fn(archiveEntry{Name: entryName(header.Name), Size: header.UnPackedSize, Open: open}); err != nil {
+				return ignoreStop(err)
+			}
+		}
+	}
+	tr, closer, err := openTar(archive)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err := fn(archiveEntry{Name: entryName(header.Name), Size: header.Size, Open: open}); err != nil {
+			return ignoreStop(err)
+		}
+	}
+}
+
+// openTar opens a tar or tar.gz archive for reading front to back
+func openTar(archive string) (*tar.Reader, io.Closer, error) {
+	kind := archiveKind(archive)
+	if kind != "tar" && kind != "tar.gz" {
+		return nil, nil, fmt.Errorf("%s isn't a zip, tar, tar.gz or rar archive", archive)
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't open archive: %w", err)
+	}
+	if kind == "tar" {
+		return tar.NewReader(file), file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("couldn't open archive: %w", err)
+	}
+	return tar.NewReader(gz), closers{gz, file}, nil
+}
+
+// entryName is an archive entry's name without the ./ and .. tar and zip
+// tools leave in
+func entryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func ignoreStop(err error) error {
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
+
+// openArchiveEntry opens the entry named name in archive, reading a tar or
+// rar only as far as the entry
+func openArchiveEntry(archive, name string) (io.ReadCloser, error) {
+	name = entryName(name)
+	if archiveKind(archive) == "zip" {
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open archive: %w", err)
+		}
+		for _, f := range zr.File {
+			if entryName(f.Name) == name {
+				rc, err := f.Open()
+				if err != nil {
+					zr.Close()
+					return nil, err
+				}
+				return struct {
+					io.Reader
+					io.Closer
+				}{rc, closers{rc, zr}}, nil
+			}
+		}
+		zr.Close()
+		return nil, fmt.Errorf("%s has no %s", archive, name)
+	}
+	if archiveKind(archive) == "rar" {
+		rr, err := rardecode.OpenReader(archive)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open archive: %w", err)
+		}
+		for {
+			header, err := rr.Next()
+			if err != nil {
+				rr.Close()
+				if err == io.EOF {
+					return nil, fmt.Errorf("%s has no %s", archive, name)
+				}
+				return nil, fmt.Errorf("couldn't read archive: %w", err)
+			}
+			if !header.IsDir && entryName(header.Name) == name {
+				return rr, nil
+			}
+		}
+	}
+
+	tr, closer, err := openTar(archive)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			closer.Close()
+			return nil, fmt.Errorf("%s has no %s", archive, name)
+		}
+		if err != nil {
+			closer.Close()
+			return nil, fmt.Errorf("couldn't read archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeReg && entryName(header.Name) == name {
+			return struct {
+				io.Reader
+				io.Closer
+			}{tr, closer}, nil
+		}
+	}
+}
+
+// closers closes all of them, returning the first error
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var first error
+	for _, closer := range c {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// walkImages calls fn for every image in dir, a directory or an archive,
+// sorted by name for directories and as stored for archives
+func walkImages(dir string, fn func(archiveEntry) error) error {
+	if archiveKind(dir) != "" {
+		return walkArchive(dir, func(entry archiveEntry) error {
+			if !isImageName(entry.Name) {
+				return nil
+			}
+			return fn(entry)
+		})
+	}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isImageName(p) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(dir, p)
+		return fn(archiveEntry{Name: filepath.ToSlash(name), Size: info.Size(), Open: func() (io.ReadCloser, error) { return os.Open(p) }})
+	})
+	return ignoreStop(err)
+}
+
+// openInDir opens the file name, as walkImages named it, in dir, a
+// directory or an archive
+func openInDir(dir, name string) (io.ReadCloser, error) {
+	if archiveKind(dir) != "" {
+		return openArchiveEntry(dir, name)
+	}
+	return os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// TestArchiveEntries checks entries open by their archive!/entry path from
+// zip and tar.gz archives, whatever ./ prefix the archive stored
+func TestArchiveEntries(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"./photos/a.png": "first", "photos/b.jpg": "second", "notes.txt": "not an image"}
+
+	zipPath := filepath.Join(dir, "photos.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		io.WriteString(w, content)
+	}
+	zw.Close()
+	f.Close()
+
+	tarPath := filepath.Join(dir, "photos.tar.gz")
+	if f, err = os.Create(tarPath); err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		io.WriteString(tw, content)
+	}
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	for _, archive := range []string{zipPath, tarPath} {
+		for _, source := range []string{archive + "!/photos/a.png", archive + "!/photos/b.jpg"} {
+			a, entry, ok := splitArchivePath(source)
+			if !ok || a != archive {
+				t.Fatalf("%s split into %q, %q", source, a, entry)
+			}
+			rc, err := openArchiveEntry(a, entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(got) != files[entry] && string(got) != files["./"+entry] {
+				t.Errorf("%s read %q", source, got)
+			}
+		}
+		images := 0
+		walkImages(archive, func(archiveEntry) error { images++; return nil })
+		if images != 2 {
+			t.Errorf("%s has %d images, want 2", archive, images)
+		}
+	}
+}
+
+// TestNaturalLess checks comic pages sort with their numbers by value
+func TestNaturalLess(t *testing.T) {
+	pages := []string{"Page10.png", "page2.png", "cover.jpg", "page01.png", "Page1b.png"}
+	sort.SliceStable(pages, func(i, j int) bool { return naturalLess(pages[i], pages[j]) })
+	want := []string{"cover.jpg", "page01.png", "Page1b.png", "page2.png", "Page10.png"}
+	if !slices.Equal(pages, want) {
+		t.Errorf("sorted to %q, want %q", pages, want)
+	}
+}
+
+// TestEPUBCover checks the cover is found by EPUB 3's cover-image property
+// with the href relative to the package document
+func TestEPUBCover(t *testing.T) {
+	book := filepath.Join(t.TempDir(), "book.epub")
+	f, err := os.Create(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"META-INF/container.xml": `<container xmlns="urn:oasis:names:tc:opendocument:xmlns:container"><rootfiles><rootfile full-path="OPS/book.opf"/></rootfiles></container>`,
+		"OPS/book.opf": `<package xmlns="http://www.idpf.org/2007/opf" version="3.0"><manifest>
+			<item id="map" href="img/map.png" media-type="image/png"/>
+			<item id="front" href="img/front%20page.jpg" media-type="image/jpeg" properties="cover-image"/>
+		</manifest></package>`,
+	} {
+		w, _ := zw.Create(name)
+		io.WriteString(w, content)
+	}
+	zw.Close()
+	f.Close()
+
+	cover, err := epubCover(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cover != "OPS/img/front page.jpg" {
+		t.Errorf("cover is %q", cover)
+	}
+}
+
+func TestGalleryOrder(t *testing.T) {
+	features := []galleryFeatures{
+		{Hue: -1, Brightness: 200},
+		{Hue: 240, Brightness: 60},
+		{Hue: 0, Brightness: 90},
+		{Hue: -1, Brightness: 20},
+		{Hue: 120, Brightness: 150},
+	}
+	features[0].Layout[0], features[1].Layout[0], features[2].Layout[0], features[3].Layout[0], features[4].Layout[0] = 0, 100, 30, 90, 10
+	for by, want := range map[string][]int{
+		"hue":        {2, 4, 1, 3, 0},
+		"brightness": {3, 1, 2, 4, 0},
+		"similarity": {0, 4, 2, 3, 1},
+	} {
+		if got := galleryOrder(features, by); !slices.Equal(got, want) {
+			t.Errorf("galleryOrder by %s = %v, want %v", by, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// audio goes from ffmpeg to the player as raw 16-bit stereo PCM
+const (
+	audioRate       = 48000
+	audioFrameBytes = 2 * 2 // two channels of 16 bits
+	audioLatency    = 100 * time.Millisecond
+	maxAudioDrift   = 80 * time.Millisecond
+	maxAudioCatchUp = time.Second
+)
+
+// audioPlayers are tried in order. afplay can't read from a pipe, so macOS
+// gets ffplay
+var audioPlayers = []struct {
+	name string
+	args []string
+}{
+	{"paplay", []string{"--raw", "--format=s16le", "--rate=" + strconv.Itoa(audioRate), "--channels=2", "--latency-msec=" + strconv.Itoa(int(audioLatency/time.Millisecond))}},
+	{"pw-play", []string{"--format=s16", "--rate=" + strconv.Itoa(audioRate), "--channels=2", "-"}},
+	{"aplay", []string{"-q", "-t", "raw", "-f", "S16_LE", "-r", strconv.Itoa(audioRate), "-c", "2"}},
+	{"ffplay", []string{"-nodisp", "-autoexit", "-loglevel", "error", "-fflags", "nobuffer", "-f", "s16le", "-ar", strconv.Itoa(audioRate), "-ch_layout", "stereo", "-i", "-"}},
+}
+
+// findAudioPlayer returns the first of audioPlayers that's installed
+func findAudioPlayer() (path string, args []string, err error) {
+	for _, p := range audioPlayers {
+		if path, err := exec.LookPath(p.name); err == nil {
+			return path, p.args, nil
+		}
+	}
+	return "", nil, errors.New("no audio player found (install paplay, pw-play, aplay or ffplay)")
+}
+
+// audioPassthrough decodes a video's audio track with a second ffmpeg and
+// pipes it to the system's player, counting the bytes so the frames can
+// follow the audio
+type audioPassthrough struct {
+	decoder, player *exec.Cmd
+	written         atomic.Int64
+	done            chan struct{}
+}
+
+// startAudio starts playing source's audio. a video without an audio
+// track just plays silently
+func startAudio(ctx context.Context, source string, seek, duration time.Duration) (*audioPassthrough, error) {
+	path, playerArgs, err := findAudioPlayer()
+	if err != nil {
+		return nil, err
+	}
+	player := exec.CommandContext(ctx, path, playerArgs...)
+
+	args := append(ffmpegInput(source, seek, duration), "-vn", "-f", "s16le", "-ar", strconv.Itoa(audioRate), "-ac", "2", "-")
+	decoder, err := ffmpegCommand(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	pcm, err := decoder.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	sink, err := player.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := player.Start(); err != nil {
+		return nil, err
+	}
+	if err := decoder.Start(); err != nil {
+		player.Process.Kill()
+		player.Wait()
+		return nil, err
+	}
+
+	a := &audioPassthrough{decoder: decoder, player: player, done: make(chan struct{})}
+	go func() {
+		defer close(a.done)
+		io.Copy(countingWriter{sink, &a.written}, pcm)
+		sink.Close()
+		decoder.Wait()
+		player.Wait()
+	}()
+	return a, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// position is how far the audio that's audible right now is into the
+// track: what went to the player minus what it still buffers
+func (a *audioPassthrough) position() time.Duration {
+	frames := a.written.Load() / audioFrameBytes
+	return time.Duration(frames)*time.Second/audioRate - audioLatency
+}
+
+// playing tells whether there is audio to follow. it's false before the
+// first bytes arrive and once the track ran out
+func (a *audioPassthrough) playing() bool {
+	select {
+	case <-a.done:
+		return false
+	default:
+		return a.written.Load() > 0
+	}
+}
+
+// wait blocks until the decoder and player have exited
+func (a *audioPassthrough) wait() {
+	<-a.done
+}
+
+// audioSyncedSource keeps the frames in step with the audio. the two come
+// from separate ffmpeg runs whose clocks slowly drift apart, and the
+// player holds back audioLatency of sound: frames ahead of the audio wait
+// for it, frames behind it are dropped
+type audioSyncedSource struct {
+	frameSource
+	ctx     context.Context
+	audio   *audioPassthrough
+	started time.Time
+}
+
+func (s *audioSyncedSource) next() (image.Image, time.Duration, error) {
+	for {
+		img, delay, err := s.frameSource.next()
+		if err != nil {
+			return img, delay, err
+		}
+		if s.started.IsZero() {
+			s.started = time.Now()
+		}
+		if !s.audio.playing() {
+			return img, delay, nil // nothing to follow, yet or anymore
+		}
+
+		drift := time.Since(s.started) - s.audio.position()
+		switch {
+		case drift > maxAudioDrift:
+			select {
+			case <-time.After(min(drift, maxAudioCatchUp)):
+			case <-s.ctx.Done():
+				return nil, 0, io.EOF
+			}
+		case drift < -maxAudioDrift:
+			continue // late, the next frame is closer to the audio
+		}
+		return img, delay, nil
+	}
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RenderBackend turns an image into something a terminal (or other consumer)
+// can display. third parties can add their own either by calling
+// RegisterBackend from an init function in a custom build, or by dropping a
+// termuwu-backend-<name> executable on PATH.
+type RenderBackend interface {
+	Name() string
+	Description() string
+	Render(img image.Image, r *ImageRenderer) (string, error)
+}
+
+// execBackendPrefix is the name prefix of executable backends on PATH
+const execBackendPrefix = "termuwu-backend-"
+
+var backendRegistry = map[string]RenderBackend{
+	"ansi":   ansiBackend{},
+	"html":   htmlBackend{},
+	"kitty":  kittyBackend{},
+	"iterm2": iterm2Backend{},
+	"sixel":  sixelBackend{},
+}
+
+// RegisterBackend makes a backend available to --protocol. registering a
+// name twice replaces the earlier backend
+func RegisterBackend(b RenderBackend) {
+	backendRegistry[b.Name()] = b
+}
+
+// LookupBackend finds a compiled-in backend first, then an executable one
+func LookupBackend(name string) (RenderBackend, bool) {
+	if b, ok := backendRegistry[name]; ok {
+		return b, true
+	}
+	path, err := exec.LookPath(execBackendPrefix + name)
+	if err != nil {
+		return nil, false
+	}
+	return execBackend{name: name, path: path}, true
+}
+
+// Backends lists every available backend sorted by name
+func Backends() []RenderBackend {
+	found := make(map[string]RenderBackend, len(backendRegistry))
+	for name, b := range backendRegistry {
+		found[name] = b
+	}
+	for _, b := range discoverExecBackends() {
+		if _, taken := found[b.Name()]; !taken {
+			found[b.Name()] = b
+		}
+	}
+
+	list := make([]RenderBackend, 0, len(found))
+	for _, b := range found {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+func compiledBackendNames() []string {
+	names := make([]string, 0, len(backendRegistry))
+	for name := range backendRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func discoverExecBackends() []RenderBackend {
+	var found []RenderBackend
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		matches, _ := filepath.Glob(filepath.Join(dir, execBackendPrefix+"*"))
+		for _, path := range matches {
+			name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), execBackendPrefix), ".exe")
+			if seen[name] {
+				continue // earlier PATH entries win, like the shell
+			}
+			if info, err := os.Stat(path); err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+				continue
+			}
+			seen[name] = true
+			found = append(found, execBackend{name: name, path: path})
+		}
+	}
+	return found
+}
+
+// protocolCells picks the cell area an image protocol should cover. a cell
+// is AspectRatio times as wide as it is tall
+func protocolCells(img image.Image, r *ImageRenderer) (cols, rows int) {
+	bounds := img.Bounds()
+	imgWidth, imgHeight := float64(bounds.Dx()), float64(bounds.Dy())
+	scale := min(float64(r.MaxWidth)/imgWidth, float64(r.MaxHeight)/(imgHeight*r.AspectRatio))
+	return max(int(imgWidth*scale), 1), max(int(imgHeight*scale*r.AspectRatio), 1)
+}
+
+type ansiBackend struct{}
+
+func (ansiBackend) Name() string { return "ansi" }
+func (ansiBackend) Description() string {
+	return "colored text cells (blocks, half-blocks, braille), works everywhere"
+}
+func (ansiBackend) Render(img image.Image, r *ImageRenderer) (string, error) {
+	return r.TryRenderImage(img)
+}
+
+type htmlBackend struct{}
+
+func (htmlBackend) Name() string        { return "html" }
+func (htmlBackend) Description() string { return "the cell grid as an HTML <pre> block" }
+func (htmlBackend) Render(img image.Image, r *ImageRenderer) (string, error) {
+	return r.Rasterize(img).HTML(), nil
+}
+
+// execBackend runs an external program: the image arrives as PNG on stdin,
+// the target size as --cols/--rows, and whatever it prints is the output
+type execBackend struct {
+	name, path string
+}
+
+func (b execBackend) Name() string        { return b.name }
+func (b execBackend) Description() string { return "external backend (" + b.path + ")" }
+func (b execBackend) Render(img image.Image, r *ImageRenderer) (string, error) {
+	img = r.filtered(img)
+	var input bytes.Buffer
+	if err := png.Encode(&input, img); err != nil {
+		return "", fmt.Errorf("couldn't encode image for %s: %w", b.name, err)
+	}
+
+	cols, rows := protocolCells(img, r)
+	cmd := exec.Command(b.path, "--cols", strconv.Itoa(cols), "--rows", strconv.Itoa(rows))
+	cmd.Stdin = &input
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"TERMUWU_COLS="+strconv.Itoa(cols),
+		"TERMUWU_ROWS="+strconv.Itoa(rows),
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("backend %s failed: %w", b.name, err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// BrailleColorMode decides how braille cells use their fg and bg colors
+type BrailleColorMode int
+
+const (
+	// BrailleColorFG colors the dots with the average of the cell, the
+	// background stays the terminal default
+	BrailleColorFG BrailleColorMode = iota
+	// BrailleColorFGBG keeps the luminance dot pattern but colors the dots
+	// with the average of the lit samples and the background with the rest
+	BrailleColorFGBG
+	// BrailleColorCluster splits the 8 samples into two color clusters, the
+	// dots mark one cluster and fg/bg are the cluster colors
+	BrailleColorCluster
+)
+
+// BrailleStyle decides which samples of a braille cell get a dot
+type BrailleStyle int
+
+const (
+	// BrailleThreshold lights the dots brighter than mid gray
+	BrailleThreshold BrailleStyle = iota
+	// BrailleEdges lights the dots on edges found by a Sobel filter, which
+	// turns photos into line art
+	BrailleEdges
+)
+
+// edgeThreshold is the gradient magnitude (in luminance steps) a sample
+// needs to count as an edge
+const edgeThreshold = 48
+
+func parseBrailleStyle(value string) (BrailleStyle, error) {
+	switch strings.ToLower(value) {
+	case "threshold", "":
+		return BrailleThreshold, nil
+	case "edges":
+		return BrailleEdges, nil
+	}
+	return BrailleThreshold, fmt.Errorf("unknown braille style %q (use threshold or edges)", value)
+}
+
+func parseBrailleColorMode(value string) (BrailleColorMode, error) {
+	switch strings.ToLower(value) {
+	case "fg", "":
+		return BrailleColorFG, nil
+	case "fgbg":
+		return BrailleColorFGBG, nil
+	case "cluster", "2color":
+		return BrailleColorCluster, nil
+	}
+	return BrailleColorFG, fmt.Errorf("unknown braille color mode %q (use fg, fgbg or cluster)", value)
+}
+
+func (r *ImageRenderer) renderBraille(grid *CellGrid, img *image.RGBA, width, height int) {
+	brailleWidth := (width + 1) / 2
+	brailleHeight := (height + 3) / 4
+	if brailleWidth <= 0 {
+		brailleWidth = 1
+	}
+	if brailleHeight <= 0 {
+		brailleHeight = 1
+	}
+
+	var edges []bool
+	if r.BrailleStyle == BrailleEdges {
+		edges = sobelEdges(img, width, height)
+	}
+
+	var samples [8]Color
+	var masks [8]uint8
+	var lit [8]bool
+	for by := 0; by < brailleHeight; by++ {
+		for bx := 0; bx < brailleWidth; bx++ {
+			count := 0
+			for py := 0; py < 4; py++ {
+				for px := 0; px < 2; px++ {
+					imgSampleX := bx*2 + px
+					imgSampleY := by*4 + py
+
+					if imgSampleX < width && imgSampleY < height {
+						samples[count] = sampleAt(img, imgSampleX, imgSampleY)
+						masks[count] = brailleDotMask(px, py)
+						if edges != nil {
+							lit[count] = edges[imgSampleY*width+imgSampleX]
+						} else {
+							lit[count] = luminance(samples[count]) > 128 // 128 is a common mid-point threshold
+						}
+						count++
+					}
+				}
+			}
+			if count == 0 {
+				continue
+			}
+			grid.Set(bx, by, r.brailleCell(samples[:count], masks[:count], lit[:count]))
+		}
+	}
+}
+
+func (r *ImageRenderer) brailleCell(samples []Color, masks []uint8, lit []bool) Cell {
+	switch {
+	case r.BrailleColor == BrailleColorCluster && r.BrailleStyle == BrailleThreshold:
+		return r.brailleClusterCell(samples, masks)
+	case r.BrailleColor != BrailleColorFG:
+		// clustering picks its own dots, with edges the split is all we can do
+		return r.brailleSplitCell(samples, masks, lit)
+	}
+
+	var pattern uint8
+	for i := range samples {
+		if lit[i] {
+			pattern |= masks[i]
+		}
+	}
+	avg := averageColor(samples)
+	brailleChar := 0x2800 + rune(pattern) // braille unicode block starts at U+2800
+	return Cell{Glyph: brailleChar, FG: r.colorCode(avg.R, avg.G, avg.B), BG: NoColor}
+}
+
+func (r *ImageRenderer) brailleSplitCell(samples []Color, masks []uint8, lit []bool) Cell {
+	var pattern uint8
+	var on, off []Color
+	for i, c := range samples {
+		if lit[i] {
+			pattern |= masks[i]
+			on = append(on, c)
+		} else {
+			off = append(off, c)
+		}
+	}
+	return r.twoColorCell(pattern, on, off)
+}
+
+// brailleClusterCell runs a tiny 2-means over the samples, seeded with the
+// darkest and brightest one. the brighter cluster becomes the dots
+func (r *ImageRenderer) brailleClusterCell(samples []Color, masks []uint8) Cell {
+	dark, bright := samples[0], samples[0]
+	for _, c := range samples[1:] {
+		if luminance(c) < luminance(dark) {
+			dark = c
+		}
+		if luminance(c) > luminance(bright) {
+			bright = c
+		}
+	}
+
+	var pattern uint8
+	var lit, unlit []Color
+	rounds := r.SearchDepth
+	if rounds <= 0 {
+		rounds = 3
+	}
+	for iteration := 0; iteration < rounds; iteration++ {
+		pattern, lit, unlit = 0, lit[:0], unlit[:0]
+		for i, c := range samples {
+			if rgbDistance(c, bright) < rgbDistance(c, dark) {
+				pattern |= masks[i]
+				lit = append(lit, c)
+			} else {
+				unlit = append(unlit, c)
+			}
+		}
+		if len(lit) == 0 || len(unlit) == 0 {
+			break
+		}
+		bright, dark = averageColor(lit), averageColor(unlit)
+	}
+	return r.twoColorCell(pattern, lit, unlit)
+}
+
+// twoColorCell colors the dots with the lit samples and the background with
+// the unlit ones, collapsing to a plain colored cell when both quantize alike
+func (r *ImageRenderer) twoColorCell(pattern uint8, lit, unlit []Color) Cell {
+	if len(lit) == 0 {
+		avg := averageColor(unlit)
+		return Cell{Glyph: ' ', FG: NoColor, BG: r.colorCode(avg.R, avg.G, avg.B)}
+	}
+	fgAvg := averageColor(lit)
+	fg := r.colorCode(fgAvg.R, fgAvg.G, fgAvg.B)
+	if len(unlit) == 0 {
+		return Cell{Glyph: ' ', FG: NoColor, BG: fg}
+	}
+	bgAvg := averageColor(unlit)
+	bg := r.colorCode(bgAvg.R, bgAvg.G, bgAvg.B)
+	if fg == bg {
+		return Cell{Glyph: ' ', FG: NoColor, BG: bg}
+	}
+	return Cell{Glyph: 0x2800 + rune(pattern), FG: fg, BG: bg}
+}
+
+// sobelEdges runs a 3x3 Sobel filter over the luminance of img and marks
+// the samples whose gradient is strong enough to be an edge
+func sobelEdges(img *image.RGBA, width, height int) []bool {
+	lum := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			lum[y*width+x] = luminance(sampleAt(img, x, y))
+		}
+	}
+	at := func(x, y int) float64 {
+		x = max(0, min(x, width-1))
+		y = max(0, min(y, height-1))
+		return lum[y*width+x]
+	}
+
+	edges := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) -
+				at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) -
+				at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			// the kernels weigh 4 samples per side, scale back to luminance steps
+			edges[y*width+x] = math.Hypot(gx, gy)/4 > edgeThreshold
+		}
+	}
+	return edges
+}
+
+func luminance(c Color) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+func averageColor(samples []Color) Color {
+	if len(samples) == 0 {
+		return Color{}
+	}
+	var r, g, b uint32
+	for _, c := range samples {
+		r += uint32(c.R)
+		g += uint32(c.G)
+		b += uint32(c.B)
+	}
+	n := uint32(len(samples))
+	return Color{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n)}
+}
+
+func rgbDistance(a, b Color) int {
+	dr := int(a.R) - int(b.R)
+	dg := int(a.G) - int(b.G)
+	db := int(a.B) - int(b.B)
+	return dr*dr + dg*dg + db*db
+}
+
+func brailleDotMask(x, y int) uint8 {
+	// braille dot pattern:
+	// 1 (0x01) 4 (0x08)
+	// 2 (0x02) 5 (0x10)
+	// 3 (0x04) 6 (0x20)
+	// 7 (0x40) 8 (0x80)
+	dotMap := [2][4]uint8{
+		{0x01, 0x02, 0x04, 0x40}, // column 0
+		{0x08, 0x10, 0x20, 0x80}, // column 1
+	}
+	if x >= 0 && x < 2 && y >= 0 && y < 4 {
+		return dotMap[x][y]
+	}
+	return 0
+}
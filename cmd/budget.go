@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+)
+
+// BudgetResult describes the settings RenderWithinBudget settled on
+type BudgetResult struct {
+	Cols, Rows int
+	ColorDepth ColorDepth
+	Dither     bool
+	Bytes      int64
+	Fits       bool
+}
+
+func (b BudgetResult) String() string {
+	dither := "dither"
+	if !b.Dither {
+		dither = "no dither"
+	}
+	return fmt.Sprintf("%dx%d cells, %s, %s (%s)", b.Cols, b.Rows, b.ColorDepth, dither, formatByteSize(b.Bytes))
+}
+
+// RenderWithinBudget renders img while trying to keep the output under budget
+// bytes. it gives up dithering first, then trades resolution and color depth,
+// and finally keeps shrinking at 16 colors until the frame fits.
+func (r *ImageRenderer) RenderWithinBudget(img image.Image, budget int64) (string, BudgetResult) {
+	candidate := *r
+	bounds := img.Bounds()
+
+	attempt := func() (string, BudgetResult) {
+		output := candidate.RenderImage(img)
+		cols, rows := candidate.cellSize(candidate.sampleSize(bounds.Dx(), bounds.Dy()))
+		result := BudgetResult{
+			Cols:       cols,
+			Rows:       rows,
+			ColorDepth: candidate.ColorDepth,
+			Dither:     candidate.UseDither,
+			Bytes:      int64(len(output)),
+		}
+		result.Fits = result.Bytes <= budget
+		return output, result
+	}
+
+	output, result := attempt()
+	if result.Fits {
+		return output, result
+	}
+
+	candidate.UseDither = false // dither noise breaks up color runs
+	output, result = attempt()
+	if result.Fits {
+		return output, result
+	}
+
+	baseWidth, baseHeight := r.MaxWidth, r.MaxHeight
+	for _, depth := range budgetDepths(r.ColorDepth) {
+		candidate.ColorDepth = depth
+		for scale := 1.0; ; scale *= 0.85 {
+			candidate.MaxWidth = max(int(float64(baseWidth)*scale), 1)
+			candidate.MaxHeight = max(int(float64(baseHeight)*scale), 1)
+			output, result = attempt()
+			if result.Fits {
+				return output, result
+			}
+
+			tooSmall := candidate.MaxWidth == 1 && candidate.MaxHeight == 1
+			// only the last depth keeps shrinking past half size
+			if tooSmall || (scale < 0.5 && depth != Color16) {
+				break
+			}
+		}
+	}
+	return output, result
+}
+
+// budgetDepths lists the color depths worth trying, richest first
+func budgetDepths(start ColorDepth) []ColorDepth {
+	switch start {
+	case TrueColor:
+		return []ColorDepth{TrueColor, Color256, Color16}
+	case Color16:
+		return []ColorDepth{Color16}
+	default:
+		return []ColorDepth{Color256, Color16}
+	}
+}
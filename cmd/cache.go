@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// caches are the directories termuwu keeps in its user cache directory
+var caches = []struct {
+	name        string
+	description string
+}{
+	{"renders", "rendered output of show"},
+	{"pyramids", "smaller copies made by prepare"},
+	{"downloads", "unfinished downloads to resume"},
+	{"tiles", "map tiles for --map"},
+}
+
+// cacheNames lists the caches for error messages and completion
+func cacheNames() []string {
+	var names []string
+	for _, c := range caches {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// termuwuCacheDir is termuwu's directory in the user cache directory
+func termuwuCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "termuwu"), nil
+}
+
+// cacheUsage counts the files under dir and the bytes they take
+func cacheUsage(dir string) (files int, size int64) {
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				files++
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return files, size
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Show how much space termuwu's caches take and clear them",
+	Long: `termuwu keeps rendered output, the copies prepare makes, unfinished
+downloads and map tiles in a termuwu directory in your user cache directory
+(~/.cache/termuwu on Linux):
+
+  termuwu cache info
+  termuwu cache clear renders
+  termuwu cache prune
+
+Everything in there is made again when it's needed.`,
+}
+
+var cacheInfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show where the caches are and how big they are",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		nameColor := themeColor("name", color.FgMagenta, color.Bold).SprintFunc()
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		dir, err := termuwuCacheDir()
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ No cache directory:"), err)
+			return
+		}
+		fmt.Fprintf(statusOut, "🗄️  %s %s\n", infoColor("Cache directory:"), dir)
+		var total int64
+		for _, c := range caches {
+			files, size := cacheUsage(filepath.Join(dir, c.name))
+			total += size
+			fmt.Printf("   %s %9s  %s  %s\n", nameColor(fmt.Sprintf("%-10s", c.name)), formatByteSize(size),
+				fmt.Sprintf(tr("%5d files"), files), dimColor(tr(c.description)))
+		}
+		fmt.Printf("   %s %9s\n", nameColor(fmt.Sprintf("%-10s", tr("total"))), formatByteSize(total))
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear [cache...]",
+	Short: "Remove the given caches, or all of them",
+	Long: `Remove everything in the given caches: renders, pyramids, downloads or
+tiles, or all of them without arguments.`,
+	ValidArgs: cacheNames(),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+
+		for _, name := range args {
+			if !slices.Contains(cacheNames(), name) {
+				fmt.Fprintf(statusOut, "%s %q (use %s)\n", errorColor("❌ Unknown cache:"), name, strings.Join(cacheNames(), ", "))
+				return
+			}
+		}
+		if len(args) == 0 {
+			args = cacheNames()
+		}
+		dir, err := termuwuCacheDir()
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ No cache directory:"), err)
+			return
+		}
+		var freed int64
+		for _, name := range args {
+			_, size := cacheUsage(filepath.Join(dir, name))
+			if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error clearing cache:"), err)
+				return
+			}
+			freed += size
+			fmt.Fprintf(statusOut, "🧹 %s %s\n", tr("Cleared"), name)
+		}
+		fmt.Fprintf(statusOut, "✅ %s %s\n", successColor("Freed"), formatByteSize(freed))
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove copies of images that changed or are gone, and expired map tiles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		dir, err := termuwuCacheDir()
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ No cache directory:"), err)
+			return
+		}
+		var freed int64
+		remove := func(path string) {
+			_, size := cacheUsage(path)
+			if os.RemoveAll(path) == nil {
+				freed += size
+			}
+		}
+
+		pyramids, _ := os.ReadDir(filepath.Join(dir, "pyramids"))
+		for _, entry := range pyramids {
+			path := filepath.Join(dir, "pyramids", entry.Name())
+			p, err := readPyramid(path)
+			switch {
+			case err != nil:
+				remove(path) // unfinished
+			case !p.current():
+				fmt.Fprintf(statusOut, "🧹 %s %s\n", tr("Removed the copies of"), dimColor(p.Source))
+				remove(path)
+			}
+		}
+		tiles, _ := os.ReadDir(filepath.Join(dir, "tiles"))
+		for _, entry := range tiles {
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) >= mapTileCache {
+				remove(filepath.Join(dir, "tiles", entry.Name()))
+			}
+		}
+		fmt.Fprintf(statusOut, "✅ %s %s\n", successColor("Freed"), formatByteSize(freed))
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheInfoCmd, cacheClearCmd, cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	captionEndpoint   string
+	captionPromptFlag string
+	captionShow       bool
+)
+
+var captionCmd = &cobra.Command{
+	Use:   "caption [path_or_url]",
+	Short: "Describe an image in words with a vision model",
+	Long: `Send an image to a vision model and print the caption it comes up with:
+
+  termuwu caption photo.jpg --endpoint ollama://llava
+  termuwu caption chart.png --endpoint openai://gpt-4o-mini --show
+
+Endpoints are ollama://MODEL (a local Ollama, or caption.ollama_host),
+openai://MODEL (caption.openai_api_key or $OPENAI_API_KEY, caption.openai_base_url
+for compatible servers) or an http(s) URL the image is POSTed to as a PNG.
+The default endpoint, prompt and timeout can be set in the config's caption section.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		statusOut = stderrStatus() // the caption is the output
+
+		endpoint := captionEndpoint
+		if endpoint == "" {
+			endpoint = config.Caption.Endpoint
+		}
+		if endpoint == "" {
+			fmt.Fprintln(statusOut, errorColor("❌ Give the model to ask with --endpoint (like ollama://llava) or set caption.endpoint in the config."))
+			return
+		}
+		if captionPromptFlag != "" {
+			config.Caption.Prompt = captionPromptFlag
+		}
+
+		img, _, err := loadImage(args[0])
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+		if captionShow {
+			renderer := configureRenderer(false, false, false, 0, 0)
+			renderer.MaxHeight -= 2 // room for the caption
+			fmt.Print(renderer.RenderImage(img))
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		fmt.Fprintf(statusOut, "🧠 %s %s\n", infoColor("Asking"), endpoint)
+		caption, err := requestCaption(ctx, endpoint, img)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ No caption:"), err)
+			return
+		}
+		fmt.Println(caption)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(captionCmd)
+
+	captionCmd.Flags().StringVarP(&captionEndpoint, "endpoint", "e", "", "Vision model to ask: ollama://MODEL, openai://MODEL or an http(s) URL (default caption.endpoint from the config).")
+	captionCmd.Flags().StringVar(&captionPromptFlag, "prompt", "", "What to ask the model (default caption.prompt from the config, or a short description).")
+	captionCmd.Flags().BoolVarP(&captionShow, "show", "s", false, "Render the image above the caption.")
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaults for captioning, see CaptionConfig
+const (
+	defaultCaptionPrompt  = "Describe this image in one or two sentences for someone who can't see it."
+	defaultCaptionTimeout = 60 * time.Second // local models can take a while
+	defaultOllamaHost     = "http://localhost:11434"
+	defaultOpenAIBaseURL  = "https://api.openai.com/v1"
+	// captionImageSize is the longest side of the image sent to the model,
+	// vision models scale down to about this anyway
+	captionImageSize = 1024
+)
+
+// requestCaption asks the vision model behind endpoint to describe img:
+//
+//	ollama://MODEL   a local (or caption.ollama_host) Ollama server
+//	openai://MODEL   the OpenAI chat API, or any server compatible with it
+//	http(s)://...    the image is POSTed as a PNG, the reply is a JSON
+//	                 {"caption": "..."} or plain text
+//
+// keys, hosts, the prompt and the timeout come from the caption section of
+// the config
+func requestCaption(ctx context.Context, endpoint string, img image.Image) (string, error) {
+	timeout := defaultCaptionTimeout
+	if config.Caption.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(config.Caption.Timeout); err != nil {
+			return "", fmt.Errorf("invalid caption.timeout in the config: %w", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body bytes.Buffer
+	if err := png.Encode(&body, Thumbnail(img, captionImageSize)); err != nil {
+		return "", err
+	}
+
+	scheme, model, _ := strings.Cut(endpoint, "://")
+	var caption string
+	var err error
+	switch scheme {
+	case "ollama":
+		caption, err = captionOllama(ctx, model, body.Bytes())
+	case "openai":
+		caption, err = captionOpenAI(ctx, model, body.Bytes())
+	case "http", "https":
+		caption, err = captionHTTP(ctx, endpoint, body.Bytes())
+	default:
+		return "", fmt.Errorf("unknown caption endpoint %q (use ollama://MODEL, openai://MODEL or an http(s) URL)", endpoint)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "", fmt.Errorf("no caption within %s (raise caption.timeout in the config)", timeout)
+	}
+	return strings.TrimSpace(caption), err
+}
+
+func captionPrompt() string {
+	if config.Caption.Prompt != "" {
+		return config.Caption.Prompt
+	}
+	return defaultCaptionPrompt
+}
+
+func captionOllama(ctx context.Context, model string, imagePNG []byte) (string, error) {
+	if model == "" {
+		return "", errors.New("ollama:// needs a model, like ollama://llava")
+	}
+	host := config.Caption.OllamaHost
+	if host == "" {
+		host = os.Getenv("OLLAMA_HOST")
+	}
+	if host == "" {
+		host = defaultOllamaHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	request := map[string]any{
+		"model":  model,
+		"prompt": captionPrompt(),
+		"images": []string{base64.StdEncoding.EncodeToString(imagePNG)},
+		"stream": false,
+	}
+	var reply struct {
+		Response string `json:"response"`
+		Error    string `json:"error"`
+	}
+	err := postJSON(ctx, strings.TrimSuffix(host, "/")+"/api/generate", "", request, &reply)
+	if isOffline(err) {
+		return "", fmt.Errorf("couldn't reach Ollama at %s, is `ollama serve` running?", host)
+	}
+	if reply.Error != "" {
+		return "", fmt.Errorf("ollama: %s", reply.Error)
+	}
+	return reply.Response, err
+}
+
+func captionOpenAI(ctx context.Context, model string, imagePNG []byte) (string, error) {
+	if model == "" {
+		return "", errors.New("openai:// needs a model, like openai://gpt-4o-mini")
+	}
+	key := config.Caption.OpenAIKey
+	if key == "" {
+		key = os.Getenv("OPENAI_API_KEY")
+	}
+	base := config.Caption.OpenAIBaseURL
+	if base == "" {
+		base = defaultOpenAIBaseURL
+		if key == "" {
+			return "", errors.New("set caption.openai_api_key in the config or $OPENAI_API_KEY")
+		}
+	}
+
+	request := map[string]any{
+		"model": model,
+		"messages": []map[string]any{{
+			"role": "user",
+			"content": []map[string]any{
+				{"type": "text", "text": captionPrompt()},
+				{"type": "image_url", "image_url": map[string]string{
+					"url": "data:image/png;base64," + base64.StdEncoding.EncodeToString(imagePNG),
+				}},
+			},
+		}},
+	}
+	var reply struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	err := postJSON(ctx, strings.TrimSuffix(base, "/")+"/chat/completions", key, request, &reply)
+	if isOffline(err) {
+		return "", fmt.Errorf("couldn't reach %s, are you offline?", base)
+	}
+	if reply.Error != nil {
+		return "", fmt.Errorf("openai: %s", reply.Error.Message)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(reply.Choices) == 0 {
+		return "", errors.New("openai: the reply has no caption")
+	}
+	return reply.Choices[0].Message.Content, nil
+}
+
+// captionHTTP posts the PNG as is to a caption service of your own
+func captionHTTP(ctx context.Context, endpoint string, imagePNG []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(imagePNG))
+	if err != nil {
+		return "", fmt.Errorf("invalid caption endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "image/png")
+	if token := os.Getenv("TERMUWU_CAPTION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	reply, err := doCaptionRequest(req)
+	if isOffline(err) {
+		return "", fmt.Errorf("couldn't reach the caption endpoint %s", endpoint)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var caption struct {
+		Caption string `json:"caption"`
+	}
+	if json.Unmarshal(reply, &caption) == nil && caption.Caption != "" {
+		return caption.Caption, nil
+	}
+	return string(reply), nil
+}
+
+// postJSON posts request as JSON and decodes the reply into reply, also
+// when the status is an error: the APIs explain what went wrong in it
+func postJSON(ctx context.Context, url, bearer string, request, reply any) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid caption endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	body, err := doCaptionRequest(req)
+	if body != nil && json.Unmarshal(body, reply) != nil && err == nil {
+		return fmt.Errorf("couldn't parse the reply from %s", url)
+	}
+	return err
+}
+
+// doCaptionRequest sends req and reads the reply. a status other than 200
+// is an error, the body comes back anyway
+func doCaptionRequest(req *http.Request) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return body, fmt.Errorf("%s answered with status code %d", req.URL.Host, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// isOffline tells failures to connect at all (refused, unreachable, no
+// such host) from other errors
+func isOffline(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && !opErr.Timeout() && !errors.Is(err, context.DeadlineExceeded)
+}
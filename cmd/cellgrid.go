@@ -0,0 +1,148 @@
+package cmd
+
+// NoColor leaves a cell's fg or bg at the terminal default
+const NoColor = -1
+
+// TransparentGlyph marks a cell that isn't drawn at all: the ANSI encoder
+// moves the cursor over it, so whatever is on screen there shows through
+const TransparentGlyph rune = 0
+
+// Cell is a single terminal character cell. FG and BG hold a palette index
+// for 16/256 color grids, or 0xRRGGBB for truecolor grids
+type Cell struct {
+	Glyph rune
+	FG    int
+	BG    int
+}
+
+// CellGrid is the rasterized form of an image, before it is encoded into
+// ANSI, HTML or anything else. other TUI programs can use it to composite
+// images into their own layouts.
+type CellGrid struct {
+	Width, Height int
+	Depth         ColorDepth
+	Cells         []Cell // row-major, Width*Height long
+}
+
+func NewCellGrid(width, height int, depth ColorDepth) *CellGrid {
+	g := &CellGrid{
+		Width:  width,
+		Height: height,
+		Depth:  depth,
+		Cells:  make([]Cell, width*height),
+	}
+	for i := range g.Cells {
+		g.Cells[i] = Cell{Glyph: ' ', FG: NoColor, BG: NoColor}
+	}
+	return g
+}
+
+// Reset resizes the grid and blanks every cell, reusing the cell storage
+// when it's big enough
+func (g *CellGrid) Reset(width, height int, depth ColorDepth) {
+	g.Width, g.Height, g.Depth = width, height, depth
+	if cap(g.Cells) < width*height {
+		g.Cells = make([]Cell, width*height)
+	}
+	g.Cells = g.Cells[:width*height]
+	for i := range g.Cells {
+		g.Cells[i] = Cell{Glyph: ' ', FG: NoColor, BG: NoColor}
+	}
+}
+
+func (g *CellGrid) At(x, y int) Cell {
+	return g.Cells[y*g.Width+x]
+}
+
+func (g *CellGrid) Set(x, y int, c Cell) {
+	g.Cells[y*g.Width+x] = c
+}
+
+// opaque turns a transparent cell into a blank one, for encoders that
+// can't leave a cell alone
+func (c Cell) opaque() Cell {
+	if c.Glyph == TransparentGlyph {
+		return Cell{Glyph: ' ', FG: NoColor, BG: NoColor}
+	}
+	return c
+}
+
+// Row returns the cells of line y, sharing memory with the grid
+func (g *CellGrid) Row(y int) []Cell {
+	return g.Cells[y*g.Width : (y+1)*g.Width]
+}
+
+// RGB resolves a cell color to the RGB value a typical terminal would show.
+// ok is false for NoColor
+func (g *CellGrid) RGB(code int) (c Color, ok bool) {
+	if code == NoColor {
+		return Color{}, false
+	}
+	switch g.Depth {
+	case TrueColor:
+		return Color{R: uint8(code >> 16), G: uint8(code >> 8), B: uint8(code)}, true
+	case Color16:
+		return ansi16Palette[code&0x0f], true
+	default:
+		return xtermRGB(code), true
+	}
+}
+
+// UniqueColors counts the distinct colors that are actually visible
+func (g *CellGrid) UniqueColors() int {
+	seen := make(map[int]struct{})
+	for _, c := range g.Cells {
+		if c.FG != NoColor && c.Glyph != ' ' {
+			seen[c.FG] = struct{}{}
+		}
+		if c.BG != NoColor {
+			seen[c.BG] = struct{}{}
+		}
+	}
+	return len(seen)
+}
+
+// xtermRGB gives the RGB xterm displays for a 256-color palette index
+func xtermRGB(index int) Color {
+	switch {
+	case index < 16:
+		return ansi16Palette[index&0x0f]
+	case index < 232:
+		levels := [6]uint8{0, 95, 135, 175, 215, 255}
+		index -= 16
+		return Color{R: levels[index/36], G: levels[(index/6)%6], B: levels[index%6]}
+	default:
+		gray := uint8(8 + (index-232)*10)
+		return Color{R: gray, G: gray, B: gray}
+	}
+}
+
+// pad grows the grid to width x height with fill cells, keeping what it
+// holds centered. it moves the cells in place, so a reused grid doesn't
+// allocate again
+func (g *CellGrid) pad(width, height int, fill Cell) {
+	width, height = max(width, g.Width), max(height, g.Height)
+	if width == g.Width && height == g.Height {
+		return
+	}
+	oldWidth, oldHeight := g.Width, g.Height
+	left, top := (width-oldWidth)/2, (height-oldHeight)/2
+	if cap(g.Cells) < width*height {
+		g.Cells = append(make([]Cell, 0, width*height), g.Cells...)
+	}
+	g.Cells = g.Cells[:width*height]
+	// bottom up, a row only ever moves over rows that already moved
+	for y := oldHeight - 1; y >= 0; y-- {
+		start := (y+top)*width + left
+		copy(g.Cells[start:start+oldWidth], g.Cells[y*oldWidth:(y+1)*oldWidth])
+	}
+	g.Width, g.Height = width, height
+	for y := 0; y < height; y++ {
+		row := g.Row(y)
+		for x := range row {
+			if y < top || y >= top+oldHeight || x < left || x >= left+oldWidth {
+				row[x] = fill
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// a cell is drawn 8x16 pixels, the 1:2 shape renders assume for a
+// terminal cell (AspectRatio 0.5)
+const (
+	gridCellWidth  = 8
+	gridCellHeight = 16
+)
+
+// the terminal's default colors for cells with NoColor: black and ANSI
+// white
+var (
+	defaultCellBG = color.RGBA{A: 0xff}
+	defaultCellFG = color.RGBA{R: 229, G: 229, B: 229, A: 0xff}
+)
+
+// quadrantBits are the filled quarters of ▖ through ▟: 1 upper left, 2
+// upper right, 4 lower left, 8 lower right
+var quadrantBits = [10]uint8{4, 8, 1, 1 | 4 | 8, 1 | 8, 1 | 2 | 4, 1 | 2 | 8, 2, 2 | 4, 2 | 4 | 8}
+
+// brailleDots are where braille's 8 dots sit in its 2x4 grid, by bit
+var brailleDots = [8]image.Point{{0, 0}, {0, 1}, {0, 2}, {1, 0}, {1, 1}, {1, 2}, {0, 3}, {1, 3}}
+
+// GridImage draws a cell grid the way a terminal would show it. block
+// elements and braille are drawn exactly, other characters come from the
+// embedded 7x13 bitmap font, which covers ASCII and Latin-1
+func GridImage(grid *CellGrid) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, grid.Width*gridCellWidth, grid.Height*gridCellHeight))
+	for y := 0; y < grid.Height; y++ {
+		for x, c := range grid.Row(y) {
+			c = c.opaque()
+			r := image.Rect(x*gridCellWidth, y*gridCellHeight, (x+1)*gridCellWidth, (y+1)*gridCellHeight)
+			fg, bg := defaultCellFG, defaultCellBG
+			if rgb, ok := grid.RGB(c.FG); ok {
+				fg = color.RGBA{R: rgb.R, G: rgb.G, B: rgb.B, A: 0xff}
+			}
+			if rgb, ok := grid.RGB(c.BG); ok {
+				bg = color.RGBA{R: rgb.R, G: rgb.G, B: rgb.B, A: 0xff}
+			}
+			draw.Draw(img, r, image.NewUniform(bg), image.Point{}, draw.Src)
+			drawGlyph(img, r, c.Glyph, fg, bg)
+		}
+	}
+	return img
+}
+
+// drawGlyph draws glyph in fg over the cell r, filled with bg already
+func drawGlyph(img *image.RGBA, r image.Rectangle, glyph rune, fg, bg color.RGBA) {
+	w, h := r.Dx(), r.Dy()
+	fill := func(x0, y0, x1, y1 int) {
+		draw.Draw(img, image.Rect(r.Min.X+x0, r.Min.Y+y0, r.Min.X+x1, r.Min.Y+y1), image.NewUniform(fg), image.Point{}, draw.Src)
+	}
+
+	switch {
+	case glyph == ' ':
+	case glyph == '▀':
+		fill(0, 0, w, h/2)
+	case glyph >= '▁' && glyph <= '█': // lower eighths up to the full block
+		fill(0, h-h*int(glyph-'▀')/8, w, h)
+	case glyph >= '▉' && glyph <= '▏': // left eighths, 7 down to 1
+		fill(0, 0, w*int(8-(glyph-'█'))/8, h)
+	case glyph == '▐':
+		fill(w/2, 0, w, h)
+	case glyph >= '░' && glyph <= '▓':
+		shade := mixRGBA(bg, fg, int(glyph-'░'+1)*64)
+		draw.Draw(img, r, image.NewUniform(shade), image.Point{}, draw.Src)
+	case glyph == '▔':
+		fill(0, 0, w, h/8)
+	case glyph == '▕':
+		fill(w-w/8, 0, w, h)
+	case glyph >= '▖' && glyph <= '▟':
+		bits := quadrantBits[glyph-'▖']
+		for i := 0; i < 4; i++ {
+			if bits&(1<<i) != 0 {
+				qx, qy := i%2*w/2, i/2*h/2
+				fill(qx, qy, qx+w/2, qy+h/2)
+			}
+		}
+	case glyph >= 0x2800 && glyph <= 0x28ff:
+		dot := w / 4
+		for bit, p := range brailleDots {
+			if (glyph-0x2800)&(1<<bit) != 0 {
+				cx, cy := p.X*w/2+w/4, p.Y*h/4+h/8
+				fill(cx-dot/2, cy-dot/2, cx-dot/2+dot, cy-dot/2+dot)
+			}
+		}
+	default:
+		face := basicfont.Face7x13
+		dot := fixed.P(r.Min.X, r.Min.Y+(h-face.Height)/2+face.Ascent)
+		dr, mask, maskp, _, ok := face.Glyph(dot, glyph)
+		if !ok {
+			dr, mask, maskp, _, _ = face.Glyph(dot, '?')
+		}
+		draw.DrawMask(img, dr, image.NewUniform(fg), image.Point{}, mask, maskp, draw.Over)
+	}
+}
+
+// mixRGBA blends from a to b, weight out of 256
+func mixRGBA(a, b color.RGBA, weight int) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8((int(x)*(256-weight) + int(y)*weight) / 256) }
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 0xff}
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// chartData is a CSV table of numbers. for line and scatter charts the
+// first column is x and every other column a series, a single column is
+// plotted against the row number. heatmaps use the whole table as a matrix
+type chartData struct {
+	Names []string    // series names from the header row, if there was one
+	Rows  [][]float64 // every row has the same length
+}
+
+// seriesColors are bright enough to light braille dots on a dark terminal
+var seriesColors = []color.RGBA{
+	{255, 95, 95, 255}, {95, 255, 95, 255}, {95, 175, 255, 255},
+	{255, 255, 95, 255}, {255, 95, 255, 255}, {95, 255, 255, 255},
+}
+
+var axisColor = color.RGBA{150, 150, 150, 255}
+
+// parseChartCSV reads numbers from CSV. a first row that isn't numeric is
+// taken as the header
+func parseChartCSV(r io.Reader) (*chartData, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read CSV: %w", err)
+	}
+
+	data := &chartData{}
+	for i, record := range records {
+		row := make([]float64, len(record))
+		numeric := true
+		for j, field := range record {
+			if row[j], err = strconv.ParseFloat(strings.TrimSpace(field), 64); err != nil {
+				numeric = false
+				break
+			}
+		}
+		if !numeric {
+			if i == 0 {
+				data.Names = record
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %q is not a number", i+1, strings.Join(record, ","))
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	if len(data.Rows) == 0 {
+		return nil, fmt.Errorf("no data rows in CSV")
+	}
+	return data, nil
+}
+
+// xy returns the x column and the y series for line and scatter charts
+func (d *chartData) xy() (xs []float64, series [][]float64, names []string) {
+	columns := len(d.Rows[0])
+	if columns == 1 {
+		xs = make([]float64, len(d.Rows))
+		ys := make([]float64, len(d.Rows))
+		for i, row := range d.Rows {
+			xs[i], ys[i] = float64(i), row[0]
+		}
+		return xs, [][]float64{ys}, d.seriesNames(0, 1)
+	}
+
+	series = make([][]float64, columns-1)
+	for _, row := range d.Rows {
+		xs = append(xs, row[0])
+		for s := range series {
+			series[s] = append(series[s], row[s+1])
+		}
+	}
+	return xs, series, d.seriesNames(1, columns)
+}
+
+func (d *chartData) seriesNames(from, to int) []string {
+	names := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		if i < len(d.Names) && d.Names[i] != "" {
+			names = append(names, d.Names[i])
+		} else {
+			names = append(names, fmt.Sprintf("series %d", len(names)+1))
+		}
+	}
+	return names
+}
+
+// chartRange is the span of values an axis covers
+type chartRange struct{ Min, Max float64 }
+
+func rangeOf(values ...[]float64) chartRange {
+	r := chartRange{math.Inf(1), math.Inf(-1)}
+	for _, vs := range values {
+		for _, v := range vs {
+			r.Min, r.Max = min(r.Min, v), max(r.Max, v)
+		}
+	}
+	if r.Min == r.Max {
+		r.Min, r.Max = r.Min-1, r.Max+1 // flat data still needs some room
+	}
+	return r
+}
+
+// scale maps v onto 0..size-1
+func (r chartRange) scale(v float64, size int) int {
+	return int(math.Round((v - r.Min) / (r.Max - r.Min) * float64(size-1)))
+}
+
+// drawChart rasterizes a line or scatter chart with axes on the left and
+// bottom edge, returning the axis ranges for labeling
+func drawChart(canvas *image.RGBA, xs []float64, series [][]float64, lines bool) (xRange, yRange chartRange) {
+	width, height := canvas.Rect.Dx(), canvas.Rect.Dy()
+	xRange, yRange = rangeOf(xs), rangeOf(series...)
+
+	for y := 0; y < height; y++ {
+		canvas.SetRGBA(0, y, axisColor)
+	}
+	for x := 0; x < width; x++ {
+		canvas.SetRGBA(x, height-1, axisColor)
+	}
+
+	// plot inside the axes
+	plotWidth, plotHeight := max(width-1, 1), max(height-1, 1)
+	for s, ys := range series {
+		c := seriesColors[s%len(seriesColors)]
+		prevX, prevY := -1, -1
+		for i, v := range ys {
+			px := 1 + xRange.scale(xs[i], plotWidth)
+			py := plotHeight - 1 - yRange.scale(v, plotHeight)
+			if lines && prevX >= 0 {
+				drawLine(canvas, prevX, prevY, px, py, c)
+			} else {
+				canvas.SetRGBA(px, py, c)
+			}
+			prevX, prevY = px, py
+		}
+	}
+	return xRange, yRange
+}
+
+// drawLine draws a line with Bresenham's algorithm
+func drawLine(canvas *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	for e := dx + dy; ; {
+		canvas.SetRGBA(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// drawHeatmap stretches the table over the canvas, low values dark blue
+// through to bright yellow, and returns the value range
+func drawHeatmap(canvas *image.RGBA, rows [][]float64) chartRange {
+	width, height := canvas.Rect.Dx(), canvas.Rect.Dy()
+	values := rangeOf(rows...)
+	for y := 0; y < height; y++ {
+		row := rows[y*len(rows)/height]
+		for x := 0; x < width; x++ {
+			t := (row[x*len(row)/width] - values.Min) / (values.Max - values.Min)
+			canvas.SetRGBA(x, y, heatmapColor(t))
+		}
+	}
+	return values
+}
+
+func heatmapColor(t float64) color.RGBA {
+	return gradientRGBA(t, color.RGBA{30, 10, 90, 255}, color.RGBA{30, 140, 140, 255}, color.RGBA{120, 210, 60, 255}, color.RGBA{255, 230, 40, 255})
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseFuzz reads a --transparent-fuzz like "10%" (or just "10") into a
+// 0..1 fraction
+func parseFuzz(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("%q should be a percentage from 0%% to 100%%", value)
+	}
+	return percent / 100, nil
+}
+
+// ChromaKeyFilter makes every pixel within fuzz of the key color fully
+// transparent. fuzz is a fraction of the largest possible RGB distance,
+// black to white, so 0 only keys out the exact color
+func ChromaKeyFilter(r8, g8, b8 uint8, fuzz float64) Filter {
+	limit := fuzz * math.Sqrt(3*255*255)
+	limit *= limit
+	return func(img *image.RGBA) {
+		for i := 0; i < len(img.Pix); i += 4 {
+			p := img.Pix[i : i+4 : i+4]
+			if p[3] == 0 {
+				continue
+			}
+			// compare the straight color, not the premultiplied one
+			alpha := float64(p[3]) / 255
+			dr := float64(p[0])/alpha - float64(r8)
+			dg := float64(p[1])/alpha - float64(g8)
+			db := float64(p[2])/alpha - float64(b8)
+			if dr*dr+dg*dg+db*db <= limit {
+				p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+			}
+		}
+	}
+}
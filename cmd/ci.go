@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ciBaselineDir  string
+	ciCandidateDir string
+	ciThreshold    float64
+	ciTolerance    int
+	ciReport       string
+	ciDiffDir      string
+	ciShow         bool
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Visual regression checks for CI and pre-commit hooks",
+}
+
+var ciCompareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compare a directory of candidate images against the baseline",
+	Long: `Compare every image in the baseline directory with the image of the same
+name in the candidate directory and fail when any changed too much:
+
+  termuwu ci compare --baseline-dir golden/ --candidate-dir out/ --threshold 0.01 --report report.html
+
+--threshold is the share of pixels that may change (0.01 is 1%), --tolerance
+how much a color channel may change before the pixel counts as changed. An
+image missing from the candidates fails as well, one only in the candidates
+is listed as new. Either directory can be a zip or tar archive too.
+
+The exit code is 0 when everything matches, 1 on regressions and 2 when the
+comparison couldn't run. --report writes an .html page with the baseline,
+candidate and diff of every image that didn't pass, or .json for tools.
+Nothing needs a terminal; --show renders the diffs of failures as well.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		fail := func(format string, a ...any) {
+			fmt.Fprintf(statusOut, format, a...)
+			exit(2)
+		}
+		if ciBaselineDir == "" || ciCandidateDir == "" {
+			fail("%s\n", errorColor("❌ --baseline-dir and --candidate-dir are both needed."))
+		}
+		if ciThreshold < 0 || ciThreshold > 1 {
+			fail("%s\n", errorColor("❌ --threshold must be between 0 and 1."))
+		}
+		if ciTolerance < 0 || ciTolerance > 255 {
+			fail("%s\n", errorColor("❌ --tolerance must be between 0 and 255."))
+		}
+		switch strings.ToLower(filepath.Ext(ciReport)) {
+		case "", ".html", ".htm", ".json":
+		default:
+			fail(tr("%s %q (use .html or .json)\n"), errorColor("❌ Unknown report format:"), ciReport)
+		}
+		var renderer *ImageRenderer
+		if ciShow {
+			var ok bool
+			if renderer, ok = sharedRenderer(); !ok {
+				exit(2)
+			}
+		}
+		if ciDiffDir != "" {
+			if err := os.MkdirAll(ciDiffDir, 0o755); err != nil {
+				fail("%s %v\n", errorColor("❌ Error creating --diff-dir:"), err)
+			}
+		}
+
+		results, err := compareDirs(ciBaselineDir, ciCandidateDir, ciThreshold, uint8(ciTolerance))
+		if err != nil {
+			fail("%s %v\n", errorColor("❌ Error comparing images:"), err)
+		}
+
+		regressions := 0
+		var firstDiff image.Image // what --notify shows
+		for _, result := range results {
+			switch result.Status {
+			case ciPassed:
+				fmt.Fprintf(statusOut, "✅ %s %s (%.3f%%)\n", successColor("passed"), result.Name, result.Stats.Percent())
+			case ciNew:
+				fmt.Fprintf(statusOut, "⚠️  %s %s\n", infoColor("new"), result.Name)
+			case ciMissing:
+				regressions++
+				fmt.Fprintf(statusOut, tr("❌ %s %s: not in the candidates\n"), errorColor("missing"), result.Name)
+			case ciError:
+				regressions++
+				fmt.Fprintf(statusOut, "❌ %s %s: %s\n", errorColor("error"), result.Name, result.Error)
+			case ciFailed:
+				regressions++
+				if firstDiff == nil {
+					firstDiff = result.Diff
+				}
+				fmt.Fprintf(statusOut, tr("❌ %s %s: %.3f%% changed in %v\n"), errorColor("failed"), result.Name, result.Stats.Percent(), result.Stats.Bounds)
+				if renderer != nil {
+					fmt.Print(renderer.RenderImage(Stitch([]image.Image{result.Baseline, result.Candidate, result.Diff}, false)))
+				}
+			}
+			if ciDiffDir != "" && result.Diff != nil && result.Status == ciFailed {
+				out := filepath.Join(ciDiffDir, filepath.FromSlash(result.Name))
+				out = out[:len(out)-len(filepath.Ext(out))] + ".png"
+				if err := os.MkdirAll(filepath.Dir(out), 0o755); err == nil {
+					err = SaveImage(out, result.Diff)
+				}
+				if err != nil {
+					fail("%s %v\n", errorColor("❌ Error saving diff:"), err)
+				}
+			}
+		}
+		if ciReport != "" {
+			if err := writeCIReport(ciReport, results, ciThreshold); err != nil {
+				fail("%s %v\n", errorColor("❌ Error writing report:"), err)
+			}
+			fmt.Fprintf(statusOut, "📊 %s %s\n", infoColor("Report:"), ciReport)
+		}
+
+		summary := fmt.Sprintf(tr("%d compared, %d regressions"), len(results), regressions)
+		if regressions > 0 {
+			if firstDiff != nil {
+				noteResult(firstDiff)
+			}
+			fmt.Fprintf(statusOut, "%s %s\n", errorColor("❌ Visual regressions:"), summary)
+			exit(1)
+		}
+		fmt.Fprintf(statusOut, "%s %s\n", successColor("✅ No visual regressions:"), summary)
+	},
+}
+
+// ciStatus is how a compared image came out
+type ciStatus string
+
+const (
+	ciPassed  ciStatus = "passed"
+	ciFailed  ciStatus = "failed"
+	ciMissing ciStatus = "missing"
+	ciNew     ciStatus = "new"
+	ciError   ciStatus = "error"
+)
+
+// ciResult is one image of the comparison. the images are only kept for
+// failures, the report shows them
+type ciResult struct {
+	Name      string
+	Status    ciStatus
+	Stats     DiffStats
+	Error     string
+	Baseline  image.Image
+	Candidate image.Image
+	Diff      *image.RGBA
+}
+
+// compareDirs compares every image in baseline with its namesake in
+// candidate, sorted by name
+func compareDirs(baseline, candidate string, threshold float64, tolerance uint8) ([]ciResult, error) {
+	baseNames, err := imageNames(baseline)
+	if err != nil {
+		return nil, err
+	}
+	candidateNames, err := imageNames(candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ciResult
+	for name := range baseNames {
+		if !candidateNames[name] {
+			results = append(results, ciResult{Name: name, Status: ciMissing})
+			continue
+		}
+		result := ciResult{Name: name}
+		a, err := decodeInDir(baseline, name)
+		var b image.Image
+		if err == nil {
+			b, err = decodeInDir(candidate, name)
+		}
+		if err != nil {
+			result.Status, result.Error = ciError, err.Error()
+			results = append(results, result)
+			continue
+		}
+		diff, stats := DiffImages(a, b, tolerance)
+		result.Stats, result.Status = stats, ciPassed
+		if stats.Changed > 0 && float64(stats.Changed) > threshold*float64(stats.Total) {
+			result.Status, result.Baseline, result.Candidate, result.Diff = ciFailed, a, b, diff
+		}
+		results = append(results, result)
+	}
+	for name := range candidateNames {
+		if !baseNames[name] {
+			results = append(results, ciResult{Name: name, Status: ciNew})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return naturalLess(results[i].Name, results[j].Name) })
+	return results, nil
+}
+
+// imageNames are the names of the images in dir as walkImages gives them
+func imageNames(dir string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	if archiveKind(dir) == "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	}
+	err := walkImages(dir, func(entry archiveEntry) error {
+		names[entry.Name] = true
+		return nil
+	})
+	return names, err
+}
+
+func decodeInDir(dir, name string) (image.Image, error) {
+	file, err := openInDir(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := DecodeImage(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode %s: %w", filepath.Join(dir, name), err)
+	}
+	return img, nil
+}
+
+func init() {
+	rootCmd.AddCommand(ciCmd)
+	ciCmd.AddCommand(ciCompareCmd)
+
+	ciCompareCmd.Flags().StringVar(&ciBaselineDir, "baseline-dir", "", "Directory (or archive) with the expected images.")
+	ciCompareCmd.Flags().StringVar(&ciCandidateDir, "candidate-dir", "", "Directory (or archive) with the images to check.")
+	ciCompareCmd.Flags().Float64Var(&ciThreshold, "threshold", 0, "Share of pixels that may change before an image fails, 0.01 for 1%.")
+	ciCompareCmd.Flags().IntVar(&ciTolerance, "tolerance", 0, "Ignore changes of up to this much per color channel (0-255).")
+	ciCompareCmd.Flags().StringVar(&ciReport, "report", "", "Write a report to this .html or .json file.")
+	ciCompareCmd.Flags().StringVar(&ciDiffDir, "diff-dir", "", "Save the diff of every failed image here as PNG.")
+	ciCompareCmd.Flags().BoolVar(&ciShow, "show", false, "Render baseline, candidate and diff of failed images.")
+	ciCompareCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	ciCompareCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	ciCompareCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	ciCompareCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered diffs in characters (0 for auto).")
+	ciCompareCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered diffs in lines (0 for auto).")
+	ciCompareCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ciReportEntry is a result as the reports show it
+type ciReportEntry struct {
+	Name      string       `json:"name"`
+	Status    ciStatus     `json:"status"`
+	Changed   int          `json:"changed_pixels,omitempty"`
+	Percent   float64      `json:"changed_percent,omitempty"`
+	Bounds    []int        `json:"changed_bounds,omitempty"` // x0, y0, x1, y1
+	Error     string       `json:"error,omitempty"`
+	Baseline  template.URL `json:"-"`
+	Candidate template.URL `json:"-"`
+	Diff      template.URL `json:"-"`
+}
+
+// writeCIReport writes results to path as an HTML page or, for .json, a
+// list of results
+func writeCIReport(path string, results []ciResult, threshold float64) error {
+	entries := make([]ciReportEntry, 0, len(results))
+	failed := 0
+	for _, result := range results {
+		entry := ciReportEntry{Name: result.Name, Status: result.Status, Error: result.Error}
+		if result.Stats.Total > 0 {
+			entry.Changed, entry.Percent = result.Stats.Changed, result.Stats.Percent()
+		}
+		if b := result.Stats.Bounds; !b.Empty() {
+			entry.Bounds = []int{b.Min.X, b.Min.Y, b.Max.X, b.Max.Y}
+		}
+		if result.Status != ciPassed && result.Status != ciNew {
+			failed++
+		}
+		entries = append(entries, entry)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		data, err := json.MarshalIndent(struct {
+			Threshold   float64         `json:"threshold"`
+			Regressions int             `json:"regressions"`
+			Results     []ciReportEntry `json:"results"`
+		}{threshold, failed, entries}, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, append(data, '\n'), 0o644)
+	case ".html", ".htm":
+		for i, result := range results {
+			if result.Status != ciFailed {
+				continue
+			}
+			var err error
+			for _, img := range []struct {
+				src image.Image
+				dst *template.URL
+			}{{result.Baseline, &entries[i].Baseline}, {result.Candidate, &entries[i].Candidate}, {result.Diff, &entries[i].Diff}} {
+				if *img.dst, err = pngDataURI(img.src); err != nil {
+					return err
+				}
+			}
+		}
+		var out bytes.Buffer
+		err := ciReportTemplate.Execute(&out, struct {
+			Threshold   float64
+			Regressions int
+			Entries     []ciReportEntry
+		}{threshold * 100, failed, entries})
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, out.Bytes(), 0o644)
+	}
+	return fmt.Errorf("unknown report format %q (use .html or .json)", filepath.Ext(path))
+}
+
+// pngDataURI embeds img in the page so the report is a single file
+func pngDataURI(img image.Image) (template.URL, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+var ciReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>termuwu visual regressions</title>
+<style>
+body { font-family: sans-serif; margin: 2em; background: #fafafa; color: #222; }
+table { border-collapse: collapse; }
+td, th { padding: 0.3em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+.passed { color: #2a7d2a; } .new { color: #a06a00; } .failed, .missing, .error { color: #c0203a; font-weight: bold; }
+figure { display: inline-block; margin: 0.5em; vertical-align: top; }
+figure img { max-width: 360px; border: 1px solid #ccc; image-rendering: pixelated; background: repeating-conic-gradient(#ddd 0 25%, #fff 0 50%) 0 0 / 16px 16px; }
+</style>
+</head>
+<body>
+<h1>{{if .Regressions}}{{.Regressions}} visual regressions{{else}}No visual regressions{{end}}</h1>
+<p>{{len .Entries}} images compared, up to {{printf "%.3g" .Threshold}}% of the pixels may change.</p>
+<table>
+<tr><th>Image</th><th>Status</th><th>Changed</th></tr>
+{{range .Entries}}<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{if .Error}}{{.Error}}{{else if .Changed}}{{printf "%.3f" .Percent}}% ({{.Changed}} pixels){{end}}</td></tr>
+{{end}}</table>
+{{range .Entries}}{{if .Diff}}
+<h2>{{.Name}}</h2>
+<figure><img src="{{.Baseline}}"><figcaption>baseline</figcaption></figure>
+<figure><img src="{{.Candidate}}"><figcaption>candidate</figcaption></figure>
+<figure><img src="{{.Diff}}"><figcaption>diff</figcaption></figure>
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// claheGrid is how many tiles across and down CLAHE equalizes on their own
+	claheGrid = 8
+	// claheClipLimit caps every histogram bin at this many times the
+	// average, which keeps flat areas from turning into amplified noise
+	claheClipLimit = 3.0
+)
+
+// CLAHEFilter equalizes the brightness histogram of every tile of an 8x8
+// grid separately (contrast-limited adaptive histogram equalization) and
+// blends the tiles' curves smoothly, bringing out detail in dark
+// screenshots, scans and X-rays that a single curve for the whole image
+// can't. only the brightness changes, the colors keep their hue
+func CLAHEFilter() Filter {
+	return func(img *image.RGBA) {
+		w, h := img.Rect.Dx(), img.Rect.Dy()
+		if w == 0 || h == 0 {
+			return
+		}
+		gridX, gridY := max(1, min(claheGrid, w/4)), max(1, min(claheGrid, h/4))
+
+		lumas := make([]uint8, w*h)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := y*img.Stride + x*4
+				if alpha := uint32(img.Pix[i+3]); alpha != 0 {
+					c := Color{uint8(uint32(img.Pix[i]) * 255 / alpha), uint8(uint32(img.Pix[i+1]) * 255 / alpha), uint8(uint32(img.Pix[i+2]) * 255 / alpha)}
+					lumas[y*w+x] = uint8(math.Round(luminance(c)))
+				}
+			}
+		}
+
+		curves := make([][256]float64, gridX*gridY)
+		for ty := 0; ty < gridY; ty++ {
+			for tx := 0; tx < gridX; tx++ {
+				var histogram [256]int
+				n := 0
+				for y := ty * h / gridY; y < (ty+1)*h/gridY; y++ {
+					for x := tx * w / gridX; x < (tx+1)*w/gridX; x++ {
+						if img.Pix[y*img.Stride+x*4+3] != 0 {
+							histogram[lumas[y*w+x]]++
+							n++
+						}
+					}
+				}
+				curves[ty*gridX+tx] = claheCurve(histogram, n)
+			}
+		}
+
+		// every pixel blends the curves of the four tiles whose centers
+		// surround it, so no tile edges show
+		tileW, tileH := float64(w)/float64(gridX), float64(h)/float64(gridY)
+		for y := 0; y < h; y++ {
+			fy := max(0, min((float64(y)+0.5)/tileH-0.5, float64(gridY-1)))
+			ty := int(fy)
+			wy := fy - float64(ty)
+			for x := 0; x < w; x++ {
+				i := y*img.Stride + x*4
+				alpha := uint32(img.Pix[i+3])
+				if alpha == 0 {
+					continue
+				}
+				fx := max(0, min((float64(x)+0.5)/tileW-0.5, float64(gridX-1)))
+				tx := int(fx)
+				wx := fx - float64(tx)
+				tx1, ty1 := min(tx+1, gridX-1), min(ty+1, gridY-1)
+
+				l := lumas[y*w+x]
+				top := curves[ty*gridX+tx][l]*(1-wx) + curves[ty*gridX+tx1][l]*wx
+				bottom := curves[ty1*gridX+tx][l]*(1-wx) + curves[ty1*gridX+tx1][l]*wx
+				delta := top*(1-wy) + bottom*wy - float64(l)
+				for c := 0; c < 3; c++ {
+					v := float64(min(uint32(img.Pix[i+c])*255/alpha, 255)) + delta // the pixels are premultiplied
+					img.Pix[i+c] = uint8(max(0, min(v, 255)) * float64(alpha) / 255)
+				}
+			}
+		}
+	}
+}
+
+// claheCurve is the equalizing curve for a tile's histogram of n pixels,
+// with the bins above the clip limit cut down and the excess spread over
+// all of them
+func claheCurve(histogram [256]int, n int) (curve [256]float64) {
+	if n == 0 {
+		for v := range curve {
+			curve[v] = float64(v)
+		}
+		return curve
+	}
+	limit := max(1, int(claheClipLimit*float64(n)/256))
+	excess := 0
+	for v, count := range histogram {
+		if count > limit {
+			excess += count - limit
+			histogram[v] = limit
+		}
+	}
+	sum := 0.0
+	for v, count := range histogram {
+		sum += float64(count) + float64(excess)/256
+		curve[v] = sum * 255 / float64(n)
+	}
+	return curve
+}
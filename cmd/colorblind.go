@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// linear RGB to LMS cone responses (Smith & Pokorny fundamentals) and back
+var (
+	lmsFromLinearRGB = [3][3]float64{
+		{0.17882, 0.43516, 0.04119},
+		{0.03456, 0.27155, 0.03867},
+		{0.00030, 0.00184, 0.01467},
+	}
+	linearRGBFromLMS = [3][3]float64{
+		{8.09444479, -13.05043429, 11.67205791},
+		{-1.02485335, 5.40193283, -11.36147258},
+		{-0.03652974, -0.41216072, 69.35132301},
+	}
+)
+
+// dichromat projections in LMS space (Viénot, Brettel & Mollon 1999): the
+// missing cone's response is rebuilt from the two that remain
+var deficiencyProjections = map[string][3][3]float64{
+	"protanopia": { // no L cones
+		{0, 2.02344, -2.52581},
+		{0, 1, 0},
+		{0, 0, 1},
+	},
+	"deuteranopia": { // no M cones
+		{1, 0, 0},
+		{0.494207, 0, 1.24827},
+		{0, 0, 1},
+	},
+	"tritanopia": { // no S cones
+		{1, 0, 0},
+		{0, 1, 0},
+		{-0.395913, 0.801109, 0},
+	},
+}
+
+// ColorBlindnessFilter returns a Filter that shows img as someone with
+// protanopia, deuteranopia or tritanopia would see it
+func ColorBlindnessFilter(deficiency string) (Filter, error) {
+	projection, ok := deficiencyProjections[strings.ToLower(deficiency)]
+	if !ok {
+		return nil, fmt.Errorf("unknown color vision deficiency %q (use protanopia, deuteranopia or tritanopia)", deficiency)
+	}
+	m := mulMatrix3(linearRGBFromLMS, mulMatrix3(projection, lmsFromLinearRGB))
+
+	return func(img *image.RGBA) {
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			r := srgbToLinear[img.Pix[i]]
+			g := srgbToLinear[img.Pix[i+1]]
+			b := srgbToLinear[img.Pix[i+2]]
+			img.Pix[i] = linearToSRGB(m[0][0]*r + m[0][1]*g + m[0][2]*b)
+			img.Pix[i+1] = linearToSRGB(m[1][0]*r + m[1][1]*g + m[1][2]*b)
+			img.Pix[i+2] = linearToSRGB(m[2][0]*r + m[2][1]*g + m[2][2]*b)
+		}
+	}, nil
+}
+
+func mulMatrix3(a, b [3][3]float64) (m [3][3]float64) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return m
+}
+
+// srgbToLinear undoes the sRGB transfer curve for every 8-bit value
+var srgbToLinear = func() (table [256]float64) {
+	for i := range table {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			table[i] = v / 12.92
+		} else {
+			table[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+func linearToSRGB(v float64) uint8 {
+	v = max(0, min(v, 1))
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(v * 255))
+}
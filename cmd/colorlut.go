@@ -0,0 +1,45 @@
+package cmd
+
+import "sync"
+
+// colorLUT maps a color reduced to 5-6-5 bits (the same split RGB565 uses,
+// green gets the extra bit since the eye is most sensitive to it) to a
+// palette index. a lookup replaces the branchy nearest-color search that
+// otherwise dominates render time on big frames
+type colorLUT [1 << 16]uint8
+
+var (
+	ansi256LUT, ansi16LUT   *colorLUT
+	ansi256Once, ansi16Once sync.Once
+)
+
+// buildColorLUT fills a table with quantize, evaluated at each entry's
+// color. the bits are replicated when widening, so black and white stay
+// exactly black and white
+func buildColorLUT(quantize func(r, g, b uint32) int) *colorLUT {
+	lut := new(colorLUT)
+	for i := range lut {
+		r5, g6, b5 := uint32(i>>11), uint32(i>>5)&0x3f, uint32(i)&0x1f
+		r8 := r5<<3 | r5>>2
+		g8 := g6<<2 | g6>>4
+		b8 := b5<<3 | b5>>2
+		lut[i] = uint8(quantize(r8<<8, g8<<8, b8<<8))
+	}
+	return lut
+}
+
+func lutIndex(r8, g8, b8 uint8) int {
+	return int(r8>>3)<<11 | int(g8>>2)<<5 | int(b8>>3)
+}
+
+// lookupANSI256 is a table driven RGBToANSI256 on 8-bit channels
+func lookupANSI256(r8, g8, b8 uint8) int {
+	ansi256Once.Do(func() { ansi256LUT = buildColorLUT(RGBToANSI256) })
+	return int(ansi256LUT[lutIndex(r8, g8, b8)])
+}
+
+// lookupANSI16 is a table driven RGBToANSI16 on 8-bit channels
+func lookupANSI16(r8, g8, b8 uint8) int {
+	ansi16Once.Do(func() { ansi16LUT = buildColorLUT(RGBToANSI16) })
+	return int(ansi16LUT[lutIndex(r8, g8, b8)])
+}
@@ -158,3 +158,32 @@ func minUint8(a, b, c uint8) uint8 {
 	}
 	return c
 }
+
+// ansi16Palette holds the xterm default values for the 16 basic colors
+var ansi16Palette = [16]Color{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// RGBToANSI16 picks the closest of the 16 basic terminal colors.
+// input r, g, b are 0-65535
+func RGBToANSI16(r, g, b uint32) int {
+	r8 := clamp8(r >> 8)
+	g8 := clamp8(g >> 8)
+	b8 := clamp8(b >> 8)
+
+	best := 0
+	bestDist := -1.0
+	for i, c := range ansi16Palette {
+		dr := float64(r8) - float64(c.R)
+		dg := float64(g8) - float64(c.G)
+		db := float64(b8) - float64(c.B)
+		dist := 2*dr*dr + 4*dg*dg + 3*db*db // same weighting as colorDistance
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
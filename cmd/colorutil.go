@@ -1,12 +1,11 @@
 package cmd
 
 import (
-	"fmt"
 	"image"
-	"os"
-	"strings"
+	"image/color"
+	"time"
 
-	"golang.org/x/term"
+	"golang.org/x/image/draw"
 )
 
 type RenderMode int
@@ -15,116 +14,307 @@ const (
 	BlockMode RenderMode = iota
 	HalfBlockMode
 	BrailleMode
+	HalftoneMode   // shade characters sized by luminance
+	CrosshatchMode // ASCII hatching sized by luminance
 )
 
+func (m RenderMode) String() string {
+	switch m {
+	case BlockMode:
+		return "block"
+	case BrailleMode:
+		return "braille"
+	case HalftoneMode:
+		return "halftone"
+	case CrosshatchMode:
+		return "crosshatch"
+	default:
+		return "half-block"
+	}
+}
+
+type ColorDepth int
+
+const (
+	Color256 ColorDepth = iota
+	Color16
+	TrueColor
+)
+
+func (d ColorDepth) String() string {
+	switch d {
+	case Color16:
+		return "16 colors"
+	case TrueColor:
+		return "truecolor"
+	default:
+		return "256 colors"
+	}
+}
+
+// ImageRenderer turns images into cells. renders keep no state in it, so
+// one renderer can render from several goroutines at once, as long as its
+// fields aren't changed meanwhile and Stats and Progress, which belong to
+// a single render, are nil
 type ImageRenderer struct {
 	Mode        RenderMode
 	MaxWidth    int
 	MaxHeight   int
 	UseDither   bool
+	Dither      DitherMode // the noise UseDither adds
 	AspectRatio float64
+	ColorDepth  ColorDepth
+	Stats       *RenderStats // filled in by RenderImage when set
+	ANSI        ANSIOptions
+	PixelArt    bool // nearest-neighbor by whole factors, see IsPixelArt
+	MaxColors   int  // posterize the scaled image to this many colors, 0 for no limit
+	ExactColors bool // search the palette for every color instead of using the lookup tables
+	Filters     []Filter
+	ExactFit    bool // pad the render out to exactly MaxWidth x MaxHeight cells
+	Transparent bool // leave fully transparent cells alone, see TransparentGlyph
+	CellScale   int  // draw every sample CellScale times as wide and tall, 0 or 1 for normal size
+	Scaler      Scaler
+	SearchDepth int                   // rounds two-color cells search for their best split, 0 for 3
+	Progress    func(percent float64) // told how far Rasterize got, scaling is most of the work
+
+	BrailleColor BrailleColorMode
+	BrailleStyle BrailleStyle
 }
 
+// fallback terminal size when detection fails, also the fixed size of
+// deterministic renders
+const (
+	defaultTerminalWidth  = 100
+	defaultTerminalHeight = 28
+)
+
 func NewImageRenderer(mode RenderMode) *ImageRenderer {
-	width, height, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		width, height = 100, 28 // fallback if terminal size detection fails
+	width, height, ok := terminalSize()
+	if !ok {
+		width, height = defaultTerminalWidth, defaultTerminalHeight
 	}
+	return newImageRenderer(mode, width, height)
+}
 
+// NewDeterministicRenderer is NewImageRenderer without probing the terminal:
+// it always assumes a 100x28 terminal, so renders only depend on the image
+// and the renderer fields. meant for golden files in test suites
+func NewDeterministicRenderer(mode RenderMode) *ImageRenderer {
+	return newImageRenderer(mode, defaultTerminalWidth, defaultTerminalHeight)
+}
+
+func newImageRenderer(mode RenderMode, width, height int) *ImageRenderer {
 	return &ImageRenderer{
 		Mode:        mode,
 		MaxWidth:    width - 2,
 		MaxHeight:   height - 3,
 		UseDither:   true,
 		AspectRatio: 0.5, // common for terminal fonts
+		ColorDepth:  Color256,
 	}
 }
 
 func (r *ImageRenderer) RenderImage(img image.Image) string {
-	bounds := img.Bounds()
-	imgWidth := bounds.Dx()
-	imgHeight := bounds.Dy()
+	start := time.Now()
+	grid := r.rasterizeInto(getGrid(), r.Scale(img))
+	defer putGrid(grid)
+
+	buf := getBuffer()
+	w := &sgrWriter{buf: *buf, opts: r.ANSI}
+	w.writeGrid(grid)
+	output := w.String()
+	*buf = w.buf
+	putBuffer(buf)
+
+	if r.Stats != nil {
+		*r.Stats = RenderStats{
+			Cols:         grid.Width,
+			Rows:         grid.Height,
+			Bytes:        len(output),
+			EscapeBytes:  w.escapeBytes,
+			UniqueColors: grid.UniqueColors(),
+			RenderTime:   time.Since(start),
+		}
+	}
+	return output
+}
 
-	var outputWidth, outputHeight int
+// RenderStyled renders img as a lipgloss friendly block, see CellGrid.Styled
+func (r *ImageRenderer) RenderStyled(img image.Image) string {
+	return r.Rasterize(img).Styled()
+}
 
-	if r.Mode == HalfBlockMode {
-		// half blocks double our effective vertical resolution
-		maxEffectiveHeight := r.MaxHeight * 2
-
-		scaleX := float64(r.MaxWidth) / float64(imgWidth)
-		scaleY := float64(maxEffectiveHeight) / float64(imgHeight)
-		scale := scaleX
-		if scaleY < scaleX {
-			scale = scaleY
-		}
+// Rasterize turns img into a grid of terminal cells without encoding it
+func (r *ImageRenderer) Rasterize(img image.Image) *CellGrid {
+	return r.RasterizeScaled(r.Scale(img))
+}
 
-		outputWidth = int(float64(imgWidth) * scale)
-		outputHeight = int(float64(imgHeight) * scale)
-		if outputHeight%2 != 0 {
-			outputHeight-- // must be even for half block pairs
-		}
-		if outputHeight <= 0 {
-			outputHeight = 2
-		}
+// Scale resamples img to the resolution the current mode works at, one pixel
+// per sample (so two per half-block cell, eight per braille cell), then
+// runs the Filters and posterizes it when MaxColors is set
+func (r *ImageRenderer) Scale(img image.Image) *image.RGBA {
+	return r.scaleInto(nil, img)
+}
 
-	} else { // BlockMode or BrailleMode
-		scaleX := float64(r.MaxWidth) / float64(imgWidth)
-		scaleY := (float64(r.MaxHeight) * r.AspectRatio) / float64(imgHeight)
-		scale := scaleX
-		if scaleY < scaleX {
-			scale = scaleY
-		}
+// scaleInto is Scale reusing dst when it already has the right size
+func (r *ImageRenderer) scaleInto(dst *image.RGBA, img image.Image) *image.RGBA {
+	if r.CellScale > 1 {
+		small := *r
+		small.CellScale = 1
+		small.MaxWidth, small.MaxHeight = max(r.MaxWidth/r.CellScale, 1), max(r.MaxHeight/r.CellScale, 1)
+		return magnify(small.scaleInto(nil, img), r.CellScale)
+	}
+	var scaled *image.RGBA
+	if r.PixelArt {
+		scaled = r.scalePixelArt(img)
+	} else {
+		scaled = r.scaleArea(dst, img)
+	}
+	r.applyFilters(scaled)
+	Posterize(scaled, r.MaxColors)
+	return scaled
+}
+
+func (r *ImageRenderer) scaleArea(dst *image.RGBA, img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	outputWidth, outputHeight := r.sampleSize(bounds.Dx(), bounds.Dy())
 
-		outputWidth = int(float64(imgWidth) * scale)
-		outputHeight = int(float64(imgHeight) * scale / r.AspectRatio)
-		if outputHeight <= 0 {
-			outputHeight = 1
+	scaled := dst
+	if scaled == nil || scaled.Rect != image.Rect(0, 0, outputWidth, outputHeight) {
+		scaled = image.NewRGBA(image.Rect(0, 0, outputWidth, outputHeight))
+	}
+	if resampler := r.Scaler.resampler(); resampler != nil {
+		resampler.Scale(scaled, scaled.Rect, img, bounds, draw.Src, nil)
+		return scaled
+	}
+	for y := 0; y < outputHeight; y++ {
+		for x := 0; x < outputWidth; x++ {
+			scaled.SetRGBA(x, y, r.sampleArea(img, bounds, x, y, outputWidth, outputHeight))
+		}
+		if r.Progress != nil {
+			r.Progress(90 * float64(y+1) / float64(outputHeight))
 		}
 	}
-	if outputWidth <= 0 {
-		outputWidth = 1
+	return scaled
+}
+
+// RasterizeScaled quantizes an image that already went through Scale
+func (r *ImageRenderer) RasterizeScaled(scaled *image.RGBA) *CellGrid {
+	return r.rasterizeInto(nil, scaled)
+}
+
+// rasterizeInto is RasterizeScaled reusing grid's cells when it has one
+func (r *ImageRenderer) rasterizeInto(grid *CellGrid, scaled *image.RGBA) *CellGrid {
+	width, height := scaled.Bounds().Dx(), scaled.Bounds().Dy()
+	cols, rows := r.cellSize(width, height)
+	if grid == nil {
+		grid = NewCellGrid(cols, rows, r.ColorDepth)
+	} else {
+		grid.Reset(cols, rows, r.ColorDepth)
 	}
 
 	switch r.Mode {
 	case HalfBlockMode:
-		return r.renderHalfBlocksImproved(img, outputWidth, outputHeight)
+		r.renderHalfBlocksImproved(grid, scaled, width, height)
 	case BrailleMode:
-		return r.renderBraille(img, outputWidth, outputHeight)
+		r.renderBraille(grid, scaled, width, height)
+	case HalftoneMode:
+		r.renderShaded(grid, scaled, width, height, halftoneRamp)
+	case CrosshatchMode:
+		r.renderShaded(grid, scaled, width, height, crosshatchRamp)
 	default: // BlockMode
-		return r.renderFullBlocksImproved(img, outputWidth, outputHeight)
+		r.renderFullBlocksImproved(grid, scaled, width, height)
+	}
+	blank := Cell{Glyph: ' ', FG: NoColor, BG: NoColor}
+	if r.Transparent {
+		r.clearTransparent(grid, scaled)
+		blank = Cell{Glyph: TransparentGlyph, FG: NoColor, BG: NoColor}
+	}
+	if r.ExactFit {
+		grid.pad(r.MaxWidth, r.MaxHeight, blank)
 	}
+	if r.Progress != nil {
+		r.Progress(100)
+	}
+	return grid
 }
 
-func (r *ImageRenderer) renderFullBlocksImproved(img image.Image, width, height int) string {
-	bounds := img.Bounds()
-	var result strings.Builder
+// sampleSize works out how many image samples fit in MaxWidth x MaxHeight
+// for the current mode, keeping the image's shape on screen
+func (r *ImageRenderer) sampleSize(imgWidth, imgHeight int) (outputWidth, outputHeight int) {
+	maxWidth, maxHeight := r.sampleResolution(r.MaxWidth, r.MaxHeight)
+	aspect := r.sampleAspect()
 
+	scale := min(float64(maxWidth)/float64(imgWidth), float64(maxHeight)/(float64(imgHeight)*aspect))
+	outputWidth = max(int(float64(imgWidth)*scale), 1)
+	outputHeight = max(int(float64(imgHeight)*scale*aspect), 1)
+	if r.Mode == HalfBlockMode {
+		outputHeight = max(outputHeight&^1, 2) // must be even for half block pairs
+	}
+	return outputWidth, outputHeight
+}
+
+// sampleAspect is how wide a sample is for its height. a cell is
+// AspectRatio wide for its height; half blocks split it in two rows, and
+// braille's 2x4 dots make a dot twice as wide for its height as the cell,
+// so both are square with the usual 0.5
+func (r *ImageRenderer) sampleAspect() float64 {
+	switch r.Mode {
+	case HalfBlockMode:
+		return r.AspectRatio * 2
+	case BrailleMode:
+		return r.AspectRatio * 4 / 2
+	default:
+		return r.AspectRatio
+	}
+}
+
+// cellSize converts sample dimensions into terminal columns and rows
+func (r *ImageRenderer) cellSize(outputWidth, outputHeight int) (cols, rows int) {
+	switch r.Mode {
+	case HalfBlockMode:
+		return outputWidth, (outputHeight + 1) / 2
+	case BrailleMode:
+		return (outputWidth + 1) / 2, (outputHeight + 3) / 4
+	default:
+		return outputWidth, outputHeight
+	}
+}
+
+// sampleResolution is the inverse of cellSize: the samples that exactly
+// fill cols x rows cells
+func (r *ImageRenderer) sampleResolution(cols, rows int) (width, height int) {
+	switch r.Mode {
+	case HalfBlockMode:
+		return cols, rows * 2
+	case BrailleMode:
+		return cols * 2, rows * 4
+	default:
+		return cols, rows
+	}
+}
+
+func (r *ImageRenderer) renderFullBlocksImproved(grid *CellGrid, img *image.RGBA, width, height int) {
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			sampledColor := r.sampleArea(img, bounds, x, y, width, height)
+			sampledColor := sampleAt(img, x, y)
 			r8, g8, b8 := sampledColor.R, sampledColor.G, sampledColor.B
 
 			if r.UseDither {
 				r8, g8, b8 = r.applySubtleDither(r8, g8, b8, x, y)
 			}
-			ansiColor := RGBToANSI256(uint32(r8)<<8, uint32(g8)<<8, uint32(b8)<<8)
-			result.WriteString(fmt.Sprintf("\033[48;5;%dm \033[0m", ansiColor))
+			grid.Set(x, y, Cell{Glyph: ' ', FG: NoColor, BG: r.colorCode(r8, g8, b8)})
 		}
-		result.WriteString("\n")
 	}
-	return result.String()
 }
 
-func (r *ImageRenderer) renderHalfBlocksImproved(img image.Image, width, height int) string {
-	bounds := img.Bounds()
-	var result strings.Builder
-
+func (r *ImageRenderer) renderHalfBlocksImproved(grid *CellGrid, img *image.RGBA, width, height int) {
 	for y := 0; y < height; y += 2 { // two image rows per terminal line
 		for x := 0; x < width; x++ {
-			topColorStruct := r.sampleArea(img, bounds, x, y, width, height)
+			topColorStruct := sampleAt(img, x, y)
 			var bottomColorStruct Color
 			if y+1 < height {
-				bottomColorStruct = r.sampleArea(img, bounds, x, y+1, width, height)
+				bottomColorStruct = sampleAt(img, x, y+1)
 			} else {
 				bottomColorStruct = topColorStruct
 			}
@@ -137,94 +327,51 @@ func (r *ImageRenderer) renderHalfBlocksImproved(img image.Image, width, height
 				bottomR, bottomG, bottomB = r.applySubtleDither(bottomR, bottomG, bottomB, x, y+1)
 			}
 
-			topANSI := RGBToANSI256(uint32(topR)<<8, uint32(topG)<<8, uint32(topB)<<8)
-			bottomANSI := RGBToANSI256(uint32(bottomR)<<8, uint32(bottomG)<<8, uint32(bottomB)<<8)
+			topCode := r.colorCode(topR, topG, topB)
+			bottomCode := r.colorCode(bottomR, bottomG, bottomB)
 
-			if topANSI == bottomANSI {
-				result.WriteString(fmt.Sprintf("\033[48;5;%dm \033[0m", topANSI))
+			if topCode == bottomCode {
+				grid.Set(x, y/2, Cell{Glyph: ' ', FG: NoColor, BG: topCode})
 			} else {
 				// '▀' (Upper Half Block) with fg for top, bg for bottom
-				result.WriteString(fmt.Sprintf("\033[38;5;%dm\033[48;5;%dm▀\033[0m", topANSI, bottomANSI))
+				grid.Set(x, y/2, Cell{Glyph: '▀', FG: topCode, BG: bottomCode})
 			}
 		}
-		result.WriteString("\n")
 	}
-	return result.String()
 }
 
-func (r *ImageRenderer) renderBraille(img image.Image, width, height int) string {
-	bounds := img.Bounds()
-	var result strings.Builder
-
-	brailleWidth := (width + 1) / 2
-	brailleHeight := (height + 3) / 4
-	if brailleWidth <= 0 {
-		brailleWidth = 1
-	}
-	if brailleHeight <= 0 {
-		brailleHeight = 1
-	}
-
-	for by := 0; by < brailleHeight; by++ {
-		for bx := 0; bx < brailleWidth; bx++ {
-			var pattern uint8
-			var avgR, avgG, avgB, count uint32
-
-			for py := 0; py < 4; py++ {
-				for px := 0; px < 2; px++ {
-					imgSampleX := bx*2 + px
-					imgSampleY := by*4 + py
-
-					if imgSampleX < width && imgSampleY < height {
-						sampledColor := r.sampleArea(img, bounds, imgSampleX, imgSampleY, width, height)
-
-						lum := 0.299*float64(sampledColor.R) + 0.587*float64(sampledColor.G) + 0.114*float64(sampledColor.B)
-						if lum > 128 { // 128 is a common mid-point threshold
-							pattern |= brailleDotMask(px, py)
-						}
-
-						avgR += uint32(sampledColor.R)
-						avgG += uint32(sampledColor.G)
-						avgB += uint32(sampledColor.B)
-						count++
-					}
-				}
-			}
-
-			var finalR8, finalG8, finalB8 uint8
-			if count > 0 {
-				finalR8 = uint8(avgR / count)
-				finalG8 = uint8(avgG / count)
-				finalB8 = uint8(avgB / count)
-			}
-
-			ansiColor := RGBToANSI256(uint32(finalR8)<<8, uint32(finalG8)<<8, uint32(finalB8)<<8)
-			brailleChar := 0x2800 + rune(pattern) // braille unicode block starts at U+2800
-
-			result.WriteString(fmt.Sprintf("\033[38;5;%dm%c\033[0m", ansiColor, brailleChar))
+// colorCode quantizes a color for the renderer's color depth. the result is
+// a palette index, or 0xRRGGBB in truecolor mode
+func (r *ImageRenderer) colorCode(r8, g8, b8 uint8) int {
+	switch r.ColorDepth {
+	case TrueColor:
+		return int(r8)<<16 | int(g8)<<8 | int(b8)
+	case Color16:
+		if r.ExactColors {
+			return RGBToANSI16(uint32(r8)<<8, uint32(g8)<<8, uint32(b8)<<8)
 		}
-		result.WriteString("\n")
+		return lookupANSI16(r8, g8, b8)
+	default:
+		return r.code256(r8, g8, b8)
 	}
-	return result.String()
 }
 
-func brailleDotMask(x, y int) uint8 {
-	// braille dot pattern:
-	// 1 (0x01) 4 (0x08)
-	// 2 (0x02) 5 (0x10)
-	// 3 (0x04) 6 (0x20)
-	// 7 (0x40) 8 (0x80)
-	dotMap := [2][4]uint8{
-		{0x01, 0x02, 0x04, 0x40}, // column 0
-		{0x08, 0x10, 0x20, 0x80}, // column 1
-	}
-	if x >= 0 && x < 2 && y >= 0 && y < 4 {
-		return dotMap[x][y]
+// code256 picks a 256 color palette index, from the lookup table unless
+// ExactColors is set
+func (r *ImageRenderer) code256(r8, g8, b8 uint8) int {
+	if r.ExactColors {
+		return RGBToANSI256(uint32(r8)<<8, uint32(g8)<<8, uint32(b8)<<8)
 	}
-	return 0
+	return lookupANSI256(r8, g8, b8)
 }
 
-func (r *ImageRenderer) sampleArea(img image.Image, bounds image.Rectangle, x, y, outWidth, outHeight int) Color {
+// sampleAt reads one sample from a scaled image
+func sampleAt(img *image.RGBA, x, y int) Color {
+	c := img.RGBAAt(x, y)
+	return Color{R: c.R, G: c.G, B: c.B}
+}
+
+func (r *ImageRenderer) sampleArea(img image.Image, bounds image.Rectangle, x, y, outWidth, outHeight int) color.RGBA {
 	srcX := float64(x) * float64(bounds.Dx()) / float64(outWidth)
 	srcY := float64(y) * float64(bounds.Dy()) / float64(outHeight)
 
@@ -244,16 +391,35 @@ func (r *ImageRenderer) sampleArea(img image.Image, bounds image.Rectangle, x, y
 		imgPixelY = bounds.Min.Y
 	}
 
-	pixel := img.At(imgPixelX, imgPixelY)
-	r32, g32, b32, _ := pixel.RGBA()
+	return pixelAt(img, imgPixelX, imgPixelY)
+}
 
-	return Color{R: uint8(r32 >> 8), G: uint8(g32 >> 8), B: uint8(b32 >> 8)}
+// pixelAt reads a premultiplied pixel. the common decoder outputs are read
+// directly, since going through img.At allocates for every pixel
+func pixelAt(img image.Image, x, y int) color.RGBA {
+	var r32, g32, b32, a32 uint32
+	switch src := img.(type) {
+	case *image.RGBA:
+		return src.RGBAAt(x, y)
+	case *image.NRGBA:
+		r32, g32, b32, a32 = src.NRGBAAt(x, y).RGBA()
+	case *image.YCbCr:
+		r32, g32, b32, a32 = src.YCbCrAt(x, y).RGBA()
+	case *image.Paletted:
+		r32, g32, b32, a32 = src.Palette[src.ColorIndexAt(x, y)].RGBA()
+	default:
+		r32, g32, b32, a32 = img.At(x, y).RGBA()
+	}
+	return color.RGBA{R: uint8(r32 >> 8), G: uint8(g32 >> 8), B: uint8(b32 >> 8), A: uint8(a32 >> 8)}
 }
 
 func (r *ImageRenderer) applySubtleDither(r8, g8, b8 uint8, x, y int) (uint8, uint8, uint8) {
-	if !r.UseDither {
+	if !r.UseDither || r.Dither == DitherNone {
 		return r8, g8, b8
 	}
+	if r.Dither == DitherBlueNoise {
+		return r.applyBlueNoise(r8, g8, b8, x, y)
+	}
 	matrix := [2][2]int8{
 		{-2, 0},
 		{1, -1},
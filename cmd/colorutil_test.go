@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// a circle should come out round in every mode: the rendered cells, at the
+// renderer's cell aspect, keep the image's shape
+func TestSampleSizeKeepsAspect(t *testing.T) {
+	sizes := [][2]int{{400, 400}, {640, 360}, {300, 900}}
+	for _, mode := range goldenModes {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%s/%dx%d", mode, size[0], size[1]), func(t *testing.T) {
+				r := NewDeterministicRenderer(mode)
+				cols, rows := r.cellSize(r.sampleSize(size[0], size[1]))
+				if cols > r.MaxWidth || rows > r.MaxHeight {
+					t.Fatalf("%dx%d cells don't fit in %dx%d", cols, rows, r.MaxWidth, r.MaxHeight)
+				}
+
+				// in cell widths, a row is 1/AspectRatio tall
+				got := float64(cols) * r.AspectRatio / float64(rows)
+				want := float64(size[0]) / float64(size[1])
+				// a render can be off by rounding to whole cells
+				slack := want * (1/float64(cols) + 1/float64(rows))
+				if math.Abs(got-want) > slack {
+					t.Errorf("%dx%d cells have aspect %.3f, want %.3f", cols, rows, got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestBrailleUsesDotResolution(t *testing.T) {
+	r := NewDeterministicRenderer(BrailleMode)
+	width, height := r.sampleSize(400, 400)
+	if height != r.MaxHeight*4 {
+		t.Errorf("a square image is %d dots tall, want the full %d", height, r.MaxHeight*4)
+	}
+	if width != height {
+		t.Errorf("a square image is %dx%d dots, want square dots", width, height)
+	}
+}
+
+func TestExactFitFillsRegion(t *testing.T) {
+	img := loadFixture(t, "gradient.png")
+	blank := Cell{Glyph: ' ', FG: NoColor, BG: NoColor}
+	for _, mode := range goldenModes {
+		r := NewDeterministicRenderer(mode)
+		r.MaxWidth, r.MaxHeight = 30, 7
+		inner := r.Rasterize(img)
+		left, top := (30-inner.Width)/2, (7-inner.Height)/2
+
+		r.ExactFit = true
+		fr := NewFrameRenderer(r)
+		for i := 0; i < 2; i++ { // the second frame pads the reused grid
+			grid := fr.RasterizeFrame(img)
+			if grid.Width != 30 || grid.Height != 7 {
+				t.Fatalf("%s: got %dx%d cells, want 30x7", mode, grid.Width, grid.Height)
+			}
+			for y := 0; y < grid.Height; y++ {
+				for x := 0; x < grid.Width; x++ {
+					want := blank
+					if x >= left && x < left+inner.Width && y >= top && y < top+inner.Height {
+						want = inner.At(x-left, y-top)
+					}
+					if got := grid.At(x, y); got != want {
+						t.Fatalf("%s frame %d: cell %d,%d is %v, want %v", mode, i, x, y, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeInline(t *testing.T) {
+	grid := NewCellGrid(2, 1, Color256)
+	grid.Cells[0] = Cell{Glyph: '▀', FG: 196, BG: 21}
+	grid.Cells[1] = Cell{Glyph: '#', FG: 196, BG: 21}
+
+	for _, tc := range []struct {
+		format inlineFormat
+		want   string
+	}{
+		{inlineANSI, "\033[38;5;196m\033[48;5;21m▀#\033[0m"},
+		{inlineZsh, "%{\033[38;5;196m%}%{\033[48;5;21m%}▀#%{\033[0m%}"},
+		{inlineBash, "\x01\033[38;5;196m\x02\x01\033[48;5;21m\x02▀#\x01\033[0m\x02"},
+		{inlineTmux, "#[fg=colour196,bg=colour21]▀###[default]"},
+	} {
+		if got := grid.EncodeInline(tc.format); got != tc.want {
+			t.Errorf("format %d: got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	comicRTL    bool
+	comicSpread bool
+	comicPage   int
+	comicColors string
+)
+
+var comicCmd = &cobra.Command{
+	Use:   "comic [book.cbz]",
+	Short: "Read a comic book archive (CBZ, CBR, CBT) page by page",
+	Long: `Page through the images of a comic book archive, or any zip, rar or tar
+archive and directory of images, in reading order:
+
+  termuwu comic book.cbz
+  termuwu comic manga.cbr --rtl --spread
+
+→ and Space go to the next page, ← to the previous one (the other way
+around with --rtl, for manga), ↑ and ↓ jump 10 pages, d switches between
+single pages and two-page spreads and q quits. The page you stop at is
+remembered for each book and reading starts there next time, unless --page
+says otherwise. Without a terminal on stdin the page is drawn once.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		depth, err := parseColorDepth(comicColors)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		book, err := openComic(args[0])
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error opening comic:"), err)
+			return
+		}
+		page := book.lastPage()
+		if comicPage > 0 {
+			page = min(comicPage, len(book.pages)) - 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		keys, restore := startControls(ctx)
+		defer restore()
+
+		renderer := configureRenderer(false, false, false, 0, 0)
+		renderer.ColorDepth = depth
+		renderer.MaxHeight-- // room for the page line
+		if err := fitGlyphs(renderer); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+			return
+		}
+		if keys != nil {
+			fmt.Print("\033[?1049h" + hideCursor) // the alternate screen, to leave the shell as it was
+			defer fmt.Print(showCursor + "\033[?1049l")
+		}
+
+		spread := comicSpread
+		for {
+			page = spreadStart(page, spread)
+			shown := []int{page}
+			if spread && page > 0 && page+1 < len(book.pages) {
+				shown = append(shown, page+1) // the cover stays on its own
+			}
+			output, err := book.render(renderer, shown, comicRTL)
+			if keys != nil {
+				fmt.Print("\033[H\033[2J")
+			}
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading page:"), err)
+			} else {
+				fmt.Print(output)
+			}
+			numbers := fmt.Sprint(shown[0] + 1)
+			if len(shown) == 2 {
+				numbers += "-" + fmt.Sprint(shown[1]+1)
+			}
+			fmt.Printf("%s %s/%d", infoColor(path.Base(book.pages[shown[0]])), numbers, len(book.pages))
+			book.saveLastPage(shown[0])
+			if keys == nil {
+				fmt.Println()
+				return
+			}
+			fmt.Print("  " + dimColor(tr("←/→ page  d spread  q quit")))
+
+			var key playKey
+			select {
+			case key = <-keys:
+			case <-ctx.Done():
+				return
+			}
+			next, previous := keyRight, keyLeft
+			if comicRTL {
+				next, previous = keyLeft, keyRight
+			}
+			step := len(shown)
+			switch key {
+			case keyQuit:
+				return
+			case next, keyPause, keyStep:
+				page = min(page+step, len(book.pages)-1)
+			case previous, keyStepBack:
+				page = max(page-1, 0) // spreadStart finds the spread it's in
+			case keyDown:
+				page = min(page+10, len(book.pages)-1)
+			case keyUp:
+				page = max(page-10, 0)
+			case keySpread:
+				spread = !spread
+			}
+		}
+	},
+}
+
+// spreadStart is the first page of the spread page is on: the cover alone,
+// then pages 2-3, 4-5 and so on (counting from 1)
+func spreadStart(page int, spread bool) int {
+	if !spread || page == 0 {
+		return page
+	}
+	return page - (page+1)%2
+}
+
+// comicBook is an archive or directory of pages in reading order
+type comicBook struct {
+	path  string
+	pages []string            // entry names, or paths under a directory
+	cache map[int]image.Image // the pages around the current one
+}
+
+// openComic lists the pages of a book, ordered by name with numbers
+// compared by value, so page2 comes before page10
+func openComic(source string) (*comicBook, error) {
+	book := &comicBook{path: source, cache: make(map[int]image.Image)}
+	err := walkImages(source, func(entry archiveEntry) error {
+		book.pages = append(book.pages, entry.Name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(book.pages) == 0 {
+		return nil, fmt.Errorf("no pages in %s", source)
+	}
+	sort.SliceStable(book.pages, func(i, j int) bool { return naturalLess(book.pages[i], book.pages[j]) })
+	return book, nil
+}
+
+// page decodes page i, keeping the few pages around it for paging back
+// and forth
+func (b *comicBook) page(i int) (image.Image, error) {
+	if img, ok := b.cache[i]; ok {
+		return img, nil
+	}
+	file, err := openInDir(b.path, b.pages[i])
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := DecodeImage(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.pages[i], err)
+	}
+	for cached := range b.cache {
+		if cached < i-2 || cached > i+3 {
+			delete(b.cache, cached)
+		}
+	}
+	b.cache[i] = img
+	return img, nil
+}
+
+// render draws the pages side by side, the first on the right with rtl
+func (b *comicBook) render(renderer *ImageRenderer, pages []int, rtl bool) (string, error) {
+	if len(pages) == 1 {
+		img, err := b.page(pages[0])
+		if err != nil {
+			return "", err
+		}
+		return renderer.RenderImage(img), nil
+	}
+	const gap = 1
+	half := *renderer
+	half.MaxWidth = (renderer.MaxWidth - gap) / 2
+	if rtl {
+		pages = []int{pages[1], pages[0]}
+	}
+	var columns [][]string
+	rows := 0
+	for _, i := range pages {
+		img, err := b.page(i)
+		if err != nil {
+			return "", err
+		}
+		grid := half.Rasterize(img)
+		var lines []string
+		for _, line := range strings.Split(grid.Styled(), "\n") {
+			lines = append(lines, strings.Repeat(" ", half.MaxWidth-grid.Width)+line) // pages meet in the middle
+		}
+		columns = append(columns, lines)
+		rows = max(rows, len(lines))
+	}
+	// the right page starts at the gutter, so its padding goes after it
+	for j, line := range columns[1] {
+		trimmed := strings.TrimLeft(line, " ")
+		columns[1][j] = trimmed + strings.Repeat(" ", len(line)-len(trimmed))
+	}
+	var out strings.Builder
+	for y := 0; y < rows; y++ {
+		for i, column := range columns {
+			if i > 0 {
+				out.WriteString(strings.Repeat(" ", gap))
+			}
+			if y < len(column) {
+				out.WriteString(column[y])
+			} else {
+				out.WriteString(strings.Repeat(" ", half.MaxWidth))
+			}
+		}
+		out.WriteString("\n")
+	}
+	return out.String(), nil
+}
+
+// comicPagesPath is where the last page read of every book is kept
+func comicPagesPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "termuwu", "comics.json"), nil
+}
+
+// key names a book in comics.json by its absolute path
+func (b *comicBook) key() string {
+	if abs, err := filepath.Abs(b.path); err == nil {
+		return abs
+	}
+	return b.path
+}
+
+func loadComicPages() map[string]int {
+	pages := make(map[string]int)
+	if path, err := comicPagesPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			json.Unmarshal(data, &pages)
+		}
+	}
+	return pages
+}
+
+// lastPage is where reading stopped last time, from 0
+func (b *comicBook) lastPage() int {
+	page := loadComicPages()[b.key()]
+	return max(min(page, len(b.pages)-1), 0)
+}
+
+// saveLastPage remembers page for next time, quietly giving up when the
+// cache can't be written
+func (b *comicBook) saveLastPage(page int) {
+	path, err := comicPagesPath()
+	if err != nil {
+		return
+	}
+	pages := loadComicPages()
+	pages[b.key()] = page
+	data, err := json.MarshalIndent(pages, "", "    ")
+	if err != nil || os.MkdirAll(filepath.Dir(path), 0o755) != nil {
+		return
+	}
+	os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// naturalLess orders names case-insensitively, comparing runs of digits by
+// their value
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		ra, rb := unicode.ToLower(rune(a[0])), unicode.ToLower(rune(b[0]))
+		if ra != rb {
+			return ra < rb
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+func init() {
+	rootCmd.AddCommand(comicCmd)
+
+	comicCmd.Flags().BoolVar(&comicRTL, "rtl", false, "Read right to left, for manga: ← turns to the next page and spreads start on the right.")
+	comicCmd.Flags().BoolVar(&comicSpread, "spread", false, "Show two pages side by side, the cover on its own.")
+	comicCmd.Flags().IntVar(&comicPage, "page", 0, "Start at this page (from 1) instead of where you left off.")
+	comicCmd.Flags().StringVar(&comicColors, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	comparePresetSave string
+	compareWithSixel  bool
+)
+
+var compareToolsCmd = &cobra.Command{
+	Use:   "compare-tools [image_path_or_url]",
+	Short: "Render an image with presets that look like chafa, timg and viu, side by side",
+	Long: `Render the same image with a few presets mimicking other terminal image
+viewers, side by side, to see what looks best in your terminal and font:
+
+  termuwu compare-tools photo.jpg
+  termuwu compare-tools photo.jpg --sixel
+  termuwu compare-tools --save braille
+
+--save stores the preset in the config, show and play start from it (flags
+given on the command line still win). sixel output can't share a row with
+text, it's drawn below the others with --sixel.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if comparePresetSave != "" {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		nameColor := themeColor("name", color.FgMagenta, color.Bold).SprintFunc()
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		var save Preset
+		if comparePresetSave != "" {
+			var found bool
+			if save, found = lookupPreset(comparePresetSave); !found {
+				fmt.Fprintf(statusOut, tr("%s %q (use %s)\n"), errorColor("❌ Unknown preset:"), comparePresetSave, strings.Join(presetNames(), ", "))
+				return
+			}
+		}
+
+		if len(args) == 1 {
+			img, _, err := loadImage(args[0])
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+
+			var textPresets []Preset
+			for _, p := range toolPresets {
+				if p.Flags["protocol"] == "" {
+					textPresets = append(textPresets, p)
+				}
+			}
+			const gap = 2
+			frame := configureRenderer(false, false, false, 0, 0)
+			width := (frame.MaxWidth - gap*(len(textPresets)-1)) / len(textPresets)
+			height := frame.MaxHeight - 2 // the labels
+			if compareWithSixel {
+				height /= 2
+			}
+
+			columns := make([][]string, len(textPresets))
+			rows := 0
+			for i, p := range textPresets {
+				renderer, err := p.renderer(width, height)
+				if err != nil {
+					fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error rendering image:"), err)
+					return
+				}
+				grid := renderer.Rasterize(img)
+				name, about := p.Name[:min(len(p.Name), width)], p.About[:min(len(p.About), width)]
+				column := []string{
+					nameColor(name) + strings.Repeat(" ", width-len(name)),
+					dimColor(about) + strings.Repeat(" ", width-len(about)),
+				}
+				column = append(column, strings.Split(grid.Styled(), "\n")...)
+				for j := 2; j < len(column); j++ {
+					column[j] += strings.Repeat(" ", width-grid.Width)
+				}
+				columns[i] = column
+				rows = max(rows, len(column))
+			}
+			for y := 0; y < rows; y++ {
+				cells := make([]string, len(columns))
+				for i, column := range columns {
+					if y < len(column) {
+						cells[i] = column[y]
+					} else {
+						cells[i] = strings.Repeat(" ", width)
+					}
+				}
+				fmt.Println(strings.Join(cells, strings.Repeat(" ", gap)))
+			}
+
+			sixel, _ := lookupPreset("sixel")
+			if compareWithSixel {
+				backend, _ := LookupBackend("sixel")
+				renderer := configureRenderer(false, false, false, frame.MaxWidth, height)
+				output, err := backend.Render(img, renderer)
+				if err != nil {
+					fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error rendering image:"), err)
+					return
+				}
+				fmt.Printf("%s  %s\n%s", nameColor(sixel.Name), dimColor(sixel.About), output)
+			} else {
+				fmt.Fprintf(statusOut, "💡 %s\n", infoColor("Add --sixel to see the sixel preset too, if your terminal shows sixel graphics"))
+			}
+		}
+
+		if save.Name == "" {
+			fmt.Fprintf(statusOut, "💡 %s\n", infoColor("Keep one with --save NAME, show and play will start from it"))
+			return
+		}
+		path := configPath
+		if path == "" {
+			path = defaultConfigPath()
+		}
+		if err := saveConfigValue(path, "preset", save.Name); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error saving preset:"), err)
+			return
+		}
+		fmt.Fprintf(statusOut, tr("✅ %s %s in %s\n"), successColor("Saved preset"), save.Name, path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareToolsCmd)
+
+	compareToolsCmd.Flags().StringVar(&comparePresetSave, "save", "", "Store this preset in the config for show and play: "+strings.Join(presetNames(), ", ")+".")
+	compareToolsCmd.Flags().BoolVar(&compareWithSixel, "sixel", false, "Also draw the sixel preset, below the text ones.")
+}
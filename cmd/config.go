@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config is the optional termuwu config file. it lives at
+// $XDG_CONFIG_HOME/termuwu/config.json (or the OS equivalent) unless
+// --config points somewhere else
+type Config struct {
+	Preset   string                       `json:"preset"`  // the Preset show and play start from
+	Presets  map[string]map[string]string `json:"presets"` // your own presets: name to flags and their values
+	Glyphs   GlyphConfig                  `json:"glyphs"`
+	Theme    ThemeConfig                  `json:"theme"`
+	Hooks    HookConfig                   `json:"hooks"`
+	Describe DescribeConfig               `json:"describe"`
+	Caption  CaptionConfig                `json:"caption"`
+	Grafana  GrafanaConfig                `json:"grafana"`
+}
+
+// DescribeConfig sets up --describe. with a caption endpoint the caption
+// comes from that model, see requestCaption
+type DescribeConfig struct {
+	CaptionEndpoint string `json:"caption_endpoint"`
+}
+
+// CaptionConfig holds what the caption models need: the endpoint the
+// caption command uses by default, API keys, hosts, the prompt and how
+// long to wait (a duration like "90s")
+type CaptionConfig struct {
+	Endpoint      string `json:"endpoint"`
+	OpenAIKey     string `json:"openai_api_key"`
+	OpenAIBaseURL string `json:"openai_base_url"`
+	OllamaHost    string `json:"ollama_host"`
+	Prompt        string `json:"prompt"`
+	Timeout       string `json:"timeout"`
+}
+
+// GlyphConfig lists the glyph sets the terminal font can't show, so show
+// and play draw without them. with Probe the terminal is asked on every run
+// too, see probeGlyphs
+type GlyphConfig struct {
+	Missing []string `json:"missing"`
+	Probe   bool     `json:"probe"`
+}
+
+// HookConfig holds shell commands run around a render, see runHook
+type HookConfig struct {
+	PreFetch   string `json:"pre_fetch"`
+	PostRender string `json:"post_render"`
+}
+
+var (
+	configPath string
+	config     Config
+)
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "termuwu", "config.json")
+}
+
+// loadConfig reads the config file. a missing file just means defaults,
+// unless the path was given explicitly
+func loadConfig(path string, explicit bool) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("couldn't read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("couldn't parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// saveConfigValue sets one top-level key in the config file, creating it if
+// needed. the other keys keep their values, but the file is rewritten with
+// its keys sorted and indented. a new file is only readable by its owner,
+// it holds API keys and tokens
+func saveConfigValue(path, key string, value any) error {
+	if path == "" {
+		return errors.New("no config directory on this system, use --config")
+	}
+	values := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("couldn't read config: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("couldn't parse config %s: %w", path, err)
+		}
+	}
+	if values[key], err = json.Marshal(value); err != nil {
+		return err
+	}
+	if data, err = json.MarshalIndent(values, "", "    "); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
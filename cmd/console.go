@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// consoleInfo describes what the attached console can handle
+type consoleInfo struct {
+	// legacy consoles (old Windows conhost) can't interpret escape
+	// sequences, so output has to go through the console API with 16
+	// colors and plain ASCII glyphs
+	legacy bool
+}
+
+// terminalSize asks the terminal on stdout for its size, falling back to
+// the platform console API (the visible window on Windows)
+func terminalSize() (width, height int, ok bool) {
+	if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 && h > 0 {
+		return w, h, true
+	}
+	return consoleSize()
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package cmd
+
+import "errors"
+
+// setupConsole is a no-op outside Windows, every terminal speaks VT
+func setupConsole() consoleInfo {
+	return consoleInfo{}
+}
+
+func consoleSize() (width, height int, ok bool) {
+	return 0, 0, false
+}
+
+func writeLegacyConsole(grid *CellGrid) error {
+	return errors.New("legacy console output is only available on Windows")
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+var procSetConsoleTextAttribute = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetConsoleTextAttribute")
+
+// setupConsole turns on VT sequence processing and UTF-8 output. when VT
+// can't be enabled we're on a legacy conhost (before Windows 10) that shows
+// escape sequences as garbage
+func setupConsole() consoleInfo {
+	out := windows.Handle(os.Stdout.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(out, &mode); err != nil {
+		return consoleInfo{} // redirected to a file or pipe, nothing to set up
+	}
+	windows.SetConsoleOutputCP(65001) // UTF-8
+
+	if err := windows.SetConsoleMode(out, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		return consoleInfo{legacy: os.Getenv("WT_SESSION") == ""}
+	}
+	return consoleInfo{}
+}
+
+// consoleSize reads the visible window of the console screen buffer through
+// CONOUT$, which works even when stdout is redirected
+func consoleSize() (width, height int, ok bool) {
+	name, _ := windows.UTF16PtrFromString("CONOUT$")
+	handle, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer windows.CloseHandle(handle)
+
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(handle, &info); err != nil {
+		return 0, 0, false
+	}
+	width = int(info.Window.Right-info.Window.Left) + 1
+	height = int(info.Window.Bottom-info.Window.Top) + 1
+	return width, height, true
+}
+
+// console attribute bits for the 16 ANSI colors, ANSI orders them
+// R-G-B while the console API uses B-G-R
+var consoleColorBits = [16]uint16{0, 4, 2, 6, 1, 5, 3, 7, 8, 12, 10, 14, 9, 13, 11, 15}
+
+// writeLegacyConsole draws a 16 color grid through the console API instead
+// of escape sequences
+func writeLegacyConsole(grid *CellGrid) error {
+	out := windows.Handle(os.Stdout.Fd())
+
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(out, &info); err != nil {
+		return err
+	}
+	defer procSetConsoleTextAttribute.Call(uintptr(out), uintptr(info.Attributes))
+
+	for y := 0; y < grid.Height; y++ {
+		for _, c := range grid.Row(y) {
+			c = c.opaque()
+			attr := info.Attributes
+			if c.FG != NoColor {
+				attr = attr&^0x0f | consoleColorBits[c.FG&0x0f]
+			}
+			if c.BG != NoColor {
+				attr = attr&^0xf0 | consoleColorBits[c.BG&0x0f]<<4
+			}
+			procSetConsoleTextAttribute.Call(uintptr(out), uintptr(attr))
+			if err := writeConsoleString(out, string(c.Glyph)); err != nil {
+				return err
+			}
+		}
+		procSetConsoleTextAttribute.Call(uintptr(out), uintptr(info.Attributes))
+		if err := writeConsoleString(out, "\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeConsoleString(out windows.Handle, s string) error {
+	buf := utf16.Encode([]rune(s))
+	var written uint32
+	return windows.WriteConsole(out, &buf[0], uint32(len(buf)), &written, nil)
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+// playKey is a key that controls playback
+type playKey int
+
+const (
+	keyPause playKey = iota + 1
+	keyStep
+	keyStepBack
+	keyQuit
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keySpread // comic's single page and spread switch
+)
+
+// seekKeys are the arrow keys and how far they jump
+var seekKeys = map[playKey]time.Duration{
+	keyLeft:  -5 * time.Second,
+	keyRight: 5 * time.Second,
+	keyDown:  -time.Minute,
+	keyUp:    time.Minute,
+}
+
+// parseKeys picks the playback keys out of what the terminal sent. arrows
+// come as ESC [ A-D, or ESC O A-D in application cursor mode; anything
+// else is ignored
+func parseKeys(p []byte) []playKey {
+	var keys []playKey
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case ' ':
+			keys = append(keys, keyPause)
+		case '.':
+			keys = append(keys, keyStep)
+		case ',':
+			keys = append(keys, keyStepBack)
+		case 'q', 'Q':
+			keys = append(keys, keyQuit)
+		case 'd', 'D':
+			keys = append(keys, keySpread)
+		case 0x1b:
+			if i+2 >= len(p) || p[i+1] != '[' && p[i+1] != 'O' {
+				continue
+			}
+			switch p[i+2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			case 'C':
+				keys = append(keys, keyRight)
+			case 'D':
+				keys = append(keys, keyLeft)
+			}
+			i += 2
+		}
+	}
+	return keys
+}
+
+// startControls reads playback keys from the terminal on stdin until ctx
+// is done. keys is nil when stdin isn't a terminal, restore puts the
+// terminal back the way it was
+func startControls(ctx context.Context) (keys <-chan playKey, restore func()) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, func() {}
+	}
+	state, err := term.GetState(fd)
+	if err != nil {
+		return nil, func() {}
+	}
+	if err := cbreakMode(fd); err != nil {
+		return nil, func() {}
+	}
+
+	ch := make(chan playKey, 8)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			for _, key := range parseKeys(buf[:n]) {
+				select {
+				case ch <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch, func() { term.Restore(fd, state) }
+}
+
+// seekableSource is a frameSource playback can pause and jump around in
+type seekableSource interface {
+	// position is where the frame last returned by next starts, length how
+	// long the whole thing runs, 0 for live streams
+	position() time.Duration
+	length() time.Duration
+	// previousFrame is where the frame before the last one starts
+	previousFrame() time.Duration
+	// seek makes next continue with the frame showing at to
+	seek(to time.Duration)
+	// pause stops decoding in the background until next is called again,
+	// which continues after the last frame
+	pause()
+}
+
+// wrappedSource is a frameSource that adds to another one
+type wrappedSource interface {
+	unwrap() frameSource
+}
+
+// findSource looks through wrapped sources for one that's a T
+func findSource[T any](source frameSource) (T, bool) {
+	for {
+		if found, ok := source.(T); ok {
+			return found, true
+		}
+		wrapped, ok := source.(wrappedSource)
+		if !ok {
+			var none T
+			return none, false
+		}
+		source = wrapped.unwrap()
+	}
+}
+
+// findSeeker returns the source that can seek, or nil
+func findSeeker(source frameSource) seekableSource {
+	seeker, _ := findSource[seekableSource](source)
+	return seeker
+}
+
+// appendProgress draws the line below the frame: whether it's playing, the
+// position and a bar through the length, or just "live" for streams
+func appendProgress(buf []byte, width int, paused bool, seeker seekableSource) []byte {
+	buf = append(buf, "\r\033[K"...)
+	state := "▶ "
+	switch {
+	case paused && plainChrome:
+		state = "|| "
+	case paused:
+		state = "⏸ "
+	case plainChrome:
+		state = "> "
+	}
+	if seeker == nil || seeker.length() <= 0 {
+		return append(append(buf, state+"live"...), '\n')
+	}
+
+	position, length := min(seeker.position(), seeker.length()), seeker.length()
+	left := state + formatClock(position) + " "
+	right := " " + formatClock(length)
+	buf = append(buf, left...)
+	chars := progressTheme(ProgressTheme{Filled: "━", Head: "━", Empty: "─"})
+	ends := runewidth.StringWidth(chars.Start) + runewidth.StringWidth(chars.End)
+	if bar := width - runewidth.StringWidth(left) - runewidth.StringWidth(right) - ends; bar > 0 {
+		filled := int(int64(bar) * int64(position) / int64(length))
+		buf = append(buf, chars.Start...)
+		if filled > 0 {
+			buf = append(buf, strings.Repeat(chars.Filled, filled-1)...)
+			buf = append(buf, chars.Head...)
+		}
+		buf = append(buf, strings.Repeat(chars.Empty, bar-filled)...)
+		buf = append(buf, chars.End...)
+	}
+	buf = append(buf, right...)
+	return append(buf, '\n')
+}
+
+// formatClock writes d as m:ss, or h:mm:ss from an hour on
+func formatClock(d time.Duration) string {
+	seconds := int(d / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,462 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var dashboardColors string
+
+// dashboardDefaultRefresh is how often a still image is fetched again when
+// neither the pane nor the file says
+const dashboardDefaultRefresh = 10 * time.Second
+
+// dashboardConfig is the YAML file a dashboard is laid out in
+type dashboardConfig struct {
+	Refresh time.Duration   `yaml:"refresh"` // for the panes that don't set their own
+	Panes   []dashboardPane `yaml:"panes"`
+}
+
+// dashboardPane is one image or stream on the dashboard
+type dashboardPane struct {
+	Source  string        `yaml:"source"`
+	Title   string        `yaml:"title"`
+	Region  string        `yaml:"region"` // x,y,w,h in cells or percent of the screen, empty to be tiled
+	Refresh time.Duration `yaml:"refresh"`
+}
+
+// title is what the pane's top line says
+func (p dashboardPane) title() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return path.Base(p.Source)
+}
+
+func loadDashboard(file string) (*dashboardConfig, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cfg dashboardConfig
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true) // a misspelled key should say so, not be ignored
+	if err := decoder.Decode(&cfg); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(cfg.Panes) == 0 {
+		return nil, fmt.Errorf("%s has no panes", file)
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = dashboardDefaultRefresh
+	}
+	for i := range cfg.Panes {
+		pane := &cfg.Panes[i]
+		if pane.Source == "" {
+			return nil, fmt.Errorf("pane %d has no source", i+1)
+		}
+		if pane.Refresh < 0 {
+			return nil, fmt.Errorf("pane %d: negative refresh %v", i+1, pane.Refresh)
+		}
+		if pane.Refresh == 0 {
+			pane.Refresh = cfg.Refresh
+		}
+	}
+	return &cfg, nil
+}
+
+// layoutPanes places the panes on a screen of cols x rows cells: at their
+// region when they have one, the rest tiled in a grid as square as it gets
+func layoutPanes(panes []dashboardPane, cols, rows int) ([]image.Rectangle, error) {
+	screen := image.Rect(0, 0, cols, rows)
+	tiled := 0
+	for _, pane := range panes {
+		if pane.Region == "" {
+			tiled++
+		}
+	}
+	across := max(1, int(math.Ceil(math.Sqrt(float64(tiled)))))
+	down := max(1, (tiled+across-1)/across)
+
+	regions := make([]image.Rectangle, len(panes))
+	tile := 0
+	for i, pane := range panes {
+		if pane.Region == "" {
+			x, y := tile%across, tile/across
+			regions[i] = image.Rect(x*cols/across, y*rows/down, (x+1)*cols/across, (y+1)*rows/down)
+			tile++
+			continue
+		}
+		region, err := parseCellRegion(pane.Region, cols, rows)
+		if err != nil {
+			return nil, fmt.Errorf("pane %d: %w", i+1, err)
+		}
+		if regions[i] = region.Intersect(screen); regions[i].Dx() < 1 || regions[i].Dy() < 2 {
+			return nil, fmt.Errorf("pane %d: region %s leaves no room on a %dx%d screen", i+1, pane.Region, cols, rows)
+		}
+	}
+	return regions, nil
+}
+
+// parseCellRegion reads "x,y,w,h" in cells, where every number can also be
+// a percentage of the screen's cols (x and w) or rows (y and h)
+func parseCellRegion(value string, cols, rows int) (image.Rectangle, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("region %q isn't x,y,w,h", value)
+	}
+	var n [4]int
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		size := cols
+		if i%2 == 1 {
+			size = rows
+		}
+		percent := strings.HasSuffix(part, "%")
+		v, err := strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64)
+		if err != nil || v < 0 {
+			return image.Rectangle{}, fmt.Errorf("region %q: %q isn't a number of cells or a percentage", value, part)
+		}
+		if percent {
+			v = v * float64(size) / 100
+		}
+		n[i] = int(math.Round(v))
+	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
+
+// dashboardUpdate is a new render of a pane, or the error getting it failed with
+type dashboardUpdate struct {
+	pane int
+	grid *CellGrid
+	at   time.Time // when a still image was fetched, zero for stream frames
+	err  error
+}
+
+// runPane renders pane into updates until ctx is done: every frame of a
+// stream, or the image again every Refresh and whenever refresh says so
+func runPane(ctx context.Context, index int, pane dashboardPane, r *ImageRenderer, refresh <-chan struct{}, updates chan<- dashboardUpdate) {
+	send := func(update dashboardUpdate) bool {
+		update.pane = index
+		select {
+		case updates <- update:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	sleep := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+		case <-refresh:
+		case <-ctx.Done():
+			return false
+		}
+		return true
+	}
+
+	for {
+		img, source, err := fetchPane(ctx, pane)
+		if err == nil && source != nil {
+			err = streamPane(ctx, source, r, func(grid *CellGrid) bool { return send(dashboardUpdate{grid: grid}) })
+		} else if err == nil {
+			if !send(dashboardUpdate{grid: r.rasterizeInto(getGrid(), r.Scale(img)), at: time.Now()}) {
+				return
+			}
+		}
+		if err != nil && ctx.Err() == nil && !send(dashboardUpdate{err: err}) {
+			return
+		}
+		if !sleep(pane.Refresh) {
+			return
+		}
+	}
+}
+
+// fetchPane loads pane's source: a still image, or a frameSource for a
+// stream (RTSP, HLS, fifo:PATH or MJPEG over HTTP)
+func fetchPane(ctx context.Context, pane dashboardPane) (image.Image, frameSource, error) {
+	switch {
+	case strings.HasPrefix(pane.Source, "fifo:"):
+		open, reopen, err := parseStreamInput(pane.Source)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, newStreamSource(ctx, open, streamAuto, reopen, 0), nil
+	case needsFFmpeg(pane.Source):
+		return nil, newVideoSource(ctx, pane.Source, 0, 0, 0, false), nil
+	case strings.HasPrefix(pane.Source, "http://") || strings.HasPrefix(pane.Source, "https://"):
+		// fetched directly, without the download chatter and progress bar
+		resp, err := httpGet(ctx, pane.Source)
+		if err != nil {
+			return nil, nil, err
+		}
+		if multipartBoundary(resp) != "" {
+			return nil, newStreamSource(ctx, mjpegOpener(ctx, pane.Source, resp), streamAuto, reopenOnError, 0), nil
+		}
+		defer resp.Body.Close()
+		img, _, err := DecodeImage(resp.Body)
+		return img, nil, err
+	}
+	img, _, err := loadImage(pane.Source)
+	return img, nil, err
+}
+
+// streamPane renders the frames of source until it ends, handing them to
+// show until it says to stop. the grids are from the pool, for whoever
+// draws them to hand back
+func streamPane(ctx context.Context, source frameSource, r *ImageRenderer, show func(*CellGrid) bool) error {
+	if video, ok := source.(*videoSource); ok {
+		defer video.close()
+	}
+	var scaled *image.RGBA // reused, a frame is rasterized before the next is scaled
+	for {
+		img, _, err := source.next()
+		if err != nil {
+			if err == io.EOF && ctx.Err() == nil {
+				return errors.New("the stream ended")
+			}
+			return err
+		}
+		scaled = r.scaleInto(scaled, img)
+		if !show(r.rasterizeInto(getGrid(), scaled)) {
+			return ctx.Err()
+		}
+	}
+}
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard [config.yaml]",
+	Short: "Show several images and streams on one screen, each refreshed on its own",
+	Long: `Lay out the images and streams listed in a YAML file on one screen,
+fetching every image again on its own schedule and drawing stream frames as
+they come. Only the cells that changed are sent, which keeps a wall of
+camera feeds and charts usable over SSH:
+
+  refresh: 30s              # for the panes that don't set their own
+  panes:
+    - source: https://example.com/weather.png
+      title: Weather
+      region: 0,0,50%,100%   # x,y,w,h in cells or percent of the screen
+      refresh: 10m
+    - source: rtsp://camera.local/stream
+      region: 50%,0,50%,50%
+    - source: fifo:/tmp/cpu-chart
+
+Panes without a region are tiled over the screen. Sources are anything show
+takes, RTSP and HLS streams, MJPEG over HTTP and fifo:PATH image streams.
+
+Space fetches every image again right away and q quits. SIGHUP reads the
+file again; under systemd the dashboard reports READY=1, reloads and
+watchdog pings with sd_notify.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		depth, err := parseColorDepth(dashboardColors)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		cfg, err := loadDashboard(args[0])
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading dashboard:"), err)
+			return
+		}
+		cols, rows := dashboardSize()
+		regions, err := layoutPanes(cfg.Panes, cols, rows)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid dashboard layout:"), err)
+			return
+		}
+		base := configureRenderer(useFullBlocks, useBraille, noDither, 0, 0)
+		base.ColorDepth = depth
+		if err := fitGlyphs(base); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+			return
+		}
+		if setupConsole().legacy {
+			fmt.Fprintln(statusOut, errorColor("❌ The dashboard needs a console with VT support (Windows 10 or later)."))
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+		keys, restore := startControls(ctx)
+		defer restore()
+		statusOut = io.Discard // the screen is the panes', failures show in their title lines
+
+		fmt.Print("\033[?1049h" + hideCursor)
+		defer fmt.Print(showCursor + "\033[?1049l")
+
+		// title draws a pane's top line: its name, then when it was last
+		// fetched or what went wrong
+		title := func(i int, at time.Time, err error) {
+			region := regions[i]
+			line := infoColor(runewidth.Truncate(cfg.Panes[i].title(), region.Dx(), "…"))
+			room := region.Dx() - runewidth.StringWidth(cfg.Panes[i].title()) - 1
+			switch {
+			case room <= 0:
+			case err != nil:
+				line += " " + errorColor(runewidth.Truncate(err.Error(), room, "…"))
+			case !at.IsZero():
+				line += " " + dimColor(runewidth.Truncate(at.Format("15:04:05"), room, ""))
+			}
+			buf := appendCursorPosition(nil, region.Min.Y+1, region.Min.X+1)
+			buf = append(buf, "\033[0m"...)
+			buf = append(buf, strings.Repeat(" ", region.Dx())...)
+			buf = appendCursorPosition(buf, region.Min.Y+1, region.Min.X+1)
+			fmt.Print(string(append(buf, line...)))
+		}
+		// hints draws the bottom line: the keys, and what went wrong reloading
+		hints := func(err error) {
+			line := ""
+			if keys != nil {
+				line = dimColor(tr("space refresh  q quit"))
+			}
+			if err != nil {
+				line += " " + errorColor(runewidth.Truncate(fmt.Sprintf("❌ %s %v", tr("Error reloading dashboard:"), err), cols-runewidth.StringWidth(line)-1, "…"))
+			}
+			fmt.Print(string(appendStatusLine(nil, rows+1, line)))
+		}
+
+		var (
+			updates   chan dashboardUpdate
+			screens   []*IncrementalRenderer
+			refreshes []chan struct{}
+			failed    []bool
+			stopPanes = func() {}
+		)
+		// start draws the dashboard afresh and starts fetching its panes, in
+		// place of the ones running before a reload
+		start := func() {
+			stopPanes()
+			var panes context.Context
+			panes, stopPanes = context.WithCancel(ctx)
+			// a channel of its own, so no update of the panes stopped can
+			// still come in
+			updates = make(chan dashboardUpdate)
+			screens = make([]*IncrementalRenderer, len(cfg.Panes))
+			refreshes = make([]chan struct{}, len(cfg.Panes))
+			failed = make([]bool, len(cfg.Panes))
+			fmt.Print("\033[H\033[2J")
+			for i, pane := range cfg.Panes {
+				r := *base
+				r.MaxWidth, r.MaxHeight = regions[i].Dx(), regions[i].Dy()-1
+				r.ExactFit = true
+				screens[i] = NewIncrementalRenderer(&r)
+				screens[i].SetPosition(regions[i].Min.X, regions[i].Min.Y+1)
+				refreshes[i] = make(chan struct{}, 1)
+				title(i, time.Time{}, nil)
+				go runPane(panes, i, pane, &r, refreshes[i], updates)
+			}
+			hints(nil)
+			sdNotify(fmt.Sprintf("READY=1\nSTATUS=%d panes from %s", len(cfg.Panes), args[0]))
+		}
+		start()
+		defer func() {
+			stopPanes()
+			sdNotify("STOPPING=1")
+		}()
+
+		var watchdog <-chan time.Time
+		if interval := sdWatchdog(); interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			watchdog = ticker.C
+		}
+
+		for {
+			select {
+			case update := <-updates:
+				switch {
+				case update.err != nil:
+					failed[update.pane] = true
+					title(update.pane, time.Time{}, update.err)
+				case !update.at.IsZero() || failed[update.pane]:
+					failed[update.pane] = false
+					title(update.pane, update.at, nil)
+				}
+				if update.grid != nil {
+					fmt.Print(screens[update.pane].RenderGrid(update.grid))
+					putGrid(update.grid) // RenderGrid kept a copy
+				}
+			case <-hangup:
+				// the file again, for changes to it, and the terminal's
+				// size, which may have changed too. a broken file leaves
+				// the dashboard as it was
+				sdNotify(sdReloading())
+				cols, rows = dashboardSize()
+				reloaded, err := loadDashboard(args[0])
+				var laidOut []image.Rectangle
+				if err == nil {
+					laidOut, err = layoutPanes(reloaded.Panes, cols, rows)
+				}
+				if err != nil {
+					hints(err)
+					sdNotify("READY=1\nSTATUS=" + tr("Error reloading dashboard:") + " " + err.Error())
+					continue
+				}
+				cfg, regions = reloaded, laidOut
+				start()
+			case <-watchdog:
+				sdNotify("WATCHDOG=1")
+			case key := <-keys:
+				switch key {
+				case keyQuit:
+					return
+				case keyPause:
+					for _, refresh := range refreshes {
+						select {
+						case refresh <- struct{}{}:
+						default: // one is pending already
+						}
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	},
+}
+
+// dashboardSize is the room the panes get: the terminal, less the line of
+// key hints at the bottom
+func dashboardSize() (cols, rows int) {
+	cols, rows, ok := terminalSize()
+	if !ok {
+		cols, rows = defaultTerminalWidth, defaultTerminalHeight
+	}
+	return cols, rows - 1
+}
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+
+	dashboardCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	dashboardCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	dashboardCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	dashboardCmd.Flags().StringVar(&dashboardColors, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"image"
+	"testing"
+)
+
+func TestLayoutPanes(t *testing.T) {
+	panes := []dashboardPane{
+		{Source: "a.png", Region: "0,0,50%,100%"},
+		{Source: "b.png"},
+		{Source: "c.png"},
+		{Source: "d.png", Region: "50%, 10, 20, 5"},
+	}
+	regions, err := layoutPanes(panes, 100, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []image.Rectangle{
+		image.Rect(0, 0, 50, 40),
+		image.Rect(0, 0, 50, 40), // two tiled panes split the screen in two columns
+		image.Rect(50, 0, 100, 40),
+		image.Rect(50, 10, 70, 15),
+	}
+	for i := range want {
+		if regions[i] != want[i] {
+			t.Errorf("pane %d: got %v, want %v", i+1, regions[i], want[i])
+		}
+	}
+
+	if _, err := layoutPanes([]dashboardPane{{Source: "a.png", Region: "120,0,10,10"}}, 100, 40); err == nil {
+		t.Error("a region off the screen should be an error")
+	}
+}
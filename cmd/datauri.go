@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// base64Input is show's --base64: the image comes base64 encoded on stdin,
+// as a bare payload or a whole data URI
+var base64Input bool
+
+// isDataURI reports whether source is a data: URI rather than a path or URL
+func isDataURI(source string) bool {
+	return len(source) >= 5 && strings.EqualFold(source[:5], "data:")
+}
+
+// decodeDataURI returns the bytes and media type of a data URI like
+// data:image/png;base64,iVBORw0..., percent-encoded when it isn't base64
+func decodeDataURI(uri string) ([]byte, string, error) {
+	header, payload, found := strings.Cut(uri[len("data:"):], ",")
+	if !found {
+		return nil, "", errors.New("invalid data URI: no comma before the data")
+	}
+	mediaType, encoded := header, false
+	if before, ok := strings.CutSuffix(strings.ToLower(header), ";base64"); ok {
+		mediaType, encoded = header[:len(before)], true
+	}
+	if mediaType == "" {
+		mediaType = "unknown type"
+	}
+	if encoded {
+		data, err := decodeBase64([]byte(payload))
+		return data, mediaType, err
+	}
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid data URI: %w", err)
+	}
+	return []byte(data), mediaType, nil
+}
+
+// decodeBase64 decodes standard or URL-safe base64 with or without padding,
+// skipping the line breaks and spaces wrapped payloads come with
+func decodeBase64(payload []byte) ([]byte, error) {
+	compact := bytes.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, payload)
+	if unescaped, err := url.PathUnescape(string(compact)); err == nil {
+		compact = []byte(unescaped) // %2B and friends from URL-encoded payloads
+	}
+	var err error
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		var data []byte
+		if data, err = encoding.DecodeString(string(compact)); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid base64: %w", err)
+}
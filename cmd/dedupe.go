@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dedupeHash        string
+	dedupeDistance    int
+	dedupeList        bool
+	dedupeInteractive bool
+	dedupeMoveTo      string
+	dedupeWidth       int
+	dedupeHeight      int
+	dedupeBraille     bool
+	dedupeFull        bool
+	dedupeColors      string
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe [directory_or_archive]",
+	Short: "Find duplicate and near-duplicate images",
+	Long: `Hash every image in a directory (or archive) perceptually and group the
+ones that look the same, even when they were resized, recompressed or
+slightly edited. Each group is rendered side by side for review, the
+largest image first:
+
+  termuwu dedupe ~/Pictures
+  termuwu dedupe ~/Pictures --hash dhash --distance 4 --list
+  termuwu dedupe ~/Pictures -i --move-to ~/Pictures/duplicates
+
+--hash picks the hash: phash (the default, the most robust), dhash or ahash
+(the fastest). --distance is how many of the 64 bits two hashes may differ
+in, 10 for phash and dhash and 5 for ahash unless set.
+
+With --interactive (-i) termuwu asks which images of each group to keep
+and deletes the rest, or moves them to --move-to keeping their paths.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		nameColor := themeColor("name", color.FgMagenta, color.Bold).SprintFunc()
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+		dir := args[0]
+
+		hash, distance, err := parseHashFunc(dedupeHash)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --hash value:"), err)
+			return
+		}
+		if cmd.Flags().Changed("distance") {
+			distance = dedupeDistance
+		}
+		if distance < 0 || distance > 64 {
+			fmt.Fprintln(statusOut, errorColor("❌ --distance must be between 0 and 64."))
+			return
+		}
+		if dedupeMoveTo != "" && !dedupeInteractive {
+			fmt.Fprintln(statusOut, errorColor("❌ --move-to only works with --interactive (-i)."))
+			return
+		}
+		if dedupeInteractive && archiveKind(dir) != "" {
+			fmt.Fprintln(statusOut, errorColor("❌ Duplicates can only be moved or deleted in a directory, not in an archive."))
+			return
+		}
+		depth, err := parseColorDepth(dedupeColors)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		if dedupeWidth < 1 || dedupeHeight < 1 {
+			fmt.Fprintln(statusOut, errorColor("❌ --width and --height must be positive."))
+			return
+		}
+
+		images, err := hashImages(dir, hash, func(name string, err error) {
+			fmt.Fprintf(statusOut, "⚠️  %s %s: %v\n", infoColor("Skipping"), name, err)
+		})
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading images:"), err)
+			return
+		}
+		hashes := make([]ImageHash, len(images))
+		for i, img := range images {
+			hashes[i] = img.Hash
+		}
+		groups := groupDuplicates(hashes, distance)
+		fmt.Fprintf(statusOut, tr("🔎 %s %d images, %d groups of duplicates\n"), infoColor("Hashed:"), len(images), len(groups))
+
+		const gap = 2
+		frame := configureRenderer(false, false, false, 0, 0)
+		columns := max((frame.MaxWidth+gap)/(dedupeWidth+gap), 1)
+		renderer := configureRenderer(dedupeFull, dedupeBraille, false, dedupeWidth, dedupeHeight)
+		renderer.ColorDepth = depth
+		if !dedupeList {
+			if err := fitGlyphs(renderer); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+				return
+			}
+		}
+
+		answers := bufio.NewReader(os.Stdin)
+		var duplicates int
+		var reclaimable, freed int64
+	groups:
+		for g, group := range groups {
+			members := make([]dedupeImage, len(group))
+			for i, index := range group {
+				members[i] = images[index]
+			}
+			sort.SliceStable(members, func(a, b int) bool { return members[a].betterThan(members[b]) })
+			duplicates += len(members) - 1
+			for _, m := range members[1:] {
+				reclaimable += m.Size
+			}
+
+			fmt.Printf("%s\n", nameColor(fmt.Sprintf(tr("Group %d of %d"), g+1, len(groups))))
+			if !dedupeList {
+				var row [][]string
+				for i, m := range members {
+					img, err := decodeInDir(dir, m.Name)
+					if err != nil {
+						fmt.Fprintf(statusOut, "⚠️  %s %s: %v\n", infoColor("Skipping"), m.Name, err)
+						continue
+					}
+					row = append(row, thumbnailTile(renderer, img, fmt.Sprintf("%d %s", i+1, path.Base(m.Name)), dedupeWidth))
+					if len(row) == columns {
+						printTiles(row, dedupeWidth, gap)
+						row = row[:0]
+					}
+				}
+				if len(row) > 0 {
+					printTiles(row, dedupeWidth, gap)
+				}
+			}
+			for i, m := range members {
+				fmt.Printf("  %d  %s  %s\n", i+1, m.Name, dimColor(fmt.Sprintf(tr("%dx%d, %s, distance %d"), m.Width, m.Height, formatByteSize(m.Size), m.Hash.Distance(members[0].Hash))))
+			}
+
+			if dedupeInteractive {
+				keep, quit := askKeep(answers, len(members))
+				if quit {
+					break groups
+				}
+				for i, m := range members {
+					if keep[i] {
+						continue
+					}
+					source := filepath.Join(dir, filepath.FromSlash(m.Name))
+					if dedupeMoveTo != "" {
+						target := filepath.Join(dedupeMoveTo, filepath.FromSlash(m.Name))
+						if err := moveFile(source, target); err != nil {
+							fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error moving image:"), err)
+							continue
+						}
+						fmt.Fprintf(statusOut, "📦 %s %s → %s\n", successColor("Moved:"), m.Name, target)
+					} else {
+						if err := os.Remove(source); err != nil {
+							fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error deleting image:"), err)
+							continue
+						}
+						fmt.Fprintf(statusOut, "🗑️  %s %s\n", successColor("Deleted:"), m.Name)
+					}
+					freed += m.Size
+				}
+			}
+			fmt.Println()
+		}
+
+		if dedupeInteractive {
+			fmt.Fprintf(statusOut, "📊 %s %s\n", infoColor("Freed:"), formatByteSize(freed))
+		} else if duplicates > 0 {
+			fmt.Fprintf(statusOut, tr("📊 %s %d, %s could be freed (-i to choose)\n"), infoColor("Duplicates:"), duplicates, formatByteSize(reclaimable))
+		}
+	},
+}
+
+// dedupeImage is a hashed image, Name as walkImages gives it
+type dedupeImage struct {
+	Name          string
+	Size          int64
+	Width, Height int
+	Hash          ImageHash
+}
+
+// betterThan tells which of two duplicates is more worth keeping: more
+// pixels, then the bigger file (less compressed), then the first name
+func (d dedupeImage) betterThan(other dedupeImage) bool {
+	if a, b := d.Width*d.Height, other.Width*other.Height; a != b {
+		return a > b
+	}
+	if d.Size != other.Size {
+		return d.Size > other.Size
+	}
+	return naturalLess(d.Name, other.Name)
+}
+
+// hashImages hashes every image in dir, a directory or an archive, sorted
+// by name. the files are read one after the other and decoded on all cores;
+// the ones that don't decode are passed to skip
+func hashImages(dir string, hash HashFunc, skip func(name string, err error)) ([]dedupeImage, error) {
+	if archiveKind(dir) == "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	}
+	type job struct {
+		image dedupeImage
+		data  []byte
+	}
+	jobs := make(chan job, runtime.NumCPU())
+	var (
+		mu     sync.Mutex // guards images and calls to skip
+		images []dedupeImage
+		wg     sync.WaitGroup
+	)
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				img, _, err := DecodeImage(bytes.NewReader(j.data))
+				mu.Lock()
+				if err != nil {
+					skip(j.image.Name, err)
+				} else {
+					j.image.Width, j.image.Height = img.Bounds().Dx(), img.Bounds().Dy()
+					j.image.Hash = hash(img)
+					images = append(images, j.image)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := walkImages(dir, func(entry archiveEntry) error {
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		jobs <- job{dedupeImage{Name: entry.Name, Size: int64(len(data))}, data}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	sort.Slice(images, func(i, j int) bool { return naturalLess(images[i].Name, images[j].Name) })
+	return images, err
+}
+
+// askKeep asks which of n images to keep until it gets an answer it
+// understands: numbers from 1, Enter for the first, a for all (the same as
+// s to skip the group) or q to stop
+func askKeep(answers *bufio.Reader, n int) (keep []bool, quit bool) {
+	keep = make([]bool, n)
+	for {
+		fmt.Printf(tr("Keep which? (1-%d, like 1 or 1,3; Enter keeps 1, a keeps all, q quits): "), n)
+		line, err := answers.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Println()
+			return keep, true // nothing more to read, leave the rest alone
+		}
+		line = strings.ToLower(strings.TrimSpace(line))
+		switch line {
+		case "":
+			keep[0] = true
+			return keep, false
+		case "a", "s":
+			for i := range keep {
+				keep[i] = true
+			}
+			return keep, false
+		case "q":
+			return keep, true
+		}
+		valid := true
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' }) {
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 1 || i > n {
+				valid = false
+				break
+			}
+			keep[i-1] = true
+		}
+		if valid {
+			return keep, false
+		}
+		clear(keep)
+	}
+}
+
+// moveFile moves source to target, creating target's directory and never
+// replacing a file that's already there
+func moveFile(source, target string) error {
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	err := os.Rename(source, target)
+	var linkErr *os.LinkError
+	if err == nil || !errors.As(err, &linkErr) {
+		return err
+	}
+	// another file system, copy it over instead
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+	return os.Remove(source)
+}
+
+func init() {
+	rootCmd.AddCommand(dedupeCmd)
+
+	dedupeCmd.Flags().StringVar(&dedupeHash, "hash", "phash", "Perceptual hash to compare: phash, dhash or ahash.")
+	dedupeCmd.Flags().IntVar(&dedupeDistance, "distance", 10, "How many of the 64 hash bits may differ for images to count as duplicates (5 for ahash unless set).")
+	dedupeCmd.Flags().BoolVar(&dedupeList, "list", false, "Only list the groups, don't render them.")
+	dedupeCmd.Flags().BoolVarP(&dedupeInteractive, "interactive", "i", false, "Ask which images of each group to keep and delete (or move) the others.")
+	dedupeCmd.Flags().StringVar(&dedupeMoveTo, "move-to", "", "With -i, move duplicates to this directory instead of deleting them.")
+	dedupeCmd.Flags().IntVarP(&dedupeWidth, "width", "W", 24, "Width of each thumbnail in characters.")
+	dedupeCmd.Flags().IntVarP(&dedupeHeight, "height", "H", 10, "Height of each thumbnail in lines.")
+	dedupeCmd.Flags().BoolVarP(&dedupeBraille, "braille", "b", false, "Draw the thumbnails with Braille patterns.")
+	dedupeCmd.Flags().BoolVarP(&dedupeFull, "full", "f", false, "Draw the thumbnails with full character blocks.")
+	dedupeCmd.Flags().StringVar(&dedupeColors, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Description is the text --describe prints in place of (or before) the
+// picture, for screen readers and logs
+type Description struct {
+	Width, Height int
+	Format        string
+	Colors        []DominantColor // most common first
+	Text          string          // read by OCR, empty when there's none
+	Caption       string          // from the caption endpoint, if one is set up
+}
+
+// DominantColor is one of the colors most of the image is made of
+type DominantColor struct {
+	Color
+	Name  string  // plain words, like "dark blue"
+	Share float64 // of the visible pixels, 0..1
+}
+
+// describeSamples caps the pixels looked at for dominant colors, a big
+// photo doesn't need every one of them
+const describeSamples = 128 * 128
+
+// describeImage works out the dimensions and dominant colors of img
+func describeImage(img image.Image, format string) Description {
+	bounds := img.Bounds()
+	return Description{
+		Width:  bounds.Dx(),
+		Height: bounds.Dy(),
+		Format: format,
+		Colors: dominantColors(img, 6),
+	}
+}
+
+// dominantColors finds up to n colors with median cut and how much of the
+// image each covers. colors with the same name are counted together and
+// the ones covering less than 3% are left out
+func dominantColors(img image.Image, n int) []DominantColor {
+	bounds := img.Bounds()
+	step := max(1, int(math.Sqrt(float64(bounds.Dx()*bounds.Dy())/describeSamples)))
+	var pixels []color.RGBA
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := pixelAt(img, x, y)
+			if c.A < 128 {
+				continue
+			}
+			if c.A < 0xff { // back from premultiplied
+				c.R, c.G, c.B = uint8(uint32(c.R)*0xff/uint32(c.A)), uint8(uint32(c.G)*0xff/uint32(c.A)), uint8(uint32(c.B)*0xff/uint32(c.A))
+			}
+			pixels = append(pixels, c)
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	palette := medianCut(slices.Clone(pixels), n)
+	shares := make(map[string]*DominantColor)
+	var colors []*DominantColor
+	for _, p := range pixels {
+		nearest := palette[0]
+		for _, c := range palette[1:] {
+			if rgbDistance(toColor(p), c) < rgbDistance(toColor(p), nearest) {
+				nearest = c
+			}
+		}
+		name := colorName(nearest)
+		if shares[name] == nil {
+			shares[name] = &DominantColor{Color: nearest, Name: name}
+			colors = append(colors, shares[name])
+		}
+		shares[name].Share += 1 / float64(len(pixels))
+	}
+
+	slices.SortStableFunc(colors, func(a, b *DominantColor) int {
+		return int(math.Round((b.Share - a.Share) * 1e6))
+	})
+	var result []DominantColor
+	for _, c := range colors {
+		if c.Share >= 0.03 {
+			result = append(result, *c)
+		}
+	}
+	return result
+}
+
+// colorName puts a color in plain words, from its hue, saturation and
+// lightness
+func colorName(c Color) string {
+	hue, saturation, lightness := hsl(c)
+	if !isChromatic(saturation, lightness) {
+		switch {
+		case lightness < 0.15:
+			return "black"
+		case lightness > 0.9:
+			return "white"
+		case lightness < 0.4:
+			return "dark gray"
+		case lightness > 0.7:
+			return "light gray"
+		default:
+			return "gray"
+		}
+	}
+
+	var name string
+	switch {
+	case hue < 15 || hue >= 345:
+		name = "red"
+	case hue < 45:
+		name = "orange"
+		if lightness < 0.35 {
+			return "brown"
+		}
+	case hue < 70:
+		name = "yellow"
+	case hue < 165:
+		name = "green"
+	case hue < 195:
+		name = "cyan"
+	case hue < 255:
+		name = "blue"
+	case hue < 290:
+		name = "purple"
+	default:
+		name = "pink"
+	}
+	switch {
+	case lightness < 0.3:
+		return "dark " + name
+	case lightness > 0.75:
+		return "light " + name
+	}
+	return name
+}
+
+// hsl converts c to hue (0-360), saturation and lightness (0-1)
+func hsl(c Color) (hue, saturation, lightness float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	high, low := max(r, g, b), min(r, g, b)
+	lightness = (high + low) / 2
+	if high > low {
+		d := high - low
+		saturation = d / (1 - math.Abs(2*lightness-1))
+		switch high {
+		case r:
+			hue = math.Mod((g-b)/d+6, 6) * 60
+		case g:
+			hue = ((b-r)/d + 2) * 60
+		default:
+			hue = ((r-g)/d + 4) * 60
+		}
+	}
+	return hue, saturation, lightness
+}
+
+// isChromatic tells colors with a hue worth naming from grays, blacks and
+// whites
+func isChromatic(saturation, lightness float64) bool {
+	return saturation >= 0.15 && lightness >= 0.08 && lightness <= 0.95
+}
+
+func (d Description) String() string {
+	var b strings.Builder
+	shape := "square"
+	switch {
+	case d.Width > d.Height:
+		shape = "landscape"
+	case d.Width < d.Height:
+		shape = "portrait"
+	}
+	fmt.Fprintf(&b, "Image: %dx%d pixels, %s, %s\n", d.Width, d.Height, d.Format, shape)
+
+	if len(d.Colors) > 0 {
+		names := make([]string, len(d.Colors))
+		for i, c := range d.Colors {
+			names[i] = fmt.Sprintf("%s (%.0f%%)", c.Name, c.Share*100)
+		}
+		fmt.Fprintf(&b, "Colors: %s\n", strings.Join(names, ", "))
+	} else {
+		b.WriteString("Colors: fully transparent\n")
+	}
+	if d.Text != "" {
+		fmt.Fprintf(&b, "Text: %s\n", d.Text)
+	}
+	if d.Caption != "" {
+		fmt.Fprintf(&b, "Caption: %s\n", d.Caption)
+	}
+	return b.String()
+}
+
+// errNoOCR means tesseract isn't installed, text just isn't read then
+var errNoOCR = errors.New("install tesseract to read the text in images")
+
+// recognizeText reads the text in img with tesseract, on one line
+func recognizeText(ctx context.Context, img image.Image) (string, error) {
+	tesseract, err := exec.LookPath("tesseract")
+	if err != nil {
+		return "", errNoOCR
+	}
+	file, err := os.CreateTemp("", "termuwu-ocr-*.png")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tesseract, file.Name(), "stdout")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tesseract %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.Join(strings.Fields(string(out)), " "), nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// DitherMode picks the noise added to colors before they are matched to
+// the palette. both modes only depend on the pixel position, never on
+// neighboring pixels like error diffusion does, so what doesn't move in an
+// animation doesn't shimmer either
+type DitherMode int
+
+const (
+	// DitherOrdered adds a small 2x2 pattern, just enough to break up
+	// banding
+	DitherOrdered DitherMode = iota
+	// DitherBlueNoise adds a fixed blue-noise mask: stronger, without any
+	// visible pattern, and still stable from frame to frame
+	DitherBlueNoise
+	// DitherNone is --no-dither
+	DitherNone
+)
+
+func parseDitherMode(value string) (DitherMode, error) {
+	switch strings.ToLower(value) {
+	case "ordered", "":
+		return DitherOrdered, nil
+	case "blue-noise", "bluenoise":
+		return DitherBlueNoise, nil
+	case "none":
+		return DitherNone, nil
+	}
+	return DitherOrdered, fmt.Errorf("unknown dither mode %q (use ordered, blue-noise or none)", value)
+}
+
+// blueNoiseSize is the side of the tiled mask
+const blueNoiseSize = 64
+
+// blueNoiseStrength is the spread of the blue noise per color depth, about
+// half a step of the palette, so the noise picks between neighboring
+// palette colors without adding visible grain
+var blueNoiseStrength = map[ColorDepth]float64{
+	Color16:   64,
+	Color256:  24,
+	TrueColor: 4,
+}
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoiseMask []int8 // offsets in -64..63, scaled by the strength
+)
+
+// blueNoiseOffset is the mask's value at x, y in -0.5..0.5
+func blueNoiseOffset(x, y int) float64 {
+	blueNoiseOnce.Do(func() { blueNoiseMask = makeBlueNoise(blueNoiseSize) })
+	return float64(blueNoiseMask[(y%blueNoiseSize)*blueNoiseSize+x%blueNoiseSize]) / 128
+}
+
+// makeBlueNoise builds a size x size blue-noise threshold mask with
+// Ulichney's void-and-cluster method: pixels are ranked by putting each
+// next one where the pattern so far has its largest gap, measured with a
+// Gaussian on the wrapped-around grid so the mask tiles seamlessly. it is
+// seeded, every run gets the same mask
+func makeBlueNoise(size int) []int8 {
+	const sigma = 1.5
+	radius := int(math.Ceil(3 * sigma))
+	n := size * size
+
+	kernel := make([]float64, 0, (2*radius+1)*(2*radius+1))
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			kernel = append(kernel, math.Exp(-float64(dx*dx+dy*dy)/(2*sigma*sigma)))
+		}
+	}
+
+	pattern := make([]bool, n)
+	energy := make([]float64, n)
+	set := func(p int, on bool) {
+		pattern[p] = on
+		sign := 1.0
+		if !on {
+			sign = -1
+		}
+		px, py := p%size, p/size
+		k := 0
+		for dy := -radius; dy <= radius; dy++ {
+			for dx := -radius; dx <= radius; dx++ {
+				x, y := (px+dx+size)%size, (py+dy+size)%size
+				energy[y*size+x] += sign * kernel[k]
+				k++
+			}
+		}
+	}
+	// tightest cluster: the set pixel with the most energy; largest void:
+	// the free pixel with the least
+	extreme := func(on bool) int {
+		best := -1
+		for p := range pattern {
+			if pattern[p] != on {
+				continue
+			}
+			if best < 0 || on && energy[p] > energy[best] || !on && energy[p] < energy[best] {
+				best = p
+			}
+		}
+		return best
+	}
+
+	// a random start, relaxed until moving the tightest cluster into the
+	// largest void changes nothing
+	random := rand.New(rand.NewSource(1))
+	ones := n / 10
+	for _, p := range random.Perm(n)[:ones] {
+		set(p, true)
+	}
+	for {
+		cluster := extreme(true)
+		set(cluster, false)
+		void := extreme(false)
+		set(void, true)
+		if void == cluster {
+			break
+		}
+	}
+	prototype := append([]bool(nil), pattern...)
+	savedEnergy := append([]float64(nil), energy...)
+
+	rank := make([]int, n)
+	// the initial pixels, ranked by taking away the tightest clusters
+	for r := ones - 1; r >= 0; r-- {
+		p := extreme(true)
+		set(p, false)
+		rank[p] = r
+	}
+	// the rest, ranked by filling the largest voids
+	copy(pattern, prototype)
+	copy(energy, savedEnergy)
+	for r := ones; r < n; r++ {
+		p := extreme(false)
+		set(p, true)
+		rank[p] = r
+	}
+
+	mask := make([]int8, n)
+	for p, r := range rank {
+		mask[p] = int8(r*128/n - 64)
+	}
+	return mask
+}
+
+// applyBlueNoise shifts a color by the blue-noise mask at x, y
+func (r *ImageRenderer) applyBlueNoise(r8, g8, b8 uint8, x, y int) (uint8, uint8, uint8) {
+	offset := int8(math.Round(blueNoiseOffset(x, y) * blueNoiseStrength[r.ColorDepth]))
+	return clampAddSigned(r8, offset), clampAddSigned(g8, offset), clampAddSigned(b8, offset)
+}
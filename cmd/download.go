@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// downloadConnections is --connections, how many range requests at once a
+// big download is split over
+var downloadConnections int
+
+const (
+	parallelDownloadMin = 2 << 20 // smaller downloads aren't worth the extra requests
+	downloadChunkSize   = 1 << 20 // what a connection asks for at a time, and resumes by
+)
+
+// rangeInfo is what a HEAD request tells about a download that can be
+// fetched in ranges
+type rangeInfo struct {
+	size         int64
+	etag         string
+	lastModified string
+}
+
+// validator is what range requests send as If-Range, so the server answers
+// with the whole file instead of a range of it once it's changed: the ETag,
+// unless it's weak, which If-Range can't take, else Last-Modified. without
+// either there's no telling ranges are of the same file
+func (info rangeInfo) validator() string {
+	if info.etag != "" && !strings.HasPrefix(info.etag, "W/") {
+		return info.etag
+	}
+	return info.lastModified
+}
+
+// errDownloadChanged is a range request answered with another version of
+// the file than the ranges before it
+var errDownloadChanged = errors.New("the file changed on the server")
+
+// downloadState sits next to a partial download in the cache directory,
+// saying which chunks arrived so the next run can pick up from there
+type downloadState struct {
+	URL          string `json:"url"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ChunkSize    int64  `json:"chunk_size"`
+	Done         []bool `json:"done"`
+}
+
+// maxDownload is --max-download in bytes, 0 for no limit
+var (
+	maxDownload     int64
+	maxDownloadFlag string
+)
+
+// openDownload starts downloading url. big files from servers taking range
+// requests come over --connections connections into the cache directory,
+// resuming what an earlier run left there, anything else over one GET.
+// what isn't an image, or is over --max-download, is refused before its
+// body is read, unless the user at the terminal says to go ahead
+func openDownload(ctx context.Context, url string) (io.ReadCloser, error) {
+	checked, unlimited := false, false
+	if head := headRequest(ctx, url); head != nil {
+		var err error
+		if unlimited, err = preflight(url, head); err != nil {
+			return nil, err
+		}
+		checked = true
+		if info, ok := rangesOf(head); ok && downloadConnections > 1 && info.size >= parallelDownloadMin && info.validator() != "" {
+			body, err := downloadParallel(ctx, url, info)
+			if !errors.Is(err, errDownloadChanged) {
+				return body, err
+			}
+			// it changed since the HEAD request, or since the run that left
+			// the part file: it's fetched again whole below
+		}
+	}
+	resp, err := httpGet(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if !checked { // the server didn't answer HEAD, its GET says the same before the body
+		if unlimited, err = preflight(url, resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	body := withProgress(resp)
+	if maxDownload > 0 && !unlimited {
+		// in case the size wasn't given, or was wrong
+		body = &limitedDownload{ReadCloser: body, left: maxDownload}
+	}
+	return body, nil
+}
+
+// headRequest asks about url with HEAD, nil when the server doesn't answer
+// it with 200 OK
+func headRequest(ctx context.Context, url string) *http.Response {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return resp
+}
+
+// rangesOf says whether the server takes range requests for a download,
+// from the answer to its HEAD request, and how big it is
+func rangesOf(head *http.Response) (rangeInfo, bool) {
+	if head.Header.Get("Accept-Ranges") != "bytes" || head.ContentLength <= 0 {
+		return rangeInfo{}, false
+	}
+	return rangeInfo{
+		size:         head.ContentLength,
+		etag:         head.Header.Get("ETag"),
+		lastModified: head.Header.Get("Last-Modified"),
+	}, true
+}
+
+// downloadableTypes are the content types taken besides image/*, what
+// servers say when they don't know
+var downloadableTypes = []string{"", "application/octet-stream", "binary/octet-stream"}
+
+// preflight checks what the headers of resp say is coming: refusing a
+// download that isn't an image or is over --max-download, unless the user
+// confirms it. unlimited is whether --max-download was waived
+func preflight(url string, resp *http.Response) (unlimited bool, err error) {
+	var problems []string
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "image/") && !slices.Contains(downloadableTypes, mediaType) {
+		problems = append(problems, fmt.Sprintf(tr("it's %s, not an image"), mediaType))
+	}
+	if maxDownload > 0 && resp.ContentLength > maxDownload {
+		problems = append(problems, fmt.Sprintf(tr("it's %s, over --max-download %s"), formatByteSize(resp.ContentLength), formatByteSize(maxDownload)))
+	}
+	if len(problems) == 0 {
+		return false, nil
+	}
+	problem := strings.Join(problems, tr(" and "))
+	if !confirm(fmt.Sprintf("%s: %s. %s", url, problem, tr("Download anyway?"))) {
+		return false, fmt.Errorf("refusing to download %s: %s", url, problem)
+	}
+	return true, nil
+}
+
+// confirm asks question on the terminal, false without one to ask at
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return false
+	}
+	infoColor := localized(themeColor("info", color.FgYellow))
+	fmt.Fprintf(stderrStatus(), "⚠️  %s [y/N] ", infoColor(question))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// limitedDownload fails a download once it's read more than --max-download
+type limitedDownload struct {
+	io.ReadCloser
+	left int64
+}
+
+func (l *limitedDownload) Read(p []byte) (int, error) {
+	n, err := l.ReadCloser.Read(p)
+	if l.left -= int64(n); l.left < 0 {
+		return n, fmt.Errorf("the download is over --max-download %s", formatByteSize(maxDownload))
+	}
+	return n, err
+}
+
+// downloadPaths are the partial file and its state for url, named by the
+// url's hash under the user cache directory
+func downloadPaths(url string) (part, state string, err error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "termuwu", "downloads")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:16])
+	return filepath.Join(dir, name+".part"), filepath.Join(dir, name+".json"), nil
+}
+
+// resumes reports whether a saved state still describes the file at url.
+// without an ETag or Last-Modified there's no telling it didn't change
+func (s *downloadState) resumes(url string, info rangeInfo) bool {
+	return s.URL == url && s.Size == info.size && s.ChunkSize == downloadChunkSize &&
+		s.ETag == info.etag && s.LastModified == info.lastModified &&
+		(info.etag != "" || info.lastModified != "")
+}
+
+// downloadParallel fetches url chunk by chunk into the cache directory, the
+// chunks spread over --connections connections. the file is removed once
+// read, and kept with its state to resume from when the download fails
+func downloadParallel(ctx context.Context, url string, info rangeInfo) (io.ReadCloser, error) {
+	partPath, statePath, err := downloadPaths(url)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't download image: %w", err)
+	}
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't download image: %w", err)
+	}
+	if lockFile(file) != nil {
+		// another run is downloading url into it, this one gets a file of
+		// its own, and leaves nothing to resume from
+		file.Close()
+		if file, err = os.CreateTemp(filepath.Dir(partPath), "*.part"); err != nil {
+			return nil, fmt.Errorf("couldn't download image: %w", err)
+		}
+		statePath = ""
+	}
+
+	chunks := int((info.size + downloadChunkSize - 1) / downloadChunkSize)
+	state := &downloadState{}
+	fresh := true
+	if data, err := os.ReadFile(statePath); statePath != "" && err == nil && json.Unmarshal(data, state) == nil && state.resumes(url, info) && len(state.Done) == chunks {
+		fresh = false
+	} else {
+		state = &downloadState{URL: url, Size: info.size, ETag: info.etag, LastModified: info.lastModified, ChunkSize: downloadChunkSize, Done: make([]bool, chunks)}
+	}
+	if fresh {
+		err = file.Truncate(0)
+	}
+	if err == nil {
+		err = file.Truncate(info.size)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("couldn't download image: %w", err)
+	}
+
+	progress := newDownloadProgress(info.size)
+	pending := make(chan int, chunks)
+	for i, done := range state.Done {
+		if done {
+			progress.Add64(min(downloadChunkSize, info.size-int64(i)*downloadChunkSize))
+		} else {
+			pending <- i
+		}
+	}
+	close(pending)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex // guards state and firstErr
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for range min(downloadConnections, chunks) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				start := int64(i) * downloadChunkSize
+				end := min(start+downloadChunkSize, info.size)
+				err := fetchRange(ctx, url, info, io.NewOffsetWriter(file, start), start, end, progress)
+				mu.Lock()
+				if err == nil {
+					state.Done[i] = true
+					err = saveDownloadState(statePath, state)
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if errors.Is(firstErr, errDownloadChanged) {
+		// what arrived is of the old file, nothing of it is kept
+		cachedDownload{file}.Close()
+		if statePath != "" {
+			os.Remove(statePath)
+		}
+		return nil, firstErr
+	}
+	if firstErr != nil {
+		file.Close()
+		return nil, fmt.Errorf("couldn't download image: %w (run again to resume)", firstErr)
+	}
+
+	if statePath != "" {
+		os.Remove(statePath)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return cachedDownload{file}, nil
+}
+
+// fetchRange copies the bytes from start up to end of url to w, as long as
+// it's still the file info describes
+func fetchRange(ctx context.Context, url string, info rangeInfo, w io.Writer, start, end int64, progress byteCounter) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	req.Header.Set("If-Range", info.validator())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return errDownloadChanged // the whole file instead, If-Range didn't match
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range request answered with status code %d", resp.StatusCode)
+	}
+	var first, last int64
+	var size string
+	contentRange := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &first, &last, &size); err != nil || first != start || last != end-1 {
+		return fmt.Errorf("range request for bytes %d-%d answered with %q", start, end-1, contentRange)
+	}
+	if size != "*" && size != strconv.FormatInt(info.size, 10) {
+		return errDownloadChanged
+	}
+	n, err := io.Copy(w, io.TeeReader(io.LimitReader(resp.Body, end-start), progress))
+	if err != nil {
+		return err
+	}
+	if n != end-start {
+		return errors.New("the connection closed early")
+	}
+	return nil
+}
+
+func saveDownloadState(path string, state *downloadState) error {
+	if path == "" {
+		return nil // a download of its own, see downloadParallel
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// cachedDownload reads a finished download, removing it when closed
+type cachedDownload struct {
+	*os.File
+}
+
+func (d cachedDownload) Close() error {
+	err := d.File.Close()
+	os.Remove(d.Name())
+	return err
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestParallelDownload checks a download split over range requests comes
+// out whole and leaves nothing behind in the cache
+func TestParallelDownload(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	content := make([]byte, parallelDownloadMin+downloadChunkSize/3)
+	rand.New(rand.NewSource(1)).Read(content)
+	var ranges atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges.Add(1)
+		}
+		w.Header().Set("ETag", `"1"`)
+		http.ServeContent(w, r, "big.png", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	defer func(connections int, plain bool) {
+		downloadConnections, plainChrome = connections, plain
+	}(downloadConnections, plainChrome)
+	downloadConnections, plainChrome = 2, true // no progress bar in the test output
+	reader, err := openDownload(context.Background(), server.URL+"/big.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("downloaded %d bytes that differ from the %d served", len(got), len(content))
+	}
+	if n := ranges.Load(); n != 3 {
+		t.Errorf("%d range requests, want 3", n)
+	}
+	part, state, err := downloadPaths(server.URL + "/big.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{part, state} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s is left behind", path)
+		}
+	}
+}
+
+// TestDownloadPreflight checks what isn't an image, or is too big, is
+// refused without a terminal to ask at, also when the server says nothing
+// about the size
+func TestDownloadPreflight(t *testing.T) {
+	defer func(limit int64, connections int, plain bool) {
+		maxDownload, downloadConnections, plainChrome = limit, connections, plain
+	}(maxDownload, downloadConnections, plainChrome)
+	maxDownload, downloadConnections, plainChrome = 1000, 1, true
+	var bodies atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+		case "/unsized.png":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.WriteHeader(http.StatusOK)
+			for range 3 {
+				w.Write(make([]byte, 500))
+				w.(http.Flusher).Flush() // chunked, so no Content-Length
+			}
+			return
+		default:
+			w.Header().Set("Content-Type", "image/png")
+		}
+		if r.Method == "GET" {
+			bodies.Add(1)
+		}
+		w.Write(make([]byte, 2000))
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/page", "/big.png"} {
+		if _, err := openDownload(context.Background(), server.URL+path); err == nil {
+			t.Errorf("%s was downloaded", path)
+		}
+	}
+	if n := bodies.Load(); n != 0 {
+		t.Errorf("%d refused downloads were fetched", n)
+	}
+
+	reader, err := openDownload(context.Background(), server.URL+"/unsized.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := io.ReadAll(reader); err == nil {
+		t.Error("a download over --max-download without a size was read whole")
+	}
+}
+
+// TestParallelDownloadChanged checks ranges of a file that changes on the
+// server aren't spliced together: If-Range gets the whole new file, which
+// is downloaded again from the start
+func TestParallelDownloadChanged(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	old := make([]byte, parallelDownloadMin+downloadChunkSize/3)
+	rand.New(rand.NewSource(1)).Read(old)
+	changed := make([]byte, len(old))
+	rand.New(rand.NewSource(2)).Read(changed)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, etag := old, `"1"`
+		if requests.Add(1) > 2 { // the HEAD request and the first range
+			content, etag = changed, `"2"`
+		}
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "big.png", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	defer func(connections int, plain bool) {
+		downloadConnections, plainChrome = connections, plain
+	}(downloadConnections, plainChrome)
+	downloadConnections, plainChrome = 2, true
+	reader, err := openDownload(context.Background(), server.URL+"/big.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, changed) {
+		t.Error("the download isn't the file as it is now")
+	}
+	part, state, err := downloadPaths(server.URL + "/big.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{part, state} {
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s is left behind", path)
+		}
+	}
+}
+
+// TestFetchRangeContentRange checks a range from elsewhere than asked for
+// isn't taken
+func TestFetchRangeContentRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-9/100")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(make([]byte, 10))
+	}))
+	defer server.Close()
+
+	info := rangeInfo{size: 100, etag: `"1"`}
+	var buf bytes.Buffer
+	if err := fetchRange(context.Background(), server.URL, info, &buf, 0, 10, noProgress{}); err != nil {
+		t.Errorf("the range asked for was refused: %v", err)
+	}
+	if err := fetchRange(context.Background(), server.URL, info, &buf, 10, 20, noProgress{}); err == nil {
+		t.Error("bytes 0-9 were taken for bytes 10-19")
+	}
+	info.size = 200
+	if err := fetchRange(context.Background(), server.URL, info, &buf, 0, 10, noProgress{}); !errors.Is(err, errDownloadChanged) {
+		t.Errorf("a range of a file of another size gave %v", err)
+	}
+}
+
+// TestParallelDownloadLocked checks a run doesn't write to the part file
+// another run is downloading into
+func TestParallelDownloadLocked(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	content := make([]byte, parallelDownloadMin+downloadChunkSize/3)
+	rand.New(rand.NewSource(1)).Read(content)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"1"`)
+		http.ServeContent(w, r, "big.png", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	part, _, err := downloadPaths(server.URL + "/big.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if err := lockFile(other); err != nil {
+		t.Skip("no file locks here:", err)
+	}
+	other.WriteString("the other run's")
+
+	defer func(connections int, plain bool) {
+		downloadConnections, plainChrome = connections, plain
+	}(downloadConnections, plainChrome)
+	downloadConnections, plainChrome = 2, true
+	reader, err := openDownload(context.Background(), server.URL+"/big.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("the download came out wrong")
+	}
+	if data, err := os.ReadFile(part); err != nil || string(data) != "the other run's" {
+		t.Errorf("the locked part file was changed: %q, %v", data, err)
+	}
+}
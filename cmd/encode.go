@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// OutputFormatVersion identifies the byte stream RenderImage and EncodeANSI
+// produce. it's bumped whenever the same image and renderer settings start
+// rendering to different bytes, so golden files can be regenerated on purpose
+const OutputFormatVersion = 3
+
+// ANSIOptions tweaks the edges of ANSI output for embedding it in prompts
+// (PS1), tmux status lines and other places where stray resets or newlines
+// break the layout
+type ANSIOptions struct {
+	NoTrailingNewline bool // leave out the newline after the last row
+	NoFinalReset      bool // keep the last row's colors active, the caller resets
+	RestoreCursor     bool // save the cursor before drawing and put it back afterwards
+	// Row and Col (1-based) place the output with cursor addressing instead
+	// of newlines, so nothing scrolls. 0 draws wherever the cursor is
+	Row, Col int
+}
+
+// parseCellPosition reads an --at value like "5,10": a 1-based row and
+// column
+func parseCellPosition(value string) (row, col int, err error) {
+	rowText, colText, ok := strings.Cut(value, ",")
+	if ok {
+		row, err = strconv.Atoi(strings.TrimSpace(rowText))
+	}
+	if ok && err == nil {
+		col, err = strconv.Atoi(strings.TrimSpace(colText))
+	}
+	if !ok || err != nil || row < 1 || col < 1 {
+		return 0, 0, fmt.Errorf("%q should be ROW,COL counting from 1", value)
+	}
+	return row, col, nil
+}
+
+// appendCursorPosition appends the CUP sequence moving to row, col
+func appendCursorPosition(buf []byte, row, col int) []byte {
+	buf = append(buf, "\033["...)
+	buf = strconv.AppendInt(buf, int64(row), 10)
+	buf = append(buf, ';')
+	buf = strconv.AppendInt(buf, int64(col), 10)
+	return append(buf, 'H')
+}
+
+// appendStatusLine appends the sequences replacing terminal row with line,
+// cleared in the default colors first
+func appendStatusLine(buf []byte, row int, line string) []byte {
+	buf = appendCursorPosition(buf, row, 1)
+	buf = append(buf, "\033[0m\033[2K"...)
+	return append(buf, line...)
+}
+
+// ANSI encodes the grid as terminal escape sequences, one line per row
+func (g *CellGrid) ANSI() string {
+	return g.EncodeANSI(ANSIOptions{})
+}
+
+// EncodeANSI is ANSI with control over the trailing newline, reset and cursor
+func (g *CellGrid) EncodeANSI(opts ANSIOptions) string {
+	w := &sgrWriter{opts: opts}
+	w.writeGrid(g)
+	return w.String()
+}
+
+// applyANSIOptions applies the parts of ANSIOptions that make sense for
+// output produced by backends other than the cell encoder
+func applyANSIOptions(output string, opts ANSIOptions) string {
+	if opts.NoTrailingNewline || opts.Row > 0 {
+		output = strings.TrimSuffix(output, "\n")
+	}
+	if opts.Row > 0 {
+		output = string(appendCursorPosition(nil, opts.Row, opts.Col)) + output
+	}
+	if opts.RestoreCursor {
+		output = "\0337" + output + "\0338"
+	}
+	return output
+}
+
+// Styled encodes the grid for embedding in lipgloss layouts: every line has
+// the same width, closes only the colors it opened (so surrounding styles
+// survive) and there is no trailing newline to throw off JoinHorizontal.
+func (g *CellGrid) Styled() string {
+	w := &sgrWriter{softReset: true, opts: ANSIOptions{NoTrailingNewline: true}}
+	w.writeGrid(g)
+	return w.String()
+}
+
+// HTML encodes the grid as a <pre> block with inline styles, handy for
+// pasting renders into web pages or docs
+func (g *CellGrid) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<pre style="line-height:1;font-family:monospace">`)
+	for y := 0; y < g.Height; y++ {
+		for _, c := range g.Row(y) {
+			c = c.opaque()
+			var style []string
+			if fg, ok := g.RGB(c.FG); ok && c.Glyph != ' ' {
+				style = append(style, fmt.Sprintf("color:#%02x%02x%02x", fg.R, fg.G, fg.B))
+			}
+			if bg, ok := g.RGB(c.BG); ok {
+				style = append(style, fmt.Sprintf("background:#%02x%02x%02x", bg.R, bg.G, bg.B))
+			}
+			glyph := html.EscapeString(string(c.Glyph))
+			if len(style) == 0 {
+				b.WriteString(glyph)
+				continue
+			}
+			fmt.Fprintf(&b, `<span style="%s">%s</span>`, strings.Join(style, ";"), glyph)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("</pre>\n")
+	return b.String()
+}
+
+// sgrWriter builds ANSI output and only emits color changes when they are
+// actually needed, which keeps the byte count down on slow links. it appends
+// to a plain byte slice so a FrameRenderer can reuse the buffer
+type sgrWriter struct {
+	buf    []byte
+	depth  ColorDepth
+	fg, bg int
+	skip   int // transparent cells to move the cursor over before the next one
+
+	softReset   bool // reset fg/bg only instead of every attribute
+	opts        ANSIOptions
+	escapeBytes int
+}
+
+func (w *sgrWriter) String() string {
+	return string(w.buf)
+}
+
+func (w *sgrWriter) writeGrid(g *CellGrid) {
+	w.depth = g.Depth
+	if w.opts.RestoreCursor {
+		w.escape("\0337") // DECSC
+	}
+	for y := 0; y < g.Height; y++ {
+		w.fg, w.bg = NoColor, NoColor
+		if w.opts.Row > 0 {
+			n := len(w.buf)
+			w.buf = appendCursorPosition(w.buf, w.opts.Row+y, w.opts.Col)
+			w.escapeBytes += len(w.buf) - n
+		}
+		for _, c := range g.Row(y) {
+			w.cell(c)
+		}
+		w.skip = 0 // nothing to draw after it, no need to move there
+
+		last := y == g.Height-1
+		if !last || !w.opts.NoFinalReset {
+			w.reset()
+		}
+		if (!last || !w.opts.NoTrailingNewline) && w.opts.Row == 0 {
+			w.buf = append(w.buf, '\n')
+		}
+	}
+	if w.opts.RestoreCursor {
+		w.escape("\0338") // DECRC
+	}
+}
+
+func (w *sgrWriter) cell(c Cell) {
+	if c.Glyph == TransparentGlyph {
+		if w.softReset {
+			c = c.opaque() // lipgloss measures lines, it has to be filled
+		} else {
+			w.skip++
+			return
+		}
+	}
+	if w.skip > 0 {
+		n := len(w.buf)
+		w.buf = append(w.buf, "\033["...)
+		w.buf = strconv.AppendInt(w.buf, int64(w.skip), 10)
+		w.buf = append(w.buf, 'C') // CUF
+		w.escapeBytes += len(w.buf) - n
+		w.skip = 0
+	}
+	// a space never shows its fg, so don't pay for switching it
+	if c.Glyph != ' ' && c.FG != w.fg {
+		w.color(c.FG, false)
+		w.fg = c.FG
+	}
+	if c.BG != w.bg {
+		w.color(c.BG, true)
+		w.bg = c.BG
+	}
+	w.buf = utf8.AppendRune(w.buf, c.Glyph)
+}
+
+func (w *sgrWriter) reset() {
+	switch {
+	case w.fg == NoColor && w.bg == NoColor:
+	case w.softReset && w.fg == NoColor:
+		w.escape("\033[49m")
+	case w.softReset && w.bg == NoColor:
+		w.escape("\033[39m")
+	case w.softReset:
+		w.escape("\033[39;49m")
+	default:
+		w.escape("\033[0m")
+	}
+}
+
+func (w *sgrWriter) escape(seq string) {
+	w.escapeBytes += len(seq)
+	w.buf = append(w.buf, seq...)
+}
+
+func (w *sgrWriter) color(code int, background bool) {
+	n := len(w.buf)
+	w.buf = appendSGRColor(w.buf, w.depth, code, background)
+	w.escapeBytes += len(w.buf) - n
+}
+
+// precomputed SGR sequences for the palette colors, index 0 is fg and 1 bg
+var (
+	sgr256 [2][256]string
+	sgr16  [2][16]string
+)
+
+func init() {
+	for i := 0; i < 256; i++ {
+		sgr256[0][i] = "\033[38;5;" + strconv.Itoa(i) + "m"
+		sgr256[1][i] = "\033[48;5;" + strconv.Itoa(i) + "m"
+	}
+	for i := 0; i < 16; i++ {
+		base := 30
+		if i >= 8 {
+			base = 90 - 8 // bright variants live at 90-97 / 100-107
+		}
+		sgr16[0][i] = "\033[" + strconv.Itoa(base+i) + "m"
+		sgr16[1][i] = "\033[" + strconv.Itoa(base+10+i) + "m"
+	}
+}
+
+// appendSGRColor appends the sequence selecting code as fg or bg color
+// without allocating
+func appendSGRColor(buf []byte, depth ColorDepth, code int, background bool) []byte {
+	layer := 0
+	if background {
+		layer = 1
+	}
+	if code == NoColor {
+		if background {
+			return append(buf, "\033[49m"...)
+		}
+		return append(buf, "\033[39m"...)
+	}
+
+	switch depth {
+	case TrueColor:
+		if background {
+			buf = append(buf, "\033[48;2;"...)
+		} else {
+			buf = append(buf, "\033[38;2;"...)
+		}
+		buf = strconv.AppendInt(buf, int64((code>>16)&0xff), 10)
+		buf = append(buf, ';')
+		buf = strconv.AppendInt(buf, int64((code>>8)&0xff), 10)
+		buf = append(buf, ';')
+		buf = strconv.AppendInt(buf, int64(code&0xff), 10)
+		return append(buf, 'm')
+	case Color16:
+		return append(buf, sgr16[layer][code&0x0f]...)
+	default:
+		return append(buf, sgr256[layer][code&0xff]...)
+	}
+}
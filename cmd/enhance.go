@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"image"
+	"sort"
+)
+
+const (
+	// enhanceClip is the share of the darkest and brightest pixels
+	// --auto-enhance lets clip, so a few specular highlights or black
+	// borders don't hold the stretch back
+	enhanceClip = 0.005
+	// enhanceMaxGain caps the stretch, so a foggy or nearly flat image
+	// doesn't get its noise blown up
+	enhanceMaxGain = 3.0
+	// enhanceSaturation is the mild saturation boost on top
+	enhanceSaturation = 1.2
+)
+
+// AutoEnhanceFilter stretches the image's brightness so it spans the full
+// range, ignoring the darkest and brightest half a percent of pixels, and
+// boosts the saturation a little. terminal palettes lose much of a photo's
+// range, and a stretched one survives them better. all channels get the
+// same stretch, so the colors keep their hue
+func AutoEnhanceFilter() Filter {
+	return func(img *image.RGBA) {
+		var lumas []float64
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			if alpha := uint32(img.Pix[i+3]); alpha != 0 {
+				c := Color{uint8(uint32(img.Pix[i]) * 255 / alpha), uint8(uint32(img.Pix[i+1]) * 255 / alpha), uint8(uint32(img.Pix[i+2]) * 255 / alpha)}
+				lumas = append(lumas, luminance(c))
+			}
+		}
+		if len(lumas) == 0 {
+			return
+		}
+		sort.Float64s(lumas)
+		clip := int(float64(len(lumas)) * enhanceClip)
+		low, high := lumas[clip], lumas[len(lumas)-1-clip]
+		gain := 255 / max(high-low, 255/enhanceMaxGain)
+		if high-low < 255/enhanceMaxGain {
+			middle := (low + high) / 2
+			low = middle - middle/gain // stretch around the middle of what's there, which stays put
+		}
+
+		var table [256]float64 // the stretch for every channel value
+		for v := range table {
+			table[v] = (float64(v) - low) * gain
+		}
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			alpha := uint32(img.Pix[i+3])
+			if alpha == 0 {
+				continue
+			}
+			var rgb [3]float64
+			for c := range rgb {
+				rgb[c] = table[min(uint32(img.Pix[i+c])*255/alpha, 255)] // the pixels are premultiplied
+			}
+			l := 0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]
+			for c, v := range rgb {
+				v = l + (v-l)*enhanceSaturation
+				img.Pix[i+c] = uint8(max(0, min(v, 255)) * float64(alpha) / 255)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// isEPUB reports whether source is an EPUB book, which show opens at its
+// cover. its other images are archive entries like any zip's
+func isEPUB(source string) bool {
+	return strings.EqualFold(path.Ext(source), ".epub")
+}
+
+// epubPackage is the parts of an EPUB's OPF package document that point
+// at the cover
+type epubPackage struct {
+	Meta []struct {
+		Name    string `xml:"name,attr"`
+		Content string `xml:"content,attr"`
+	} `xml:"metadata>meta"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+// epubCover finds the entry holding book's cover image: the manifest item
+// marked cover-image (EPUB 3) or named by the cover meta (EPUB 2), else the
+// first image with cover in its name, else the first image at all
+func epubCover(book string) (string, error) {
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := readEPUBXML(book, "META-INF/container.xml", &container); err != nil {
+		return "", err
+	}
+	if len(container.Rootfiles) == 0 {
+		return "", fmt.Errorf("%s has no package document", book)
+	}
+	opf := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := readEPUBXML(book, opf, &pkg); err != nil {
+		return "", err
+	}
+
+	coverID := ""
+	for _, meta := range pkg.Meta {
+		if meta.Name == "cover" {
+			coverID = meta.Content
+		}
+	}
+	var named, first string
+	for _, item := range pkg.Items {
+		if !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		href, err := url.PathUnescape(item.Href)
+		if err != nil {
+			href = item.Href
+		}
+		name := entryName(path.Join(path.Dir(opf), href))
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") || item.ID == coverID && coverID != "" {
+			return name, nil
+		}
+		if named == "" && strings.Contains(strings.ToLower(item.ID+" "+href), "cover") {
+			named = name
+		}
+		if first == "" {
+			first = name
+		}
+	}
+	if named != "" {
+		return named, nil
+	}
+	if first != "" {
+		return first, nil
+	}
+	return "", fmt.Errorf("%s has no images", book)
+}
+
+// readEPUBXML decodes the XML document at name in book into v
+func readEPUBXML(book, name string, v any) error {
+	rc, err := openArchiveEntry(book, name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	decoder := xml.NewDecoder(rc)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil // books declare all sorts, the parts read here are ASCII
+	}
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("couldn't read %s: %w", name, err)
+	}
+	return nil
+}
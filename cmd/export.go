@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CellGridSchemaVersion is bumped whenever the JSON export changes shape
+const CellGridSchemaVersion = 1
+
+type jsonCell struct {
+	Glyph   string `json:"glyph"`
+	FG      string `json:"fg,omitempty"`
+	BG      string `json:"bg,omitempty"`
+	FGIndex *int   `json:"fg_index,omitempty"`
+	BGIndex *int   `json:"bg_index,omitempty"`
+}
+
+type jsonGrid struct {
+	Version    int          `json:"version"`
+	Width      int          `json:"width"`
+	Height     int          `json:"height"`
+	ColorDepth string       `json:"color_depth"`
+	Rows       [][]jsonCell `json:"rows"`
+}
+
+// WriteJSON dumps the grid so programs in any language can re-encode or
+// post-process a render. colors are "#rrggbb" and left out for NoColor;
+// 16/256 color grids also carry the palette index. transparent cells have
+// an empty glyph
+func (g *CellGrid) WriteJSON(out io.Writer) error {
+	doc := jsonGrid{
+		Version:    CellGridSchemaVersion,
+		Width:      g.Width,
+		Height:     g.Height,
+		ColorDepth: colorDepthFlagValue(g.Depth),
+		Rows:       make([][]jsonCell, g.Height),
+	}
+
+	for y := 0; y < g.Height; y++ {
+		row := make([]jsonCell, g.Width)
+		for x, c := range g.Row(y) {
+			row[x] = jsonCell{Glyph: string(c.Glyph)}
+			if c.Glyph == TransparentGlyph {
+				row[x].Glyph = "" // see-through
+			}
+			row[x].FG, row[x].FGIndex = g.jsonColor(c.FG)
+			row[x].BG, row[x].BGIndex = g.jsonColor(c.BG)
+		}
+		doc.Rows[y] = row
+	}
+
+	encoder := json.NewEncoder(out)
+	return encoder.Encode(doc)
+}
+
+func (g *CellGrid) jsonColor(code int) (string, *int) {
+	rgb, ok := g.RGB(code)
+	if !ok {
+		return "", nil
+	}
+	hex := fmt.Sprintf("#%02x%02x%02x", rgb.R, rgb.G, rgb.B)
+	if g.Depth == TrueColor {
+		return hex, nil
+	}
+	index := code
+	return hex, &index
+}
+
+// colorDepthFlagValue is the inverse of parseColorDepth
+func colorDepthFlagValue(depth ColorDepth) string {
+	switch depth {
+	case Color16:
+		return "16"
+	case TrueColor:
+		return "true"
+	default:
+		return "256"
+	}
+}
+
+// WriteFarbfeld encodes img as farbfeld: "farbfeld", width and height as
+// big-endian uint32, then 16-bit big-endian non-premultiplied RGBA pixels
+func WriteFarbfeld(out io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	w := bufio.NewWriter(out)
+
+	header := make([]byte, 16)
+	copy(header, "farbfeld")
+	binary.BigEndian.PutUint32(header[8:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(header[12:], uint32(bounds.Dy()))
+	w.Write(header)
+
+	pixel := make([]byte, 8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			binary.BigEndian.PutUint16(pixel[0:], c.R)
+			binary.BigEndian.PutUint16(pixel[2:], c.G)
+			binary.BigEndian.PutUint16(pixel[4:], c.B)
+			binary.BigEndian.PutUint16(pixel[6:], c.A)
+			w.Write(pixel)
+		}
+	}
+	return w.Flush()
+}
+
+// WritePPM encodes img as a binary (P6) portable pixmap. PPM has no alpha,
+// so transparent areas come out black just like in the terminal render
+func WritePPM(out io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "P6\n%d %d\n255\n", bounds.Dx(), bounds.Dy())
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			w.Write([]byte{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+		}
+	}
+	return w.Flush()
+}
+
+// SaveImage writes img to path, picking the format from the extension
+// (.png, .jpg/.jpeg, .ff or .ppm)
+func SaveImage(path string, img image.Image) error {
+	var encode func(io.Writer, image.Image) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		encode = png.Encode
+	case ".jpg", ".jpeg":
+		encode = func(w io.Writer, img image.Image) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+		}
+	case ".ff":
+		encode = WriteFarbfeld
+	case ".ppm":
+		encode = WritePPM
+	default:
+		return fmt.Errorf("don't know how to write %q (use .png, .jpg, .ff or .ppm)", filepath.Base(path))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
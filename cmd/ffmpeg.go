@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ffmpegMaxWidth is as wide as ffmpeg scales frames before piping them
+// over. anything bigger only costs decoding time, no terminal shows it
+const ffmpegMaxWidth = 640
+
+// videoExtensions are local files play hands to ffmpeg instead of
+// decoding them as GIFs
+var videoExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mkv": true, ".webm": true, ".mov": true, ".avi": true, ".ts": true,
+}
+
+// needsFFmpeg tells whether play hands source to ffmpeg: RTSP cameras, HLS
+// playlists and video files, which Go can't demux on its own
+func needsFFmpeg(source string) bool {
+	if u, err := url.Parse(source); err == nil {
+		switch strings.ToLower(u.Scheme) {
+		case "rtsp", "rtsps":
+			return true
+		case "http", "https":
+			if strings.HasSuffix(strings.ToLower(u.Path), ".m3u8") {
+				return true
+			}
+		}
+	}
+	return videoExtensions[strings.ToLower(filepath.Ext(source))]
+}
+
+// ffmpegInput builds the start of an ffmpeg command line reading source.
+// RTSP is live, so ffmpeg's own buffering is turned off to keep the
+// latency down; HLS and files are read at their native rate (-re) since
+// ffmpeg would otherwise race through them
+func ffmpegInput(source string, seek, duration time.Duration) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+	if strings.HasPrefix(strings.ToLower(source), "rtsp") {
+		args = append(args, "-rtsp_transport", "tcp", "-fflags", "nobuffer", "-flags", "low_delay")
+	} else {
+		args = append(args, "-re")
+	}
+	if seek > 0 {
+		args = append(args, "-ss", ffmpegSeconds(seek))
+	}
+	args = append(args, "-i", source)
+	if duration > 0 {
+		args = append(args, "-t", ffmpegSeconds(duration))
+	}
+	return args
+}
+
+// ffmpegArgs decodes source's video into a pipe of MJPEG frames
+func ffmpegArgs(source string, seek, duration time.Duration, fpsCap float64) []string {
+	filter := fmt.Sprintf("scale='min(%d,iw)':-2", ffmpegMaxWidth)
+	if fpsCap > 0 {
+		filter = "fps=" + strconv.FormatFloat(fpsCap, 'f', -1, 64) + "," + filter
+	}
+	return append(ffmpegInput(source, seek, duration), "-an", "-vf", filter, "-f", "image2pipe", "-c:v", "mjpeg", "-q:v", "4", "-")
+}
+
+func ffmpegSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// ffmpegStream is ffmpeg's stdout. once it runs dry, Read reports how
+// ffmpeg exited, so a refused connection doesn't look like a clean end
+type ffmpegStream struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	err    error
+}
+
+// ffmpegCommand prepares an ffmpeg run. cancelling ctx asks it to quit
+// with an interrupt first, so it can close connections cleanly, and kills
+// it when that doesn't work within a couple of seconds
+func ffmpegCommand(ctx context.Context, args []string) (*exec.Cmd, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("videos and RTSP/HLS streams need ffmpeg installed: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			return cmd.Process.Kill() // no interrupts on Windows
+		}
+		return nil
+	}
+	cmd.WaitDelay = 2 * time.Second
+	return cmd, nil
+}
+
+// startFFmpeg runs ffmpeg to decode source's video
+func startFFmpeg(ctx context.Context, source string, seek, duration time.Duration, fpsCap float64) (*ffmpegStream, error) {
+	return runFFmpeg(ctx, ffmpegArgs(source, seek, duration, fpsCap))
+}
+
+// decodeFFmpeg runs ffmpeg to decode source's video at a constant fps as
+// fast as it can, for recording rather than watching
+func decodeFFmpeg(ctx context.Context, source string, duration time.Duration, fps float64) (*ffmpegStream, error) {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin", "-i", source}
+	if duration > 0 {
+		args = append(args, "-t", ffmpegSeconds(duration))
+	}
+	filter := "fps=" + strconv.FormatFloat(fps, 'f', -1, 64) + fmt.Sprintf(",scale='min(%d,iw)':-2", ffmpegMaxWidth)
+	return runFFmpeg(ctx, append(args, "-an", "-vf", filter, "-f", "image2pipe", "-c:v", "mjpeg", "-q:v", "4", "-"))
+}
+
+func runFFmpeg(ctx context.Context, args []string) (*ffmpegStream, error) {
+	cmd, err := ffmpegCommand(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &ffmpegStream{cmd: cmd, stderr: &bytes.Buffer{}}
+	cmd.Stderr = stream.stderr
+	if stream.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("couldn't start ffmpeg: %w", err)
+	}
+	return stream, nil
+}
+
+func (s *ffmpegStream) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.stdout.Read(p)
+	if err == io.EOF {
+		s.err = io.EOF
+		if waitErr := s.cmd.Wait(); waitErr != nil {
+			s.err = fmt.Errorf("ffmpeg %v: %s", waitErr, strings.TrimSpace(s.stderr.String()))
+		}
+		err = s.err
+	}
+	return n, err
+}
+
+// Close stops ffmpeg if it's still running and waits for it
+func (s *ffmpegStream) Close() error {
+	if s.err != nil {
+		return nil // already waited for
+	}
+	s.err = io.EOF
+	s.cmd.Cancel()
+	return s.cmd.Wait()
+}
+
+// ffprobeDuration asks ffprobe how long source runs. live streams, and
+// anything ffprobe can't tell, get 0
+func ffprobeDuration(ctx context.Context, source string) time.Duration {
+	path, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return 0
+	}
+	output, err := exec.CommandContext(ctx, path, "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", source).Output()
+	if err != nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0 // "N/A" for live streams
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// defaultFrameInterval is the frame time assumed until a video's own shows
+const defaultFrameInterval = 40 * time.Millisecond
+
+// videoSource plays a video or stream through ffmpeg, with its sound
+// unless muted. ffmpeg can't be told to jump while it runs, so pausing
+// and seeking stop it and the next frame starts a new run at the new
+// position
+type videoSource struct {
+	ctx    context.Context
+	source string
+	end    time.Duration // where --duration stops, 0 for the end
+	total  time.Duration // 0 for live streams
+	fpsCap float64
+	sound  bool
+
+	frames   frameSource // of the current run, nil while stopped
+	stop     func()
+	from     time.Duration // where the current or next run starts
+	started  time.Time     // when the run's first frame came
+	at       time.Duration
+	interval time.Duration // between frames, on average
+}
+
+// newVideoSource plays source from seek on, for duration if it's set. it
+// asks ffprobe for the length, RTSP cameras are always live
+func newVideoSource(ctx context.Context, source string, seek, duration time.Duration, fpsCap float64, sound bool) *videoSource {
+	s := &videoSource{ctx: ctx, source: source, fpsCap: fpsCap, sound: sound, from: seek, at: seek, interval: defaultFrameInterval}
+	if duration > 0 {
+		s.end = seek + duration
+	}
+	if !strings.HasPrefix(strings.ToLower(source), "rtsp") {
+		s.total = ffprobeDuration(ctx, source)
+	}
+	if s.end > 0 && s.total > s.end {
+		s.total = s.end
+	}
+	return s
+}
+
+// start runs ffmpeg from s.from. a seekable video starts half a frame
+// early, so the first frame is the one closest to s.from
+func (s *videoSource) start() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	from := s.from
+	if s.total > 0 {
+		from = max(from-s.interval/2, 0)
+	}
+	var duration time.Duration
+	if s.end > 0 {
+		duration = s.end - from
+	}
+
+	open := func() (io.ReadCloser, error) {
+		return startFFmpeg(ctx, s.source, from, duration, s.fpsCap)
+	}
+	stream := newStreamSource(ctx, open, streamJPEG, reopenNever, s.fpsCap)
+	s.frames = stream
+	var audio *audioPassthrough
+	if s.sound {
+		if audio, _ = startAudio(ctx, s.source, from, duration); audio != nil {
+			s.frames = &audioSyncedSource{frameSource: stream, ctx: ctx, audio: audio}
+		}
+	}
+	s.stop = func() {
+		cancel()
+		<-stream.done // let ffmpeg shut down
+		if audio != nil {
+			audio.wait()
+		}
+	}
+	s.started = time.Time{}
+}
+
+func (s *videoSource) next() (image.Image, time.Duration, error) {
+	if s.frames == nil {
+		if s.total > 0 && s.from >= s.total {
+			return nil, 0, io.EOF
+		}
+		s.start()
+	}
+	img, delay, err := s.frames.next()
+	if err != nil {
+		return img, delay, err
+	}
+
+	now := time.Now()
+	if s.started.IsZero() {
+		s.started, s.at = now, s.from
+	} else {
+		at := s.from + now.Sub(s.started)
+		s.interval = (3*s.interval + at - s.at) / 4
+		s.at = at
+	}
+	return img, delay, nil
+}
+
+func (s *videoSource) position() time.Duration { return s.at }
+func (s *videoSource) length() time.Duration   { return s.total }
+
+func (s *videoSource) previousFrame() time.Duration {
+	return max(s.at-s.interval, 0)
+}
+
+func (s *videoSource) seek(to time.Duration) {
+	s.close()
+	s.from, s.at = to, to
+}
+
+// pause stops ffmpeg. a live stream picks up where it is by then when it
+// goes on
+func (s *videoSource) pause() {
+	if s.frames == nil {
+		return
+	}
+	s.close()
+	s.from = 0
+	if s.total > 0 {
+		s.from = s.at + s.interval
+	}
+}
+
+// close stops the current run
+func (s *videoSource) close() {
+	if s.frames != nil {
+		s.stop()
+		s.frames = nil
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !zos && !windows
+
+package cmd
+
+import "os"
+
+// lockFile can't lock files here, so runs downloading the same file at
+// once aren't kept apart
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Filter adjusts the colors of an image in place. the renderer runs its
+// Filters on the scaled image right before quantizing, so they only touch
+// the samples that end up on screen
+type Filter func(img *image.RGBA)
+
+func (r *ImageRenderer) applyFilters(img *image.RGBA) {
+	for _, f := range r.Filters {
+		f(img)
+	}
+}
+
+// filtered returns a filtered copy of img for backends that send the image
+// itself instead of going through Scale. without filters img comes back as is
+func (r *ImageRenderer) filtered(img image.Image) image.Image {
+	if len(r.Filters) == 0 {
+		return img
+	}
+	return ApplyFilters(img, r.Filters...)
+}
+
+// ApplyFilters runs filters on an RGBA copy of img, e.g. to redact the
+// source image before it's rendered or exported
+func ApplyFilters(img image.Image, filters ...Filter) *image.RGBA {
+	copied := image.NewRGBA(img.Bounds())
+	draw.Draw(copied, copied.Rect, img, img.Bounds().Min, draw.Src)
+	for _, f := range filters {
+		f(copied)
+	}
+	return copied
+}
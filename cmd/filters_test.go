@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestDenoiseFilter(t *testing.T) {
+	// two flat halves with noise on top
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < len(img.Pix); i += 4 {
+		base := 60
+		if (i/4)%200 >= 100 {
+			base = 190
+		}
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = uint8(base + r.Intn(41) - 20)
+		}
+		img.Pix[i+3] = 255
+	}
+	variance := func() float64 {
+		sum, squares, n := 0.0, 0.0, 0.0
+		for y := 0; y < 100; y++ {
+			for x := 10; x < 90; x++ {
+				v := float64(img.Pix[img.PixOffset(x, y)])
+				sum, squares, n = sum+v, squares+v*v, n+1
+			}
+		}
+		return squares/n - (sum/n)*(sum/n)
+	}
+
+	before := variance()
+	filter, err := DenoiseFilter(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter(img)
+	if after := variance(); after > before/4 {
+		t.Errorf("the noise's variance went from %.1f to %.1f, want a quarter or less", before, after)
+	}
+	// the edge stays sharp
+	if left, right := img.Pix[img.PixOffset(99, 50)], img.Pix[img.PixOffset(100, 50)]; left > 80 || right < 170 {
+		t.Errorf("the edge blurred to %d | %d", left, right)
+	}
+
+	if _, err := DenoiseFilter(11); err == nil {
+		t.Error("strength 11 was accepted")
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/image/font/sfnt"
+)
+
+var (
+	fontText  string
+	fontSize  float64
+	fontIndex int
+	fontFG    string
+	fontBG    string
+)
+
+var fontCmd = &cobra.Command{
+	Use:   "font",
+	Short: "Work with font files",
+}
+
+var fontPreviewCmd = &cobra.Command{
+	Use:   "preview [font_file]",
+	Short: "Render sample text with a TrueType or OpenType font",
+	Long: `Draw sample text with a .ttf, .otf or .ttc font and render it like an image,
+to try out fonts where no font viewer runs, like over SSH:
+
+  termuwu font preview ./MyFont.ttf
+  termuwu font preview ./MyFont.otf --text "Sphinx of black quartz" --size 96
+  termuwu font preview /usr/share/fonts/noto/NotoSansCJK.ttc --index 2
+
+Newlines in --text start new lines. Characters the font has no glyph for
+are listed after the preview.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		if fontSize <= 0 {
+			fmt.Fprintln(statusOut, errorColor("❌ --size must be positive."))
+			return
+		}
+		fg, err := hexColor(fontFG)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --fg value:"), err)
+			return
+		}
+		bg, err := hexColor(fontBG)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --bg value:"), err)
+			return
+		}
+
+		f, err := loadFont(args[0], fontIndex)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading font:"), err)
+			return
+		}
+		img, missing, err := drawText(f, fontText, fontSize, fg, bg)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error drawing text:"), err)
+			return
+		}
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.ColorDepth = depth
+		if setupConsole().legacy {
+			renderer.Mode = BlockMode
+			renderer.ColorDepth = Color16
+			if err := writeLegacyConsole(renderer.Rasterize(img)); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error drawing to console:"), err)
+			}
+		} else {
+			fmt.Print(renderer.RenderImage(img))
+		}
+
+		name, _ := f.Name(nil, sfnt.NameIDFull)
+		if name == "" {
+			name = args[0]
+		}
+		fmt.Fprintf(statusOut, tr("🔤 %s %s, %d glyphs\n"), infoColor("Font:"), name, f.NumGlyphs())
+		if missing != "" {
+			fmt.Fprintf(statusOut, "⚠️  %s %s\n", infoColor("Not in the font:"), missing)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fontCmd)
+	fontCmd.AddCommand(fontPreviewCmd)
+
+	fontPreviewCmd.Flags().StringVarP(&fontText, "text", "t", "Sphinx of black quartz, judge my vow\n0123456789 !?&@", "Sample text to draw.")
+	fontPreviewCmd.Flags().Float64VarP(&fontSize, "size", "s", 64, "Font size in pixels before the preview is scaled to the terminal.")
+	fontPreviewCmd.Flags().IntVar(&fontIndex, "index", 0, "Which font of a .ttc/.otc collection to use.")
+	fontPreviewCmd.Flags().StringVar(&fontFG, "fg", "#ffffff", "Text color as #rrggbb.")
+	fontPreviewCmd.Flags().StringVar(&fontBG, "bg", "#000000", "Background color as #rrggbb.")
+	fontPreviewCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	fontPreviewCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	fontPreviewCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	fontPreviewCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	fontPreviewCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	fontPreviewCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// hexColor is a "#rrggbb" color as a uniform image to draw with
+func hexColor(value string) (*image.Uniform, error) {
+	r, g, b, err := parseHexColor(value)
+	if err != nil {
+		return nil, err
+	}
+	return image.NewUniform(color.RGBA{r, g, b, 255}), nil
+}
+
+// loadFont parses a font file, picking font index out of a collection
+func loadFont(path string, index int) (*sfnt.Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= collection.NumFonts() {
+		return nil, fmt.Errorf("%s has %d fonts, no index %d", path, collection.NumFonts(), index)
+	}
+	return collection.Font(index)
+}
+
+// drawText draws text at size pixels in fg over bg, a line per newline
+// with a margin around, and returns the characters f has no glyph for
+func drawText(f *sfnt.Font, text string, size float64, fg, bg image.Image) (*image.RGBA, string, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, "", err
+	}
+	defer face.Close()
+
+	lines := strings.Split(text, "\n")
+	metrics := face.Metrics()
+	lineHeight := max(metrics.Height.Ceil(), 1)
+	margin := int(size / 4)
+	width := 0
+	for _, line := range lines {
+		width = max(width, font.MeasureString(face, line).Ceil())
+	}
+	img := image.NewRGBA(image.Rect(0, 0, max(width, 1)+2*margin, lineHeight*len(lines)+2*margin))
+	draw.Draw(img, img.Bounds(), bg, image.Point{}, draw.Src)
+
+	drawer := &font.Drawer{Dst: img, Src: fg, Face: face}
+	for i, line := range lines {
+		drawer.Dot = fixed.P(margin, margin+i*lineHeight+metrics.Ascent.Ceil())
+		drawer.DrawString(line)
+	}
+
+	var missing []rune
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if r == '\n' || r == ' ' || strings.ContainsRune(string(missing), r) {
+			continue
+		}
+		if index, err := f.GlyphIndex(&buf, r); err == nil && index == 0 {
+			missing = append(missing, r)
+		}
+	}
+	return img, string(missing), nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import "image"
+
+// FrameRenderer renders a stream of frames (animations, video) with an
+// ImageRenderer while reusing the scaled frame, the cell grid and the output
+// buffer between calls. once the first frame has sized everything, frames
+// of the same size render without allocating in the half-block, block and
+// braille (fg) modes
+type FrameRenderer struct {
+	*ImageRenderer
+
+	scaled *image.RGBA
+	grid   *CellGrid
+	w      sgrWriter
+}
+
+func NewFrameRenderer(r *ImageRenderer) *FrameRenderer {
+	return &FrameRenderer{ImageRenderer: r}
+}
+
+// RenderFrame encodes img as ANSI. the returned slice is only valid until
+// the next call
+func (f *FrameRenderer) RenderFrame(img image.Image) []byte {
+	f.scaled = f.scaleInto(f.scaled, img)
+	return f.RenderScaledFrame(f.scaled)
+}
+
+// RasterizeFrame is RenderFrame stopping at the cell grid, for frames that
+// end up somewhere other than a terminal. the grid is shared like Grid's
+func (f *FrameRenderer) RasterizeFrame(img image.Image) *CellGrid {
+	f.scaled = f.scaleInto(f.scaled, img)
+	f.grid = f.rasterizeInto(f.grid, f.scaled)
+	return f.grid
+}
+
+// RenderScaledFrame is RenderFrame for an image that is already at the
+// mode's sample resolution (see Scale), e.g. one drawn directly into a
+// canvas. it skips scaling
+func (f *FrameRenderer) RenderScaledFrame(scaled *image.RGBA) []byte {
+	f.grid = f.rasterizeInto(f.grid, scaled)
+
+	f.w = sgrWriter{buf: f.w.buf[:0], opts: f.ANSI}
+	f.w.writeGrid(f.grid)
+	return f.w.buf
+}
+
+// Grid returns the cell grid of the last frame, shared with the renderer
+func (f *FrameRenderer) Grid() *CellGrid {
+	return f.grid
+}
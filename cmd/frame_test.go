@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFrameRendererMatchesRenderImage(t *testing.T) {
+	img := loadFixture(t, "gradient.png")
+	for _, mode := range goldenModes {
+		for _, depth := range goldenDepths {
+			r := NewDeterministicRenderer(mode)
+			r.MaxWidth, r.MaxHeight = 24, 12
+			r.ColorDepth = depth
+
+			f := NewFrameRenderer(r)
+			for i := 0; i < 2; i++ {
+				if got, want := f.RenderFrame(img), []byte(r.RenderImage(img)); !bytes.Equal(got, want) {
+					t.Errorf("%s/%s frame %d differs from RenderImage", mode, depth, i)
+				}
+			}
+		}
+	}
+}
+
+func TestFrameRendererDoesNotAllocate(t *testing.T) {
+	img := loadFixture(t, "gradient.png")
+	for _, depth := range goldenDepths {
+		r := NewDeterministicRenderer(HalfBlockMode)
+		r.ColorDepth = depth
+		f := NewFrameRenderer(r)
+		f.RenderFrame(img)
+
+		if allocs := testing.AllocsPerRun(20, func() { f.RenderFrame(img) }); allocs > 0 {
+			t.Errorf("%s: %.0f allocations per frame", depth, allocs)
+		}
+	}
+}
+
+// TestRenderImageReusesGrids checks one-shot renders take their grids and
+// buffers back from the pools instead of allocating them every time. the
+// race detector drops some of what's put back, so it's only most of them
+func TestRenderImageReusesGrids(t *testing.T) {
+	img := loadFixture(t, "gradient.png")
+	r := NewDeterministicRenderer(HalfBlockMode)
+	r.RenderImage(img)
+
+	before := poolStats()
+	const renders = 20
+	for range renders {
+		r.RenderImage(img)
+	}
+	after := poolStats()
+	if gets := after.Grids - before.Grids; gets != renders {
+		t.Errorf("%d grids taken for %d renders", gets, renders)
+	}
+	if allocs := after.GridAllocs - before.GridAllocs; allocs > renders/2 {
+		t.Errorf("%d of %d grids allocated", allocs, renders)
+	}
+	if allocs := after.BufferAllocs - before.BufferAllocs; allocs > renders/2 {
+		t.Errorf("%d of %d buffers allocated", allocs, renders)
+	}
+}
+
+func BenchmarkRenderFrame(b *testing.B) {
+	img := loadFixture(b, "gradient.png")
+	for _, depth := range goldenDepths {
+		b.Run(strings.Fields(depth.String())[0], func(b *testing.B) {
+			r := NewDeterministicRenderer(HalfBlockMode)
+			r.ColorDepth = depth
+			f := NewFrameRenderer(r)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				f.RenderFrame(img)
+			}
+		})
+	}
+}
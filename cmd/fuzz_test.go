@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzDecodeImage feeds malformed files to the decoders. the fixtures and
+// their truncated copies seed the corpus
+func FuzzDecodeImage(f *testing.F) {
+	fixtures, err := os.ReadDir(filepath.Join("testdata", "fixtures"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, fixture := range fixtures {
+		data, err := os.ReadFile(filepath.Join("testdata", "fixtures", fixture.Name()))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+		f.Add(data[:len(data)/2])
+	}
+	f.Add([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, _, err := DecodeImage(bytes.NewReader(data))
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) {
+			t.Fatalf("decoder panicked: %v", panicErr)
+		}
+		if err != nil {
+			return
+		}
+
+		r := NewDeterministicRenderer(HalfBlockMode)
+		r.MaxWidth, r.MaxHeight = 16, 8
+		if _, err := r.TryRenderImage(img); err != nil {
+			t.Fatalf("couldn't render decoded image: %v", err)
+		}
+	})
+}
+
+// FuzzRender runs the emitters with arbitrary image and terminal sizes
+func FuzzRender(f *testing.F) {
+	f.Add(uint8(8), uint8(8), int16(24), int16(12), uint8(0), uint8(0), []byte{0, 128, 255})
+	f.Add(uint8(1), uint8(1), int16(1), int16(1), uint8(1), uint8(2), []byte{42})
+	f.Add(uint8(200), uint8(3), int16(0), int16(-5), uint8(2), uint8(1), []byte{})
+
+	f.Fuzz(func(t *testing.T, width, height uint8, cols, rows int16, mode, depth uint8, pixels []byte) {
+		if width == 0 || height == 0 {
+			return
+		}
+		img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+		for i := range img.Pix {
+			if len(pixels) > 0 {
+				img.Pix[i] = pixels[i%len(pixels)]
+			}
+		}
+		img.Set(0, 0, color.Transparent)
+
+		r := NewDeterministicRenderer(RenderMode(mode % 5))
+		r.MaxWidth, r.MaxHeight = int(cols), int(rows)
+		r.ColorDepth = ColorDepth(depth % 3)
+		r.BrailleColor = BrailleColorMode(mode % 3)
+		if _, err := r.TryRenderImage(img); err != nil {
+			t.Fatalf("%dx%d image at %dx%d cells: %v", width, height, cols, rows, err)
+		}
+	})
+}
+
+// FuzzParseANSIArt feeds broken escape sequences and SAUCE records to the
+// art parser, the grid has to stay inside its limits
+func FuzzParseANSIArt(f *testing.F) {
+	f.Add([]byte("\x1b[1;31mHi\x1b[0m \xdb\xb0\r\n\x1b[44m blue\x1b[0m\x1a"), 0)
+	f.Add([]byte("\x1b[38;2;255;0;128m\xe2\x96\x88\x1b[38;5;200m\x1b[10;999H*\x1b[s\x1b[99B\x1b[u"), 40)
+	f.Add([]byte("\x1b[2J\x1b[K\x1b[?7h\x1b[38;5"), 1)
+	f.Add(append(bytes.Repeat([]byte{' '}, 10), append([]byte("SAUCE00"), make([]byte, 121)...)...), -3)
+
+	f.Fuzz(func(t *testing.T, data []byte, width int) {
+		grid := ParseANSIArt(data, width)
+		if grid.Width < 1 || grid.Width > ansiArtMaxWidth || grid.Height < 1 || grid.Height > ansiArtMaxHeight {
+			t.Fatalf("grid is %dx%d cells", grid.Width, grid.Height)
+		}
+		grid.ANSI()
+	})
+}
+
+// FuzzDecodeDataURI checks data URIs decode without panicking and that any
+// payload comes back from its base64 data URI
+func FuzzDecodeDataURI(f *testing.F) {
+	f.Add("data:image/png;base64,iVBORw0KGgo=", []byte("\x89PNG"))
+	f.Add("data:,hello%20there", []byte{})
+	f.Add("DATA:;BASE64,_-8", []byte{0xff, 0xfe})
+	f.Add("data:image/gif", []byte("GIF89a"))
+
+	f.Fuzz(func(t *testing.T, uri string, payload []byte) {
+		if isDataURI(uri) {
+			decodeDataURI(uri)
+		}
+		data, _, err := decodeDataURI("data:image/png;base64," + base64.StdEncoding.EncodeToString(payload))
+		if err != nil || !bytes.Equal(data, payload) {
+			t.Fatalf("payload %x came back as %x (%v)", payload, data, err)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+)
+
+var (
+	galleryColumns int
+	galleryWidth   int
+	galleryHeight  int
+	galleryBraille bool
+	galleryFull    bool
+	galleryColors  string
+	galleryList    bool
+	gallerySort    string
+)
+
+var galleryCmd = &cobra.Command{
+	Use:   "gallery [directory_or_archive]",
+	Short: "Show the images in a directory or a zip, tar, tar.gz or rar archive as thumbnails",
+	Long: `Render every image in a directory or an archive as a grid of thumbnails
+with their names, reading archives in place without extracting them:
+
+  termuwu gallery ~/Pictures/trip
+  termuwu gallery photos.zip --columns 4
+  termuwu gallery scans.tar.gz --list
+
+Any image shown can be opened on its own with show, an archive's entries
+with archive!/entry:
+
+  termuwu show photos.zip!/2024/img01.jpg
+
+tar and rar archives are read front to back once, the thumbnails come out as their
+images stream by.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		var sortBy string
+		if gallerySort != "" {
+			var err error
+			if sortBy, err = parseGallerySort(gallerySort); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --sort value:"), err)
+				return
+			}
+		}
+		skip := func(name string, err error) {
+			fmt.Fprintf(statusOut, "⚠️  %s %s: %v\n", infoColor("Skipping"), name, err)
+		}
+		readSorted := func() ([]galleryImage, bool) {
+			fmt.Fprintf(statusOut, tr("🔎 %s %s, reading every image first\n"), infoColor("Sorting by"), sortBy)
+			sorted, err := sortedGallery(args[0], sortBy, skip)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading images:"), err)
+				return nil, false
+			}
+			return sorted, true
+		}
+
+		if galleryList && sortBy != "" {
+			sorted, ok := readSorted()
+			if !ok {
+				return
+			}
+			for _, img := range sorted {
+				fmt.Printf("%s  %s\n", img.Name, dimColor(formatByteSize(img.Size)))
+			}
+			fmt.Fprintf(statusOut, tr("🖼️  %s %d\n"), infoColor("Images:"), len(sorted))
+			return
+		}
+		if galleryList {
+			count := 0
+			err := walkImages(args[0], func(entry archiveEntry) error {
+				fmt.Printf("%s  %s\n", entry.Name, dimColor(formatByteSize(entry.Size)))
+				count++
+				return nil
+			})
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading images:"), err)
+				return
+			}
+			fmt.Fprintf(statusOut, tr("🖼️  %s %d\n"), infoColor("Images:"), count)
+			return
+		}
+
+		depth, err := parseColorDepth(galleryColors)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		if galleryWidth < 1 || galleryHeight < 1 {
+			fmt.Fprintln(statusOut, errorColor("❌ --width and --height must be positive."))
+			return
+		}
+		const gap = 2
+		columns := galleryColumns
+		if columns <= 0 {
+			frame := configureRenderer(false, false, false, 0, 0)
+			columns = max((frame.MaxWidth+gap)/(galleryWidth+gap), 1)
+		}
+		renderer := configureRenderer(galleryFull, galleryBraille, false, galleryWidth, galleryHeight)
+		renderer.ColorDepth = depth
+		if err := fitGlyphs(renderer); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+			return
+		}
+
+		var row [][]string
+		flush := func() {
+			printTiles(row, galleryWidth, gap)
+			row = row[:0]
+		}
+		count := 0
+		add := func(name string, img image.Image) {
+			row = append(row, thumbnailTile(renderer, img, path.Base(name), galleryWidth))
+			count++
+			if len(row) == columns {
+				flush()
+			}
+		}
+		if sortBy != "" {
+			sorted, ok := readSorted()
+			if !ok {
+				return
+			}
+			for _, img := range sorted {
+				add(img.Name, img.Thumb)
+			}
+		} else {
+			err = walkImages(args[0], func(entry archiveEntry) error {
+				rc, err := entry.Open()
+				if err != nil {
+					return err
+				}
+				img, _, err := DecodeImage(rc)
+				rc.Close()
+				if err != nil {
+					skip(entry.Name, err)
+					return nil
+				}
+				add(entry.Name, img)
+				return nil
+			})
+		}
+		if len(row) > 0 {
+			flush()
+		}
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading images:"), err)
+			return
+		}
+		if count == 0 {
+			fmt.Fprintf(statusOut, "⚠️  %s %s\n", infoColor("No images in"), args[0])
+		}
+	},
+}
+
+// thumbnailTile rasterizes img with label above it, every line padded to
+// width cells
+func thumbnailTile(renderer *ImageRenderer, img image.Image, label string, width int) []string {
+	nameColor := themeColor("name", color.FgMagenta, color.Bold).SprintFunc()
+	grid := renderer.Rasterize(img)
+	label = runewidth.Truncate(label, width, "…")
+	tile := []string{nameColor(label) + strings.Repeat(" ", width-runewidth.StringWidth(label))}
+	for _, line := range strings.Split(grid.Styled(), "\n") {
+		tile = append(tile, line+strings.Repeat(" ", width-grid.Width))
+	}
+	return tile
+}
+
+// printTiles prints a row of tiles side by side, gap cells apart, and an
+// empty line below
+func printTiles(row [][]string, width, gap int) {
+	lines := 0
+	for _, tile := range row {
+		lines = max(lines, len(tile))
+	}
+	for y := 0; y < lines; y++ {
+		cells := make([]string, len(row))
+		for i, tile := range row {
+			cells[i] = strings.Repeat(" ", width)
+			if y < len(tile) {
+				cells[i] = tile[y]
+			}
+		}
+		fmt.Println(strings.TrimRight(strings.Join(cells, strings.Repeat(" ", gap)), " "))
+	}
+	fmt.Println()
+}
+
+func init() {
+	rootCmd.AddCommand(galleryCmd)
+
+	galleryCmd.Flags().IntVar(&galleryColumns, "columns", 0, "Thumbnails per row (0 to fit the terminal).")
+	galleryCmd.Flags().IntVarP(&galleryWidth, "width", "W", 24, "Width of each thumbnail in characters.")
+	galleryCmd.Flags().IntVarP(&galleryHeight, "height", "H", 10, "Height of each thumbnail in lines.")
+	galleryCmd.Flags().BoolVarP(&galleryBraille, "braille", "b", false, "Draw the thumbnails with Braille patterns.")
+	galleryCmd.Flags().BoolVarP(&galleryFull, "full", "f", false, "Draw the thumbnails with full character blocks.")
+	galleryCmd.Flags().StringVar(&galleryColors, "colors", "256", "Color depth to render with: 256, 16 or true.")
+	galleryCmd.Flags().BoolVar(&galleryList, "list", false, "Only list the images and their sizes.")
+	galleryCmd.Flags().StringVar(&gallerySort, "sort", "", "Order the images by hue, brightness or similarity instead of by name, to scan big photo dumps.")
+}
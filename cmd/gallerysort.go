@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// galleryFeatures are what gallery --sort orders images by
+type galleryFeatures struct {
+	Hue        float64     // of the most common colorful color, -1 for gray images
+	Brightness float64     // mean luma, 0-255
+	Layout     [48]float64 // the average colors of a 4x4 grid, for similarity
+}
+
+func parseGallerySort(value string) (string, error) {
+	switch value = strings.ToLower(value); value {
+	case "hue", "brightness", "similarity":
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown sort %q (use hue, brightness or similarity)", value)
+}
+
+// imageFeatures works out the features of img, best from a thumbnail
+func imageFeatures(img image.Image) galleryFeatures {
+	f := galleryFeatures{Hue: -1}
+	for _, c := range dominantColors(img, 6) {
+		if hue, saturation, lightness := hsl(c.Color); isChromatic(saturation, lightness) {
+			f.Hue = hue
+			break
+		}
+	}
+
+	small := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	for i := 0; i < 16; i++ {
+		p := small.Pix[i*4:]
+		f.Layout[i*3], f.Layout[i*3+1], f.Layout[i*3+2] = float64(p[0]), float64(p[1]), float64(p[2])
+		f.Brightness += (0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])) / 16
+	}
+	return f
+}
+
+// galleryOrder is the order to show images with features in: by hue around
+// the color wheel with the gray ones last from dark to light, by
+// brightness from dark to light, or by similarity, each image followed by
+// the one that looks most like it of those left. ties keep their order
+func galleryOrder(features []galleryFeatures, by string) []int {
+	order := make([]int, len(features))
+	for i := range order {
+		order[i] = i
+	}
+	switch by {
+	case "hue":
+		sort.SliceStable(order, func(a, b int) bool {
+			fa, fb := features[order[a]], features[order[b]]
+			if (fa.Hue < 0) != (fb.Hue < 0) {
+				return fb.Hue < 0
+			}
+			if fa.Hue != fb.Hue {
+				return fa.Hue < fb.Hue
+			}
+			return fa.Brightness < fb.Brightness
+		})
+	case "brightness":
+		sort.SliceStable(order, func(a, b int) bool {
+			return features[order[a]].Brightness < features[order[b]].Brightness
+		})
+	case "similarity":
+		// a greedy walk from the first image: not the shortest path
+		// through all of them, but close and quick
+		for i := 1; i < len(order); i++ {
+			last := features[order[i-1]].Layout
+			best, bestDistance := i, math.Inf(1)
+			for j := i; j < len(order); j++ {
+				if d := layoutDistance(last, features[order[j]].Layout); d < bestDistance {
+					best, bestDistance = j, d
+				}
+			}
+			// move it up, keeping the rest in their order for the ties
+			chosen := order[best]
+			copy(order[i+1:best+1], order[i:best])
+			order[i] = chosen
+		}
+	}
+	return order
+}
+
+func layoutDistance(a, b [48]float64) float64 {
+	sum := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// galleryImage is an image gallery --sort read ahead, shrunk to a thumbnail
+type galleryImage struct {
+	Name  string
+	Size  int64
+	Thumb image.Image
+}
+
+// galleryThumbSize is big enough for a tile of any sensible size
+const galleryThumbSize = 256
+
+// sortedGallery reads every image in dir and returns them in the order by
+// wants. the ones that don't decode are passed to skip
+func sortedGallery(dir, by string, skip func(name string, err error)) ([]galleryImage, error) {
+	var images []galleryImage
+	var features []galleryFeatures
+	err := walkImages(dir, func(entry archiveEntry) error {
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		img, _, err := DecodeImage(rc)
+		rc.Close()
+		if err != nil {
+			skip(entry.Name, err)
+			return nil
+		}
+		thumb := Thumbnail(img, galleryThumbSize)
+		images = append(images, galleryImage{entry.Name, entry.Size, thumb})
+		features = append(features, imageFeatures(thumb))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]galleryImage, len(images))
+	for i, index := range galleryOrder(features, by) {
+		sorted[i] = images[index]
+	}
+	return sorted, nil
+}
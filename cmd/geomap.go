@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"golang.org/x/image/draw"
+)
+
+// defaultTileServer is the OpenStreetMap tile server, whose usage policy
+// asks for a descriptive User-Agent and cached tiles
+const defaultTileServer = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+
+const (
+	mapTileSize  = 256
+	mapSize      = 512                 // the map is cut to this square around the position
+	mapTileCache = 30 * 24 * time.Hour // tiles are fetched again when older
+
+	// a tile server is whatever --map-server says, so what it sends is bounded
+	// before it's decoded: tiles are tens of KB, and at most 512 pixels for
+	// high resolution screens
+	maxTileBytes = 4 << 20
+	maxTileSide  = 4 * mapTileSize
+)
+
+// GeoPoint is a position in degrees, north and east positive
+type GeoPoint struct {
+	Lat, Lon float64
+}
+
+func (p GeoPoint) String() string {
+	return fmt.Sprintf("%.6f, %.6f", p.Lat, p.Lon)
+}
+
+// DMS writes p in degrees, minutes and seconds, like 48°51'29.6"N
+func (p GeoPoint) DMS() string {
+	dms := func(v float64, pos, neg string) string {
+		hemisphere := pos
+		if v < 0 {
+			hemisphere, v = neg, -v
+		}
+		deg := math.Floor(v)
+		minutes := math.Floor((v - deg) * 60)
+		seconds := (v - deg - minutes/60) * 3600
+		return fmt.Sprintf("%.0f°%02.0f'%04.1f\"%s", deg, minutes, seconds, hemisphere)
+	}
+	return dms(p.Lat, "N", "S") + " " + dms(p.Lon, "E", "W")
+}
+
+// PhotoLocation reads the GPS position from the EXIF data of a JPEG or
+// TIFF photo
+func PhotoLocation(r io.Reader) (GeoPoint, error) {
+	x, err := exif.Decode(r)
+	if err != nil {
+		return GeoPoint{}, errors.New("no EXIF data")
+	}
+	lat, lon, err := x.LatLong()
+	if err != nil {
+		return GeoPoint{}, errors.New("no GPS position in the EXIF data")
+	}
+	if math.IsNaN(lat) || math.IsNaN(lon) || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return GeoPoint{}, fmt.Errorf("invalid GPS position %.6f, %.6f", lat, lon)
+	}
+	return GeoPoint{lat, lon}, nil
+}
+
+// mapPixel is where p is on the web mercator map of the world at zoom,
+// in pixels from its top left corner
+func mapPixel(p GeoPoint, zoom int) (x, y float64) {
+	size := float64(int(mapTileSize) << zoom)
+	lat := p.Lat * math.Pi / 180
+	x = (p.Lon + 180) / 360 * size
+	y = (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * size
+	return x, min(max(y, 0), size-1) // mercator ends at about 85° north and south
+}
+
+// LocationMap draws the map around p at zoom from the tiles of server, a
+// URL with {z}, {x} and {y} in it, with a marker at p
+func LocationMap(ctx context.Context, p GeoPoint, zoom int, server string) (*image.RGBA, error) {
+	cx, cy := mapPixel(p, zoom)
+	origin := image.Pt(int(cx)-mapSize/2, int(cy)-mapSize/2)
+	out := image.NewRGBA(image.Rect(0, 0, mapSize, mapSize))
+	draw.Draw(out, out.Bounds(), &image.Uniform{color.RGBA{221, 221, 221, 255}}, image.Point{}, draw.Src)
+
+	tiles := 1 << zoom
+	floorDiv := func(a int) int { return int(math.Floor(float64(a) / mapTileSize)) }
+	for ty := floorDiv(origin.Y); ty <= floorDiv(origin.Y+mapSize-1); ty++ {
+		if ty < 0 || ty >= tiles {
+			continue
+		}
+		for tx := floorDiv(origin.X); tx <= floorDiv(origin.X+mapSize-1); tx++ {
+			tile, err := mapTile(ctx, server, zoom, ((tx%tiles)+tiles)%tiles, ty)
+			if err != nil {
+				return nil, err
+			}
+			at := image.Pt(tx*mapTileSize, ty*mapTileSize).Sub(origin)
+			draw.Draw(out, image.Rectangle{Min: at, Max: at.Add(image.Pt(mapTileSize, mapTileSize))}, tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+	drawMarker(out, image.Pt(int(cx)-origin.X, int(cy)-origin.Y))
+	return out, nil
+}
+
+// drawMarker puts a red dot with a white ring at center
+func drawMarker(img *image.RGBA, center image.Point) {
+	for _, ring := range []struct {
+		radius int
+		c      color.RGBA
+	}{{11, color.RGBA{255, 255, 255, 255}}, {8, color.RGBA{220, 30, 40, 255}}} {
+		r2 := ring.radius * ring.radius
+		for y := -ring.radius; y <= ring.radius; y++ {
+			for x := -ring.radius; x <= ring.radius; x++ {
+				if x*x+y*y <= r2 {
+					img.SetRGBA(center.X+x, center.Y+y, ring.c)
+				}
+			}
+		}
+	}
+}
+
+// mapTile fetches tile x, y at zoom from server, or reads it from the
+// cache directory when it was fetched before
+func mapTile(ctx context.Context, server string, zoom, x, y int) (image.Image, error) {
+	url := strings.NewReplacer("{z}", strconv.Itoa(zoom), "{x}", strconv.Itoa(x), "{y}", strconv.Itoa(y)).Replace(server)
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(dir, "termuwu", "tiles", hex.EncodeToString(sum[:16])+".png")
+
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < mapTileCache {
+		if img, err := decodeTile(path); err == nil {
+			return img, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tile URL: %w", err)
+	}
+	req.Header.Set("User-Agent", "termuwu/"+rootCmd.Version+" (+https://github.com/coffeeboi0811/termuwu)")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't download map tile: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("couldn't download map tile %s: received status code %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't download map tile: %w", err)
+	}
+	if len(data) > maxTileBytes {
+		return nil, fmt.Errorf("map tile %s is over %s", url, formatByteSize(maxTileBytes))
+	}
+	img, err := decodeTileData(data)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode map tile %s: %w", url, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+		os.WriteFile(path, data, 0o644) // a failed cache write only costs a download next time
+	}
+	return img, nil
+}
+
+func decodeTile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxTileBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTileBytes {
+		return nil, fmt.Errorf("cached map tile is over %s", formatByteSize(maxTileBytes))
+	}
+	return decodeTileData(data)
+}
+
+// decodeTileData decodes a tile, refusing ones bigger than maxTileSide
+// before their pixels are allocated
+func decodeTileData(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width > maxTileSide || cfg.Height > maxTileSide {
+		return nil, fmt.Errorf("tile is %dx%d, over %d pixels across", cfg.Width, cfg.Height, maxTileSide)
+	}
+	img, _, err := DecodeImage(bytes.NewReader(data))
+	return img, err
+}
+
+// besideMap puts the map, scaled to the photo's height, to the right of it
+func besideMap(photo image.Image, m image.Image) image.Image {
+	h := photo.Bounds().Dy()
+	scaled := image.NewRGBA(image.Rect(0, 0, h, h))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), m, m.Bounds(), draw.Src, nil)
+	return Stitch([]image.Image{photo, scaled}, false)
+}
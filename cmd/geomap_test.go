@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMapPixel(t *testing.T) {
+	for _, tc := range []struct {
+		p      GeoPoint
+		zoom   int
+		tx, ty int
+	}{
+		{GeoPoint{0, 0}, 1, 1, 1},
+		{GeoPoint{51.5007, -0.1246}, 15, 16372, 10897},
+		{GeoPoint{-33.8568, 151.2153}, 12, 3768, 2457},
+	} {
+		x, y := mapPixel(tc.p, tc.zoom)
+		if tx, ty := int(x)/mapTileSize, int(y)/mapTileSize; tx != tc.tx || ty != tc.ty {
+			t.Errorf("%v at zoom %d: tile %d/%d, want %d/%d", tc.p, tc.zoom, tx, ty, tc.tx, tc.ty)
+		}
+	}
+	if _, y := mapPixel(GeoPoint{89.9, 0}, 2); y < 0 || math.IsNaN(y) {
+		t.Errorf("y = %v near the pole, want it clamped to the map", y)
+	}
+}
+
+func TestGeoPointDMS(t *testing.T) {
+	if got, want := (GeoPoint{48.858222, -2.2945}).DMS(), `48°51'29.6"N 2°17'40.2"W`; got != want {
+		t.Errorf("DMS() = %s, want %s", got, want)
+	}
+}
+
+// TestMapTileLimits checks a tile server can't make termuwu read or
+// allocate much: oversized bodies and tiles claiming a huge size are refused
+func TestMapTileLimits(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	encode := func(size int) []byte {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, size, size)))
+		return buf.Bytes()
+	}
+	tiles := map[string][]byte{
+		"/tile.png":  encode(mapTileSize),
+		"/bomb.png":  encode(8 * mapTileSize), // a few KB of zeros, a lot of pixels
+		"/large.png": make([]byte, maxTileBytes+1),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tiles[r.URL.Path])
+	}))
+	defer server.Close()
+
+	if img, err := mapTile(context.Background(), server.URL+"/tile.png", 1, 0, 0); err != nil || img.Bounds().Dx() != mapTileSize {
+		t.Errorf("a plain tile gave %v", err)
+	}
+	for _, name := range []string{"bomb", "large"} {
+		if _, err := mapTile(context.Background(), server.URL+"/"+name+".png", 1, 0, 0); err == nil {
+			t.Errorf("the %s tile was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gitDiffTolerance int
+	gitDiffOnly      bool
+)
+
+// gitSource is the rev:path inside a git: or git:// source, like
+// git:HEAD~3:assets/logo.png
+func gitSource(source string) (string, bool) {
+	for _, prefix := range []string{"git://", "git:"} {
+		if rest, ok := strings.CutPrefix(source, prefix); ok && strings.Contains(rest, ":") {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// readGitBlob reads rev:path out of the repository the current directory
+// is in. like git's own syntax, path is from the top of the repository
+// unless it starts with ./ or ../
+func readGitBlob(object string) ([]byte, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errors.New("git needs to be installed to read from a repository")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "cat-file", "blob", object)
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git: %s", strings.TrimPrefix(message, "fatal: "))
+		}
+		return nil, fmt.Errorf("git failed: %w", err)
+	}
+	return data, nil
+}
+
+// gitRelative turns path, relative to the current directory, into the
+// ./path form git resolves from there as well
+func gitRelative(path string) string {
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, path); err == nil {
+				path = rel
+			}
+		}
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+		path = "./" + path
+	}
+	return path
+}
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Show and compare images from a git repository",
+	Long: `Read images straight out of the git repository the current directory is
+in, any revision git understands. Every command taking a path reads blobs
+as git:REV:PATH as well, like termuwu show git:main:docs/logo.png.`,
+}
+
+var gitShowCmd = &cobra.Command{
+	Use:   "show [rev:path]",
+	Short: "Render an image as it was at a revision",
+	Long: `Render an image from the git object database:
+
+  termuwu git show HEAD~3:assets/logo.png
+  termuwu git show v1.0:./logo.png
+
+As with git show, the path is from the top of the repository, or from the
+current directory when it starts with ./ or ../.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+
+		object := args[0]
+		if rest, ok := gitSource(object); ok {
+			object = rest
+		}
+		if !strings.Contains(object, ":") {
+			fmt.Fprintf(statusOut, tr("%s %q (use rev:path, like HEAD~1:logo.png)\n"), errorColor("❌ Not a git object:"), args[0])
+			return
+		}
+		renderer, ok := sharedRenderer()
+		if !ok {
+			return
+		}
+		img, _, err := loadImage("git:" + object)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+		fmt.Print(renderer.RenderImage(img))
+	},
+}
+
+var gitDiffCmd = &cobra.Command{
+	Use:   "diff [rev] [rev2] [path]",
+	Short: "Compare an image between revisions",
+	Long: `Render an image as it was at rev next to how it is now (or at rev2) and a
+third picture with the changed pixels highlighted on a faded copy:
+
+  termuwu git diff HEAD~1 assets/logo.png
+  termuwu git diff main feature assets/logo.png --diff-only
+
+The path is relative to the current directory. The line below says how
+many pixels changed and where; --tolerance ignores changes of up to that
+much per channel, like re-encoding noise.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+
+		if gitDiffTolerance < 0 || gitDiffTolerance > 255 {
+			fmt.Fprintln(statusOut, errorColor("❌ --tolerance must be between 0 and 255."))
+			return
+		}
+		path := args[len(args)-1]
+		oldSource, oldName := "git:"+args[0]+":"+gitRelative(path), args[0]
+		newSource, newName := path, tr("working tree")
+		if len(args) == 3 {
+			newSource, newName = "git:"+args[1]+":"+gitRelative(path), args[1]
+		}
+		renderer, ok := sharedRenderer()
+		if !ok {
+			return
+		}
+		var images [2]image.Image
+		for i, source := range []string{oldSource, newSource} {
+			img, _, err := loadImage(source)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+			images[i] = img
+		}
+
+		diff, stats := DiffImages(images[0], images[1], uint8(gitDiffTolerance))
+		if gitDiffOnly {
+			fmt.Print(renderer.RenderImage(diff))
+		} else {
+			fmt.Print(renderer.RenderImage(Stitch([]image.Image{images[0], images[1], diff}, false)))
+		}
+		sizes := fmt.Sprintf("%s %dx%d → %s %dx%d", oldName, images[0].Bounds().Dx(), images[0].Bounds().Dy(),
+			newName, images[1].Bounds().Dx(), images[1].Bounds().Dy())
+		if stats.Changed == 0 {
+			fmt.Fprintf(statusOut, "✅ %s %s\n", successColor("No changes:"), sizes)
+			return
+		}
+		b := stats.Bounds
+		fmt.Fprintf(statusOut, tr("🔎 %s %s, %d pixels (%.2f%%) in %d,%d-%d,%d\n"), infoColor("Changed:"), sizes,
+			stats.Changed, stats.Percent(), b.Min.X, b.Min.Y, b.Max.X, b.Max.Y)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gitCmd)
+	gitCmd.AddCommand(gitShowCmd, gitDiffCmd)
+
+	gitDiffCmd.Flags().IntVar(&gitDiffTolerance, "tolerance", 0, "Ignore changes of up to this much per color channel (0-255).")
+	gitDiffCmd.Flags().BoolVar(&gitDiffOnly, "diff-only", false, "Only render the highlighted changes, not the two versions.")
+	for _, c := range []*cobra.Command{gitShowCmd, gitDiffCmd} {
+		c.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+		c.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+		c.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+		c.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+		c.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+		c.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// glyphSet is a group of characters a font might not have
+type glyphSet struct {
+	Name   string
+	Sample []rune // what glyphtest and the probe print
+	// Fallback is the mode to draw with instead when the font lacks the
+	// set, by the mode that needs it
+	Fallback map[RenderMode]RenderMode
+}
+
+// glyphSets are the sets the renderer draws with, and the quadrants and
+// sextants other tools use, to help pick a font
+var glyphSets = []glyphSet{
+	{Name: "half-blocks", Sample: []rune("▀▄"), Fallback: map[RenderMode]RenderMode{HalfBlockMode: BlockMode}},
+	{Name: "shades", Sample: []rune("░▒▓█"), Fallback: map[RenderMode]RenderMode{HalftoneMode: CrosshatchMode}},
+	{Name: "braille", Sample: []rune("⠁⠃⠇⡇⣇⣧⣷⣿⢸⠿"), Fallback: map[RenderMode]RenderMode{BrailleMode: HalfBlockMode}},
+	{Name: "quadrants", Sample: []rune("▘▝▖▗▚▞▙▛▜▟")},
+	{Name: "sextants", Sample: []rune("🬀🬁🬂🬃🬇🬋🬓🬞🬹🬻")},
+}
+
+func glyphSetNames() []string {
+	names := make([]string, len(glyphSets))
+	for i, set := range glyphSets {
+		names[i] = set.Name
+	}
+	return names
+}
+
+// parseGlyphSets reads the missing set names from the config
+func parseGlyphSets(names []string) (map[string]bool, error) {
+	missing := make(map[string]bool)
+	for _, name := range names {
+		found := false
+		for _, set := range glyphSets {
+			found = found || strings.EqualFold(set.Name, name)
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown glyph set %q (use %s)", name, strings.Join(glyphSetNames(), ", "))
+		}
+		missing[strings.ToLower(name)] = true
+	}
+	return missing, nil
+}
+
+// glyphFallback picks a mode the font can show, starting from mode. it
+// returns the set that was missing for the first change, if any
+func glyphFallback(mode RenderMode, missing map[string]bool) (RenderMode, string) {
+	lacking := ""
+	for changed := true; changed; {
+		changed = false
+		for _, set := range glyphSets {
+			if next, ok := set.Fallback[mode]; ok && missing[set.Name] {
+				if lacking == "" {
+					lacking = set.Name
+				}
+				mode, changed = next, true
+			}
+		}
+	}
+	return mode, lacking
+}
+
+// glyphProbeTimeout is how long to wait for the terminal to report the
+// cursor, terminals that don't answer at all make the probe fail
+const glyphProbeTimeout = 300 * time.Millisecond
+
+// errNoProbe means there's no terminal to ask
+var errNoProbe = errors.New("no terminal to probe, the glyphs can be listed in the config instead")
+
+// probeGlyphs prints every glyph of the sets on the terminal and asks where
+// the cursor ended up. a glyph the font lacks often falls back to a wide
+// or zero width replacement; a set with a glyph that doesn't move the
+// cursor exactly one cell is missing. tofu boxes one cell wide can't be
+// told apart from the real glyph, glyphtest shows them for the eye to see
+func probeGlyphs(sets []glyphSet) (map[string]bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errNoProbe
+	}
+	defer tty.Close()
+	// through the raw conn, tty.Fd() would make reads blocking and the
+	// deadline useless
+	raw, err := tty.SyscallConn()
+	if err != nil {
+		return nil, errNoProbe
+	}
+	var state *term.State
+	raw.Control(func(fd uintptr) {
+		if state, err = term.GetState(int(fd)); err == nil {
+			err = cbreakMode(int(fd))
+		}
+	})
+	if err != nil {
+		return nil, errNoProbe
+	}
+	defer raw.Control(func(fd uintptr) { term.Restore(int(fd), state) })
+	defer tty.WriteString("\r\033[K")
+
+	missing := make(map[string]bool)
+	for _, set := range sets {
+		for _, glyph := range set.Sample {
+			col, err := probeCursorAfter(tty, string(glyph))
+			if err != nil {
+				return nil, err
+			}
+			if col != 2 {
+				missing[set.Name] = true
+				break
+			}
+		}
+	}
+	return missing, nil
+}
+
+// probeCursorAfter prints text at the start of the line and returns the
+// column (from 1) the terminal reports the cursor at afterwards
+func probeCursorAfter(tty *os.File, text string) (int, error) {
+	if _, err := tty.WriteString("\r" + text + "\033[6n"); err != nil {
+		return 0, err
+	}
+	if err := tty.SetReadDeadline(time.Now().Add(glyphProbeTimeout)); err != nil {
+		return 0, errNoProbe
+	}
+	var reply []byte
+	buf := make([]byte, 32)
+	for {
+		n, err := tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if start := strings.LastIndex(string(reply), "\033["); start >= 0 && strings.HasSuffix(string(reply), "R") {
+			var row, col int
+			if _, err := fmt.Sscanf(string(reply[start:]), "\033[%d;%dR", &row, &col); err == nil {
+				return col, nil
+			}
+		}
+		if err != nil {
+			return 0, errors.New("the terminal didn't report the cursor position")
+		}
+	}
+}
+
+// missingGlyphs is the config's list of missing glyph sets, plus what the
+// probe finds when glyphs.probe is set
+func missingGlyphs() (map[string]bool, error) {
+	missing, err := parseGlyphSets(config.Glyphs.Missing)
+	if err != nil || !config.Glyphs.Probe || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return missing, err
+	}
+	probed, err := probeGlyphs(glyphSets)
+	if err != nil {
+		return missing, nil // the config's list is all there is
+	}
+	for name := range probed {
+		missing[name] = true
+	}
+	return missing, nil
+}
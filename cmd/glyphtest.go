@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	glyphTestSave  bool
+	glyphTestProbe bool
+)
+
+var glyphTestCmd = &cobra.Command{
+	Use:   "glyphtest",
+	Short: "Check which block, shade and braille characters your terminal font can show",
+	Long: `Print a check pattern for every glyph set termuwu can draw with and ask
+the terminal how wide each glyph came out:
+
+  termuwu glyphtest
+  termuwu glyphtest --save
+
+Every row should fill the space between the bars exactly, with no boxes,
+question marks or gaps. --save stores the sets the probe found missing as
+glyphs.missing in the config, show and play then draw without them (braille
+falls back to half blocks, half blocks to full blocks, shades to ASCII).
+Sets the probe can't catch can be added to that list by hand.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		nameColor := themeColor("name", color.FgMagenta, color.Bold).SprintFunc()
+
+		configured, err := parseGlyphSets(config.Glyphs.Missing)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+			return
+		}
+		var probed map[string]bool
+		if glyphTestProbe {
+			probed, err = probeGlyphs(glyphSets)
+			if err != nil {
+				fmt.Fprintf(statusOut, "⚠️  %s %v\n", infoColor("Couldn't probe the terminal:"), err)
+			}
+		}
+
+		const repeat = 3
+		for _, set := range glyphSets {
+			line := strings.Repeat(string(set.Sample), repeat)
+			guide := strings.Repeat("-", len(set.Sample)*repeat)
+			verdict := ""
+			switch {
+			case probed[set.Name]:
+				verdict = errorColor("❌ wrong width, missing from the font")
+			case probed != nil:
+				verdict = successColor("✅ one cell each")
+			}
+			if configured[set.Name] {
+				verdict = strings.TrimSpace(verdict + " " + infoColor("(missing in the config)"))
+			}
+			fmt.Println(strings.TrimSpace(fmt.Sprintf("%s |%s| %s", nameColor(fmt.Sprintf("%-11s", set.Name)), line, verdict)))
+			fmt.Printf("%-11s |%s|\n", "", guide)
+		}
+		fmt.Println()
+		fmt.Println(tr("The bars of each pair of rows should line up, with every glyph drawn in full."))
+
+		if !glyphTestSave {
+			return
+		}
+		if probed == nil {
+			fmt.Fprintln(statusOut, errorColor("❌ Nothing to save without a probe, list the missing sets in the config's glyphs.missing instead."))
+			return
+		}
+		glyphs := config.Glyphs
+		glyphs.Missing = nil
+		for _, name := range glyphSetNames() {
+			if probed[name] || configured[name] {
+				glyphs.Missing = append(glyphs.Missing, name)
+			}
+		}
+		path := configPath
+		if path == "" {
+			path = defaultConfigPath()
+		}
+		if err := saveConfigValue(path, "glyphs", glyphs); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error saving glyphs:"), err)
+			return
+		}
+		if len(glyphs.Missing) == 0 {
+			glyphs.Missing = []string{"none"}
+		}
+		fmt.Fprintf(statusOut, tr("✅ %s %s in %s\n"), successColor("Saved missing glyph sets:"), strings.Join(glyphs.Missing, ", "), path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(glyphTestCmd)
+
+	glyphTestCmd.Flags().BoolVar(&glyphTestProbe, "probe", true, "Ask the terminal how wide each glyph is drawn (--probe=false to only print the pattern).")
+	glyphTestCmd.Flags().BoolVar(&glyphTestSave, "save", false, "Store the missing sets in the config for show and play.")
+}
+
+// fitGlyphs switches renderer to a mode the font can show, going by the
+// config's glyphs section, and says so on statusOut
+func fitGlyphs(renderer *ImageRenderer) error {
+	missing, err := missingGlyphs()
+	if err != nil {
+		return err
+	}
+	mode, lacking := glyphFallback(renderer.Mode, missing)
+	if lacking != "" {
+		infoColor := localized(themeColor("info", color.FgYellow))
+		fmt.Fprintf(statusOut, "⚠️  %s\n", infoColor(fmt.Sprintf(tr("Your font can't show %s, using %s mode instead (see termuwu glyphtest)"), lacking, mode)))
+		renderer.Mode = mode
+	}
+	return nil
+}
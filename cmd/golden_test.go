@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenModes and goldenDepths are rendered for every fixture
+var (
+	goldenModes  = []RenderMode{BlockMode, HalfBlockMode, BrailleMode}
+	goldenDepths = []ColorDepth{Color256, Color16, TrueColor}
+)
+
+func loadFixture(t testing.TB, name string) image.Image {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", "fixtures", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("couldn't decode %s: %v", name, err)
+	}
+	return img
+}
+
+// checkGolden compares got with testdata/golden/name, or rewrites the file
+// when the tests run with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test ./cmd -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed (run go test ./cmd -update if that's intended)\ngot:\n%q\nwant:\n%q", name, got, want)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	fixtures, err := os.ReadDir(filepath.Join("testdata", "fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fixture := range fixtures {
+		img := loadFixture(t, fixture.Name())
+		base := strings.TrimSuffix(fixture.Name(), filepath.Ext(fixture.Name()))
+
+		for _, mode := range goldenModes {
+			for _, depth := range goldenDepths {
+				name := base + "_" + mode.String() + "_" + strings.Fields(depth.String())[0] + ".ansi"
+				t.Run(name, func(t *testing.T) {
+					r := NewDeterministicRenderer(mode)
+					r.MaxWidth, r.MaxHeight = 24, 12
+					r.ColorDepth = depth
+					checkGolden(t, name, []byte(r.RenderImage(img)))
+				})
+			}
+		}
+	}
+}
+
+// TestConcurrentRender renders with one renderer from several goroutines,
+// which has to give every one of them the render a lone call gives. run it
+// with -race to catch state shared between renders
+func TestConcurrentRender(t *testing.T) {
+	img := loadFixture(t, "gradient.png")
+	for _, mode := range goldenModes {
+		r := NewDeterministicRenderer(mode)
+		r.MaxWidth, r.MaxHeight = 24, 12
+		want := r.RenderImage(img)
+
+		var wg sync.WaitGroup
+		renders := make([]string, 8)
+		for i := range renders {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				renders[i] = r.RenderImage(img)
+			}()
+		}
+		wg.Wait()
+		for i, got := range renders {
+			if got != want {
+				t.Errorf("%s: render %d of %d at once differs from a lone one", mode, i+1, len(renders))
+			}
+		}
+	}
+}
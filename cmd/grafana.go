@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	grafanaURL     string
+	grafanaPanel   int
+	grafanaFrom    string
+	grafanaTo      string
+	grafanaTheme   string
+	grafanaRefresh time.Duration
+	grafanaColors  string
+)
+
+// GrafanaConfig holds the API token the grafana command authenticates with
+type GrafanaConfig struct {
+	Token string `json:"api_token"`
+}
+
+// grafanaCellPixels is how many pixels wide a terminal cell is asked for,
+// about what a terminal font draws, so labels come out at a readable size
+const grafanaCellPixels = 8
+
+// grafanaRenderURL turns the URL of a dashboard (as the browser shows it)
+// into the URL of the image renderer's PNG of one of its panels, keeping
+// the org and template variables
+func grafanaRenderURL(dashboard string, panel int, from, to string, width, height int, theme string) (string, error) {
+	u, err := url.Parse(dashboard)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("%q isn't a dashboard URL", dashboard)
+	}
+	i := strings.Index(u.Path, "/d/")
+	if i < 0 {
+		if i = strings.Index(u.Path, "/d-solo/"); i < 0 {
+			return "", fmt.Errorf("%q isn't a dashboard URL (they have /d/UID in the path)", dashboard)
+		}
+	}
+	rest := u.Path[i+1:]
+	rest = rest[strings.Index(rest, "/"):] // the UID and slug
+	u.Path = u.Path[:i] + "/render/d-solo" + rest
+
+	query := u.Query()
+	for _, key := range []string{"viewPanel", "editPanel", "refresh", "from", "to"} {
+		query.Del(key)
+	}
+	query.Set("panelId", strconv.Itoa(panel))
+	query.Set("from", grafanaTime(from))
+	query.Set("to", grafanaTime(to))
+	query.Set("width", strconv.Itoa(width))
+	query.Set("height", strconv.Itoa(height))
+	query.Set("theme", theme)
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// grafanaTime reads times the way Grafana's time picker does, with -1h
+// short for now-1h
+func grafanaTime(value string) string {
+	if strings.HasPrefix(value, "-") {
+		return "now" + value
+	}
+	return value
+}
+
+// fetchGrafanaPanel downloads a rendered panel, explaining the usual ways
+// that goes wrong: a refused token and a Grafana without the image renderer
+func fetchGrafanaPanel(ctx context.Context, renderURL, token string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", renderURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		if token == "" {
+			return nil, fmt.Errorf("Grafana wants a token (status %d), set grafana.api_token in the config or $GRAFANA_TOKEN", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("Grafana refused the token (status %d)", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return nil, fmt.Errorf("Grafana answered with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); !strings.HasPrefix(mediaType, "image/") {
+		return nil, fmt.Errorf("Grafana sent %s instead of a PNG, is the image renderer plugin installed?", mediaType)
+	}
+	img, _, err := DecodeImage(resp.Body)
+	return img, err
+}
+
+var grafanaCmd = &cobra.Command{
+	Use:   "grafana",
+	Short: "Render a Grafana panel in the terminal",
+	Long: `Fetch a panel of a Grafana dashboard as Grafana's image renderer draws it
+and render it, for keeping an eye on graphs over SSH:
+
+  termuwu grafana --url https://grafana.example.com/d/abc123/api --panel 12 --from -1h
+  termuwu grafana --url https://grafana.example.com/d/abc123/api --panel 12 --refresh 30s
+
+--url is the dashboard's URL as the browser shows it; its org and template
+variables carry over. --panel is the panel's id, the viewPanel number in
+the URL when the panel is opened on its own. The API token comes from
+grafana.api_token in the config, or $GRAFANA_TOKEN.
+
+With --refresh the panel is fetched again on that interval, redrawing only
+what changed; Space fetches it right away and q quits. The panel needs
+Grafana's image renderer plugin.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		if grafanaURL == "" || grafanaPanel <= 0 {
+			fmt.Fprintln(statusOut, errorColor("❌ Give the dashboard with --url and the panel's id with --panel."))
+			return
+		}
+		if grafanaRefresh < 0 {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --refresh value:"), grafanaRefresh)
+			return
+		}
+		if grafanaTheme != "dark" && grafanaTheme != "light" {
+			fmt.Fprintf(statusOut, "%s %q (use dark or light)\n", errorColor("❌ Invalid --theme value:"), grafanaTheme)
+			return
+		}
+		depth, err := parseColorDepth(grafanaColors)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		token := config.Grafana.Token
+		if token == "" {
+			token = os.Getenv("GRAFANA_TOKEN")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		var keys <-chan playKey
+		if grafanaRefresh > 0 {
+			var restore func()
+			keys, restore = startControls(ctx)
+			defer restore()
+		}
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.ColorDepth = depth
+		if grafanaRefresh > 0 && renderHeight == 0 {
+			renderer.MaxHeight-- // room for the status line
+		}
+		if err := fitGlyphs(renderer); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+			return
+		}
+		// the panel is drawn the size and shape of the terminal
+		width := renderer.MaxWidth * grafanaCellPixels
+		height := int(float64(renderer.MaxHeight*grafanaCellPixels) / renderer.AspectRatio)
+		renderURL, err := grafanaRenderURL(grafanaURL, grafanaPanel, grafanaFrom, grafanaTo, width, height, grafanaTheme)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --url value:"), err)
+			return
+		}
+
+		if grafanaRefresh == 0 {
+			fmt.Fprintf(statusOut, "📈 %s %d\n", infoColor("Fetching Grafana panel"), grafanaPanel)
+			img, err := fetchGrafanaPanel(ctx, renderURL, token)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error fetching panel:"), err)
+				return
+			}
+			fmt.Print(renderer.RenderImage(img))
+			return
+		}
+
+		renderer.ExactFit = true // a panel that comes back a bit different doesn't leave bits of the last one
+		if keys != nil {
+			fmt.Print("\033[?1049h" + hideCursor) // the alternate screen, to leave the shell as it was
+			defer fmt.Print(showCursor + "\033[?1049l")
+		}
+		fmt.Print("\033[H\033[2J")
+		screen := NewIncrementalRenderer(renderer)
+		for {
+			img, err := fetchGrafanaPanel(ctx, renderURL, token)
+			if ctx.Err() != nil {
+				return
+			}
+			line := infoColor(fmt.Sprintf(tr("Panel %d"), grafanaPanel))
+			if err != nil {
+				line += " " + errorColor(fmt.Sprintf("❌ %v", err))
+			} else {
+				fmt.Print(screen.Render(img))
+				line += " " + dimColor(fmt.Sprintf(tr("updated %s"), time.Now().Format("15:04:05")))
+			}
+			if keys != nil {
+				line += "  " + dimColor(tr("space refresh  q quit"))
+			}
+			fmt.Print(string(appendStatusLine(nil, renderer.MaxHeight+1, line)))
+
+			select {
+			case <-time.After(grafanaRefresh):
+			case key := <-keys:
+				if key == keyQuit {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(grafanaCmd)
+
+	grafanaCmd.Flags().StringVar(&grafanaURL, "url", "", "URL of the dashboard, as the browser shows it.")
+	grafanaCmd.Flags().IntVar(&grafanaPanel, "panel", 0, "Id of the panel to render (the viewPanel number in its URL).")
+	grafanaCmd.Flags().StringVar(&grafanaFrom, "from", "-6h", "Start of the time range: -1h, now-7d, or milliseconds since 1970.")
+	grafanaCmd.Flags().StringVar(&grafanaTo, "to", "now", "End of the time range.")
+	grafanaCmd.Flags().StringVar(&grafanaTheme, "theme", "dark", "Grafana theme to draw the panel in: dark or light.")
+	grafanaCmd.Flags().DurationVar(&grafanaRefresh, "refresh", 0, "Fetch the panel again on this interval, 0 to render it once.")
+	grafanaCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	grafanaCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	grafanaCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	grafanaCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	grafanaCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	grafanaCmd.Flags().StringVar(&grafanaColors, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
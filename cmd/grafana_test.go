@@ -0,0 +1,18 @@
+package cmd
+
+import "testing"
+
+func TestGrafanaRenderURL(t *testing.T) {
+	got, err := grafanaRenderURL("https://grafana.example.com/sub/d/abc123/api-latency?orgId=2&var-host=web1&viewPanel=7&from=now-24h#top", 7, "-1h", "now", 800, 400, "dark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://grafana.example.com/sub/render/d-solo/abc123/api-latency?from=now-1h&height=400&orgId=2&panelId=7&theme=dark&to=now&var-host=web1&width=800"
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	if _, err := grafanaRenderURL("https://grafana.example.com/explore", 7, "-1h", "now", 800, 400, "dark"); err == nil {
+		t.Error("a URL without a dashboard should be an error")
+	}
+}
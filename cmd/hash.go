@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hashAlgo    string
+	hashCompare bool
+)
+
+var hashCmd = &cobra.Command{
+	Use:   "hash [path_or_url...]",
+	Short: "Print perceptual hashes of images, or how far apart two are",
+	Long: `Print a 64 bit perceptual hash of each image, as 16 hex digits followed
+by the image's path like sha256sum does. Images that look alike get hashes
+that differ in few bits, whatever their size or format:
+
+  termuwu hash photo.jpg --algo dhash
+  termuwu hash --compare a.png b.png
+
+--compare prints the Hamming distance between two images instead, the
+number of bits their hashes differ in (0 to 64); either can be a hash
+printed before. --algo is phash (the default), dhash or ahash, and hashes
+only compare with hashes of the same algorithm.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if hashCompare {
+			return cobra.ExactArgs(2)(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		statusOut = stderrStatus() // the hashes are the output
+
+		_, similar, err := parseHashFunc(hashAlgo)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --algo value:"), err)
+			return
+		}
+		hashOf := func(source string) (ImageHash, bool) {
+			if _, err := os.Stat(source); err != nil && hashCompare {
+				if h, err := ParseImageHash(source); err == nil {
+					return h, true
+				}
+			}
+			img, _, err := loadImage(source)
+			if err == nil {
+				var h ImageHash
+				if h, err = HashImage(img, hashAlgo); err == nil {
+					return h, true
+				}
+			}
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error hashing image:"), err)
+			return 0, false
+		}
+
+		if !hashCompare {
+			for _, source := range args {
+				if h, ok := hashOf(source); ok {
+					fmt.Printf("%s  %s\n", h, source)
+				}
+			}
+			return
+		}
+		a, ok := hashOf(args[0])
+		if !ok {
+			return
+		}
+		b, ok := hashOf(args[1])
+		if !ok {
+			return
+		}
+		distance := a.Distance(b)
+		fmt.Println(distance)
+		verdict := tr("they look different")
+		if distance <= similar {
+			verdict = tr("they look alike")
+		}
+		fmt.Fprintf(statusOut, tr("🔎 %s %d of 64 bits, %s (up to %d count as alike with %s)\n"), infoColor("Distance:"), distance, verdict, similar, hashAlgo)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hashCmd)
+
+	hashCmd.Flags().StringVar(&hashAlgo, "algo", "phash", "Perceptual hash to compute: phash, dhash or ahash.")
+	hashCmd.Flags().BoolVar(&hashCompare, "compare", false, "Print the Hamming distance between two images (or hashes) instead.")
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"image"
+	"sort"
+
+	"golang.org/x/image/draw"
+)
+
+// highContrastPalette is what --high-contrast snaps colors to: black, white
+// and the fully saturated primaries and secondaries, the colors that are
+// furthest apart from each other
+var highContrastPalette = []Color{
+	{0, 0, 0}, {255, 255, 255},
+	{255, 0, 0}, {0, 255, 0}, {0, 0, 255},
+	{255, 255, 0}, {0, 255, 255}, {255, 0, 255},
+}
+
+// HighContrastFilter stretches the image's brightness to the full range,
+// ignoring the darkest and brightest 2% of pixels, and snaps every pixel to
+// highContrastPalette, for legibility on small fonts and for low vision
+func HighContrastFilter() Filter {
+	return func(img *image.RGBA) {
+		var lumas []float64
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			if img.Pix[i+3] != 0 {
+				lumas = append(lumas, luminance(Color{R: img.Pix[i], G: img.Pix[i+1], B: img.Pix[i+2]}))
+			}
+		}
+		if len(lumas) == 0 {
+			return
+		}
+		sort.Float64s(lumas)
+		low, high := lumas[len(lumas)*2/100], lumas[len(lumas)*98/100]
+		if high-low < 1 {
+			low, high = 0, 255 // flat image, nothing to stretch
+		}
+		stretch := func(v uint8) float64 {
+			return max(0, min((float64(v)-low)*255/(high-low), 255))
+		}
+
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			alpha := uint32(img.Pix[i+3])
+			if alpha == 0 {
+				continue
+			}
+			r, g, b := stretch(img.Pix[i]), stretch(img.Pix[i+1]), stretch(img.Pix[i+2])
+			best, bestDistance := highContrastPalette[0], -1.0
+			for _, c := range highContrastPalette {
+				dr, dg, db := r-float64(c.R), g-float64(c.G), b-float64(c.B)
+				if d := dr*dr + dg*dg + db*db; bestDistance < 0 || d < bestDistance {
+					best, bestDistance = c, d
+				}
+			}
+			// the pixels are premultiplied
+			img.Pix[i] = uint8(uint32(best.R) * alpha / 255)
+			img.Pix[i+1] = uint8(uint32(best.G) * alpha / 255)
+			img.Pix[i+2] = uint8(uint32(best.B) * alpha / 255)
+		}
+	}
+}
+
+// magnify blows every sample up into factor x factor samples, so a render
+// takes factor x factor cells for what would have been one
+func magnify(img *image.RGBA, factor int) *image.RGBA {
+	bounds := img.Bounds()
+	large := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*factor, bounds.Dy()*factor))
+	draw.NearestNeighbor.Scale(large, large.Rect, img, bounds, draw.Src, nil)
+	return large
+}
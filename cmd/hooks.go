@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// hookEvent is the metadata handed to hooks, as JSON on stdin and as
+// TERMUWU_* environment variables
+type hookEvent struct {
+	Hook        string `json:"hook"`
+	Source      string `json:"source"`
+	Format      string `json:"format,omitempty"`
+	ImageWidth  int    `json:"image_width,omitempty"`
+	ImageHeight int    `json:"image_height,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	Protocol    string `json:"protocol,omitempty"`
+	OutputBytes int    `json:"output_bytes,omitempty"`
+}
+
+func (e hookEvent) env() []string {
+	return []string{
+		"TERMUWU_HOOK=" + e.Hook,
+		"TERMUWU_SOURCE=" + e.Source,
+		"TERMUWU_FORMAT=" + e.Format,
+		"TERMUWU_IMAGE_WIDTH=" + strconv.Itoa(e.ImageWidth),
+		"TERMUWU_IMAGE_HEIGHT=" + strconv.Itoa(e.ImageHeight),
+		"TERMUWU_MODE=" + e.Mode,
+		"TERMUWU_PROTOCOL=" + e.Protocol,
+		"TERMUWU_OUTPUT_BYTES=" + strconv.Itoa(e.OutputBytes),
+	}
+}
+
+// runHook runs a hook command through the shell and returns its stdout.
+// an empty command is a no-op
+func runHook(command string, event hookEvent) (string, error) {
+	if command == "" {
+		return "", nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	var shell *exec.Cmd
+	if runtime.GOOS == "windows" {
+		shell = exec.Command("cmd", "/C", command)
+	} else {
+		shell = exec.Command("sh", "-c", command)
+	}
+	shell.Env = append(os.Environ(), event.env()...)
+	shell.Stdin = bytes.NewReader(append(payload, '\n'))
+	shell.Stderr = os.Stderr
+
+	output, err := shell.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s hook failed: %w", event.Hook, err)
+	}
+	return string(output), nil
+}
+
+// runPreFetchHook lets the hook swap the source, e.g. for a decrypted copy:
+// if it prints a line, that line becomes the new path or URL
+func runPreFetchHook(source string) (string, error) {
+	output, err := runHook(config.Hooks.PreFetch, hookEvent{Hook: "pre_fetch", Source: source})
+	if err != nil {
+		return "", err
+	}
+	if replacement := strings.TrimSpace(strings.SplitN(output, "\n", 2)[0]); replacement != "" {
+		return replacement, nil
+	}
+	return source, nil
+}
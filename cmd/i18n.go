@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"embed"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+)
+
+// the translations, one JSON file per language mapping the English
+// message to the translated one
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+var (
+	langFlag  string
+	localizer *i18n.Localizer
+)
+
+// setupLocale picks the language for messages: --lang, or else the usual
+// LC_ALL, LC_MESSAGES and LANG variables
+func setupLocale(lang string) error {
+	bundle := i18n.NewBundle(language.English)
+	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return err
+		}
+		if _, err := bundle.ParseMessageFileBytes(data, file.Name()); err != nil {
+			return err
+		}
+	}
+	if lang == "" {
+		lang = environmentLanguage()
+	}
+	localizer = i18n.NewLocalizer(bundle, lang)
+	return nil
+}
+
+// environmentLanguage turns a POSIX locale like de_DE.UTF-8 into a language
+// tag, empty for the C locale or none at all
+func environmentLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		value, _, _ = strings.Cut(value, ".")
+		value, _, _ = strings.Cut(value, "@")
+		if value == "C" || value == "POSIX" {
+			return ""
+		}
+		return strings.ReplaceAll(value, "_", "-")
+	}
+	return ""
+}
+
+// tr translates a message to the user's language. messages are looked up
+// by their English text, so the ones without a translation stay English
+func tr(message string) string {
+	if localizer == nil {
+		return message
+	}
+	translated, err := localizer.Localize(&i18n.LocalizeConfig{MessageID: message})
+	if err != nil {
+		return message
+	}
+	return translated
+}
+
+// localized is c's SprintFunc translating the messages it colors
+func localized(c *color.Color) func(a ...any) string {
+	sprint := c.SprintFunc()
+	return func(a ...any) string {
+		for i, value := range a {
+			if message, ok := value.(string); ok {
+				a[i] = tr(message)
+			}
+		}
+		return sprint(a...)
+	}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestLocales checks every translation is found by its English message and
+// keeps the message's format verbs, in order
+func TestLocales(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer setupLocale("en")
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			t.Fatalf("%s: %v", file.Name(), err)
+		}
+		if err := setupLocale(strings.TrimSuffix(file.Name(), ".json")); err != nil {
+			t.Fatal(err)
+		}
+		for message, translated := range messages {
+			if got := tr(message); got != translated {
+				t.Errorf("%s: %q translates to %q, want %q", file.Name(), message, got, translated)
+			}
+			if !slices.Equal(verbs.FindAllString(message, -1), verbs.FindAllString(translated, -1)) {
+				t.Errorf("%s: %q doesn't keep the verbs of %q", file.Name(), translated, message)
+			}
+		}
+	}
+}
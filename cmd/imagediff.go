@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// DiffStats is how much two images differ
+type DiffStats struct {
+	Changed int             // pixels that differ by more than the threshold
+	Total   int             // pixels compared, the union of both sizes
+	Bounds  image.Rectangle // the smallest box around the changes
+}
+
+// Percent is the share of changed pixels
+func (s DiffStats) Percent() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return 100 * float64(s.Changed) / float64(s.Total)
+}
+
+// diffHighlight marks changed pixels in the diff image
+var diffHighlight = color.RGBA{255, 40, 120, 255}
+
+// DiffImages compares a and b pixel by pixel, aligned at their top left
+// corners. the result is b faded to gray with the pixels that changed by
+// more than threshold (0-255 on any channel, alpha included) highlighted;
+// where only one image has pixels they count as changed
+func DiffImages(a, b image.Image, threshold uint8) (*image.RGBA, DiffStats) {
+	ab, bb := a.Bounds(), b.Bounds()
+	size := image.Pt(max(ab.Dx(), bb.Dx()), max(ab.Dy(), bb.Dy()))
+	out := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(out, out.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	stats := DiffStats{Total: size.X * size.Y}
+	limit := uint32(threshold) * 0x101
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			pa, pb := image.Pt(x+ab.Min.X, y+ab.Min.Y), image.Pt(x+bb.Min.X, y+bb.Min.Y)
+			inA, inB := pa.In(ab), pb.In(bb)
+			changed := inA != inB
+			if inA && inB {
+				r1, g1, b1, a1 := a.At(pa.X, pa.Y).RGBA()
+				r2, g2, b2, a2 := b.At(pb.X, pb.Y).RGBA()
+				changed = absDiff(r1, r2) > limit || absDiff(g1, g2) > limit || absDiff(b1, b2) > limit || absDiff(a1, a2) > limit
+			}
+			if changed {
+				stats.Changed++
+				stats.Bounds = stats.Bounds.Union(image.Rect(x, y, x+1, y+1))
+				out.SetRGBA(x, y, diffHighlight)
+				continue
+			}
+			if inB {
+				// a faded gray of what's still there, for orientation
+				gray := color.GrayModel.Convert(b.At(pb.X, pb.Y)).(color.Gray).Y
+				faded := 32 + gray/3
+				out.SetRGBA(x, y, color.RGBA{faded, faded, faded, 255})
+			}
+		}
+	}
+	return out, stats
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
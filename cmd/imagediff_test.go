@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDiffImages checks changed pixels are counted and boxed, small ones
+// ignored under the threshold and size differences counted as changes
+func TestDiffImages(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	b := image.NewRGBA(image.Rect(0, 0, 4, 5))
+	b.SetRGBA(1, 2, color.RGBA{200, 0, 0, 255})
+	b.SetRGBA(3, 0, color.RGBA{0, 0, 0, 5})
+
+	diff, stats := DiffImages(a, b, 10)
+	if stats.Changed != 5 || stats.Total != 20 {
+		t.Errorf("%d of %d pixels changed, want 5 of 20", stats.Changed, stats.Total)
+	}
+	if stats.Bounds != image.Rect(0, 2, 4, 5) {
+		t.Errorf("changes in %v", stats.Bounds)
+	}
+	if diff.RGBAAt(1, 2) != diffHighlight || diff.RGBAAt(3, 0) == diffHighlight {
+		t.Errorf("highlighted %v and %v", diff.RGBAAt(1, 2), diff.RGBAAt(3, 0))
+	}
+}
+
+// TestCompareDirs checks images pass, fail over the threshold, and go
+// missing or new between the directories
+func TestCompareDirs(t *testing.T) {
+	baseline, candidate := t.TempDir(), t.TempDir()
+	same := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	changed := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	changed.SetRGBA(0, 0, color.RGBA{255, 255, 255, 255})
+	for _, file := range []struct {
+		path string
+		img  image.Image
+	}{
+		{filepath.Join(baseline, "same.png"), same}, {filepath.Join(candidate, "same.png"), same},
+		{filepath.Join(baseline, "one.png"), same}, {filepath.Join(candidate, "one.png"), changed},
+		{filepath.Join(baseline, "gone.png"), same}, {filepath.Join(candidate, "new.png"), same},
+	} {
+		if err := SaveImage(file.path, file.img); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		threshold float64
+		want      string
+	}{{0.01, "gone.png missing, new.png new, one.png passed, same.png passed"}, {0.005, "gone.png missing, new.png new, one.png failed, same.png passed"}} {
+		results, err := compareDirs(baseline, candidate, tc.threshold, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.Name+" "+string(result.Status))
+		}
+		if strings.Join(got, ", ") != tc.want {
+			t.Errorf("threshold %g: %s, want %s", tc.threshold, strings.Join(got, ", "), tc.want)
+		}
+	}
+}
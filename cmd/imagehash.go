@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ImageHash is a 64 bit perceptual hash: similar looking images have
+// hashes that differ in few bits, whatever their size or encoding
+type ImageHash uint64
+
+// Distance is the number of bits h and other differ in, 0 to 64
+func (h ImageHash) Distance(other ImageHash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
+
+func (h ImageHash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// HashFunc hashes an image
+type HashFunc func(image.Image) ImageHash
+
+// hashFuncs are the hashes dedupe and hash know, with the distance up to which
+// two images are taken for the same
+var hashFuncs = map[string]struct {
+	hash     HashFunc
+	distance int
+}{
+	"ahash": {AverageHash, 5},
+	"dhash": {DifferenceHash, 10},
+	"phash": {PerceptualHash, 10},
+}
+
+func parseHashFunc(name string) (HashFunc, int, error) {
+	if h, ok := hashFuncs[strings.ToLower(name)]; ok {
+		return h.hash, h.distance, nil
+	}
+	return nil, 0, fmt.Errorf("unknown hash %q (use ahash, dhash or phash)", name)
+}
+
+// HashImage hashes img with algo, phash, dhash or ahash
+func HashImage(img image.Image, algo string) (ImageHash, error) {
+	hash, _, err := parseHashFunc(algo)
+	if err != nil {
+		return 0, err
+	}
+	return hash(img), nil
+}
+
+// ParseImageHash reads a hash back from the 16 hex digits String writes
+func ParseImageHash(s string) (ImageHash, error) {
+	if len(s) != 16 {
+		return 0, fmt.Errorf("invalid hash %q (want 16 hex digits)", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hash %q (want 16 hex digits)", s)
+	}
+	return ImageHash(v), nil
+}
+
+// grayGrid shrinks img to w x h and returns its luma, row by row
+func grayGrid(img image.Image, w, h int) []float64 {
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	gray := make([]float64, w*h)
+	for i := range gray {
+		p := small.Pix[i*4:]
+		gray[i] = 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+	}
+	return gray
+}
+
+// AverageHash (aHash) sets a bit for every pixel of the 8x8 gray image
+// brighter than the mean. fast, but fooled by changes in brightness
+func AverageHash(img image.Image) ImageHash {
+	gray := grayGrid(img, 8, 8)
+	mean := 0.0
+	for _, v := range gray {
+		mean += v
+	}
+	mean /= 64
+	var h ImageHash
+	for i, v := range gray {
+		if v > mean {
+			h |= 1 << i
+		}
+	}
+	return h
+}
+
+// DifferenceHash (dHash) sets a bit where a pixel of the 9x8 gray image is
+// brighter than its right neighbor, following the gradients
+func DifferenceHash(img image.Image) ImageHash {
+	gray := grayGrid(img, 9, 8)
+	var h ImageHash
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if gray[y*9+x] > gray[y*9+x+1] {
+				h |= 1 << (y*8 + x)
+			}
+		}
+	}
+	return h
+}
+
+// PerceptualHash (pHash) compares the lowest 8x8 frequencies of the 32x32
+// gray image's discrete cosine transform to their median, which survives
+// scaling, recompression and small edits best
+func PerceptualHash(img image.Image) ImageHash {
+	const n = 32
+	gray := grayGrid(img, n, n)
+	var cosines [8][n]float64
+	for u := range cosines {
+		for x := 0; x < n; x++ {
+			cosines[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n))
+		}
+	}
+	// the rows first, then the columns, and only the frequencies kept
+	var rows [n][8]float64
+	for y := 0; y < n; y++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for x := 0; x < n; x++ {
+				sum += gray[y*n+x] * cosines[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	var freq [64]float64
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for y := 0; y < n; y++ {
+				sum += rows[y][u] * cosines[v][y]
+			}
+			freq[v*8+u] = sum
+		}
+	}
+
+	// the DC term is only the overall brightness, leave it out of the median
+	sorted := append([]float64(nil), freq[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[31] + sorted[32]) / 2
+	var h ImageHash
+	for i, v := range freq {
+		if i > 0 && v > median {
+			h |= 1 << i
+		}
+	}
+	return h
+}
+
+// groupDuplicates groups the indices of hashes that are within distance of
+// each other, directly or through another image of the group. only groups
+// of two or more come back, in the order of their first member
+func groupDuplicates(hashes []ImageHash, distance int) [][]int {
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if hashes[i].Distance(hashes[j]) <= distance {
+				if a, b := find(i), find(j); a != b {
+					parent[max(a, b)] = min(a, b)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range hashes {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	var groups [][]int
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			groups = append(groups, members[root])
+		}
+	}
+	return groups
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func TestImageHashes(t *testing.T) {
+	waves := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	stripes := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			v := uint8(128 + 127*math.Sin(float64(x)/40)*math.Cos(float64(y)/30))
+			waves.Set(x, y, color.RGBA{v, uint8(x / 2), 200 - v/2, 255})
+			stripes.Set(x, y, color.Gray{uint8((x + 2*y) / 25 % 2 * 255)})
+		}
+	}
+	small := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	draw.CatmullRom.Scale(small, small.Bounds(), waves, waves.Bounds(), draw.Src, nil)
+
+	for name, h := range hashFuncs {
+		if d := h.hash(waves).Distance(h.hash(small)); d > h.distance {
+			t.Errorf("%s: resized copy is %d bits away, want at most %d", name, d, h.distance)
+		}
+		if d := h.hash(waves).Distance(h.hash(stripes)); d <= h.distance {
+			t.Errorf("%s: different image is only %d bits away", name, d)
+		}
+	}
+}
+
+func TestGroupDuplicates(t *testing.T) {
+	// 0 and 2 are too far apart, but both close to 3
+	hashes := []ImageHash{0b0000, 0xff00, 0b0111, 0b0011, 0xff01}
+	want := [][]int{{0, 2, 3}, {1, 4}}
+	if got := groupDuplicates(hashes, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupDuplicates = %v, want %v", got, want)
+	}
+	if got := groupDuplicates(hashes, 0); got != nil {
+		t.Errorf("groupDuplicates at distance 0 = %v, want none", got)
+	}
+}
+
+func TestParseImageHash(t *testing.T) {
+	h := ImageHash(0x00f08ff00f0ff050)
+	if got, err := ParseImageHash(h.String()); err != nil || got != h {
+		t.Errorf("ParseImageHash(%s) = %s, %v", h, got, err)
+	}
+	for _, bad := range []string{"", "f08ff00f0ff050", "g08ff00f0ff00f50"} {
+		if _, err := ParseImageHash(bad); err == nil {
+			t.Errorf("ParseImageHash(%q) didn't fail", bad)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importColumns int
+	importOut     string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import [path]",
+	Short: "Read ANSI or ASCII art and print, convert or rescale it",
+	Long: `Parse an ANSI/ASCII art file (CP437 or UTF-8 text with SGR colors, like
+.ans files from the BBS days) into cells, then print it on a modern UTF-8
+terminal or write it out in another format:
+
+  termuwu import art.ans
+  termuwu import art.ans --out art.png
+  termuwu import art.ans --braille --width 40 --height 20
+
+--out picks the format by extension: .png (drawn with the built-in font),
+.html, .json (the cell grid) or .ans/.txt (UTF-8 ANSI). Giving a size, a
+render mode or --colors renders the art again at that size, like an image.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading art:"), err)
+			return
+		}
+		if importColumns < 0 {
+			fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --columns value:"), importColumns)
+			return
+		}
+		grid := ParseANSIArt(data, importColumns)
+
+		rescale := renderWidth > 0 || renderHeight > 0 || useFullBlocks || useBraille || cmd.Flags().Changed("colors")
+		if rescale {
+			depth, err := parseColorDepth(colorMode)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+				return
+			}
+			renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+			renderer.ColorDepth = depth
+			grid = renderer.Rasterize(GridImage(grid))
+		}
+
+		if importOut == "" {
+			fmt.Print(grid.ANSI())
+			return
+		}
+		if err := writeImportedArt(grid, importOut); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error writing art:"), err)
+			return
+		}
+		fmt.Fprintf(statusOut, tr("✅ %s %s (%dx%d cells)\n"), successColor("Saved"), importOut, grid.Width, grid.Height)
+	},
+}
+
+// writeImportedArt writes grid to path in the format its extension names
+func writeImportedArt(grid *CellGrid, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".png":
+		err = png.Encode(file, GridImage(grid))
+	case ".html", ".htm":
+		_, err = file.WriteString(grid.HTML())
+	case ".json":
+		err = grid.WriteJSON(file)
+	case ".ans", ".txt":
+		_, err = file.WriteString(grid.ANSI())
+	default:
+		err = fmt.Errorf("unknown output format %q (use .png, .html, .json, .ans or .txt)", ext)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().IntVar(&importColumns, "columns", 0, "Width the art was drawn for (0 for the SAUCE record's, or 80).")
+	importCmd.Flags().StringVarP(&importOut, "out", "o", "", "Write to a .png, .html, .json or .ans/.txt file instead of printing.")
+	importCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Render again with full character blocks.")
+	importCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Render again with Braille patterns.")
+	importCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering when rendering again.")
+	importCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Render again this many characters wide (0 to keep the art as it is).")
+	importCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Render again this many lines tall (0 to keep the art as it is).")
+	importCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render again with: 256, 16 or true.")
+}
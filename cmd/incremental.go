@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"image"
+	"strconv"
+)
+
+// diffMergeGap is the longest run of unchanged cells that gets rewritten
+// anyway to join two changed runs, since a cursor move costs about as much
+const diffMergeGap = 3
+
+// IncrementalRenderer draws a stream of images at a fixed spot on the screen.
+// it keeps the previous cell grid and, after the first frame, only moves the
+// cursor to the cells that changed and rewrites those, which avoids the
+// flicker of a full redraw in interactive programs
+type IncrementalRenderer struct {
+	*ImageRenderer
+
+	x, y       int // top-left cell of the image on screen, 0-based
+	invalid    bool
+	scaled     *image.RGBA
+	prev, next *CellGrid
+	buf        []byte
+}
+
+func NewIncrementalRenderer(r *ImageRenderer) *IncrementalRenderer {
+	return &IncrementalRenderer{ImageRenderer: r, invalid: true}
+}
+
+// SetPosition moves the image to column x, row y (0-based). the next frame
+// is drawn in full, clearing the old spot is up to the caller
+func (ir *IncrementalRenderer) SetPosition(x, y int) {
+	if x != ir.x || y != ir.y {
+		ir.x, ir.y = x, y
+		ir.invalid = true
+	}
+}
+
+// Invalidate forces the next frame to be drawn in full, e.g. after the
+// screen was cleared or something else drew over the image
+func (ir *IncrementalRenderer) Invalidate() {
+	ir.invalid = true
+}
+
+// Render rasterizes img and returns the escape sequences that turn the
+// previous frame into this one. it's empty when nothing changed
+func (ir *IncrementalRenderer) Render(img image.Image) string {
+	ir.scaled = ir.scaleInto(ir.scaled, img)
+	ir.next = ir.rasterizeInto(ir.next, ir.scaled)
+	return ir.swap()
+}
+
+// RenderGrid is Render for a grid that was rasterized elsewhere
+func (ir *IncrementalRenderer) RenderGrid(grid *CellGrid) string {
+	if ir.next == nil {
+		ir.next = &CellGrid{}
+	}
+	ir.next.Width, ir.next.Height, ir.next.Depth = grid.Width, grid.Height, grid.Depth
+	ir.next.Cells = append(ir.next.Cells[:0], grid.Cells...)
+	return ir.swap()
+}
+
+func (ir *IncrementalRenderer) swap() string {
+	out := ir.diff(ir.prev, ir.next)
+	ir.prev, ir.next = ir.next, ir.prev
+	ir.invalid = false
+	return out
+}
+
+func (ir *IncrementalRenderer) diff(prev, next *CellGrid) string {
+	full := ir.invalid || prev == nil || prev.Width != next.Width ||
+		prev.Height != next.Height || prev.Depth != next.Depth
+
+	w := &sgrWriter{buf: ir.buf[:0], depth: next.Depth, fg: NoColor, bg: NoColor}
+	moved := false
+	moveTo := func(x, y int) {
+		if !moved {
+			w.escape("\033[0m") // we don't know what colors the terminal has active
+			moved = true
+		}
+		w.moveTo(x, y)
+	}
+	for y := 0; y < next.Height; y++ {
+		row := next.Row(y)
+		if full {
+			moveTo(ir.x, ir.y+y)
+			ir.cells(w, prev, row, 0, y)
+			continue
+		}
+
+		old := prev.Row(y)
+		for x := 0; x < next.Width; {
+			if row[x] == old[x] {
+				x++
+				continue
+			}
+			start, end := x, x+1
+			for x = end; x < next.Width && x-end <= diffMergeGap; x++ {
+				if row[x] != old[x] {
+					end = x + 1
+				}
+			}
+			moveTo(ir.x+start, ir.y+y)
+			ir.cells(w, prev, row[start:end], start, y)
+			x = end
+		}
+	}
+
+	// blank whatever the previous, bigger frame left behind
+	if full && prev != nil && !ir.invalid {
+		w.reset()
+		w.fg, w.bg = NoColor, NoColor
+		for y := 0; y < prev.Height; y++ {
+			from := 0
+			if y < next.Height {
+				from = next.Width
+			}
+			if from >= prev.Width {
+				continue
+			}
+			moveTo(ir.x+from, ir.y+y)
+			for range prev.Width - from {
+				w.buf = append(w.buf, ' ')
+			}
+		}
+	}
+	w.reset()
+
+	ir.buf = w.buf
+	return string(w.buf)
+}
+
+// moveTo positions the cursor with CUP, which counts from 1. transparent
+// cells skipped before it don't need moving over any more
+func (w *sgrWriter) moveTo(x, y int) {
+	w.skip = 0
+	n := len(w.buf)
+	w.buf = append(w.buf, "\033["...)
+	w.buf = strconv.AppendInt(w.buf, int64(y+1), 10)
+	w.buf = append(w.buf, ';')
+	w.buf = strconv.AppendInt(w.buf, int64(x+1), 10)
+	w.buf = append(w.buf, 'H')
+	w.escapeBytes += len(w.buf) - n
+}
+
+// cells writes row, the cells from column x0 of line y. a transparent cell
+// over one the previous frame drew is blanked rather than skipped, or the
+// old colors would stay on screen: what was there before the image is gone
+func (ir *IncrementalRenderer) cells(w *sgrWriter, prev *CellGrid, row []Cell, x0, y int) {
+	drawn := prev != nil && !ir.invalid && y < prev.Height
+	for i, c := range row {
+		if x := x0 + i; c.Glyph == TransparentGlyph && drawn && x < prev.Width && prev.At(x, y).Glyph != TransparentGlyph {
+			c = c.opaque()
+		}
+		w.cell(c)
+	}
+}
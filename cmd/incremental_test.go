@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIncrementalRendererOnlyRedrawsChanges(t *testing.T) {
+	grid := NewCellGrid(8, 2, Color256)
+	for i := range grid.Cells {
+		grid.Cells[i] = Cell{Glyph: '▀', FG: 196, BG: 21}
+	}
+
+	ir := NewIncrementalRenderer(NewDeterministicRenderer(HalfBlockMode))
+	ir.SetPosition(4, 2)
+	first := ir.RenderGrid(grid)
+	if !strings.Contains(first, "\033[3;5H") || !strings.Contains(first, "\033[4;5H") {
+		t.Errorf("first frame should position every row, got %q", first)
+	}
+
+	if again := ir.RenderGrid(grid); again != "" {
+		t.Errorf("unchanged frame should be empty, got %q", again)
+	}
+
+	grid.Set(6, 1, Cell{Glyph: ' ', FG: NoColor, BG: 46})
+	want := "\033[0m\033[4;11H\033[48;5;46m \033[0m"
+	if got := ir.RenderGrid(grid); got != want {
+		t.Errorf("one changed cell: got %q, want %q", got, want)
+	}
+}
+
+// TestIncrementalRendererTransparent checks transparent cells are moved
+// over, but blanked when they'd leave the previous frame's cell on screen
+func TestIncrementalRendererTransparent(t *testing.T) {
+	blue := Cell{Glyph: ' ', FG: NoColor, BG: 21}
+	hole := Cell{Glyph: TransparentGlyph, FG: NoColor, BG: NoColor}
+	grid := NewCellGrid(3, 2, Color256)
+	copy(grid.Cells, []Cell{blue, blue, hole, hole, blue, blue})
+
+	ir := NewIncrementalRenderer(NewDeterministicRenderer(HalfBlockMode))
+	// the first row's last cell isn't skipped past the second row's start
+	want := "\033[0m\033[1;1H\033[48;5;21m  \033[2;1H\033[1C  \033[0m"
+	if got := ir.RenderGrid(grid); got != want {
+		t.Errorf("first frame: got %q, want %q", got, want)
+	}
+
+	grid.Set(1, 0, hole)
+	want = "\033[0m\033[1;2H "
+	if got := ir.RenderGrid(grid); got != want {
+		t.Errorf("a cell turned transparent: got %q, want it blanked with %q", got, want)
+	}
+
+	grid.Set(2, 1, hole)
+	grid.Set(1, 1, Cell{Glyph: ' ', FG: NoColor, BG: 46})
+	want = "\033[0m\033[2;2H\033[48;5;46m \033[49m "
+	if got := ir.RenderGrid(grid); got != want {
+		t.Errorf("a run ending in a transparent cell: got %q, want %q", got, want)
+	}
+
+	if got := ir.RenderGrid(grid); got != "" {
+		t.Errorf("unchanged transparent cells should be left alone, got %q", got)
+	}
+}
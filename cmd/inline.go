@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// inlineColumnsPerRow is how wide --inline renders may get for every row
+// they're tall when --width doesn't say, about a word in a prompt
+const inlineColumnsPerRow = 12
+
+// inlineFormat is how --inline output is marked up for where it's embedded
+type inlineFormat int
+
+const (
+	inlineANSI inlineFormat = iota // plain escape sequences, for echo and most status bars
+	inlineZsh                      // every sequence in %{ %}, so zsh counts the prompt's width right
+	inlineBash                     // every sequence in \001 \002, readline's markers for invisible text
+	inlineTmux                     // #[fg=...,bg=...] styles for tmux's status-left and status-right
+)
+
+func parseInlineFormat(value string) (inlineFormat, error) {
+	switch strings.ToLower(value) {
+	case "ansi", "":
+		return inlineANSI, nil
+	case "zsh":
+		return inlineZsh, nil
+	case "bash":
+		return inlineBash, nil
+	case "tmux":
+		return inlineTmux, nil
+	}
+	return inlineANSI, fmt.Errorf("unknown inline format %q (use ansi, zsh, bash or tmux)", value)
+}
+
+// escapeSequence matches the sequences the cell encoder writes
+var escapeSequence = regexp.MustCompile("\033\\[[0-9;]*[A-Za-z]")
+
+// EncodeInline encodes the grid for a shell prompt or status bar: no newline
+// after the last row, and the escape sequences marked as taking no room the
+// way format needs, so the prompt's width and the cursor stay right
+func (g *CellGrid) EncodeInline(format inlineFormat) string {
+	if format == inlineTmux {
+		return g.tmuxStyles()
+	}
+	out := g.EncodeANSI(ANSIOptions{NoTrailingNewline: true})
+	switch format {
+	case inlineZsh:
+		return escapeSequence.ReplaceAllString(out, "%{${0}%}")
+	case inlineBash:
+		return escapeSequence.ReplaceAllString(out, "\x01${0}\x02")
+	}
+	return out
+}
+
+// tmuxStyles writes the grid with tmux's own style markup, since tmux
+// shows the escape sequences of #() output as text
+func (g *CellGrid) tmuxStyles() string {
+	var b []byte
+	for y := 0; y < g.Height; y++ {
+		if y > 0 {
+			b = append(b, '\n')
+		}
+		fg, bg := tmuxUnset, tmuxUnset
+		for _, c := range g.Row(y) {
+			c = c.opaque()
+			cellFG := c.FG
+			if c.Glyph == ' ' {
+				cellFG = fg // a space never shows its fg
+			}
+			if cellFG != fg || c.BG != bg {
+				b = append(b, "#[fg="...)
+				b = g.appendTmuxColor(b, cellFG)
+				b = append(b, ",bg="...)
+				b = g.appendTmuxColor(b, c.BG)
+				b = append(b, ']')
+				fg, bg = cellFG, c.BG
+			}
+			if c.Glyph == '#' {
+				b = append(b, '#') // ## is a literal #
+			}
+			b = utf8.AppendRune(b, c.Glyph)
+		}
+		b = append(b, "#[default]"...)
+	}
+	return string(b)
+}
+
+// tmuxColours are tmux's names for the palette colors
+var tmuxColours [256]string
+
+func init() {
+	for i := range tmuxColours {
+		tmuxColours[i] = "colour" + strconv.Itoa(i)
+	}
+}
+
+// tmuxUnset is the color before a line's first style, which has no name
+const tmuxUnset = NoColor - 1
+
+// appendTmuxColor appends a cell color the way tmux styles name it
+func (g *CellGrid) appendTmuxColor(b []byte, code int) []byte {
+	if code == tmuxUnset {
+		return b
+	}
+	c, ok := g.RGB(code)
+	switch {
+	case !ok:
+		return append(b, "default"...)
+	case g.Depth == TrueColor:
+		const hex = "0123456789abcdef"
+		return append(b, '#', hex[c.R>>4], hex[c.R&15], hex[c.G>>4], hex[c.G&15], hex[c.B>>4], hex[c.B&15])
+	}
+	return append(b, tmuxColours[code&0xff]...)
+}
+
+// errorLines passes on only the status lines that report errors, for
+// output embedded where any other chatter would show up, like a prompt
+type errorLines struct {
+	w io.Writer
+}
+
+func (e errorLines) Write(p []byte) (int, error) {
+	if bytes.Contains(p, []byte("❌")) {
+		if _, err := e.w.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
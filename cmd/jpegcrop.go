@@ -0,0 +1,580 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"math/bits"
+)
+
+// CropJPEG crops a baseline JPEG to r without decoding it to pixels: the
+// quantized DCT blocks inside r are copied as they are, so nothing is lost
+// to a second compression. that only works when r's top left corner is on
+// the grid of the JPEG's MCUs (8, 16 or 32 pixels); the right and bottom edges
+// can be anywhere. the blocks are written with the standard Huffman tables,
+// everything else (quantization tables, EXIF, color profiles) is kept
+func CropJPEG(data []byte, r image.Rectangle) ([]byte, error) {
+	j, err := parseJPEGBlocks(data)
+	if err != nil {
+		return nil, err
+	}
+	if !r.In(image.Rect(0, 0, j.width, j.height)) || r.Empty() {
+		return nil, fmt.Errorf("the crop %v isn't inside the %dx%d image", r, j.width, j.height)
+	}
+	mcuW, mcuH := 8*j.hmax, 8*j.vmax
+	if r.Min.X%mcuW != 0 || r.Min.Y%mcuH != 0 {
+		return nil, fmt.Errorf("the crop's top left corner isn't on the JPEG's %dx%d block grid (the nearest one is %d,%d)",
+			mcuW, mcuH, r.Min.X/mcuW*mcuW, r.Min.Y/mcuH*mcuH)
+	}
+	return j.encode(r.Min.X/mcuW, r.Min.Y/mcuH, r.Dx(), r.Dy())
+}
+
+type jpegComponent struct {
+	id, h, v, tq byte
+	dc, ac       *jpegHuffman // the decoding tables the scan picked
+	blocksW      int          // blocks per row, including the padding
+	blocks       [][64]int16  // in zigzag order, as they're coded
+}
+
+// jpegBlocks is a baseline JPEG taken apart into its DCT blocks
+type jpegBlocks struct {
+	sof           byte     // the frame marker, SOF0 or SOF1
+	segments      [][]byte // what's kept as is, in order; nil where the frame header goes
+	width, height int
+	hmax, vmax    int
+	components    []*jpegComponent
+}
+
+func parseJPEGBlocks(data []byte) (*jpegBlocks, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errors.New("not a JPEG file")
+	}
+	j := &jpegBlocks{}
+	var tables [2][4]*jpegHuffman
+	restart := 0
+	for i := 2; ; {
+		for i+1 < len(data) && data[i] == 0xff && data[i+1] == 0xff {
+			i++ // fill bytes
+		}
+		if i+4 > len(data) || data[i] != 0xff {
+			return nil, errTruncated
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil, errTruncated
+		}
+		segment := data[i : i+2+length]
+		payload := segment[4:]
+		i += len(segment)
+
+		switch {
+		case marker == 0xc0 || marker == 0xc1:
+			if j.components != nil {
+				return nil, errors.New("the JPEG has more than one frame")
+			}
+			if err := j.parseFrame(marker, payload); err != nil {
+				return nil, err
+			}
+			j.segments = append(j.segments, nil)
+		case marker == 0xc2:
+			return nil, errors.New("progressive JPEGs can't be cropped losslessly")
+		case marker >= 0xc3 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			return nil, errors.New("only baseline JPEGs can be cropped losslessly")
+		case marker == 0xc4:
+			for p := payload; len(p) > 0; {
+				table, n, err := parseJPEGHuffman(p)
+				if err != nil {
+					return nil, err
+				}
+				class, id := p[0]>>4, p[0]&0x0f
+				if class > 1 || id > 3 {
+					return nil, errors.New("invalid Huffman table")
+				}
+				tables[class][id] = table
+				p = p[n:]
+			}
+		case marker == 0xdd:
+			if len(payload) < 2 {
+				return nil, errTruncated
+			}
+			restart = int(binary.BigEndian.Uint16(payload))
+		case marker == 0xda:
+			if j.components == nil {
+				return nil, errors.New("the image data comes before the frame header")
+			}
+			end, err := j.decodeScan(payload, data[i:], tables, restart)
+			if err != nil {
+				return nil, err
+			}
+			// a second scan would mean a non-interleaved or otherwise unusual
+			// file, rare enough to leave to the re-encode
+			if rest := data[i+end:]; len(rest) < 2 || rest[1] != 0xd9 {
+				return nil, errors.New("only JPEGs with a single scan can be cropped losslessly")
+			}
+			return j, nil
+		case marker == 0xd9:
+			return nil, errors.New("the JPEG has no image data")
+		default:
+			j.segments = append(j.segments, segment)
+		}
+	}
+}
+
+func (j *jpegBlocks) parseFrame(marker byte, p []byte) error {
+	if len(p) < 6 || p[0] != 8 {
+		return errors.New("only 8 bit JPEGs can be cropped losslessly")
+	}
+	j.sof = marker
+	j.height = int(binary.BigEndian.Uint16(p[1:]))
+	j.width = int(binary.BigEndian.Uint16(p[3:]))
+	n := int(p[5])
+	if n == 0 || n > 4 || len(p) < 6+3*n || j.width == 0 || j.height == 0 {
+		return errors.New("invalid frame header")
+	}
+	j.hmax, j.vmax = 1, 1
+	for c := 0; c < n; c++ {
+		q := p[6+3*c:]
+		comp := &jpegComponent{id: q[0], h: q[1] >> 4, v: q[1] & 0x0f, tq: q[2]}
+		if comp.h < 1 || comp.h > 4 || comp.v < 1 || comp.v > 4 {
+			return errors.New("invalid sampling factors")
+		}
+		if n == 1 {
+			comp.h, comp.v = 1, 1 // a lone component is coded block by block whatever it says
+		}
+		j.hmax, j.vmax = max(j.hmax, int(comp.h)), max(j.vmax, int(comp.v))
+		j.components = append(j.components, comp)
+	}
+	return nil
+}
+
+// mcus is how many MCUs across and down an image of w x h takes
+func (j *jpegBlocks) mcus(w, h int) (int, int) {
+	return (w + 8*j.hmax - 1) / (8 * j.hmax), (h + 8*j.vmax - 1) / (8 * j.vmax)
+}
+
+// decodeScan reads the blocks of the scan with header p out of the entropy
+// coded data, returning how far into data they reach
+func (j *jpegBlocks) decodeScan(p, data []byte, tables [2][4]*jpegHuffman, restart int) (int, error) {
+	if len(p) < 1 || int(p[0]) != len(j.components) || len(p) < 1+2*len(j.components)+3 {
+		return 0, errors.New("only JPEGs with a single scan can be cropped losslessly")
+	}
+	for c, comp := range j.components {
+		id, sel := p[1+2*c], p[2+2*c]
+		if id != comp.id {
+			return 0, errors.New("the scan's components don't match the frame's")
+		}
+		comp.dc, comp.ac = tables[0][sel>>4&3], tables[1][sel&3]
+		if comp.dc == nil || comp.ac == nil {
+			return 0, errors.New("the scan uses a Huffman table that isn't defined")
+		}
+	}
+
+	mcusX, mcusY := j.mcus(j.width, j.height)
+	for _, comp := range j.components {
+		comp.blocksW = mcusX * int(comp.h)
+		comp.blocks = make([][64]int16, comp.blocksW*mcusY*int(comp.v))
+	}
+	reader := &jpegBitReader{data: data}
+	preds := make([]int, len(j.components))
+	for mcu := 0; mcu < mcusX*mcusY; mcu++ {
+		if restart > 0 && mcu > 0 && mcu%restart == 0 {
+			if err := reader.restart(); err != nil {
+				return 0, err
+			}
+			clear(preds)
+		}
+		mx, my := mcu%mcusX, mcu/mcusX
+		for c, comp := range j.components {
+			for v := 0; v < int(comp.v); v++ {
+				for h := 0; h < int(comp.h); h++ {
+					index := (my*int(comp.v)+v)*comp.blocksW + mx*int(comp.h) + h
+					if err := reader.block(&comp.blocks[index], comp.dc, comp.ac, &preds[c]); err != nil {
+						return 0, err
+					}
+				}
+			}
+		}
+	}
+
+	// past the padding bits to the marker that ends the scan
+	end := reader.pos
+	for end+1 < len(data) && (data[end] != 0xff || data[end+1] == 0 || data[end+1] == 0xff || data[end+1]&0xf8 == 0xd0) {
+		end++
+	}
+	return end, nil
+}
+
+// encode writes the MCUs from (mcuX, mcuY) on that cover w x h pixels as a
+// new JPEG
+func (j *jpegBlocks) encode(mcuX, mcuY, w, h int) ([]byte, error) {
+	out := []byte{0xff, 0xd8}
+	for _, segment := range j.segments {
+		if segment != nil {
+			out = append(out, segment...)
+			continue
+		}
+		out = append(out, 0xff, j.sof)
+		out = binary.BigEndian.AppendUint16(out, uint16(8+3*len(j.components)))
+		out = append(out, 8)
+		out = binary.BigEndian.AppendUint16(out, uint16(h))
+		out = binary.BigEndian.AppendUint16(out, uint16(w))
+		out = append(out, byte(len(j.components)))
+		for _, comp := range j.components {
+			out = append(out, comp.id, comp.h<<4|comp.v, comp.tq)
+		}
+	}
+
+	// the DC differences change with the neighbors, so the file's own
+	// tables may lack codes for them. the standard ones have them all
+	out = append(out, 0xff, 0xc4)
+	out = binary.BigEndian.AppendUint16(out, uint16(2+4*17+12+162+12+162))
+	for i, spec := range standardHuffman {
+		out = append(out, byte(i%2)<<4|byte(i/2))
+		out = append(out, spec.counts[:]...)
+		out = append(out, spec.values...)
+	}
+
+	out = append(out, 0xff, 0xda)
+	out = binary.BigEndian.AppendUint16(out, uint16(6+2*len(j.components)))
+	out = append(out, byte(len(j.components)))
+	for c, comp := range j.components {
+		table := byte(min(c, 1)) // luminance for the first component, chrominance for the rest
+		out = append(out, comp.id, table<<4|table)
+	}
+	out = append(out, 0, 63, 0)
+
+	writer := &jpegBitWriter{out: out}
+	preds := make([]int, len(j.components))
+	mcusX, mcusY := j.mcus(w, h)
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			for c, comp := range j.components {
+				table := min(c, 1)
+				for v := 0; v < int(comp.v); v++ {
+					for h := 0; h < int(comp.h); h++ {
+						row := (mcuY+my)*int(comp.v) + v
+						col := (mcuX+mx)*int(comp.h) + h
+						block := &comp.blocks[row*comp.blocksW+col]
+						if err := writer.block(block, &standardHuffmanCodes[2*table], &standardHuffmanCodes[2*table+1], &preds[c]); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+		}
+	}
+	return append(writer.flush(), 0xff, 0xd9), nil
+}
+
+// jpegHuffman is a Huffman table for decoding, as laid out in the spec's
+// F.2.2.3: for every code length the largest code and where its values start
+type jpegHuffman struct {
+	maxCode [17]int32
+	offset  [17]int32
+	values  []byte
+}
+
+// parseJPEGHuffman reads one table from a DHT segment, returning how many
+// bytes it took
+func parseJPEGHuffman(p []byte) (*jpegHuffman, int, error) {
+	if len(p) < 17 {
+		return nil, 0, errTruncated
+	}
+	t := &jpegHuffman{}
+	total := 0
+	for _, n := range p[1:17] {
+		total += int(n)
+	}
+	if len(p) < 17+total || total > 256 {
+		return nil, 0, errTruncated
+	}
+	t.values = p[17 : 17+total]
+	code, k := int32(0), int32(0)
+	for length := 1; length <= 16; length++ {
+		n := int32(p[length])
+		t.offset[length] = k - code
+		code += n
+		k += n
+		t.maxCode[length] = code - 1
+		if n == 0 {
+			t.maxCode[length] = -1
+		}
+		code <<= 1
+	}
+	return t, 17 + total, nil
+}
+
+// jpegBitReader reads entropy coded data, dropping the stuffed zero bytes.
+// at a marker it stops and hands out zeros, like other decoders do
+type jpegBitReader struct {
+	data []byte
+	pos  int
+	acc  uint32
+	n    int
+}
+
+func (b *jpegBitReader) bit() int {
+	if b.n == 0 {
+		b.acc, b.n = 0, 8
+		if b.pos < len(b.data) {
+			c := b.data[b.pos]
+			if c != 0xff {
+				b.acc = uint32(c)
+				b.pos++
+			} else if b.pos+1 < len(b.data) && b.data[b.pos+1] == 0 {
+				b.acc = 0xff
+				b.pos += 2
+			}
+		}
+	}
+	b.n--
+	return int(b.acc>>b.n) & 1
+}
+
+func (b *jpegBitReader) receive(n int) int {
+	v := 0
+	for ; n > 0; n-- {
+		v = v<<1 | b.bit()
+	}
+	return v
+}
+
+func (b *jpegBitReader) decode(t *jpegHuffman) (byte, error) {
+	code := int32(0)
+	for length := 1; length <= 16; length++ {
+		code = code<<1 | int32(b.bit())
+		if code <= t.maxCode[length] {
+			index := t.offset[length] + code
+			if index < 0 || int(index) >= len(t.values) {
+				break
+			}
+			return t.values[index], nil
+		}
+	}
+	return 0, errors.New("corrupt image data")
+}
+
+// extend turns the n bits v into the signed value they code
+func extend(v, n int) int {
+	if n > 0 && v < 1<<(n-1) {
+		return v - 1<<n + 1
+	}
+	return v
+}
+
+// restart skips the padding bits and the RSTn marker every restart interval
+// ends with
+func (b *jpegBitReader) restart() error {
+	b.n = 0
+	if b.pos+1 >= len(b.data) || b.data[b.pos] != 0xff || b.data[b.pos+1]&0xf8 != 0xd0 {
+		return errors.New("a restart marker is missing")
+	}
+	b.pos += 2
+	return nil
+}
+
+func (b *jpegBitReader) block(block *[64]int16, dc, ac *jpegHuffman, pred *int) error {
+	size, err := b.decode(dc)
+	if err != nil {
+		return err
+	}
+	if size > 11 {
+		return errors.New("corrupt image data")
+	}
+	*pred += extend(b.receive(int(size)), int(size))
+	block[0] = int16(*pred)
+	for k := 1; k < 64; k++ {
+		rs, err := b.decode(ac)
+		if err != nil {
+			return err
+		}
+		run, size := int(rs>>4), int(rs&0x0f)
+		if size == 0 {
+			if run != 15 {
+				return nil // end of block
+			}
+			k += 15
+			continue
+		}
+		if k += run; k > 63 {
+			return errors.New("corrupt image data")
+		}
+		block[k] = int16(extend(b.receive(size), size))
+	}
+	return nil
+}
+
+// jpegHuffmanCode is a Huffman table for encoding: the code and its length
+// for every value
+type jpegHuffmanCode struct {
+	code [256]uint16
+	size [256]uint8
+}
+
+// jpegBitWriter writes entropy coded data, stuffing a zero after every 0xff
+type jpegBitWriter struct {
+	out []byte
+	acc uint32
+	n   int
+}
+
+func (w *jpegBitWriter) emit(v uint32, n int) {
+	w.acc = w.acc<<n | v&(1<<n-1)
+	w.n += n
+	for w.n >= 8 {
+		c := byte(w.acc >> (w.n - 8))
+		w.out = append(w.out, c)
+		if c == 0xff {
+			w.out = append(w.out, 0)
+		}
+		w.n -= 8
+	}
+}
+
+func (w *jpegBitWriter) symbol(t *jpegHuffmanCode, v byte) error {
+	if t.size[v] == 0 {
+		return errors.New("a coefficient is too large for the standard Huffman tables")
+	}
+	w.emit(uint32(t.code[v]), int(t.size[v]))
+	return nil
+}
+
+// value writes v as its size category followed by its bits
+func (w *jpegBitWriter) value(t *jpegHuffmanCode, run int, v int) error {
+	magnitude := v
+	if v < 0 {
+		magnitude, v = -v, v-1
+	}
+	size := bits.Len(uint(magnitude))
+	if err := w.symbol(t, byte(run<<4|size)); err != nil {
+		return err
+	}
+	w.emit(uint32(v), size)
+	return nil
+}
+
+func (w *jpegBitWriter) block(block *[64]int16, dc, ac *jpegHuffmanCode, pred *int) error {
+	if err := w.value(dc, 0, int(block[0])-*pred); err != nil {
+		return err
+	}
+	*pred = int(block[0])
+	run := 0
+	for k := 1; k < 64; k++ {
+		if block[k] == 0 {
+			run++
+			continue
+		}
+		for ; run > 15; run -= 16 {
+			if err := w.symbol(ac, 0xf0); err != nil {
+				return err
+			}
+		}
+		if err := w.value(ac, run, int(block[k])); err != nil {
+			return err
+		}
+		run = 0
+	}
+	if run > 0 {
+		return w.symbol(ac, 0x00)
+	}
+	return nil
+}
+
+// flush pads the last byte with ones
+func (w *jpegBitWriter) flush() []byte {
+	if w.n > 0 {
+		w.emit(1<<(8-w.n)-1, 8-w.n)
+	}
+	return w.out
+}
+
+// standardHuffman are the example tables of the spec's section K.3, which
+// libjpeg and image/jpeg use too: luminance DC and AC, then chrominance DC
+// and AC
+var standardHuffman = [4]struct {
+	counts [16]byte
+	values []byte
+}{
+	// luminance DC
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// luminance AC
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	// chrominance DC
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// chrominance AC
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+var standardHuffmanCodes = func() (codes [4]jpegHuffmanCode) {
+	for i, spec := range standardHuffman {
+		code, k := uint16(0), 0
+		for length, n := range spec.counts {
+			for ; n > 0; n-- {
+				v := spec.values[k]
+				codes[i].code[v], codes[i].size[v] = code, uint8(length+1)
+				code++
+				k++
+			}
+			code <<= 1
+		}
+	}
+	return codes
+}()
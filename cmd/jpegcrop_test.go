@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestCropJPEG(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 70))
+	for y := 0; y < 70; y++ {
+		for x := 0; x < 100; x++ {
+			src.Set(x, y, color.RGBA{uint8(x * 2), uint8(y * 3), uint8(x ^ y), 255})
+		}
+	}
+	gray := image.NewGray(src.Bounds())
+	for i := range gray.Pix {
+		gray.Pix[i] = src.Pix[i*4]
+	}
+
+	for _, tc := range []struct {
+		name string
+		img  image.Image
+		crop image.Rectangle
+	}{
+		{"color", src, image.Rect(16, 32, 16+45, 32+21)},
+		{"color to the edge", src, image.Rect(80, 48, 100, 70)},
+		{"gray", gray, image.Rect(8, 24, 8+37, 24+13)},
+	} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, tc.img, &jpeg.Options{Quality: 80}); err != nil {
+			t.Fatal(err)
+		}
+		whole, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cropped, err := CropJPEG(buf.Bytes(), tc.crop)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(cropped))
+		if err != nil {
+			t.Fatalf("%s: the crop doesn't decode: %v", tc.name, err)
+		}
+		if img.Bounds().Size() != tc.crop.Size() {
+			t.Fatalf("%s: cropped to %v, want %v", tc.name, img.Bounds().Size(), tc.crop.Size())
+		}
+		// the same coefficients decode to the very same pixels
+		for y := 0; y < tc.crop.Dy(); y++ {
+			for x := 0; x < tc.crop.Dx(); x++ {
+				got, want := img.At(x, y), whole.At(tc.crop.Min.X+x, tc.crop.Min.Y+y)
+				if color.RGBAModel.Convert(got) != color.RGBAModel.Convert(want) {
+					t.Fatalf("%s: pixel %d,%d is %v, want %v", tc.name, x, y, got, want)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, src, nil)
+	if _, err := CropJPEG(buf.Bytes(), image.Rect(10, 16, 50, 50)); err == nil {
+		t.Error("a crop off the 16 pixel grid of a 4:2:0 JPEG worked")
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"image"
+)
+
+// kenBurnsZoom is how close the Ken Burns effect gets, 1.25 shows 80% of the
+// image's width and height
+const kenBurnsZoom = 1.25
+
+// kenBurnsPans are the start and end points of the pans, as shares of the
+// room the view has to move in; slides take turns with them
+var kenBurnsPans = [][2][2]float64{
+	{{0.2, 0.2}, {0.8, 0.7}},
+	{{0.8, 0.3}, {0.2, 0.6}},
+	{{0.5, 0.8}, {0.5, 0.2}},
+	{{0.3, 0.7}, {0.7, 0.3}},
+}
+
+// kenBurnsView is the part of an image with bounds that the Ken Burns effect
+// shows progress (0 to 1) of the way through slide number slide. every other
+// slide zooms in, the rest zoom out, while the view pans across. the view
+// keeps the image's shape, so every frame renders to the same cells
+func kenBurnsView(bounds image.Rectangle, progress float64, slide int) image.Rectangle {
+	progress = max(0, min(progress, 1))
+	zoom := 1 + (kenBurnsZoom-1)*progress
+	if slide%2 == 1 {
+		zoom = kenBurnsZoom - (kenBurnsZoom-1)*progress
+	}
+	w := int(float64(bounds.Dx())/zoom + 0.5)
+	h := int(float64(bounds.Dy())/zoom + 0.5)
+
+	pan := kenBurnsPans[slide%len(kenBurnsPans)]
+	fx := pan[0][0] + (pan[1][0]-pan[0][0])*progress
+	fy := pan[0][1] + (pan[1][1]-pan[0][1])*progress
+	x := bounds.Min.X + int(fx*float64(bounds.Dx()-w)+0.5)
+	y := bounds.Min.Y + int(fy*float64(bounds.Dy()-h)+0.5)
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// kenBurnsSource shrinks img to a working copy for the Ken Burns frames: a
+// few times the resolution the renderer draws it at, so the view moves in
+// steps smaller than a sample while every frame only scales a small image
+func kenBurnsSource(r *ImageRenderer, img image.Image) image.Image {
+	bounds := img.Bounds()
+	samples, _ := r.sampleSize(bounds.Dx(), bounds.Dy())
+	w, h := resizeBox(bounds.Dx(), bounds.Dy(), int(3*kenBurnsZoom*float64(samples)), 0)
+	if w == bounds.Dx() && h == bounds.Dy() {
+		return img
+	}
+	return ResizeImage(img, w, h, r.Scaler)
+}
@@ -0,0 +1,9 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !zos && !windows
+
+package cmd
+
+import "errors"
+
+func cbreakMode(fd int) error {
+	return errors.New("keyboard controls aren't supported on this platform")
+}
@@ -0,0 +1,20 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || zos
+
+package cmd
+
+import "golang.org/x/sys/unix"
+
+// cbreakMode turns off echo and line buffering on the terminal at fd, so
+// keys arrive as they're pressed. unlike raw mode, output processing and
+// signals stay on: frames still end lines with a plain \n and Ctrl+C still
+// interrupts
+func cbreakMode(fd int) error {
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+	termios.Lflag &^= unix.ECHO | unix.ICANON
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, termios)
+}
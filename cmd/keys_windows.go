@@ -0,0 +1,16 @@
+package cmd
+
+import "golang.org/x/sys/windows"
+
+// cbreakMode turns off echo and line input on the console at fd and has it
+// send arrow keys as VT sequences, the same the Unix terminals send.
+// processed input stays on so Ctrl+C still interrupts
+func cbreakMode(fd int) error {
+	in := windows.Handle(fd)
+	var mode uint32
+	if err := windows.GetConsoleMode(in, &mode); err != nil {
+		return err
+	}
+	mode &^= windows.ENABLE_LINE_INPUT | windows.ENABLE_ECHO_INPUT
+	return windows.SetConsoleMode(in, mode|windows.ENABLE_VIRTUAL_TERMINAL_INPUT)
+}
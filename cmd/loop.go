@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseLoopRegion reads a --loop-region like "3s-7s" or "1m2s-1m10s".
+// plain numbers are seconds
+func parseLoopRegion(value string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q should look like 3s-7s", value)
+	}
+	if start, err = parseLoopTime(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseLoopTime(to); err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("%q ends before it starts", value)
+	}
+	return start, end, nil
+}
+
+func parseLoopTime(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// regionFrame is a frame of the loop region, scaled for the renderer
+type regionFrame struct {
+	img        *image.RGBA
+	at, length time.Duration
+}
+
+// regionSource repeats a stretch of a seekable source, forward over and
+// over or, with boomerang, forward and back. the first pass comes from the
+// source; its frames are kept at the renderer's sample size, so later
+// passes neither decode again nor restart ffmpeg, and can run backwards
+type regionSource struct {
+	source     frameSource
+	seeker     seekableSource
+	scale      func(dst *image.RGBA, img image.Image) *image.RGBA
+	start, end time.Duration
+	boomerang  bool
+
+	frames   []regionFrame
+	partial  bool // the first pass was seeked into, it has to start over
+	complete bool
+	index    int // of the frame next shows once complete
+	step     int
+	at       time.Duration
+	before   time.Duration
+}
+
+// newRegionSource loops source between start and end. end 0 is the end of
+// the source
+func newRegionSource(source frameSource, start, end time.Duration, boomerang bool, r *ImageRenderer) (*regionSource, error) {
+	seeker := findSeeker(source)
+	if seeker == nil || seeker.length() <= 0 {
+		return nil, errors.New("only GIFs and video files can loop, not live streams")
+	}
+	if end == 0 || end > seeker.length() {
+		end = seeker.length()
+	}
+	if start >= end {
+		return nil, fmt.Errorf("the region starts after the end (%s)", formatClock(seeker.length()))
+	}
+	if start > 0 {
+		seeker.seek(start)
+	}
+	return &regionSource{source: source, seeker: seeker, scale: r.scaleInto, start: start, end: end, boomerang: boomerang, step: 1, at: start}, nil
+}
+
+func (s *regionSource) prescaled() {}
+
+func (s *regionSource) next() (image.Image, time.Duration, error) {
+	if !s.complete {
+		img, delay, err := s.source.next()
+		at := s.seeker.position()
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		// a looping GIF starts over at the end, that's past the region too
+		if err == nil && at < s.end && (len(s.frames) == 0 || at > s.frames[len(s.frames)-1].at) {
+			return s.keep(img, delay, at), delay, nil
+		}
+		if s.partial {
+			s.frames, s.partial = s.frames[:0], false
+			s.seeker.seek(s.start)
+			return s.next()
+		}
+		if len(s.frames) == 0 {
+			return nil, 0, io.EOF
+		}
+		last := &s.frames[len(s.frames)-1]
+		last.length = max(s.end-last.at, last.length)
+		s.seeker.pause()
+		s.complete = true
+		s.index = 0
+		if s.boomerang && len(s.frames) > 1 {
+			s.index, s.step = len(s.frames)-2, -1
+		}
+	}
+
+	frame := s.frames[s.index]
+	s.before, s.at = s.at, frame.at
+	switch {
+	case !s.boomerang:
+		s.index = (s.index + 1) % len(s.frames)
+	case len(s.frames) > 1:
+		if s.index+s.step < 0 || s.index+s.step >= len(s.frames) {
+			s.step = -s.step
+		}
+		s.index += s.step
+	}
+	return frame.img, frame.length, nil
+}
+
+// keep adds a frame of the first pass. a frame's length is only known by
+// the time the one after it comes, which it's filled in from
+func (s *regionSource) keep(img image.Image, delay, at time.Duration) *image.RGBA {
+	if n := len(s.frames); n > 0 && s.frames[n-1].length == 0 {
+		s.frames[n-1].length = at - s.frames[n-1].at
+	}
+	scaled := s.scale(nil, img)
+	s.frames = append(s.frames, regionFrame{img: scaled, at: at, length: delay})
+	s.before, s.at = s.at, at
+	return scaled
+}
+
+func (s *regionSource) position() time.Duration      { return s.at }
+func (s *regionSource) length() time.Duration        { return s.seeker.length() }
+func (s *regionSource) previousFrame() time.Duration { return s.before }
+
+// seek stays in the region. before the first pass is done, it seeks the
+// source and the pass starts over, from the region's start once it gets to
+// its end when it didn't start there
+func (s *regionSource) seek(to time.Duration) {
+	to = min(max(to, s.start), s.end-1)
+	if !s.complete {
+		s.seeker.seek(to)
+		s.frames, s.partial = s.frames[:0], to > s.start
+		return
+	}
+	s.index, s.step = 0, 1
+	for i, frame := range s.frames {
+		if frame.at <= to {
+			s.index = i
+		}
+	}
+}
+
+func (s *regionSource) pause() {
+	if !s.complete {
+		s.seeker.pause()
+	}
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	markdownText bool
+	markdownList bool
+)
+
+var markdownCmd = &cobra.Command{
+	Use:   "md [markdown_path_or_url]",
+	Short: "Render the images a Markdown file refers to",
+	Long: `Find the images in a Markdown document, ![alt](src), ![alt][ref] and
+<img src="..."> alike, and render them one after the other, or in their
+place in the text with --text:
+
+  termuwu md README.md
+  termuwu md docs/guide.md --text -W 60
+  termuwu md https://example.com/raw/README.md --list
+
+Relative sources are looked up next to the document, or against its URL
+when it was downloaded. Images in code blocks are left out, as are the ones
+that don't decode (like SVG badges), with a warning. - reads the document
+from stdin.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		nameColor := themeColor("name", color.FgMagenta, color.Bold).SprintFunc()
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		document, base, err := readMarkdown(args[0])
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading Markdown:"), err)
+			return
+		}
+		lines := strings.Split(strings.ReplaceAll(document, "\r\n", "\n"), "\n")
+		images := markdownImages(lines)
+
+		if markdownList {
+			for _, img := range images {
+				fmt.Printf("%s  %s  %s\n", dimColor(fmt.Sprintf("%d:", img.Line+1)), nameColor(img.Alt), resolveMarkdownSource(base, img.Src))
+			}
+			fmt.Fprintf(statusOut, tr("🖼️  %s %d\n"), infoColor("Images:"), len(images))
+			return
+		}
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.ColorDepth = depth
+		render := func(img markdownImage) {
+			source := resolveMarkdownSource(base, img.Src)
+			if !markdownText {
+				fmt.Printf("%s %s\n", nameColor(img.Alt), dimColor(img.Src))
+			}
+			decoded, _, err := loadImage(source)
+			if err != nil {
+				fmt.Fprintf(statusOut, "⚠️  %s %s: %v\n", infoColor("Skipping"), img.Src, err)
+				return
+			}
+			fmt.Print(renderer.RenderImage(decoded))
+			fmt.Println()
+		}
+
+		if !markdownText {
+			for _, img := range images {
+				render(img)
+			}
+		} else {
+			next := 0
+			for i, line := range lines {
+				fmt.Println(line)
+				for ; next < len(images) && images[next].Line == i; next++ {
+					render(images[next])
+				}
+			}
+		}
+		if len(images) == 0 {
+			fmt.Fprintf(statusOut, "⚠️  %s %s\n", infoColor("No images in"), args[0])
+		}
+	},
+}
+
+// markdownImage is an image reference on line Line (from 0) of a document
+type markdownImage struct {
+	Line     int
+	Alt, Src string
+}
+
+var (
+	markdownInline     = regexp.MustCompile(`!\[([^\]]*)\]\(\s*(?:<([^>]*)>|([^\s)]+))(?:\s+(?:"[^"]*"|'[^']*'|\([^)]*\)))?\s*\)`)
+	markdownReference  = regexp.MustCompile(`!\[([^\]]*)\]\[([^\]]*)\]`)
+	markdownDefinition = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:\s*(?:<([^>]*)>|(\S+))`)
+	markdownHTMLImage  = regexp.MustCompile(`(?i)<img\s[^>]*>`)
+	markdownAttribute  = regexp.MustCompile(`(?i)\b(src|alt)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// markdownImages finds the images in lines in the order they appear,
+// outside fenced code blocks
+func markdownImages(lines []string) []markdownImage {
+	definitions := make(map[string]string)
+	fence := ""
+	code := make([]bool, len(lines))
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			code[i] = true
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence, code[i] = trimmed[:3], true
+			continue
+		}
+		if m := markdownDefinition.FindStringSubmatch(line); m != nil {
+			definitions[strings.ToLower(m[1])] = m[2] + m[3]
+		}
+	}
+
+	var images []markdownImage
+	for i, line := range lines {
+		if code[i] {
+			continue
+		}
+		type found struct {
+			at  int
+			img markdownImage
+		}
+		var onLine []found
+		for _, m := range markdownInline.FindAllStringSubmatchIndex(line, -1) {
+			onLine = append(onLine, found{m[0], markdownImage{i, submatch(line, m, 1), submatch(line, m, 2) + submatch(line, m, 3)}})
+		}
+		for _, m := range markdownReference.FindAllStringSubmatchIndex(line, -1) {
+			alt, ref := line[m[2]:m[3]], line[m[4]:m[5]]
+			if ref == "" {
+				ref = alt // ![alt][] uses the alt text as the label
+			}
+			if src, ok := definitions[strings.ToLower(ref)]; ok {
+				onLine = append(onLine, found{m[0], markdownImage{i, alt, src}})
+			}
+		}
+		for _, m := range markdownHTMLImage.FindAllStringIndex(line, -1) {
+			img := markdownImage{Line: i}
+			for _, attr := range markdownAttribute.FindAllStringSubmatch(line[m[0]:m[1]], -1) {
+				value := attr[2] + attr[3]
+				if strings.EqualFold(attr[1], "src") {
+					img.Src = value
+				} else {
+					img.Alt = value
+				}
+			}
+			if img.Src != "" {
+				onLine = append(onLine, found{m[0], img})
+			}
+		}
+		sort.SliceStable(onLine, func(a, b int) bool { return onLine[a].at < onLine[b].at })
+		for _, f := range onLine {
+			images = append(images, f.img)
+		}
+	}
+	return images
+}
+
+// submatch is group n of the match at m in s, empty when it didn't take part
+func submatch(s string, m []int, n int) string {
+	if m[2*n] < 0 {
+		return ""
+	}
+	return s[m[2*n]:m[2*n+1]]
+}
+
+// readMarkdown reads the document at source, a path, a URL or - for stdin,
+// and returns what relative image sources are resolved against
+func readMarkdown(source string) (document, base string, err error) {
+	var r io.ReadCloser
+	switch {
+	case source == "-":
+		r, base = io.NopCloser(os.Stdin), "."
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		resp, err := httpGet(context.Background(), source)
+		if err != nil {
+			return "", "", err
+		}
+		r, base = resp.Body, source
+	default:
+		file, err := os.Open(source)
+		if err != nil {
+			return "", "", err
+		}
+		r, base = file, filepath.Dir(source)
+	}
+	defer r.Close()
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, bufio.NewReader(r)); err != nil {
+		return "", "", err
+	}
+	return sb.String(), base, nil
+}
+
+// resolveMarkdownSource turns src into something loadImage opens: URLs and
+// data URIs stay as they are, the rest is relative to base, a directory or
+// the document's URL
+func resolveMarkdownSource(base, src string) string {
+	if isDataURI(src) || strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		return src
+	}
+	if strings.HasPrefix(src, "//") {
+		return "https:" + src
+	}
+	if strings.HasPrefix(base, "http://") || strings.HasPrefix(base, "https://") {
+		baseURL, err := url.Parse(base)
+		ref, refErr := url.Parse(src)
+		if err != nil || refErr != nil {
+			return src
+		}
+		return baseURL.ResolveReference(ref).String()
+	}
+	local := src
+	if i := strings.IndexAny(local, "?#"); i >= 0 {
+		local = local[:i]
+	}
+	if unescaped, err := url.PathUnescape(local); err == nil {
+		local = unescaped
+	}
+	local = filepath.FromSlash(local)
+	if filepath.IsAbs(local) {
+		return local
+	}
+	return filepath.Join(base, local)
+}
+
+func init() {
+	rootCmd.AddCommand(markdownCmd)
+
+	markdownCmd.Flags().BoolVarP(&markdownText, "text", "t", false, "Print the document's text with each image rendered below its line.")
+	markdownCmd.Flags().BoolVar(&markdownList, "list", false, "Only list the images and where they resolve to.")
+	markdownCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	markdownCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	markdownCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	markdownCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered images in characters (0 for auto).")
+	markdownCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered images in lines (0 for auto).")
+	markdownCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
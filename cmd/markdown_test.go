@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMarkdownImages checks inline, reference and HTML images are found in
+// order, code blocks skipped and relative sources resolved
+func TestMarkdownImages(t *testing.T) {
+	doc := strings.Join([]string{
+		`<img alt="logo" src="logo.png"> ![shot](<img/a b.png> "title")`,
+		"```",
+		"![code](no.png)",
+		"```",
+		"![ref][diagram] ![inline](https://example.com/x.png)",
+		`[Diagram]: docs/diagram%20v2.png`,
+	}, "\n")
+	var got []string
+	for _, img := range markdownImages(strings.Split(doc, "\n")) {
+		got = append(got, img.Alt+"="+resolveMarkdownSource("base", img.Src))
+	}
+	want := []string{
+		"logo=" + filepath.Join("base", "logo.png"),
+		"shot=" + filepath.Join("base", "img", "a b.png"),
+		"ref=" + filepath.Join("base", "docs", "diagram v2.png"),
+		"inline=https://example.com/x.png",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("found\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if src := resolveMarkdownSource("https://example.com/docs/README.md", "../img/a.png"); src != "https://example.com/img/a.png" {
+		t.Errorf("resolved against a URL to %s", src)
+	}
+}
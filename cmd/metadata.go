@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// StrippedPart is a piece of metadata StripMetadata took out
+type StrippedPart struct {
+	Kind string // like "EXIF" or "XMP"
+	Size int    // bytes
+}
+
+// StripResult is what StripMetadata made of a file
+type StripResult struct {
+	Data        []byte
+	Removed     []StrippedPart
+	Orientation int // the EXIF orientation kept, 0 when there was none or it wasn't asked for
+}
+
+// StripMetadata removes EXIF (with any GPS position), XMP, IPTC, comments
+// and text chunks from a JPEG, PNG or WebP file without re-encoding it.
+// color profiles stay since they change how the image looks. with
+// keepOrientation an EXIF block with only the orientation is put back
+func StripMetadata(data []byte, keepOrientation bool) (StripResult, error) {
+	var strip func([]byte, bool) (StripResult, error)
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		strip = stripJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		strip = stripPNG
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		strip = stripWebP
+	default:
+		return StripResult{}, errors.New("only JPEG, PNG and WebP files can be stripped without re-encoding them")
+	}
+	result, err := strip(data, keepOrientation)
+	if !keepOrientation {
+		result.Orientation = 0
+	}
+	return result, err
+}
+
+var errTruncated = errors.New("the file is truncated")
+
+// exifKind names an EXIF block, telling when it gives away where the
+// photo was taken
+func exifKind(tiff []byte) string {
+	if _, err := PhotoLocation(bytes.NewReader(tiff)); err == nil {
+		return "EXIF with a GPS position"
+	}
+	return "EXIF"
+}
+
+// exifOrientation reads the orientation (1-8) out of EXIF data, 0 when
+// there's none
+func exifOrientation(tiff []byte) int {
+	x, err := exif.Decode(bytes.NewReader(tiff))
+	if err != nil {
+		return 0
+	}
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 0
+	}
+	v, err := tag.Int(0)
+	if err != nil || v < 1 || v > 8 {
+		return 0
+	}
+	return v
+}
+
+// orientationTIFF is the smallest EXIF data there is: a big-endian TIFF
+// header and one IFD with the orientation tag
+func orientationTIFF(orientation int) []byte {
+	b := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	b = binary.BigEndian.AppendUint16(b, 1)      // one entry
+	b = binary.BigEndian.AppendUint16(b, 0x0112) // Orientation
+	b = binary.BigEndian.AppendUint16(b, 3)      // SHORT
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint16(b, uint16(orientation))
+	b = append(b, 0, 0)                        // the value is padded to 4 bytes
+	return binary.BigEndian.AppendUint32(b, 0) // no next IFD
+}
+
+// jpegKept are the APPn segments JPEG decoders need: JFIF, the ICC color
+// profile and Adobe's color transform
+func jpegKept(marker byte, payload []byte) bool {
+	switch marker {
+	case 0xe0:
+		return bytes.HasPrefix(payload, []byte("JFIF\x00")) || bytes.HasPrefix(payload, []byte("JFXX\x00"))
+	case 0xe2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case 0xee:
+		return bytes.HasPrefix(payload, []byte("Adobe"))
+	}
+	return false
+}
+
+func stripJPEG(data []byte, keepOrientation bool) (StripResult, error) {
+	out := []byte{0xff, 0xd8}
+	var parts []StrippedPart
+	orientation := 0
+	exifAt := -1 // where the EXIF block was, to put the orientation back
+	i := 2
+	for {
+		for i < len(data) && data[i] == 0xff && i+1 < len(data) && data[i+1] == 0xff {
+			i++ // fill bytes
+		}
+		if i+4 > len(data) || data[i] != 0xff {
+			return StripResult{}, errTruncated
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return StripResult{}, errTruncated
+		}
+		segment := data[i : i+2+length]
+		payload := segment[4:]
+
+		if marker == 0xda {
+			// the scans run to the end of image marker, which can't appear
+			// inside them; whatever follows it (more pictures, a motion
+			// photo's video) goes too
+			end := bytes.Index(data[i:], []byte{0xff, 0xd9})
+			if end < 0 {
+				return StripResult{}, errTruncated
+			}
+			end += i + 2
+			out = append(out, data[i:end]...)
+			if trailing := len(data) - end; trailing > 0 {
+				parts = append(parts, StrippedPart{"data after the image", trailing})
+			}
+			break
+		}
+
+		drop := ""
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+			drop = exifKind(payload[6:])
+			if o := exifOrientation(payload[6:]); o != 0 {
+				orientation = o
+			}
+			if exifAt < 0 {
+				exifAt = len(out)
+			}
+		case marker == 0xe1 && bytes.HasPrefix(payload, []byte("http://ns.adobe.com/")):
+			drop = "XMP"
+		case marker == 0xed:
+			drop = "IPTC"
+		case marker == 0xfe:
+			drop = "comment"
+		case marker >= 0xe0 && marker <= 0xef && !jpegKept(marker, payload):
+			drop = fmt.Sprintf("APP%d", marker-0xe0)
+		}
+		if drop != "" {
+			parts = append(parts, StrippedPart{drop, len(segment)})
+		} else {
+			out = append(out, segment...)
+		}
+		i += len(segment)
+	}
+
+	if keepOrientation && orientation != 0 {
+		tiff := append([]byte("Exif\x00\x00"), orientationTIFF(orientation)...)
+		app1 := binary.BigEndian.AppendUint16([]byte{0xff, 0xe1}, uint16(len(tiff)+2))
+		app1 = append(app1, tiff...)
+		out = append(out[:exifAt], append(app1, out[exifAt:]...)...)
+	}
+	return StripResult{out, parts, orientation}, nil
+}
+
+// pngKept are the ancillary PNG chunks that change how the image looks or
+// animates, the rest of them is metadata
+var pngKept = map[string]bool{
+	"tRNS": true, "gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "sBIT": true,
+	"bKGD": true, "pHYs": true, "cICP": true, "mDCv": true, "cLLi": true,
+	"acTL": true, "fcTL": true, "fdAT": true,
+}
+
+func stripPNG(data []byte, keepOrientation bool) (StripResult, error) {
+	out := append([]byte(nil), data[:8]...)
+	var parts []StrippedPart
+	orientation, written := 0, false
+	for i := 8; ; {
+		if i+12 > len(data) {
+			return StripResult{}, errTruncated
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		if length < 0 || i+12+length > len(data) {
+			return StripResult{}, errTruncated
+		}
+		chunk := data[i : i+12+length]
+		i += len(chunk)
+
+		critical := kind[0] >= 'A' && kind[0] <= 'Z'
+		if (kind == "IDAT" || kind == "IEND") && keepOrientation && orientation != 0 && !written {
+			// before the image data where it belongs, or at the end when the
+			// orientation only came after it
+			out = appendPNGChunk(out, "eXIf", orientationTIFF(orientation))
+			written = true
+		}
+		if critical || pngKept[kind] {
+			out = append(out, chunk...)
+			if kind == "IEND" {
+				if trailing := len(data) - i; trailing > 0 {
+					parts = append(parts, StrippedPart{"data after the image", trailing})
+				}
+				return StripResult{out, parts, orientation}, nil
+			}
+			continue
+		}
+		name := kind + " chunk"
+		switch kind {
+		case "eXIf":
+			name = exifKind(chunk[8 : 8+length])
+			if o := exifOrientation(chunk[8 : 8+length]); o != 0 {
+				orientation = o
+			}
+		case "tEXt", "zTXt", "iTXt":
+			name = "text"
+			if bytes.HasPrefix(chunk[8:], []byte("XML:com.adobe.xmp\x00")) {
+				name = "XMP"
+			}
+		case "tIME":
+			name = "modification time"
+		}
+		parts = append(parts, StrippedPart{name, len(chunk)})
+	}
+}
+
+func stripWebP(data []byte, keepOrientation bool) (StripResult, error) {
+	const (
+		flagEXIF = 0x08
+		flagXMP  = 0x04
+	)
+	var chunks [][]byte
+	var parts []StrippedPart
+	vp8x := -1
+	orientation := 0
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return StripResult{}, errTruncated
+		}
+		kind := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if size < 0 || end > len(data) {
+			return StripResult{}, errTruncated
+		}
+		chunk := data[i:end]
+		i = end
+		switch kind {
+		case "EXIF":
+			tiff := chunk[8 : 8+size]
+			tiff = bytes.TrimPrefix(tiff, []byte("Exif\x00\x00"))
+			parts = append(parts, StrippedPart{exifKind(tiff), len(chunk)})
+			if o := exifOrientation(tiff); o != 0 {
+				orientation = o
+			}
+			continue
+		case "XMP ":
+			parts = append(parts, StrippedPart{"XMP", len(chunk)})
+			continue
+		case "VP8X":
+			vp8x = len(chunks)
+			chunk = append([]byte(nil), chunk...)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if vp8x >= 0 {
+		chunks[vp8x][8] &^= flagEXIF | flagXMP
+		if keepOrientation && orientation != 0 {
+			chunks[vp8x][8] |= flagEXIF
+			tiff := orientationTIFF(orientation)
+			chunk := append([]byte("EXIF"), binary.LittleEndian.AppendUint32(nil, uint32(len(tiff)))...)
+			chunks = append(chunks, append(chunk, tiff...)) // EXIF goes after the image data
+		}
+	}
+	out := append([]byte(nil), data[:12]...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return StripResult{out, parts, orientation}, nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+// TestStripMetadata strips a JPEG and a PNG carrying metadata and checks
+// they still decode, without it but with the orientation when asked
+func TestStripMetadata(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	segment := func(marker byte, payload string) []byte {
+		return append(binary.BigEndian.AppendUint16([]byte{0xff, marker}, uint16(len(payload)+2)), payload...)
+	}
+
+	var jpg bytes.Buffer
+	jpeg.Encode(&jpg, img, nil)
+	var withMeta []byte
+	withMeta = append(withMeta, jpg.Bytes()[:2]...)
+	withMeta = append(withMeta, segment(0xe1, "Exif\x00\x00"+string(orientationTIFF(6)))...)
+	withMeta = append(withMeta, segment(0xe1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>")...)
+	withMeta = append(withMeta, segment(0xfe, "shot on my phone")...)
+	withMeta = append(withMeta, jpg.Bytes()[2:]...)
+	withMeta = append(withMeta, "trailing video"...)
+
+	var pngData bytes.Buffer
+	png.Encode(&pngData, img)
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	withText := append([]byte(nil), pngData.Bytes()[:ihdrEnd]...)
+	withText = appendPNGChunk(withText, "tEXt", []byte("Author\x00me"))
+	withText = appendPNGChunk(withText, "eXIf", orientationTIFF(3))
+	withText = append(withText, pngData.Bytes()[ihdrEnd:]...)
+
+	for _, tc := range []struct {
+		name        string
+		data        []byte
+		removed     []string
+		orientation int
+	}{
+		{"jpeg", withMeta, []string{"EXIF", "XMP", "comment", "data after the image"}, 6},
+		{"png", withText, []string{"text", "EXIF"}, 3},
+	} {
+		for _, keep := range []bool{false, true} {
+			result, err := StripMetadata(tc.data, keep)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			var kinds []string
+			for _, part := range result.Removed {
+				kinds = append(kinds, part.Kind)
+			}
+			if !slices.Equal(kinds, tc.removed) {
+				t.Errorf("%s: removed %v, want %v", tc.name, kinds, tc.removed)
+			}
+			if _, _, err := image.Decode(bytes.NewReader(result.Data)); err != nil {
+				t.Errorf("%s: stripped image doesn't decode: %v", tc.name, err)
+			}
+			for _, leak := range []string{"xmpmeta", "my phone", "video", "Author"} {
+				if bytes.Contains(result.Data, []byte(leak)) {
+					t.Errorf("%s: %q is still in the image", tc.name, leak)
+				}
+			}
+			want := 0
+			if keep {
+				want = tc.orientation
+			}
+			if got := bytes.Contains(result.Data, orientationTIFF(tc.orientation)); result.Orientation != want || got != keep {
+				t.Errorf("%s keeping orientation %v: orientation %d (in the data: %v), want %d", tc.name, keep, result.Orientation, got, want)
+			}
+		}
+	}
+
+	if _, err := StripMetadata([]byte("GIF89a"), false); err == nil {
+		t.Error("stripping a GIF didn't fail")
+	}
+}
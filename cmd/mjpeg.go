@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// multipartBoundary returns the boundary of an MJPEG stream response
+// (multipart/x-mixed-replace, what IP and 3D printer cameras serve), or ""
+// when resp is a plain file
+func multipartBoundary(resp *http.Response) string {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return ""
+	}
+	return params["boundary"]
+}
+
+// mjpegReader strings the parts of a multipart stream together, so the
+// frames read like concatenated JPEGs
+type mjpegReader struct {
+	parts *multipart.Reader
+	part  *multipart.Part
+	body  io.Closer
+}
+
+func newMJPEGReader(resp *http.Response, boundary string) *mjpegReader {
+	// some cameras put the "--" prefix in the header as well
+	boundary = strings.TrimPrefix(boundary, "--")
+	return &mjpegReader{parts: multipart.NewReader(resp.Body, boundary), body: resp.Body}
+}
+
+func (m *mjpegReader) Read(p []byte) (int, error) {
+	for {
+		if m.part == nil {
+			part, err := m.parts.NextPart()
+			if err != nil {
+				return 0, err
+			}
+			m.part = part
+		}
+		n, err := m.part.Read(p)
+		if err == io.EOF {
+			m.part = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (m *mjpegReader) Close() error {
+	return m.body.Close()
+}
+
+// mjpegOpener connects to an MJPEG stream for a streamSource, handing out
+// the response play already fetched first and reconnecting after that
+func mjpegOpener(ctx context.Context, url string, first *http.Response) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		resp := first
+		first = nil
+		if resp == nil {
+			var err error
+			if resp, err = httpGet(ctx, url); err != nil {
+				return nil, err
+			}
+		}
+		boundary := multipartBoundary(resp)
+		if boundary == "" {
+			resp.Body.Close()
+			return nil, errors.New("the stream isn't multipart MJPEG anymore")
+		}
+		return newMJPEGReader(resp, boundary), nil
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"runtime/debug"
+)
+
+// mmapThreshold is the size from which local files are memory-mapped
+// instead of read, so a multi-hundred-MB TIFF or PNG goes to the decoder
+// without being copied through read buffers first
+const mmapThreshold = 16 << 20
+
+// mappedFile reads a memory-mapped file. it peeks and seeks, so the header
+// check and the image decoders read the mapping in place instead of through
+// a bufio.Reader
+type mappedFile struct {
+	*bytes.Reader
+	data  []byte
+	unmap func() error
+}
+
+// Peek is bufio.Reader's Peek, which image.Decode looks for
+func (m *mappedFile) Peek(n int) ([]byte, error) {
+	rest := m.data[len(m.data)-m.Len():]
+	if n > len(rest) {
+		return rest, io.EOF
+	}
+	return rest[:n], nil
+}
+
+// Bytes is the whole file, valid until Close
+func (m *mappedFile) Bytes() []byte {
+	return m.data
+}
+
+func (m *mappedFile) Close() error {
+	if m.unmap == nil {
+		return nil
+	}
+	err := m.unmap()
+	m.unmap = nil
+	return err
+}
+
+// openLocalFile opens a local image, memory-mapped when it's big enough for
+// that to pay off and mapping works here
+func openLocalFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	// the sandboxed decoder is fed by os/exec's copying goroutine, where a
+	// fault on a file cut short couldn't be recovered. and the mapping is
+	// shared, anyone who can write the file could change it between
+	// --sha256 or --verify-sig checking it and the decoder reading it
+	if sandboxDecode || verifying() || err != nil || !info.Mode().IsRegular() || info.Size() < mmapThreshold || int64(int(info.Size())) != info.Size() {
+		return file, nil
+	}
+	data, unmap, err := mmapFile(file, int(info.Size()))
+	if err != nil {
+		return file, nil // read it the usual way
+	}
+	file.Close() // the mapping outlives the file
+	return &mappedFile{bytes.NewReader(data), data, unmap}, nil
+}
+
+// readMapped runs read, which reads mapped files, with faults on this
+// goroutine turned into panics and recovered: a file cut short while it's
+// mapped faults on the pages it lost, an error instead of a crash
+func readMapped(read func() error) (err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Op: "reading image", Value: v}
+		}
+	}()
+	return read()
+}
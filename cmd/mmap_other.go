@@ -0,0 +1,13 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !zos && !windows
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile can't map files here, they're read instead
+func mmapFile(file *os.File, size int) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// TestMappedFile decodes a memory-mapped PNG the way big local files are,
+// checking the header check hands the decoder the mapping itself
+func TestMappedFile(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 7, 5)))
+	path := filepath.Join(t.TempDir(), "small.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	data, unmap, err := mmapFile(file, buf.Len())
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	mapped := &mappedFile{bytes.NewReader(data), data, unmap}
+	defer mapped.Close()
+
+	if r, err := checkImageHeader(mapped); err != nil || r != io.Reader(mapped) {
+		t.Fatalf("checkImageHeader gave %T, %v", r, err)
+	}
+	img, format, err := DecodeImage(mapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || img.Bounds().Dx() != 7 || img.Bounds().Dy() != 5 {
+		t.Errorf("got a %v %s", img.Bounds(), format)
+	}
+}
+
+// TestMappedFileTruncated cuts a mapped file short, the way another program
+// rewriting it would, and checks reading the lost pages is an error rather
+// than a crash
+func TestMappedFileTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 7, 5)))
+	data := append(buf.Bytes(), make([]byte, 3*os.Getpagesize())...) // pages to lose
+	path := filepath.Join(t.TempDir(), "cut.png")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	mapping, unmap, err := mmapFile(file, len(data))
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	mapped := &mappedFile{bytes.NewReader(mapping), mapping, unmap}
+	defer mapped.Close()
+	if err := os.Truncate(path, 0); err != nil {
+		t.Skip(err) // windows doesn't cut mapped files
+	}
+
+	err = readMapped(func() error {
+		_ = sha256.Sum256(mapped.Bytes())
+		return nil
+	})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Errorf("hashing the lost pages gave %v, want a *PanicError", err)
+	}
+	if _, _, err := DecodeImage(mapped); !errors.As(err, &panicErr) {
+		t.Errorf("decoding the lost pages gave %v, want a *PanicError", err)
+	}
+	if debug.SetPanicOnFault(false) {
+		t.Error("faults are still panics after reading")
+	}
+}
+
+// TestOpenLocalFileVerifying checks a big file isn't mapped while --sha256
+// is on: the mapping is shared, the decoder could read other bytes than the
+// ones checked
+func TestOpenLocalFileVerifying(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.png")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, mmapThreshold); err != nil {
+		t.Fatal(err)
+	}
+	defer func(sum string) { verifySHA256 = sum }(verifySHA256)
+
+	verifySHA256 = strings.Repeat("0", 64)
+	r, err := openLocalFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, mapped := r.(*mappedFile); mapped {
+		t.Error("a file to verify was mapped")
+	}
+}
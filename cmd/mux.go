@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// muxKind is the terminal multiplexer termuwu runs under
+type muxKind int
+
+const (
+	muxNone muxKind = iota
+	muxTmux
+	muxScreen
+	muxZellij
+)
+
+func (m muxKind) String() string {
+	switch m {
+	case muxTmux:
+		return "tmux"
+	case muxScreen:
+		return "screen"
+	case muxZellij:
+		return "zellij"
+	default:
+		return "none"
+	}
+}
+
+func parseMux(value string) (muxKind, error) {
+	switch strings.ToLower(value) {
+	case "auto", "":
+		return detectMux(), nil
+	case "tmux":
+		return muxTmux, nil
+	case "screen":
+		return muxScreen, nil
+	case "zellij":
+		return muxZellij, nil
+	case "none":
+		return muxNone, nil
+	}
+	return muxNone, fmt.Errorf("unknown multiplexer %q (use auto, tmux, screen, zellij or none)", value)
+}
+
+// detectMux looks at the environment each multiplexer sets up. tmux is
+// checked first since it can run inside screen and vice versa
+func detectMux() muxKind {
+	switch {
+	case os.Getenv("TMUX") != "":
+		return muxTmux
+	case os.Getenv("ZELLIJ") != "" || os.Getenv("ZELLIJ_SESSION_NAME") != "":
+		return muxZellij
+	case os.Getenv("STY") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		return muxScreen
+	}
+	return muxNone
+}
+
+// supports reports whether a graphics backend's output can reach the outer
+// terminal through this multiplexer
+func (m muxKind) supports(b RenderBackend) bool {
+	if !needsPassthrough(b) {
+		return true
+	}
+	switch m {
+	case muxTmux:
+		return tmuxAllowsPassthrough()
+	case muxScreen:
+		// screen passes DCS through, but ends it at the first ESC \, so only
+		// BEL terminated OSC payloads survive
+		return b.Name() == "iterm2"
+	case muxZellij:
+		// zellij has no passthrough, but renders sixel itself
+		return b.Name() == "sixel"
+	default:
+		return true
+	}
+}
+
+// adaptDepth downgrades color depths the multiplexer can't show
+func (m muxKind) adaptDepth(depth ColorDepth) ColorDepth {
+	if m == muxScreen && depth == TrueColor {
+		return Color256 // screen 4.x has no truecolor support
+	}
+	return depth
+}
+
+// wrap prepares graphics payload for the multiplexer. wrapped reports
+// whether the multiplexer lost track of the cursor and rows must be reserved
+func (m muxKind) wrap(b RenderBackend, output string) (result string, wrapped bool) {
+	if !needsPassthrough(b) {
+		return output, false
+	}
+	switch m {
+	case muxTmux:
+		return wrapTmuxPassthrough(output), true
+	case muxScreen:
+		return wrapScreenPassthrough(output), true
+	}
+	return output, false
+}
+
+// PassthroughBackend is implemented by backends whose output is a graphics
+// protocol payload rather than text. terminal multiplexers swallow those
+// unless they are wrapped in a passthrough sequence
+type PassthroughBackend interface {
+	NeedsPassthrough() bool
+}
+
+func (kittyBackend) NeedsPassthrough() bool  { return true }
+func (iterm2Backend) NeedsPassthrough() bool { return true }
+func (sixelBackend) NeedsPassthrough() bool  { return true }
+
+func needsPassthrough(b RenderBackend) bool {
+	p, ok := b.(PassthroughBackend)
+	return ok && p.NeedsPassthrough()
+}
+
+// tmuxAllowsPassthrough asks tmux (3.3+) whether allow-passthrough is on.
+// older versions don't know the option and always pass DCS through
+func tmuxAllowsPassthrough() bool {
+	output, err := exec.Command("tmux", "show", "-gv", "allow-passthrough").Output()
+	if err != nil {
+		// tmux without the option errors out, and those versions pass
+		// everything through anyway
+		return true
+	}
+	value := strings.TrimSpace(string(output))
+	return value == "on" || value == "all"
+}
+
+// wrapTmuxPassthrough wraps every escape sequence in payload in tmux's DCS
+// passthrough, doubling the ESC bytes inside as tmux requires. plain text
+// between sequences is left alone
+func wrapTmuxPassthrough(payload string) string {
+	var out strings.Builder
+	for len(payload) > 0 {
+		start := strings.IndexByte(payload, '\033')
+		if start < 0 {
+			out.WriteString(payload)
+			break
+		}
+		out.WriteString(payload[:start])
+		payload = payload[start:]
+
+		end := sequenceEnd(payload)
+		out.WriteString("\033Ptmux;")
+		out.WriteString(strings.ReplaceAll(payload[:end], "\033", "\033\033"))
+		out.WriteString("\033\\")
+		payload = payload[end:]
+	}
+	return out.String()
+}
+
+// sequenceEnd finds where the escape sequence at the start of s ends: at the
+// string terminator (ESC \ or BEL) for DCS/APC/OSC, or the whole string
+func sequenceEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\a' {
+			return i + 1
+		}
+		if s[i] == '\033' && i+1 < len(s) && s[i+1] == '\\' {
+			return i + 2
+		}
+	}
+	return len(s)
+}
+
+// screenChunkSize stays under GNU screen's 768 byte limit for a single DCS
+const screenChunkSize = 700
+
+// wrapScreenPassthrough splits escape sequences into DCS chunks that screen
+// forwards verbatim, the same trick osc52 helpers use
+func wrapScreenPassthrough(payload string) string {
+	var out strings.Builder
+	for len(payload) > 0 {
+		start := strings.IndexByte(payload, '\033')
+		if start < 0 {
+			out.WriteString(payload)
+			break
+		}
+		out.WriteString(payload[:start])
+		payload = payload[start:]
+
+		end := sequenceEnd(payload)
+		for sequence := payload[:end]; len(sequence) > 0; {
+			n := min(screenChunkSize, len(sequence))
+			out.WriteString("\033P")
+			out.WriteString(sequence[:n])
+			out.WriteString("\033\\")
+			sequence = sequence[n:]
+		}
+		payload = payload[end:]
+	}
+	return out.String()
+}
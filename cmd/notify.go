@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+var notifyFlag bool
+
+// notifyThumbnailSize is how big the picture in a notification gets
+const notifyThumbnailSize = 256
+
+// notice is what --notify tells once the command is done: the last status
+// line that said how it went, and a picture of the result when there is one
+var notice struct {
+	mu      sync.Mutex
+	command string
+	line    string
+	failed  bool
+	image   image.Image
+}
+
+// noteStatus keeps the last ✅ or ❌ status line for --notify
+func noteStatus(p []byte) {
+	line := strings.TrimSpace(escapeSequence.ReplaceAllString(string(p), ""))
+	var failed bool
+	switch {
+	case strings.HasPrefix(line, "❌"):
+		failed = true
+	case !strings.HasPrefix(line, "✅"):
+		return
+	}
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "❌"), "✅"))
+
+	notice.mu.Lock()
+	defer notice.mu.Unlock()
+	notice.line, notice.failed = line, failed
+}
+
+// noteResult makes img the picture --notify shows
+func noteResult(img image.Image) {
+	if !notifyFlag {
+		return
+	}
+	notice.mu.Lock()
+	defer notice.mu.Unlock()
+	notice.image = img
+}
+
+// sendNotice sends the --notify notification, once
+func sendNotice() {
+	notice.mu.Lock()
+	command, line, failed, img := notice.command, notice.line, notice.failed, notice.image
+	notice.command = ""
+	notice.mu.Unlock()
+	if !notifyFlag || command == "" {
+		return
+	}
+
+	title := command
+	if failed {
+		title += " " + tr("failed")
+	}
+	if line == "" {
+		line = tr("Done")
+	}
+	if err := notify(title, line, img); err != nil {
+		infoColor := localized(themeColor("info", color.FgYellow))
+		fmt.Fprintf(stderrStatus(), "⚠️  %s %v\n", infoColor("No notification:"), err)
+	}
+}
+
+// exit ends the process with code, sending the --notify notification first
+// since deferred functions and cobra's post runs don't get to
+func exit(code int) {
+	sendNotice()
+	os.Exit(code)
+}
+
+// notify shows a desktop notification with notify-send, terminal-notifier
+// or osascript. over SSH, or with none of them installed, it asks the
+// terminal to show one with OSC 9, which the notifier can't do for
+// a desktop that isn't this machine's
+func notify(title, body string, thumb image.Image) error {
+	if os.Getenv("SSH_CONNECTION") == "" {
+		err := desktopNotify(title, body, thumb)
+		if !errors.Is(err, exec.ErrNotFound) {
+			return err
+		}
+	}
+	return terminalNotify(title, body)
+}
+
+func desktopNotify(title, body string, thumb image.Image) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		if path, err := exec.LookPath("terminal-notifier"); err == nil {
+			args := []string{"-title", title, "-message", body, "-group", "termuwu"}
+			if icon := notifyThumbnail(thumb); icon != "" {
+				args = append(args, "-contentImage", icon)
+			}
+			cmd = exec.Command(path, args...)
+			break
+		}
+		path, err := exec.LookPath("osascript")
+		if err != nil {
+			return err
+		}
+		// osascript can't show a picture
+		cmd = exec.Command(path, "-e", fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title)))
+	case "windows":
+		return exec.ErrNotFound
+	default:
+		path, err := exec.LookPath("notify-send")
+		if err != nil {
+			return err
+		}
+		args := []string{"--app-name=termuwu"}
+		if icon := notifyThumbnail(thumb); icon != "" {
+			args = append(args, "--icon="+icon)
+		}
+		cmd = exec.Command(path, append(args, "--", title, body)...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %v: %s", filepath.Base(cmd.Path), err, msg)
+		}
+		return fmt.Errorf("%s: %w", filepath.Base(cmd.Path), err)
+	}
+	return nil
+}
+
+// notifyThumbnail saves a small copy of img for the notifier to show,
+// returning its path, or "" without an image or when it can't be saved
+func notifyThumbnail(img image.Image) string {
+	if img == nil {
+		return ""
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(dir, "termuwu", "notify.png")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return ""
+	}
+	if err := SaveImage(path, Thumbnail(img, notifyThumbnailSize)); err != nil {
+		return ""
+	}
+	return path
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// terminalNotify asks the terminal for a notification with OSC 9, which
+// iTerm2, WezTerm, kitty and Ghostty show
+func terminalNotify(title, body string) error {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return errors.New("no notify-send, terminal-notifier or osascript found, and stderr isn't a terminal")
+	}
+	message := strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' ' // a control character would end the sequence early
+		}
+		return r
+	}, title+": "+body)
+	_, err := os.Stderr.WriteString("\033]9;" + message + "\a")
+	return err
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// overlayMargin is the gap between an overlay and the image's edges, as a
+// fraction of the image's shorter side
+const overlayMargin = 0.02
+
+// overlayPositions maps --overlay-pos names to the overlay's anchor, in
+// halves of the free space on each axis (0 left/top, 1 center, 2 right/bottom)
+var overlayPositions = map[string]image.Point{
+	"top-left":     {0, 0},
+	"top":          {1, 0},
+	"top-right":    {2, 0},
+	"left":         {0, 1},
+	"center":       {1, 1},
+	"right":        {2, 1},
+	"bottom-left":  {0, 2},
+	"bottom":       {1, 2},
+	"bottom-right": {2, 2},
+}
+
+// OverlayFilter alpha-composites overlay on top of the image at pos (e.g.
+// "bottom-right") with the given opacity between 0 and 1. overlays bigger
+// than the image are scaled down to fit
+func OverlayFilter(overlay image.Image, pos string, opacity float64) (Filter, error) {
+	anchor, ok := overlayPositions[strings.ToLower(pos)]
+	if !ok {
+		return nil, fmt.Errorf("unknown position %q (use top-left, top, top-right, left, center, right, bottom-left, bottom or bottom-right)", pos)
+	}
+	if opacity < 0 || opacity > 1 {
+		return nil, fmt.Errorf("opacity %g should be between 0 and 1", opacity)
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity*255 + 0.5)})
+
+	return func(img *image.RGBA) {
+		bounds := img.Bounds()
+		margin := int(float64(min(bounds.Dx(), bounds.Dy())) * overlayMargin)
+		room := bounds.Inset(margin)
+		if room.Empty() {
+			room = bounds
+		}
+
+		src := fitWithin(overlay, room.Dx(), room.Dy(), draw.CatmullRom)
+		size := src.Bounds().Size()
+
+		at := room.Min.Add(image.Pt(
+			(room.Dx()-size.X)*anchor.X/2,
+			(room.Dy()-size.Y)*anchor.Y/2,
+		))
+		draw.DrawMask(img, image.Rectangle{Min: at, Max: at.Add(size)}, src, src.Bounds().Min, mask, image.Point{}, draw.Over)
+	}, nil
+}
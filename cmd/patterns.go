@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// test pattern resolution, 16:9 with square pixels
+const (
+	patternWidth  = 640
+	patternHeight = 360
+)
+
+// testPatterns generate the built-in images of `termuwu test`
+var testPatterns = map[string]func() *image.RGBA{
+	"bars":       colorBarsPattern,
+	"gradient":   gradientPattern,
+	"mandelbrot": mandelbrotPattern,
+	"grid":       gridPattern,
+}
+
+func newPatternCanvas() *image.RGBA {
+	return image.NewRGBA(image.Rect(0, 0, patternWidth, patternHeight))
+}
+
+// colorBarsPattern is modeled on SMPTE bars: 75% bars on top, then the
+// reverse blue strip and a black to white step row
+func colorBarsPattern() *image.RGBA {
+	img := newPatternCanvas()
+	bars := []color.RGBA{
+		{191, 191, 191, 255}, {191, 191, 0, 255}, {0, 191, 191, 255}, {0, 191, 0, 255},
+		{191, 0, 191, 255}, {191, 0, 0, 255}, {0, 0, 191, 255},
+	}
+	strip := []color.RGBA{
+		{0, 0, 191, 255}, {19, 19, 19, 255}, {191, 0, 191, 255}, {19, 19, 19, 255},
+		{0, 191, 191, 255}, {19, 19, 19, 255}, {191, 191, 191, 255},
+	}
+	for y := 0; y < patternHeight; y++ {
+		for x := 0; x < patternWidth; x++ {
+			var c color.RGBA
+			switch {
+			case y < patternHeight*2/3:
+				c = bars[x*len(bars)/patternWidth]
+			case y < patternHeight*3/4:
+				c = strip[x*len(strip)/patternWidth]
+			default:
+				v := uint8(x * 8 / patternWidth * 255 / 7)
+				c = color.RGBA{v, v, v, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// gradientPattern sweeps the hue across the top and a gray ramp across the
+// bottom, which shows banding and how well a color depth holds up
+func gradientPattern() *image.RGBA {
+	img := newPatternCanvas()
+	for y := 0; y < patternHeight; y++ {
+		for x := 0; x < patternWidth; x++ {
+			t := float64(x) / float64(patternWidth-1)
+			var c color.RGBA
+			if y < patternHeight*2/3 {
+				// fade the hues to white towards the top
+				c = hsvToRGBA(360*t*0.999, float64(y)/float64(patternHeight*2/3), 1)
+			} else {
+				v := uint8(t * 255)
+				c = color.RGBA{v, v, v, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// mandelbrotPattern renders the Mandelbrot set with smooth escape coloring
+func mandelbrotPattern() *image.RGBA {
+	img := newPatternCanvas()
+	const maxIterations = 100
+	for py := 0; py < patternHeight; py++ {
+		for px := 0; px < patternWidth; px++ {
+			// the view spans -2.5..1 on the real axis, centered on the imaginary one
+			c := complex(-2.5+3.5*float64(px)/patternWidth, (float64(py)-patternHeight/2)*3.5/patternWidth)
+			z := complex(0, 0)
+			i := 0
+			for ; i < maxIterations && real(z)*real(z)+imag(z)*imag(z) <= 4; i++ {
+				z = z*z + c
+			}
+			if i == maxIterations {
+				img.SetRGBA(px, py, color.RGBA{0, 0, 0, 255})
+				continue
+			}
+			smooth := float64(i) + 1 - math.Log2(math.Log(math.Hypot(real(z), imag(z))))
+			img.SetRGBA(px, py, hsvToRGBA(math.Mod(200+smooth*12, 360), 0.8, 1))
+		}
+	}
+	return img
+}
+
+// gridPattern draws square cells and a circle, both only look right when
+// the aspect ratio of the output matches the terminal font
+func gridPattern() *image.RGBA {
+	img := newPatternCanvas()
+	const cell = 40
+	cx, cy, radius := float64(patternWidth)/2, float64(patternHeight)/2, float64(patternHeight)*0.4
+	for y := 0; y < patternHeight; y++ {
+		for x := 0; x < patternWidth; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if x%cell < 2 || y%cell < 2 || x >= patternWidth-2 || y >= patternHeight-2 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			if d := math.Hypot(float64(x)-cx, float64(y)-cy); math.Abs(d-radius) < 3 {
+				c = color.RGBA{255, 60, 60, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
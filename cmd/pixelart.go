@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// pixel art heuristics: small images with a small palette. photos and
+// anything antialiased blow past the color limit quickly
+const (
+	pixelArtMaxSide   = 256
+	pixelArtMaxColors = 64
+)
+
+// IsPixelArt guesses whether img is a sprite or other pixel art that should
+// be scaled with nearest-neighbor instead of being averaged
+func IsPixelArt(img image.Image) bool {
+	bounds := img.Bounds()
+	if bounds.Dx() > pixelArtMaxSide || bounds.Dy() > pixelArtMaxSide {
+		return false
+	}
+
+	colors := make(map[color.RGBA]bool)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			colors[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)] = true
+			if len(colors) > pixelArtMaxColors {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// scalePixelArt resamples img by whole factors with nearest-neighbor so every
+// source pixel becomes the same number of samples. with half-blocks and
+// braille a sample is square, so a pixel maps to whole subcells
+func (r *ImageRenderer) scalePixelArt(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	imgWidth, imgHeight := bounds.Dx(), bounds.Dy()
+	fitWidth, fitHeight := r.sampleSize(imgWidth, imgHeight)
+
+	factorX, factorY := fitWidth/imgWidth, fitHeight/imgHeight
+	if r.Mode == HalfBlockMode || r.Mode == BrailleMode {
+		factorX = min(factorX, factorY)
+		factorY = factorX
+	}
+
+	outputWidth, outputHeight := fitWidth, fitHeight
+	if factorX >= 1 && factorY >= 1 {
+		outputWidth, outputHeight = imgWidth*factorX, imgHeight*factorY
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, outputWidth, outputHeight))
+	draw.NearestNeighbor.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+	return scaled
+}
+
+// interpolator picks the resampler the graphics protocol backends use
+func (r *ImageRenderer) interpolator() draw.Interpolator {
+	if r.PixelArt || r.Scaler == ScalerNearest {
+		return draw.NearestNeighbor
+	}
+	if resampler := r.Scaler.resampler(); resampler != nil {
+		return resampler
+	}
+	return draw.ApproxBiLinear
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	noSync           bool
+	streamInput      string
+	streamFormatName string
+	fpsCap           float64
+	playSeek         time.Duration
+	playDuration     time.Duration
+	useYtdlp         bool
+	ytdlpThumbnail   bool
+	subtitlePath     string
+	subtitlePosition string
+	subtitleColor    string
+	subtitleBG       string
+	mute             bool
+	loopRegion       string
+	boomerang        bool
+)
+
+var playCmd = &cobra.Command{
+	Use:   "play [path_or_url]",
+	Short: "Play an animated GIF, a video or a live stream in the terminal",
+	Long: `Play an animated GIF, a video file (with ffmpeg installed), an MJPEG stream from an IP or 3D printer camera, or with
+--input a live stream of PNG/JPEG frames that another process writes, e.g. a
+monitoring script feeding a dashboard:
+
+  termuwu play http://octopi.local/webcam/?action=stream --fps-cap 10
+  termuwu play rtsp://camera.local/live --fps-cap 15
+  termuwu play movie.mkv --sub-position over
+  termuwu play --ytdlp https://www.youtube.com/watch?v=dQw4w9WgXcQ
+
+  mkfifo /tmp/frames
+  termuwu play --input fifo:/tmp/frames --format png-stream
+
+Space pauses, ←/→ seek 5s, ↑/↓ 60s, . and , step a frame and q quits.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		if err := applySelectedPreset(cmd); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid preset:"), err)
+			return
+		}
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		dither, err := parseDitherMode(ditherName)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --dither value:"), err)
+			return
+		}
+		scaler, err := parseScaler(scalerName)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --scaler value:"), err)
+			return
+		}
+		if (len(args) == 1) == (streamInput != "") {
+			fmt.Fprintln(statusOut, errorColor("❌ Give either a file or URL to play or --input, not both."))
+			return
+		}
+		format, err := parseStreamFormat(streamFormatName)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --format value:"), err)
+			return
+		}
+		if fpsCap < 0 {
+			fmt.Fprintf(statusOut, "%s %g\n", errorColor("❌ Invalid --fps-cap value:"), fpsCap)
+			return
+		}
+		if (useYtdlp || ytdlpThumbnail) && len(args) == 0 {
+			fmt.Fprintln(statusOut, errorColor("❌ --ytdlp and --thumbnail need a video URL."))
+			return
+		}
+		if ytdlpThumbnail && !useYtdlp {
+			fmt.Fprintln(statusOut, errorColor("❌ --thumbnail only works with --ytdlp."))
+			return
+		}
+		if (playSeek != 0 || playDuration != 0) && (len(args) == 0 || !useYtdlp && !needsFFmpeg(args[0])) {
+			fmt.Fprintln(statusOut, errorColor("❌ --seek and --duration only work with videos and RTSP, HLS or --ytdlp streams."))
+			return
+		}
+		var loopStart, loopEnd time.Duration
+		if loopRegion != "" {
+			if loopStart, loopEnd, err = parseLoopRegion(loopRegion); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --loop-region value:"), err)
+				return
+			}
+			if playSeek != 0 || playDuration != 0 {
+				fmt.Fprintln(statusOut, errorColor("❌ --loop-region picks the part to play already, drop --seek and --duration."))
+				return
+			}
+		}
+		looping := loopRegion != "" || boomerang
+		if looping && streamInput != "" {
+			fmt.Fprintln(statusOut, errorColor("❌ --loop-region and --boomerang only work with GIFs and video files."))
+			return
+		}
+		if playSeek < 0 || playDuration < 0 {
+			fmt.Fprintln(statusOut, errorColor("❌ --seek and --duration can't be negative."))
+			return
+		}
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.Dither = dither
+		if dither == DitherNone {
+			renderer.UseDither = false
+		}
+		renderer.Scaler = scaler
+		renderer.ColorDepth = depth
+		renderer.ExactFit = exactFit
+		if err := fitGlyphs(renderer); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+			return
+		}
+		style, err := parseSubtitleStyle(subtitlePosition, subtitleColor, subtitleBG, renderer)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid subtitle style:"), err)
+			return
+		}
+		if setupConsole().legacy {
+			fmt.Fprintln(statusOut, errorColor("❌ Playback needs a console with VT support (Windows 10 or later)."))
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		// stdin carries the frames with --input -
+		var keys <-chan playKey
+		if streamInput != "-" && !ytdlpThumbnail {
+			var restore func()
+			keys, restore = startControls(ctx)
+			defer restore()
+		}
+		if keys != nil && renderHeight == 0 {
+			renderer.MaxHeight-- // room for the progress line
+		}
+
+		if streamInput != "" {
+			open, reopen, err := parseStreamInput(streamInput)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --input value:"), err)
+				return
+			}
+			fmt.Fprintf(statusOut, "📡 %s %s\n", infoColor("Waiting for frames on"), streamInput)
+			source := newStreamSource(ctx, open, format, reopen, fpsCap)
+			if err := playFrames(ctx, os.Stdout, source, NewFrameRenderer(renderer), !noSync, keys); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Playback failed:"), err)
+			}
+			return
+		}
+
+		if useYtdlp {
+			fmt.Fprintf(statusOut, "🔎 %s %s\n", infoColor("Looking up with yt-dlp:"), args[0])
+			resolved, err := ytdlpResolve(ctx, args[0], ytdlpThumbnail)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error resolving video:"), err)
+				return
+			}
+			if ytdlpThumbnail {
+				img, _, err := loadImage(resolved)
+				if err != nil {
+					fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading thumbnail:"), err)
+					return
+				}
+				fmt.Print(renderer.RenderImage(img))
+				return
+			}
+			args[0] = resolved
+		}
+
+		if useYtdlp || needsFFmpeg(args[0]) {
+			var cues []subtitleCue
+			switch {
+			case subtitlePath == "none":
+			case subtitlePath != "auto":
+				if cues, err = loadSubtitles(subtitlePath); err != nil {
+					fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading subtitles:"), err)
+					return
+				}
+			case !strings.Contains(args[0], "://"):
+				cues = findSubtitles(ctx, args[0])
+			}
+
+			fmt.Fprintf(statusOut, "📸 %s %s\n", infoColor("Opening stream with ffmpeg:"), args[0])
+			if len(cues) > 0 {
+				fmt.Fprintf(statusOut, tr("💬 %s %d cues\n"), infoColor("Subtitles:"), len(cues))
+			}
+
+			sound := !mute && !looping // the passes after the first one replay kept frames
+			if sound {
+				if _, _, err := findAudioPlayer(); err != nil {
+					fmt.Fprintf(statusOut, "⚠️  %s %v\n", infoColor("Playing without sound:"), err)
+					sound = false
+				}
+			}
+			video := newVideoSource(ctx, args[0], playSeek, playDuration, fpsCap, sound)
+			var source frameSource = video
+			if looping {
+				if source, err = newRegionSource(video, loopStart, loopEnd, boomerang, renderer); err != nil {
+					fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Can't loop:"), err)
+					return
+				}
+			}
+			if len(cues) > 0 {
+				source = &subtitledSource{frameSource: source, cues: cues, style: style, offset: playSeek}
+			}
+			err := playFrames(ctx, os.Stdout, source, NewFrameRenderer(renderer), !noSync, keys)
+			video.close()
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Playback failed:"), err)
+			}
+			return
+		}
+
+		var reader io.ReadCloser
+		if strings.HasPrefix(args[0], "http://") || strings.HasPrefix(args[0], "https://") {
+			fmt.Fprintf(statusOut, "📸 %s %s\n", infoColor("Connecting to:"), args[0])
+			resp, err := httpGet(ctx, args[0])
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+			if multipartBoundary(resp) != "" {
+				if looping {
+					resp.Body.Close()
+					fmt.Fprintln(statusOut, errorColor("❌ --loop-region and --boomerang only work with GIFs and video files."))
+					return
+				}
+				fmt.Fprintf(statusOut, "✅ %s\n", successColor("MJPEG stream connected!"))
+				source := newStreamSource(ctx, mjpegOpener(ctx, args[0], resp), format, reopenOnError, fpsCap)
+				if err := playFrames(ctx, os.Stdout, source, NewFrameRenderer(renderer), !noSync, keys); err != nil {
+					fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Playback failed:"), err)
+				}
+				return
+			}
+			reader = withProgress(resp)
+		} else if reader, err = openSource(args[0]); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+		animation, err := DecodeGIF(reader)
+		reader.Close()
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error decoding GIF:"), err)
+			return
+		}
+
+		emitProgress(ProgressEvent{Event: "decode", Format: "gif", Width: animation.Config.Width, Height: animation.Config.Height, Frames: len(animation.Image)})
+		fmt.Fprintf(statusOut, tr("✅ %s Frames: %s, Size: %dx%d\n"),
+			successColor("Animation loaded!"),
+			infoColor(len(animation.Image)),
+			animation.Config.Width,
+			animation.Config.Height)
+
+		var source frameSource = newGIFSource(animation)
+		if looping {
+			if source, err = newRegionSource(source, loopStart, loopEnd, boomerang, renderer); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Can't loop:"), err)
+				return
+			}
+		}
+		if err := playFrames(ctx, os.Stdout, source, NewFrameRenderer(renderer), !noSync, keys); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Playback failed:"), err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(playCmd)
+
+	playCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	playCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	playCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	playCmd.Flags().StringVar(&ditherName, "dither", "ordered", "Dithering to use: ordered, blue-noise (stronger, steady across animation frames) or none.")
+	playCmd.Flags().StringVar(&presetName, "preset", "", "Start from a preset: fast, balanced or best, a look-alike from compare-tools or one of your own from the config.")
+	playCmd.Flags().StringVar(&scalerName, "scaler", "auto", "How to resample the image: auto, nearest (fastest), bilinear or catmull-rom (sharpest).")
+	playCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	playCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	playCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+	playCmd.Flags().StringVar(&streamInput, "input", "", "Play frames as they arrive instead of a GIF: fifo:PATH for a named pipe or - for stdin.")
+	playCmd.Flags().StringVar(&streamFormatName, "format", "auto", "Frame format for --input: png-stream, jpeg-stream or auto.")
+	playCmd.Flags().Float64Var(&fpsCap, "fps-cap", 0, "Show at most this many frames a second of a stream (0 for no cap).")
+	playCmd.Flags().DurationVar(&playSeek, "seek", 0, "Start an RTSP/HLS stream this far in, e.g. 1m30s.")
+	playCmd.Flags().DurationVar(&playDuration, "duration", 0, "Stop an RTSP/HLS stream after this long (0 to play until it ends).")
+	playCmd.Flags().BoolVar(&useYtdlp, "ytdlp", false, "Treat the argument as a video page (YouTube and friends) and play a low resolution rendition found by yt-dlp.")
+	playCmd.Flags().BoolVar(&ytdlpThumbnail, "thumbnail", false, "With --ytdlp, just render the video's thumbnail.")
+	playCmd.Flags().StringVar(&subtitlePath, "subs", "auto", "Subtitles for a video: a .srt/.vtt file, auto (a file next to the video or its own subtitle track) or none.")
+	playCmd.Flags().StringVar(&subtitlePosition, "sub-position", "below", "Draw subtitles below the video or over its bottom rows.")
+	playCmd.Flags().StringVar(&subtitleColor, "sub-color", "#ffffff", "Subtitle text color as #rrggbb.")
+	playCmd.Flags().StringVar(&subtitleBG, "sub-bg", "", "Subtitle background color as #rrggbb (default: the terminal's).")
+	playCmd.Flags().StringVar(&loopRegion, "loop-region", "", "Repeat just this part of a GIF or video, e.g. 3s-7s.")
+	playCmd.Flags().BoolVar(&boomerang, "boomerang", false, "Play a GIF or video (or its --loop-region) forward and back, over and over.")
+	playCmd.Flags().BoolVar(&exactFit, "exact-fit", false, "Pad every frame to exactly --width x --height cells (or the terminal size), the video centered.")
+	playCmd.Flags().BoolVar(&mute, "mute", false, "Don't play the sound of videos and streams.")
+	playCmd.Flags().BoolVar(&noSync, "no-sync", false, "Don't wrap frames in synchronized updates (DEC mode 2026).")
+}
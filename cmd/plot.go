@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var plotType string
+
+var plotCmd = &cobra.Command{
+	Use:   "plot [csv_path]",
+	Short: "Plot CSV data as a line, scatter or heatmap chart",
+	Long: `Plot numbers from a CSV file (or - for stdin).
+
+For line and scatter charts the first column is x and every other column is a
+series. A file with a single column is plotted against the row number.
+Heatmaps draw the whole table as a matrix. A non-numeric first row is used as
+the header for the legend.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		if plotType != "line" && plotType != "scatter" && plotType != "heatmap" {
+			fmt.Fprintf(statusOut, tr("%s %q (use line, scatter or heatmap)\n"), errorColor("❌ Unknown chart type:"), plotType)
+			return
+		}
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+
+		in := os.Stdin
+		if args[0] != "-" {
+			if in, err = os.Open(args[0]); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error opening CSV:"), err)
+				return
+			}
+			defer in.Close()
+		}
+		data, err := parseChartCSV(in)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading data:"), err)
+			return
+		}
+
+		// braille gives lines and dots the most detail, heatmaps need color per cell
+		mode := BrailleMode
+		if plotType == "heatmap" {
+			mode = HalfBlockMode
+		}
+		if useFullBlocks {
+			mode = BlockMode
+		}
+		renderer := configureRenderer(false, false, true, renderWidth, renderHeight)
+		renderer.Mode = mode
+		renderer.ColorDepth = depth
+		renderer.BrailleColor = BrailleColorFGBG // color lines by their own dots only
+		console := setupConsole()
+		if console.legacy {
+			renderer.Mode = BlockMode
+			renderer.ColorDepth = Color16
+		}
+
+		width, height := renderer.sampleResolution(renderer.MaxWidth, renderer.MaxHeight-1) // a line for the labels
+		canvas := image.NewRGBA(image.Rect(0, 0, max(width, 2), max(height, 2)))
+
+		var labels string
+		if plotType == "heatmap" {
+			values := drawHeatmap(canvas, data.Rows)
+			labels = fmt.Sprintf("%s %s", infoColor("values:"), formatRange(values))
+		} else {
+			xs, series, names := data.xy()
+			xRange, yRange := drawChart(canvas, xs, series, plotType == "line")
+			labels = fmt.Sprintf("%s %s  %s %s ", infoColor("x:"), formatRange(xRange), infoColor("y:"), formatRange(yRange))
+			for i, name := range names {
+				c := seriesColors[i%len(seriesColors)]
+				swatch := appendSGRColor([]byte{' '}, renderer.ColorDepth, renderer.colorCode(c.R, c.G, c.B), false)
+				labels += string(swatch) + "■\033[0m " + name
+			}
+		}
+
+		grid := renderer.RasterizeScaled(canvas)
+		if console.legacy {
+			if err := writeLegacyConsole(grid); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error drawing to console:"), err)
+			}
+		} else {
+			fmt.Print(grid.ANSI())
+		}
+		fmt.Println(labels)
+	},
+}
+
+func formatRange(r chartRange) string {
+	return strconv.FormatFloat(r.Min, 'g', 4, 64) + " … " + strconv.FormatFloat(r.Max, 'g', 4, 64)
+}
+
+func init() {
+	rootCmd.AddCommand(plotCmd)
+
+	plotCmd.Flags().StringVarP(&plotType, "type", "t", "line", "Chart type: line, scatter or heatmap.")
+	plotCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	plotCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the chart in characters (0 for auto).")
+	plotCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the chart in lines (0 for auto).")
+	plotCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// gridPool and bufferPool keep the cell grids and output buffers renders
+// are done with for the next render, so one-shot renders (RenderImage,
+// dashboard panes) don't allocate new ones every time. FrameRenderer keeps
+// its own and doesn't need them
+var (
+	gridPool   = sync.Pool{New: func() any { poolCounts.gridAllocs.Add(1); return &CellGrid{} }}
+	bufferPool = sync.Pool{New: func() any { poolCounts.bufferAllocs.Add(1); return new([]byte) }}
+	poolCounts struct {
+		grids, gridAllocs, buffers, bufferAllocs atomic.Int64
+	}
+)
+
+// PoolStats counts the grids and buffers renders took from the pools, and
+// how many of them had to be allocated because none was free
+type PoolStats struct {
+	Grids, GridAllocs     int64
+	Buffers, BufferAllocs int64
+}
+
+func poolStats() PoolStats {
+	return PoolStats{
+		Grids:        poolCounts.grids.Load(),
+		GridAllocs:   poolCounts.gridAllocs.Load(),
+		Buffers:      poolCounts.buffers.Load(),
+		BufferAllocs: poolCounts.bufferAllocs.Load(),
+	}
+}
+
+// getGrid is a grid for rasterizeInto to size and fill
+func getGrid() *CellGrid {
+	poolCounts.grids.Add(1)
+	return gridPool.Get().(*CellGrid)
+}
+
+// putGrid hands g back, nothing may use it after
+func putGrid(g *CellGrid) {
+	if g != nil {
+		gridPool.Put(g)
+	}
+}
+
+// getBuffer is an empty buffer to encode output into
+func getBuffer() *[]byte {
+	poolCounts.buffers.Add(1)
+	buf := bufferPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// putBuffer hands buf back, nothing may use it after
+func putBuffer(buf *[]byte) {
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"slices"
+)
+
+// Posterize reduces img to at most n colors in place using median cut. the
+// result only depends on the pixels, so the same image always posterizes
+// the same way
+func Posterize(img *image.RGBA, n int) {
+	if n <= 0 {
+		return
+	}
+
+	bounds := img.Bounds()
+	pixels := make([]color.RGBA, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixels = append(pixels, img.RGBAAt(x, y))
+		}
+	}
+	palette := medianCut(pixels, n)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			nearest := palette[0]
+			for _, p := range palette[1:] {
+				if rgbDistance(toColor(c), p) < rgbDistance(toColor(c), nearest) {
+					nearest = p
+				}
+			}
+			img.SetRGBA(x, y, color.RGBA{R: nearest.R, G: nearest.G, B: nearest.B, A: c.A})
+		}
+	}
+}
+
+// medianCut splits the pixels into up to n boxes, always cutting the box with
+// the widest channel range at its median, and returns each box's average
+func medianCut(pixels []color.RGBA, n int) []Color {
+	boxes := [][]color.RGBA{pixels}
+	for len(boxes) < n {
+		widest, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, s := widestChannel(box); s > spread {
+				widest, channel, spread = i, c, s
+			}
+		}
+		if widest < 0 {
+			break // every box holds a single color
+		}
+
+		box := boxes[widest]
+		slices.SortStableFunc(box, func(a, b color.RGBA) int {
+			return int(channelValue(a, channel)) - int(channelValue(b, channel))
+		})
+		half := len(box) / 2
+		boxes[widest] = box[:half]
+		boxes = append(boxes, box[half:])
+	}
+
+	palette := make([]Color, len(boxes))
+	for i, box := range boxes {
+		samples := make([]Color, len(box))
+		for j, c := range box {
+			samples[j] = toColor(c)
+		}
+		palette[i] = averageColor(samples)
+	}
+	return palette
+}
+
+// widestChannel returns the channel (0 red, 1 green, 2 blue) with the
+// largest range in box, and that range
+func widestChannel(box []color.RGBA) (channel, spread int) {
+	for c := 0; c < 3; c++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, p := range box {
+			v := channelValue(p, c)
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		if hi > lo && int(hi-lo) > spread {
+			channel, spread = c, int(hi-lo)
+		}
+	}
+	return channel, spread
+}
+
+func channelValue(c color.RGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
+
+func toColor(c color.RGBA) Color {
+	return Color{R: c.R, G: c.G, B: c.B}
+}
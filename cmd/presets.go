@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Preset bundles flags under one name, like --preset best. a command starts
+// from the preset and the flags given on the command line still win
+type Preset struct {
+	Name  string
+	About string            // what it's for, or the tool it mimics
+	Flags map[string]string // flag name to value, as if given on the command line
+}
+
+// qualityPresets trade speed for quality: how the image is scaled,
+// dithered, how hard two-color cells search and how exactly colors match
+var qualityPresets = []Preset{
+	{Name: "fast", About: "nearest-neighbor scaling, no dithering, lookup table colors", Flags: map[string]string{
+		"scaler": "nearest", "dither": "none", "search-depth": "1", "exact-colors": "false",
+	}},
+	{Name: "balanced", About: "the defaults", Flags: map[string]string{
+		"scaler": "auto", "dither": "ordered", "search-depth": "3", "exact-colors": "false",
+	}},
+	{Name: "best", About: "Catmull-Rom scaling, blue noise dithering, exact colors", Flags: map[string]string{
+		"scaler": "catmull-rom", "dither": "blue-noise", "search-depth": "8", "exact-colors": "true",
+	}},
+}
+
+// toolPresets make termuwu's output look like other terminal image
+// viewers', see compare-tools
+var toolPresets = []Preset{
+	{Name: "termuwu", About: "termuwu's own defaults", Flags: map[string]string{}},
+	{Name: "viu", About: "viu, timg -p half", Flags: map[string]string{"colors": "true", "no-dither": "true"}},
+	{Name: "symbols", About: "chafa -f symbols", Flags: map[string]string{"style": "halftone", "dither": "blue-noise"}},
+	{Name: "braille", About: "chafa --symbols braille", Flags: map[string]string{"braille": "true", "colors": "true", "braille-color": "fgbg"}},
+	{Name: "sixel", About: "timg -p sixel, chafa -f sixel", Flags: map[string]string{"protocol": "sixel"}},
+}
+
+// modeFlags pick what kind of output there is, or only work with text
+// cells. when any of them is given, the preset's ones are left out, so
+// --braille isn't overridden by a preset's --style and --budget doesn't
+// meet a preset's sixel
+var modeFlags = []string{"full", "braille", "style", "protocol", "export", "budget", "exact-fit", "transparent", "transparent-color"}
+
+var presetName string
+
+// lookupPreset finds a preset by name, the config's own presets first so
+// they can replace built-in ones
+func lookupPreset(name string) (Preset, bool) {
+	for userName, flags := range config.Presets {
+		if strings.EqualFold(userName, name) {
+			return Preset{Name: userName, About: "from the config", Flags: flags}, true
+		}
+	}
+	for _, p := range slices.Concat(qualityPresets, toolPresets) {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Preset{}, false
+}
+
+// presetNames lists every preset: built-in ones, then the config's
+func presetNames() []string {
+	var names []string
+	for _, p := range slices.Concat(qualityPresets, toolPresets) {
+		names = append(names, p.Name)
+	}
+	var user []string
+	for name := range config.Presets {
+		if !slices.Contains(names, name) {
+			user = append(user, name)
+		}
+	}
+	sort.Strings(user)
+	return append(names, user...)
+}
+
+// applyPreset sets the preset's flags that flags has and the user left
+// alone. flags other commands have are skipped
+func applyPreset(flags *pflag.FlagSet, p Preset) error {
+	modeGiven := false
+	for _, name := range modeFlags {
+		modeGiven = modeGiven || flags.Changed(name)
+	}
+	for name, value := range p.Flags {
+		f := flags.Lookup(name)
+		if f == nil || f.Changed || (modeGiven && slices.Contains(modeFlags, name)) {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("preset %s: --%s %s: %w", p.Name, name, value, err)
+		}
+	}
+	return nil
+}
+
+// applySelectedPreset starts a command from --preset, or the config's
+// "preset" when it isn't given
+func applySelectedPreset(cmd *cobra.Command) error {
+	name, source := presetName, "--preset"
+	if name == "" {
+		name, source = config.Preset, "the config's preset"
+	}
+	if name == "" {
+		return nil
+	}
+	p, found := lookupPreset(name)
+	if !found {
+		return fmt.Errorf("unknown preset %q in %s (use %s)", name, source, strings.Join(presetNames(), ", "))
+	}
+	return applyPreset(cmd.Flags(), p)
+}
+
+// renderer builds the text cell renderer a preset describes, width x height
+// cells
+func (p Preset) renderer(width, height int) (*ImageRenderer, error) {
+	renderer := configureRenderer(p.Flags["full"] == "true", p.Flags["braille"] == "true", p.Flags["no-dither"] == "true", width, height)
+	var err error
+	if renderer.Mode, err = parseStyle(p.Flags["style"], renderer.Mode); err != nil {
+		return nil, err
+	}
+	if renderer.ColorDepth, err = parseColorDepth(p.Flags["colors"]); err != nil {
+		return nil, err
+	}
+	if value, ok := p.Flags["dither"]; ok {
+		if renderer.Dither, err = parseDitherMode(value); err != nil {
+			return nil, err
+		}
+		renderer.UseDither = renderer.UseDither && renderer.Dither != DitherNone
+	}
+	if value, ok := p.Flags["braille-color"]; ok {
+		if renderer.BrailleColor, err = parseBrailleColorMode(value); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := p.Flags["scaler"]; ok {
+		if renderer.Scaler, err = parseScaler(value); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := p.Flags["search-depth"]; ok {
+		if renderer.SearchDepth, err = strconv.Atoi(value); err != nil {
+			return nil, err
+		}
+	}
+	renderer.ExactColors = p.Flags["exact-colors"] == "true"
+	return renderer, nil
+}
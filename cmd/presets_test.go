@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestPresetFlags checks the built-in presets only name show's flags, with
+// values those flags take
+func TestPresetFlags(t *testing.T) {
+	for _, p := range slices.Concat(qualityPresets, toolPresets) {
+		for name := range p.Flags {
+			if showCmd.Flags().Lookup(name) == nil {
+				t.Errorf("preset %s sets --%s, which show doesn't have", p.Name, name)
+			}
+		}
+		if p.Flags["protocol"] != "" {
+			continue
+		}
+		if _, err := p.renderer(20, 10); err != nil {
+			t.Errorf("preset %s: %v", p.Name, err)
+		}
+	}
+}
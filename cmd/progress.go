@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressFormat is --progress: empty for the usual progress bar, json for
+// NDJSON events on stderr that wrappers can draw their own progress from
+var progressFormat string
+
+// ProgressEvent is one line of --progress json
+type ProgressEvent struct {
+	Event   string   `json:"event"`             // download, decode, render or done
+	Percent *float64 `json:"percent,omitempty"` // how far along, when that's known
+	Bytes   int64    `json:"bytes,omitempty"`   // downloaded so far
+	Total   int64    `json:"total,omitempty"`   // the download's size, when the server sends it
+	Format  string   `json:"format,omitempty"`
+	Width   int      `json:"width,omitempty"` // image size in pixels
+	Height  int      `json:"height,omitempty"`
+	Frames  int      `json:"frames,omitempty"` // for animations
+}
+
+var progressEvents = struct {
+	sync.Mutex
+	last map[string]int // the whole percent each event was last sent at
+}{last: make(map[string]int)}
+
+// emitProgress writes event to stderr with --progress json. events with a
+// percent are only sent when it reaches the next whole percent
+func emitProgress(event ProgressEvent) {
+	if progressFormat != "json" {
+		return
+	}
+	progressEvents.Lock()
+	defer progressEvents.Unlock()
+	if event.Percent != nil {
+		whole := int(*event.Percent)
+		if last, ok := progressEvents.last[event.Event]; ok && last == whole {
+			return
+		}
+		progressEvents.last[event.Event] = whole
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	os.Stderr.Write(append(line, '\n'))
+}
+
+// percentOf is done out of total as a percent, to a tenth
+func percentOf(done, total int64) *float64 {
+	p := math.Round(float64(done)*1000/float64(total)) / 10
+	return &p
+}
+
+// renderProgress is an ImageRenderer.Progress sending render events
+func renderProgress(percent float64) {
+	p := math.Round(percent*10) / 10
+	emitProgress(ProgressEvent{Event: "render", Percent: &p})
+}
+
+// showsProgressBar is whether downloads draw the progress bar on stderr
+func showsProgressBar() bool {
+	return !plainChrome && progressFormat != "json"
+}
+
+// downloadProgress counts the bytes of a download into events. without a
+// size it sends the count at most every downloadProgressInterval
+type downloadProgress struct {
+	mu    sync.Mutex // parallel downloads count from every connection
+	total int64
+	done  int64
+	sent  time.Time
+}
+
+const downloadProgressInterval = 100 * time.Millisecond
+
+func (d *downloadProgress) Write(p []byte) (int, error) {
+	return len(p), d.Add64(int64(len(p)))
+}
+
+// Add64 counts n more bytes, like progressbar's
+func (d *downloadProgress) Add64(n int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.done += n
+	switch {
+	case d.total > 0:
+		emitProgress(ProgressEvent{Event: "download", Percent: percentOf(d.done, d.total), Bytes: d.done, Total: d.total})
+	case time.Since(d.sent) >= downloadProgressInterval:
+		d.sent = time.Now()
+		emitProgress(ProgressEvent{Event: "download", Bytes: d.done})
+	}
+	return nil
+}
+
+// byteCounter is what a download reports its bytes to: the progress bar,
+// the events of --progress json or nothing
+type byteCounter interface {
+	io.Writer
+	Add64(n int64) error
+}
+
+type noProgress struct{}
+
+func (noProgress) Write(p []byte) (int, error) { return len(p), nil }
+func (noProgress) Add64(int64) error           { return nil }
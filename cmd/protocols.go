@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+// typical cell size in pixels, used to avoid shipping far more pixels than
+// the terminal will ever show
+const (
+	cellPixelWidth  = 10
+	cellPixelHeight = 20
+)
+
+// fitToCells shrinks img to roughly the pixel size of cols x rows cells.
+// images that are already small enough are returned untouched
+func fitToCells(img image.Image, cols, rows int, interp draw.Interpolator) image.Image {
+	return fitWithin(img, cols*cellPixelWidth, rows*cellPixelHeight, interp)
+}
+
+// fitWithin shrinks img to fit maxW x maxH keeping its aspect ratio. images
+// that already fit are returned untouched, never scaled up
+func fitWithin(img image.Image, maxW, maxH int, interp draw.Interpolator) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= maxW && bounds.Dy() <= maxH {
+		return img
+	}
+
+	scale := min(float64(maxW)/float64(bounds.Dx()), float64(maxH)/float64(bounds.Dy()))
+	w := max(int(float64(bounds.Dx())*scale), 1)
+	h := max(int(float64(bounds.Dy())*scale), 1)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	interp.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func encodePNGBase64(img image.Image) (string, int, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", 0, fmt.Errorf("couldn't encode image: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), buf.Len(), nil
+}
+
+type kittyBackend struct{}
+
+func (kittyBackend) Name() string { return "kitty" }
+func (kittyBackend) Description() string {
+	return "kitty graphics protocol (kitty, WezTerm, Ghostty, Konsole)"
+}
+func (kittyBackend) Render(img image.Image, r *ImageRenderer) (string, error) {
+	img = r.filtered(img)
+	cols, rows := protocolCells(img, r)
+	payload, _, err := encodePNGBase64(fitToCells(img, cols, rows, r.interpolator()))
+	if err != nil {
+		return "", err
+	}
+
+	// the payload has to be sent in chunks of at most 4096 bytes
+	const chunkSize = 4096
+	out := make([]byte, 0, len(payload)+(len(payload)/chunkSize+1)*16+32)
+	for start := 0; start < len(payload); start += chunkSize {
+		end := min(start+chunkSize, len(payload))
+		if start == 0 {
+			// a=T transmits and displays, f=100 is PNG, q=2 keeps the terminal quiet
+			out = append(out, "\033_Ga=T,f=100,q=2,c="...)
+			out = strconv.AppendInt(out, int64(cols), 10)
+			out = append(out, ",r="...)
+			out = strconv.AppendInt(out, int64(rows), 10)
+			out = append(out, ",m="...)
+		} else {
+			out = append(out, "\033_Gm="...)
+		}
+		if end < len(payload) {
+			out = append(out, '1') // more chunks follow
+		} else {
+			out = append(out, '0')
+		}
+		out = append(out, ';')
+		out = append(out, payload[start:end]...)
+		out = append(out, "\033\\"...)
+	}
+	return string(append(out, '\n')), nil
+}
+
+type iterm2Backend struct{}
+
+func (iterm2Backend) Name() string        { return "iterm2" }
+func (iterm2Backend) Description() string { return "iTerm2 inline images (iTerm2, WezTerm, mintty)" }
+func (iterm2Backend) Render(img image.Image, r *ImageRenderer) (string, error) {
+	img = r.filtered(img)
+	cols, rows := protocolCells(img, r)
+	payload, size, err := encodePNGBase64(fitToCells(img, cols, rows, r.interpolator()))
+	if err != nil {
+		return "", err
+	}
+	out := make([]byte, 0, len(payload)+96)
+	out = append(out, "\033]1337;File=inline=1;size="...)
+	out = strconv.AppendInt(out, int64(size), 10)
+	out = append(out, ";width="...)
+	out = strconv.AppendInt(out, int64(cols), 10)
+	out = append(out, ";height="...)
+	out = strconv.AppendInt(out, int64(rows), 10)
+	out = append(out, ";preserveAspectRatio=1:"...)
+	out = append(out, payload...)
+	return string(append(out, "\a\n"...)), nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestProtocolGolden pins what the graphics protocols and tmux's styles
+// make of every fixture, byte for byte
+func TestProtocolGolden(t *testing.T) {
+	fixtures, err := os.ReadDir(filepath.Join("testdata", "fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fixture := range fixtures {
+		img := loadFixture(t, fixture.Name())
+		base := strings.TrimSuffix(fixture.Name(), filepath.Ext(fixture.Name()))
+
+		for _, backend := range []RenderBackend{sixelBackend{}, kittyBackend{}, iterm2Backend{}} {
+			name := base + "." + backend.Name()
+			t.Run(name, func(t *testing.T) {
+				r := NewDeterministicRenderer(HalfBlockMode)
+				r.MaxWidth, r.MaxHeight = 12, 6
+				out, err := backend.Render(img, r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkGolden(t, name, []byte(out))
+			})
+		}
+		for _, depth := range []ColorDepth{Color256, TrueColor} {
+			name := base + "_" + strings.Fields(depth.String())[0] + ".tmux"
+			t.Run(name, func(t *testing.T) {
+				r := NewDeterministicRenderer(HalfBlockMode)
+				r.MaxWidth, r.MaxHeight = 24, 12
+				r.ColorDepth = depth
+				r.Transparent = true
+				checkGolden(t, name, []byte(r.Rasterize(img).EncodeInline(inlineTmux)))
+			})
+		}
+	}
+}
+
+// TestKittyChunks checks a payload over 4096 bytes goes out in chunks, all
+// but the last saying more follow
+func TestKittyChunks(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 160, 160))
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] = uint8(seed >> 24) // noise doesn't compress
+	}
+	r := NewDeterministicRenderer(HalfBlockMode)
+	r.MaxWidth, r.MaxHeight = 20, 10
+	out, err := kittyBackend{}.Render(img, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cols, rows := protocolCells(img, r)
+	chunks := strings.Split(strings.TrimSuffix(out, "\033\\\n"), "\033\\")
+	if len(chunks) < 2 {
+		t.Fatalf("%d chunks, want the payload split", len(chunks))
+	}
+	var payload string
+	for i, chunk := range chunks {
+		want := "\033_Gm=1;"
+		switch {
+		case i == 0:
+			want = "\033_Ga=T,f=100,q=2,c=" + strconv.Itoa(cols) + ",r=" + strconv.Itoa(rows) + ",m=1;"
+		case i == len(chunks)-1:
+			want = "\033_Gm=0;"
+		}
+		data, ok := strings.CutPrefix(chunk, want)
+		if !ok || len(data) > 4096 {
+			t.Errorf("chunk %d starts %.40q with %d bytes of payload, want %q and at most 4096", i, chunk, len(data), want)
+		}
+		payload += data
+	}
+	if want, _, _ := encodePNGBase64(fitToCells(img, cols, rows, r.interpolator())); payload != want {
+		t.Error("the chunks don't add up to the image")
+	}
+}
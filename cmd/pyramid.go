@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/image/draw"
+)
+
+// pyramidMinSize is the longest side the smallest pyramid level gets down to
+const pyramidMinSize = 256
+
+// pyramid is a prepared image: copies at half, a quarter, an eighth... of
+// its size, and what the original was like when they were made
+type pyramid struct {
+	Source  string         `json:"source"`
+	Size    int64          `json:"size"`
+	ModTime time.Time      `json:"mod_time"`
+	Format  string         `json:"format"`
+	Width   int            `json:"width"`
+	Height  int            `json:"height"`
+	Levels  []pyramidLevel `json:"levels"` // biggest first
+}
+
+type pyramidLevel struct {
+	File   string `json:"file"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// pyramidDir is where the pyramid of the image at the absolute path source
+// is kept, named by the path's hash
+func pyramidDir(source string) (string, error) {
+	dir, err := termuwuCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(dir, "pyramids", hex.EncodeToString(sum[:16])), nil
+}
+
+// localImagePath says whether pathOrURL names a plain local file, not a
+// download, data URI, git blob or something in an archive
+func localImagePath(pathOrURL string) bool {
+	if _, ok := gitSource(pathOrURL); ok || isDataURI(pathOrURL) || isEPUB(pathOrURL) {
+		return false
+	}
+	if _, _, ok := splitArchivePath(pathOrURL); ok {
+		return false
+	}
+	return !strings.HasPrefix(pathOrURL, "http://") && !strings.HasPrefix(pathOrURL, "https://")
+}
+
+// readPyramid reads the manifest of a pyramid
+func readPyramid(dir string) (*pyramid, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "pyramid.json"))
+	if err != nil {
+		return nil, err
+	}
+	var p pyramid
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// current says whether the original is still the file the pyramid was
+// made from
+func (p *pyramid) current() bool {
+	info, err := os.Stat(p.Source)
+	return err == nil && info.Size() == p.Size && info.ModTime().Equal(p.ModTime)
+}
+
+// loadPyramid is the up to date pyramid of the image at path, if it was
+// prepared
+func loadPyramid(path string) (*pyramid, string, bool) {
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", false
+	}
+	dir, err := pyramidDir(source)
+	if err != nil {
+		return nil, "", false
+	}
+	p, err := readPyramid(dir)
+	if err != nil || p.Source != source || !p.current() {
+		return nil, "", false
+	}
+	return p, dir, true
+}
+
+// preparedLevel decodes the smallest level of path's pyramid that still has
+// width or height pixels across, enough for a render that size. there's
+// none when the image wasn't prepared, changed since, or the render needs
+// the original
+func preparedLevel(path string, width, height int) (image.Image, *pyramid, bool) {
+	p, dir, ok := loadPyramid(path)
+	if !ok {
+		return nil, nil, false
+	}
+	for i := len(p.Levels) - 1; i >= 0; i-- {
+		level := p.Levels[i]
+		if level.Width < width && level.Height < height {
+			continue
+		}
+		file, err := os.Open(filepath.Join(dir, level.File))
+		if err != nil {
+			return nil, nil, false
+		}
+		defer file.Close()
+		img, _, err := DecodeImage(file)
+		if err != nil {
+			return nil, nil, false
+		}
+		return img, p, true
+	}
+	return nil, nil, false
+}
+
+// preparedSource is the level of path's pyramid show renders in place of the
+// original, like preparedLevel, but never when sourceFilters have to see the
+// original: --redact and --pixelate-region regions, the --overlay's size and
+// the --denoise radius are all in its pixels
+func preparedSource(path string, width, height int, sourceFilters []Filter) (image.Image, *pyramid, bool) {
+	if len(sourceFilters) > 0 {
+		return nil, nil, false
+	}
+	return preparedLevel(path, width, height)
+}
+
+// preparePyramid makes the pyramid of the image at path, halving it until
+// its longest side is at most pyramidMinSize
+func preparePyramid(path string) (*pyramid, error) {
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open image: %w", err)
+	}
+	dir, err := pyramidDir(source)
+	if err != nil {
+		return nil, err
+	}
+	img, format, err := loadImage(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pyramid{
+		Source:  source,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Format:  format,
+		Width:   img.Bounds().Dx(),
+		Height:  img.Bounds().Dy(),
+	}
+	if max(p.Width, p.Height) <= 2*pyramidMinSize {
+		return nil, fmt.Errorf("%dx%d is small enough to show as it is", p.Width, p.Height)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	// every level is made from the one before, a quarter of the pixels
+	level := img
+	for max(level.Bounds().Dx(), level.Bounds().Dy()) > pyramidMinSize {
+		b := level.Bounds()
+		level = fitWithin(level, max(b.Dx()/2, 1), max(b.Dy()/2, 1), draw.BiLinear)
+		name := strconv.Itoa(len(p.Levels)+1) + ".png"
+		if err := SaveImage(filepath.Join(dir, name), level); err != nil {
+			return nil, err
+		}
+		p.Levels = append(p.Levels, pyramidLevel{name, level.Bounds().Dx(), level.Bounds().Dy()})
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// written last, a pyramid without it is an unfinished one
+	if err := writeFileAtomic(filepath.Join(dir, "pyramid.json"), data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+var prepareCmd = &cobra.Command{
+	Use:   "prepare [path...]",
+	Short: "Pre-generate smaller copies of big images so show opens them quickly",
+	Long: `Decode big local images once and keep copies at half, a quarter, an
+eighth... of their size in the cache directory:
+
+  termuwu prepare bigimage.tif
+  termuwu show bigimage.tif
+
+show then decodes the smallest copy that's still big enough for the render
+instead of the original, which takes a fraction of the time for images of
+hundreds of megabytes. A copy whose original changed isn't used; prepare
+it again. termuwu cache shows how much space the copies take and removes
+them.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+
+		for _, path := range args {
+			if !localImagePath(path) {
+				fmt.Fprintf(statusOut, "%s %s\n", errorColor("❌ Only local files can be prepared:"), path)
+				continue
+			}
+			if p, _, ok := loadPyramid(path); ok {
+				fmt.Fprintf(statusOut, tr("✅ %s %s (%d levels)\n"), successColor("Already prepared:"), path, len(p.Levels))
+				continue
+			}
+			start := time.Now()
+			p, err := preparePyramid(path)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %s: %v\n", errorColor("❌ Error preparing image:"), path, err)
+				continue
+			}
+			smallest := p.Levels[len(p.Levels)-1]
+			fmt.Fprintf(statusOut, tr("✅ %s %s, %dx%d down to %dx%d in %d levels (%v)\n"), successColor("Prepared"), path,
+				p.Width, p.Height, smallest.Width, smallest.Height, len(p.Levels), time.Since(start).Round(time.Millisecond))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(prepareCmd)
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestPyramid prepares an image and checks show would pick the smallest
+// level that's big enough, and none once the original changed
+func TestPyramid(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func(out io.Writer) { statusOut = out }(statusOut)
+	statusOut = io.Discard
+
+	path := filepath.Join(t.TempDir(), "big.png")
+	if err := SaveImage(path, image.NewGray(image.Rect(0, 0, 1200, 800))); err != nil {
+		t.Fatal(err)
+	}
+	p, err := preparePyramid(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sizes []image.Point
+	for _, level := range p.Levels {
+		sizes = append(sizes, image.Pt(level.Width, level.Height))
+	}
+	if want := []image.Point{{600, 400}, {300, 200}, {150, 100}}; len(sizes) != len(want) || sizes[0] != want[0] || sizes[2] != want[2] {
+		t.Fatalf("levels %v, want %v", sizes, want)
+	}
+
+	if img, _, ok := preparedLevel(path, 250, 1000); !ok || img.Bounds().Dx() != 300 {
+		t.Errorf("got %v %v for a 250 pixel wide render, want the 300x200 level", img, ok)
+	}
+	if _, _, ok := preparedLevel(path, 1000, 1000); ok {
+		t.Error("a render bigger than every level didn't get the original")
+	}
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	if _, _, ok := preparedLevel(path, 100, 100); ok {
+		t.Error("a level of a changed original was used")
+	}
+}
+
+// TestPreparedSourceRedacts checks a region redacted in the original's
+// pixels stays black when the image was prepared, since the regions don't
+// fit the smaller copies
+func TestPreparedSourceRedacts(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func(out io.Writer) { statusOut = out }(statusOut)
+	statusOut = io.Discard
+
+	// white bottom-right quadrant
+	original := image.NewGray(image.Rect(0, 0, 2048, 2048))
+	draw.Draw(original, image.Rect(1024, 1024, 2048, 2048), image.White, image.Point{}, draw.Src)
+	path := filepath.Join(t.TempDir(), "big.png")
+	if err := SaveImage(path, original); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := preparePyramid(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := preparedSource(path, 32, 32, nil); !ok {
+		t.Fatal("the prepared copy isn't used without filters")
+	}
+
+	filters, err := regionFilters([]string{"1024,1024,1024,1024"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, ok := preparedSource(path, 32, 32, filters)
+	if ok {
+		t.Error("the prepared copy is used with --redact")
+	} else if img, _, err = loadImage(path); err != nil {
+		t.Fatal(err)
+	}
+	img = ApplyFilters(img, filters...)
+	b := img.Bounds()
+	if r, _, _, _ := img.At(b.Min.X+b.Dx()*3/4, b.Min.Y+b.Dy()*3/4).RGBA(); r != 0 {
+		t.Errorf("the redacted quadrant has red %d, want it black", r)
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recordOut      string
+	recordFPS      float64
+	recordDuration time.Duration
+)
+
+var recordCmd = &cobra.Command{
+	Use:   "record [path_or_url]",
+	Short: "Record the terminal rendering of a GIF or video to a GIF, MP4 or WebP",
+	Long: `Render a GIF or a video (with ffmpeg installed) the way play would, and draw
+the rendered cells back into pixels with a built-in font, so the terminal
+look can be shared anywhere without a screen recorder:
+
+  termuwu record anim.gif --braille --out anim-braille.gif
+  termuwu record movie.mkv --duration 10s --fps 15 --out clip.mp4
+
+GIFs are written directly; MP4 and WebP output goes through ffmpeg.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		if recordOut == "" {
+			fmt.Fprintln(statusOut, errorColor("❌ Give the file to record to with --out."))
+			return
+		}
+		if recordFPS <= 0 {
+			fmt.Fprintf(statusOut, "%s %g\n", errorColor("❌ Invalid --fps value:"), recordFPS)
+			return
+		}
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		dither, err := parseDitherMode(ditherName)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --dither value:"), err)
+			return
+		}
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.Dither = dither
+		if dither == DitherNone {
+			renderer.UseDither = false
+		}
+		renderer.ColorDepth = depth
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		var source frameSource
+		if needsFFmpeg(args[0]) {
+			stream, err := decodeFFmpeg(ctx, args[0], recordDuration, recordFPS)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error opening video:"), err)
+				return
+			}
+			defer stream.Close()
+			source = &decodedSource{r: bufio.NewReader(stream), delay: time.Duration(float64(time.Second) / recordFPS)}
+		} else {
+			reader, err := openSource(args[0])
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+			animation, err := DecodeGIF(reader)
+			reader.Close()
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error decoding GIF:"), err)
+				return
+			}
+			animation.LoopCount = -1 // one pass is enough
+			source = newGIFSource(animation)
+		}
+
+		rec, err := newRecorder(recordOut, recordFPS)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Can't record:"), err)
+			return
+		}
+		fmt.Fprintf(statusOut, "🎬 %s %s\n", infoColor("Recording to"), recordOut)
+
+		fr := NewFrameRenderer(renderer)
+		frames := 0
+		var clock time.Duration
+		for ctx.Err() == nil && (recordDuration == 0 || clock < recordDuration) {
+			img, delay, err := source.next()
+			if err == io.EOF {
+				break
+			}
+			if err == nil {
+				err = rec.add(GridImage(fr.RasterizeFrame(img)), delay)
+			}
+			if err != nil {
+				rec.close()
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Recording failed:"), err)
+				return
+			}
+			frames++
+			clock += delay
+		}
+		if err := rec.close(); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Recording failed:"), err)
+			return
+		}
+		grid := fr.Grid()
+		fmt.Fprintf(statusOut, tr("✅ %s %s (%d frames, %dx%d cells, %s)\n"), successColor("Saved"), recordOut,
+			frames, grid.Width, grid.Height, clock.Round(10*time.Millisecond))
+	},
+}
+
+// decodedSource reads the JPEG frames ffmpeg decodes for recording, one
+// every delay
+type decodedSource struct {
+	r     *bufio.Reader
+	delay time.Duration
+}
+
+func (s *decodedSource) next() (image.Image, time.Duration, error) {
+	data, err := readStreamFrame(s.r, streamJPEG)
+	if err != nil {
+		return nil, 0, err
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	return img, s.delay, err
+}
+
+func init() {
+	rootCmd.AddCommand(recordCmd)
+
+	recordCmd.Flags().StringVarP(&recordOut, "out", "o", "", "File to record to: .gif, .mp4 or .webp.")
+	recordCmd.Flags().Float64Var(&recordFPS, "fps", 10, "Frames a second to take from videos and to write MP4/WebP at.")
+	recordCmd.Flags().DurationVar(&recordDuration, "duration", 0, "Stop recording after this long (0 to record it all).")
+	recordCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	recordCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	recordCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	recordCmd.Flags().StringVar(&ditherName, "dither", "ordered", "Dithering to use: ordered, blue-noise (stronger, steady across animation frames) or none.")
+	recordCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	recordCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	recordCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
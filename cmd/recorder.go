@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recorder writes rendered frames into an animation file
+type recorder interface {
+	// add appends a frame shown for delay
+	add(frame *image.RGBA, delay time.Duration) error
+	close() error
+}
+
+// ffmpegCodecs are the ffmpeg encoder settings for the video formats
+// record writes. chroma subsampling (yuv420p) keeps mp4s playable
+// everywhere at the cost of slightly blurred colors
+var ffmpegCodecs = map[string][]string{
+	".mp4":  {"-c:v", "libx264", "-pix_fmt", "yuv420p", "-crf", "18", "-movflags", "+faststart"},
+	".webp": {"-c:v", "libwebp", "-lossless", "1", "-loop", "0"},
+}
+
+// newRecorder picks the recorder for path's extension. GIFs are written
+// directly, MP4 and WebP go through ffmpeg at fps frames a second
+func newRecorder(path string, fps float64) (recorder, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".gif" {
+		return &gifRecorder{path: path}, nil
+	}
+	codec, ok := ffmpegCodecs[ext]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q (use .gif, .mp4 or .webp)", ext)
+	}
+	// absolute, so a name starting with - doesn't read as an option
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return startFFmpegRecorder(path, fps, codec)
+}
+
+// gifPalette is the xterm 256 color palette, the colors a 256 color render
+// is made of anyway. true color renders get the nearest of them
+var gifPalette = func() color.Palette {
+	p := make(color.Palette, 256)
+	for i := range p {
+		c := xtermRGB(i)
+		p[i] = color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff}
+	}
+	return p
+}()
+
+// gifRecorder keeps the frames in memory, image/gif can only write whole
+// animations. a frame equal to the one before just makes that one longer
+type gifRecorder struct {
+	path  string
+	anim  gif.GIF
+	clock time.Duration
+}
+
+func (g *gifRecorder) add(frame *image.RGBA, delay time.Duration) error {
+	// delays are centiseconds, rounded on the running clock so they don't
+	// drift apart from the source
+	start := centiseconds(g.clock)
+	g.clock += delay
+	length := centiseconds(g.clock) - start
+
+	paletted := image.NewPaletted(frame.Bounds(), gifPalette)
+	draw.Draw(paletted, paletted.Rect, frame, frame.Rect.Min, draw.Src)
+	if n := len(g.anim.Image); n > 0 && bytes.Equal(g.anim.Image[n-1].Pix, paletted.Pix) {
+		g.anim.Delay[n-1] += length
+		return nil
+	}
+	g.anim.Image = append(g.anim.Image, paletted)
+	g.anim.Delay = append(g.anim.Delay, length)
+	return nil
+}
+
+func centiseconds(d time.Duration) int {
+	return int(math.Round(d.Seconds() * 100))
+}
+
+func (g *gifRecorder) close() error {
+	if len(g.anim.Image) == 0 {
+		return errors.New("nothing to record")
+	}
+	for i, delay := range g.anim.Delay {
+		g.anim.Delay[i] = max(delay, 2) // players treat anything shorter as 10
+	}
+	file, err := os.Create(g.path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(file, &g.anim); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ffmpegRecorder pipes PNG frames into ffmpeg at a constant frame rate. a
+// frame is repeated for as many slots of the rate as its delay covers
+type ffmpegRecorder struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  bytes.Buffer
+	fps     float64
+	clock   time.Duration
+	written int
+	png     bytes.Buffer
+}
+
+func startFFmpegRecorder(path string, fps float64, codec []string) (*ffmpegRecorder, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("recording MP4 and WebP needs ffmpeg installed: %w", err)
+	}
+	args := []string{"-hide_banner", "-loglevel", "error", "-y",
+		"-f", "image2pipe", "-c:v", "png", "-framerate", strconv.FormatFloat(fps, 'f', -1, 64), "-i", "-"}
+	args = append(append(args, codec...), path)
+
+	r := &ffmpegRecorder{cmd: exec.Command(ffmpeg, args...), fps: fps}
+	r.cmd.Stderr = &r.stderr
+	if r.stdin, err = r.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("couldn't start ffmpeg: %w", err)
+	}
+	return r, nil
+}
+
+func (r *ffmpegRecorder) add(frame *image.RGBA, delay time.Duration) error {
+	r.clock += delay
+	slots := int(math.Round(r.clock.Seconds()*r.fps)) - r.written
+	if slots <= 0 {
+		return nil // shorter than a frame at this rate
+	}
+	r.png.Reset()
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&r.png, frame); err != nil {
+		return err
+	}
+	for ; slots > 0; slots-- {
+		if _, err := r.stdin.Write(r.png.Bytes()); err != nil {
+			if closeErr := r.close(); closeErr != nil {
+				return closeErr // ffmpeg quit, its output says why
+			}
+			return err
+		}
+		r.written++
+	}
+	return nil
+}
+
+func (r *ffmpegRecorder) close() error {
+	r.stdin.Close()
+	if err := r.cmd.Wait(); err != nil {
+		return fmt.Errorf("ffmpeg %v: %s", err, strings.TrimSpace(r.stderr.String()))
+	}
+	return nil
+}
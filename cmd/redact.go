@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	pigo "github.com/esimov/pigo/core"
+)
+
+// facefinder is pigo's frontal face cascade (MIT licensed, github.com/esimov/pigo)
+//
+//go:embed cascade/facefinder
+var faceCascade []byte
+
+// faceQuality is the pigo score a detection needs to count as a face
+const faceQuality = 5
+
+// parseRegion reads a region given as x,y,w,h in image pixels
+func parseRegion(value string) (image.Rectangle, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("region %q should be x,y,w,h", value)
+	}
+	var n [4]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || (i >= 2 && v <= 0) {
+			return image.Rectangle{}, fmt.Errorf("region %q should be x,y,w,h with a positive width and height", value)
+		}
+		n[i] = v
+	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
+
+// regionsFilter applies fill to the regions, plus every face in the image
+// when faces is set. region coordinates are relative to the image's top left
+func regionsFilter(regions []image.Rectangle, faces bool, fill func(img *image.RGBA, r image.Rectangle)) Filter {
+	return func(img *image.RGBA) {
+		all := regions
+		if faces {
+			all = append(append([]image.Rectangle(nil), regions...), DetectFaces(img)...)
+		}
+		for _, r := range all {
+			fill(img, r.Add(img.Rect.Min).Intersect(img.Rect))
+		}
+	}
+}
+
+// RedactFilter paints the regions solid black. "faces" detects the regions
+// automatically
+func RedactFilter(regions []image.Rectangle, faces bool) Filter {
+	return regionsFilter(regions, faces, func(img *image.RGBA, r image.Rectangle) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetRGBA(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	})
+}
+
+// PixelateFilter replaces the regions with coarse blocks of their average
+// color, too coarse to read text or recognize faces
+func PixelateFilter(regions []image.Rectangle, faces bool) Filter {
+	return regionsFilter(regions, faces, func(img *image.RGBA, r image.Rectangle) {
+		block := max(8, min(r.Dx(), r.Dy())/6)
+		for by := r.Min.Y; by < r.Max.Y; by += block {
+			for bx := r.Min.X; bx < r.Max.X; bx += block {
+				cell := image.Rect(bx, by, bx+block, by+block).Intersect(r)
+				var sum [4]int
+				for y := cell.Min.Y; y < cell.Max.Y; y++ {
+					for x := cell.Min.X; x < cell.Max.X; x++ {
+						c := img.RGBAAt(x, y)
+						sum[0], sum[1], sum[2], sum[3] = sum[0]+int(c.R), sum[1]+int(c.G), sum[2]+int(c.B), sum[3]+int(c.A)
+					}
+				}
+				n := cell.Dx() * cell.Dy()
+				avg := color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), uint8(sum[3] / n)}
+				for y := cell.Min.Y; y < cell.Max.Y; y++ {
+					for x := cell.Min.X; x < cell.Max.X; x++ {
+						img.SetRGBA(x, y, avg)
+					}
+				}
+			}
+		}
+	})
+}
+
+// DetectFaces finds frontal faces with pigo and returns their boxes relative
+// to the image's top left, padded a bit to cover hair and chin
+func DetectFaces(img *image.RGBA) []image.Rectangle {
+	classifier, err := pigo.NewPigo().Unpack(faceCascade)
+	if err != nil {
+		return nil // the cascade is embedded, this can't really happen
+	}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	gray := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gray[y*width+x] = uint8(luminance(toColor(img.RGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y))))
+		}
+	}
+
+	detections := classifier.RunCascade(pigo.CascadeParams{
+		MinSize:     20,
+		MaxSize:     max(width, height),
+		ShiftFactor: 0.1,
+		ScaleFactor: 1.1,
+		ImageParams: pigo.ImageParams{Pixels: gray, Rows: height, Cols: width, Dim: width},
+	}, 0)
+	detections = classifier.ClusterDetections(detections, 0.2)
+
+	var faces []image.Rectangle
+	for _, d := range detections {
+		if d.Q < faceQuality {
+			continue
+		}
+		half := d.Scale * 6 / 10
+		faces = append(faces, image.Rect(d.Col-half, d.Row-half, d.Col+half, d.Row+half))
+	}
+	return faces
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var noRenderCache bool
+
+// renderCacheMaxSize bounds the renders kept on disk, the ones shown least
+// recently are removed past it
+const renderCacheMaxSize = 100 << 20
+
+// renderCacheDir is where rendered output is kept, in the user cache directory
+func renderCacheDir() (string, error) {
+	dir, err := termuwuCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "renders"), nil
+}
+
+// renderCacheKey names the render of the image in source with cmd's flags
+// and the --overlay image in overlay, nil without one. it hashes everything
+// the output depends on: the images' bytes, the command's own flags, the
+// config, the terminal's size, the multiplexer and the termuwu binary
+// itself, so a change to any of them is a miss rather than a stale render
+func renderCacheKey(source, overlay []byte, cmd *cobra.Command, mux muxKind) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "termuwu render cache 1\n")
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			fmt.Fprintf(h, "binary %d %d\n", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	fmt.Fprintf(h, "image %x\n", sha256.Sum256(source))
+	if overlay != nil {
+		fmt.Fprintf(h, "overlay %x\n", sha256.Sum256(overlay))
+	}
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		fmt.Fprintf(h, "--%s=%s\n", f.Name, f.Value.String())
+	})
+	configJSON, _ := json.Marshal(config)
+	fmt.Fprintf(h, "config %s\n", configJSON)
+	width, height, ok := terminalSize()
+	fmt.Fprintf(h, "terminal %d %d %v mux %s\n", width, height, ok, mux)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedRender is the render kept for key, marked as just used
+func cachedRender(key string) (string, bool) {
+	dir, err := renderCacheDir()
+	if err != nil {
+		return "", false
+	}
+	path := filepath.Join(dir, key+".ansi")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now) // the modification time is when it was last shown
+	return string(data), true
+}
+
+// cacheRender keeps output for key, removing the least recently shown
+// renders while the cache is over renderCacheMaxSize. the cache is only a
+// shortcut, so failing to write it isn't an error
+func cacheRender(key, output string) {
+	dir, err := renderCacheDir()
+	if err != nil || len(output) > renderCacheMaxSize {
+		return
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(dir, key+".ansi"), []byte(output)); err != nil {
+		return
+	}
+	evictRenders(dir, renderCacheMaxSize)
+}
+
+// evictRenders removes the renders in dir shown longest ago until the rest
+// take up at most max bytes
+func evictRenders(dir string, max int64) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type render struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var renders []render
+	var total int64
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() && filepath.Ext(entry.Name()) == ".ansi" {
+			renders = append(renders, render{filepath.Join(dir, entry.Name()), info.Size(), info.ModTime()})
+			total += info.Size()
+		}
+	}
+	sort.Slice(renders, func(i, j int) bool { return renders[i].used.Before(renders[j].used) })
+	for _, r := range renders {
+		if total <= max {
+			break
+		}
+		if os.Remove(r.path) == nil {
+			total -= r.size
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// TestEvictRenders checks the renders shown longest ago go first, and only
+// until the cache fits
+func TestEvictRenders(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now()
+	for i, name := range []string{"b", "a", "d", "c"} { // shown in this order
+		path := filepath.Join(dir, name+".ansi")
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 10)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		used := start.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, used, used)
+	}
+	os.WriteFile(filepath.Join(dir, ".termuwu-123"), []byte(strings.Repeat("x", 100)), 0o644) // a write in progress
+
+	evictRenders(dir, 25)
+	var left []string
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	if got := strings.Join(left, " "); got != ".termuwu-123 c.ansi d.ansi" {
+		t.Errorf("left %s, want the two shown last", got)
+	}
+}
+
+// TestRenderCacheKey checks a change to anything the render depends on is
+// a miss, and the same image shown the same way a hit
+func TestRenderCacheKey(t *testing.T) {
+	defer func(c Config) { config = c }(config)
+	source, overlay := []byte("not really a PNG"), []byte("not really a logo")
+	cmd := &cobra.Command{}
+	width := cmd.Flags().Int("width", 0, "")
+	key := renderCacheKey(source, overlay, cmd, muxNone)
+
+	if again := renderCacheKey(source, overlay, cmd, muxNone); again != key {
+		t.Error("the same render has another key")
+	}
+	if renderCacheKey([]byte("another PNG"), overlay, cmd, muxNone) == key {
+		t.Error("another image has the same key")
+	}
+	if renderCacheKey(source, []byte("another logo"), cmd, muxNone) == key {
+		t.Error("another overlay has the same key")
+	}
+	if renderCacheKey(source, nil, cmd, muxNone) == key {
+		t.Error("no overlay has the same key")
+	}
+	if renderCacheKey(source, overlay, cmd, muxTmux) == key {
+		t.Error("another multiplexer has the same key")
+	}
+
+	*width = 40
+	if renderCacheKey(source, overlay, cmd, muxNone) == key {
+		t.Error("another --width has the same key")
+	}
+	*width = 0
+
+	config.Glyphs.Missing = []string{"braille"}
+	if renderCacheKey(source, overlay, cmd, muxNone) == key {
+		t.Error("another config has the same key")
+	}
+}
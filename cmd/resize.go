@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/image/draw"
+)
+
+var (
+	resizeWidth   int
+	resizeHeight  int
+	resizeQuality int
+	resizeOut     string
+	resizeScaler  string
+	resizeCrop    string
+)
+
+// resizeFormats are the formats resize writes, by extension. WebP and AVIF
+// go through ffmpeg, there's no encoder for them in Go
+var resizeFormats = map[string]string{
+	".png": "PNG", ".jpg": "JPEG", ".jpeg": "JPEG", ".webp": "WebP", ".avif": "AVIF",
+}
+
+// resizeBox is the size w x h comes out at to fit within maxW x maxH,
+// keeping its aspect ratio. a 0 leaves that side free, and images are never
+// enlarged
+func resizeBox(w, h, maxW, maxH int) (int, int) {
+	scale := 1.0
+	if maxW > 0 {
+		scale = min(scale, float64(maxW)/float64(w))
+	}
+	if maxH > 0 {
+		scale = min(scale, float64(maxH)/float64(h))
+	}
+	if scale >= 1 {
+		return w, h
+	}
+	return max(int(float64(w)*scale+0.5), 1), max(int(float64(h)*scale+0.5), 1)
+}
+
+// ResizeImage scales img to w x h with scaler, Catmull-Rom for ScalerAuto
+func ResizeImage(img image.Image, w, h int, scaler Scaler) image.Image {
+	interp := scaler.resampler()
+	switch {
+	case scaler == ScalerNearest:
+		interp = draw.NearestNeighbor
+	case interp == nil:
+		interp = draw.CatmullRom
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	interp.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// EncodeImage encodes img as the format of ext: .png, .jpg/.jpeg, .webp or
+// .avif, the last two with ffmpeg. quality (1-100) is ignored for PNG.
+// JPEG has no alpha, so transparent areas are put on white
+func EncodeImage(img image.Image, ext string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	switch strings.ToLower(ext) {
+	case ".png":
+		err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+		return buf.Bytes(), err
+	case ".jpg", ".jpeg":
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: quality})
+		return buf.Bytes(), err
+	case ".webp":
+		return ffmpegEncode(img, ".webp", "-c:v", "libwebp", "-quality", strconv.Itoa(quality))
+	case ".avif":
+		// AV1's constant rate factor runs the other way, 0 (lossless) to 63
+		crf := (100 - quality) * 63 / 100
+		return ffmpegEncode(img, ".avif", "-c:v", "libaom-av1", "-still-picture", "1",
+			"-crf", strconv.Itoa(crf), "-cpu-used", "6", "-pix_fmt", "yuv420p")
+	}
+	return nil, fmt.Errorf("don't know how to write %q (use .png, .jpg, .webp or .avif)", ext)
+}
+
+// ffmpegEncode pipes img to ffmpeg as a PNG and has it encode it with
+// codec. the output goes to a temporary file since the AVIF muxer wants to
+// seek
+func ffmpegEncode(img image.Image, ext string, codec ...string) ([]byte, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("writing %s needs ffmpeg installed: %w", resizeFormats[ext], err)
+	}
+	var input bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.BestSpeed}).Encode(&input, img); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "termuwu-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out"+ext)
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-f", "image2pipe", "-c:v", "png", "-i", "-"}
+	args = append(append(args, codec...), "-frames:v", "1", out)
+	cmd := exec.Command(ffmpeg, args...)
+	cmd.Stdin = &input
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg failed: %s", msg)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return os.ReadFile(out)
+}
+
+// cropImage is the part r of img, copied when img can't be cut out of
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+var resizeCmd = &cobra.Command{
+	Use:   "resize [path_or_url]",
+	Short: "Shrink an image and convert it to PNG, JPEG, WebP or AVIF",
+	Long: `Scale an image down with the same high quality (Catmull-Rom) scaler the
+renders use and save it in the format of --out's extension: .png, .jpg,
+.webp or .avif. The aspect ratio is kept: give --width, --height or both to
+fit the image within them. Images are never enlarged, and without either
+it's only converted. --crop x,y,w,h cuts out a region first, and
+--temperature and --tint correct the white balance, --auto-enhance
+stretches the contrast and --denoise smooths away noise.
+
+  termuwu resize in.png --width 1280 --quality 85 --out out.webp
+  termuwu resize photo.jpg --height 600 --out small.jpg
+  termuwu resize scan.png --out scan.avif --quality 60
+
+--quality (1-100) is for JPEG, WebP and AVIF; PNG is always lossless.
+WebP and AVIF are encoded by ffmpeg, which has to be installed. Metadata
+isn't carried over, so the result is as clean as after termuwu strip.
+
+Cropping a JPEG to a JPEG without resizing it is lossless when the crop's
+top left corner is on the JPEG's block grid, every 8 or 16 pixels: the compressed
+blocks are copied instead of decoded and compressed again, and the
+metadata stays. Otherwise it's re-encoded at --quality, and resize says
+which of the two happened.
+
+  termuwu resize photo.jpg --crop 512,256,1200,800 --out detail.jpg`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		if resizeOut == "" {
+			fmt.Fprintln(statusOut, errorColor("❌ Give the path to write the image to with --out."))
+			return
+		}
+		ext := strings.ToLower(filepath.Ext(resizeOut))
+		if _, ok := resizeFormats[ext]; !ok {
+			fmt.Fprintf(statusOut, "%s %q (use .png, .jpg, .webp or .avif)\n", errorColor("❌ Unknown output format:"), ext)
+			return
+		}
+		if resizeWidth < 0 {
+			fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --width value:"), resizeWidth)
+			return
+		}
+		if resizeHeight < 0 {
+			fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --height value:"), resizeHeight)
+			return
+		}
+		if resizeQuality < 1 || resizeQuality > 100 {
+			fmt.Fprintf(statusOut, "%s %d (use 1-100)\n", errorColor("❌ Invalid --quality value:"), resizeQuality)
+			return
+		}
+		scaler, err := parseScaler(resizeScaler)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --scaler value:"), err)
+			return
+		}
+
+		var crop image.Rectangle
+		if resizeCrop != "" {
+			if crop, err = parseRegion(resizeCrop); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --crop value:"), err)
+				return
+			}
+		}
+
+		var adjust []Filter
+		var smooth Filter // before scaling, unlike the others
+		if denoise != 0 {
+			if smooth, err = DenoiseFilter(denoise); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --denoise value:"), err)
+				return
+			}
+		}
+		if temperature != 0 || tint != 0 {
+			filter, err := WhiteBalanceFilter(temperature, tint)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid white balance:"), err)
+				return
+			}
+			adjust = append(adjust, filter)
+		}
+		if autoEnhance {
+			adjust = append(adjust, AutoEnhanceFilter())
+		}
+
+		var original bytes.Buffer
+		img, format, err := loadImageCopy(args[0], &original)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+		bounds := img.Bounds()
+		if resizeCrop != "" {
+			crop = crop.Add(bounds.Min).Intersect(bounds)
+			if crop.Empty() {
+				fmt.Fprintf(statusOut, "%s %s\n", errorColor("❌ The crop is outside the image:"), resizeCrop)
+				return
+			}
+			img = cropImage(img, crop)
+		}
+		from := img.Bounds()
+		w, h := resizeBox(from.Dx(), from.Dy(), resizeWidth, resizeHeight)
+
+		var data []byte
+		if resizeCrop != "" && len(adjust) == 0 && smooth == nil && format == "jpeg" && resizeFormats[ext] == "JPEG" && w == from.Dx() && h == from.Dy() {
+			// a plain crop from JPEG to JPEG can keep the compressed data
+			if data, err = CropJPEG(original.Bytes(), crop.Sub(bounds.Min)); err == nil {
+				fmt.Fprintf(statusOut, "✂️ %s\n", infoColor("Cropped losslessly, nothing was re-encoded."))
+			} else {
+				fmt.Fprintf(statusOut, "✂️ %s %v\n", infoColor("Re-encoded the crop:"), err)
+				data, err = nil, nil
+			}
+		}
+		if smooth != nil && data == nil {
+			img = ApplyFilters(img, smooth)
+		}
+		if w != from.Dx() || h != from.Dy() {
+			img = ResizeImage(img, w, h, scaler)
+		}
+
+		if len(adjust) > 0 {
+			img = ApplyFilters(img, adjust...)
+		}
+		if data == nil {
+			data, err = EncodeImage(img, ext, resizeQuality)
+		}
+		if err == nil {
+			err = writeFileAtomic(resizeOut, data)
+		}
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error saving image:"), err)
+			return
+		}
+		noteResult(img)
+		fmt.Fprintf(statusOut, tr("✅ %s %s (%dx%d %s, %s, was %dx%d and %s)\n"), successColor("Saved to"), resizeOut,
+			w, h, resizeFormats[ext], formatByteSize(int64(len(data))),
+			bounds.Dx(), bounds.Dy(), formatByteSize(int64(original.Len())))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resizeCmd)
+
+	resizeCmd.Flags().IntVar(&resizeWidth, "width", 0, "Largest width in pixels (0 for any).")
+	resizeCmd.Flags().IntVar(&resizeHeight, "height", 0, "Largest height in pixels (0 for any).")
+	resizeCmd.Flags().IntVarP(&resizeQuality, "quality", "q", 85, "Quality for JPEG, WebP and AVIF, 1-100.")
+	resizeCmd.Flags().StringVarP(&resizeOut, "out", "o", "", "Where to save the image; the extension picks the format (.png, .jpg, .webp or .avif).")
+	resizeCmd.Flags().StringVar(&resizeCrop, "crop", "", "Cut out a region first, as x,y,w,h in pixels (lossless for JPEG to JPEG when it's on the block grid).")
+	resizeCmd.Flags().Float64Var(&temperature, "temperature", 0, "Correct the white balance from -100 (cooler, for warm indoor light) to 100 (warmer).")
+	resizeCmd.Flags().Float64Var(&tint, "tint", 0, "Correct the tint from -100 (greener) to 100 (more magenta, for fluorescent light).")
+	resizeCmd.Flags().BoolVar(&autoEnhance, "auto-enhance", false, "Stretch the contrast to the full range and boost the saturation a little.")
+	resizeCmd.Flags().Float64Var(&denoise, "denoise", 0, "Smooth away the noise of low-light photos before scaling, with a strength from 1 to 10 (3 without a value).")
+	resizeCmd.Flags().StringVar(&resizeScaler, "scaler", "catmull-rom", "Resampling: catmull-rom, bilinear or nearest.")
+	resizeCmd.Flags().Lookup("denoise").NoOptDefVal = "3"
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import "testing"
+
+func TestResizeBox(t *testing.T) {
+	for _, tc := range []struct {
+		w, h, maxW, maxH int
+		wantW, wantH     int
+	}{
+		{4000, 3000, 1280, 0, 1280, 960},
+		{4000, 3000, 0, 600, 800, 600},
+		{4000, 3000, 1000, 1000, 1000, 750},
+		{3000, 4000, 1000, 1000, 750, 1000},
+		{800, 600, 1280, 0, 800, 600}, // never enlarged
+		{800, 600, 0, 0, 800, 600},
+		{1000, 3, 10, 0, 10, 1}, // never below a pixel
+	} {
+		if w, h := resizeBox(tc.w, tc.h, tc.maxW, tc.maxH); w != tc.wantW || h != tc.wantH {
+			t.Errorf("resizeBox(%d, %d, %d, %d) = %dx%d, want %dx%d", tc.w, tc.h, tc.maxW, tc.maxH, w, h, tc.wantW, tc.wantH)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/fatih/color"
@@ -46,6 +47,54 @@ It supports both local files and URLs, and displays them using detailed, colorfu
 	termuwu show image.jpg --braille --no-dither
 
 🚀 Get started by running: termuwu show --help`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := setupLocale(langFlag); err != nil {
+			fmt.Printf("%s %v\n", color.New(color.FgRed, color.Bold).Sprint("❌ Error loading translations:"), err)
+			os.Exit(1)
+		}
+		explicit := configPath != ""
+		path := configPath
+		if !explicit {
+			path = defaultConfigPath()
+		}
+
+		cfg, err := loadConfig(path, explicit)
+		if err != nil {
+			fmt.Printf("%s %v\n", localized(themeColor("error", color.FgRed, color.Bold))("❌ Error loading config:"), err)
+			os.Exit(1)
+		}
+		config = cfg
+		if progressFormat != "" && progressFormat != "json" {
+			fmt.Printf("%s %q\n", localized(color.New(color.FgRed, color.Bold))("❌ Invalid --progress value:"), progressFormat)
+			os.Exit(1)
+		}
+		if maxDownload, err = parseByteSize(maxDownloadFlag); err != nil {
+			fmt.Printf("%s %v\n", localized(color.New(color.FgRed, color.Bold))("❌ Invalid --max-download value:"), err)
+			os.Exit(1)
+		}
+		if err := setupChrome(); err != nil {
+			fmt.Printf("%s %v\n", localized(color.New(color.FgRed, color.Bold))("❌ Invalid theme:"), err)
+			os.Exit(1)
+		}
+		notice.command = cmd.CommandPath()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		sendNotice()
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&langFlag, "lang", "", "Language for messages, like de or es (default from LC_ALL, LC_MESSAGES or LANG).")
+	rootCmd.PersistentFlags().BoolVar(&plainChrome, "plain", false, "Plain status output for logs and CI: no colors, no download progress bar and ASCII instead of emoji.")
+	rootCmd.PersistentFlags().StringVar(&progressFormat, "progress", "", "Report progress as json events on stderr (download, decode, render, done) for wrappers drawing their own progress.")
+	rootCmd.PersistentFlags().IntVar(&downloadConnections, "connections", 4, "Connections to download big images over when the server takes range requests, resuming from the cache directory after a failure (1 for a plain download).")
+	rootCmd.PersistentFlags().StringVar(&maxDownloadFlag, "max-download", "50MB", "Refuse to download images bigger than this, or that aren't images, asking first on a terminal (0 for no size limit).")
+	rootCmd.PersistentFlags().BoolVar(&sandboxDecode, "sandbox", false, "Decode images in a child process limited in CPU time and memory, that can't open files or connections (seccomp on Linux), for untrusted input.")
+	rootCmd.PersistentFlags().StringVar(&verifySHA256, "sha256", "", "Refuse images whose SHA-256 isn't this hash, checked before anything decodes them.")
+	rootCmd.PersistentFlags().StringVar(&verifyKey, "verify-sig", "", "Refuse images without a valid minisign signature by this public key (the key or its .pub file).")
+	rootCmd.PersistentFlags().StringVar(&verifySigPath, "sig", "", "Path or URL of the minisign signature for --verify-sig (default is the image's path or URL with .minisig added).")
+	rootCmd.PersistentFlags().BoolVar(&notifyFlag, "notify", false, "Send a desktop notification when the command is done, with a thumbnail of the result when it has one (notify-send, terminal-notifier or osascript, OSC 9 over SSH).")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to the config file (default is termuwu/config.json in your user config directory).")
 }
 
 func Execute() {
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"runtime/debug"
+)
+
+// maxImagePixels caps what DecodeImage is willing to allocate. a hostile
+// file can claim any size in its header and make the decoder allocate it
+const maxImagePixels = 1 << 27 // 128 megapixels
+
+// ErrImageTooLarge is returned for images bigger than maxImagePixels
+var ErrImageTooLarge = errors.New("image dimensions are too large")
+
+// PanicError is a panic recovered from a decoder or renderer, turned into an
+// error so a broken input can't crash the program
+type PanicError struct {
+	Op    string // what was going on, e.g. "decoding image"
+	Value any    // the value passed to panic
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic while %s: %v", e.Op, e.Value)
+}
+
+// DecodeImage is image.Decode hardened for untrusted input: the header is
+// checked against maxImagePixels before decoding and decoder panics come
+// back as a *PanicError. with --sandbox a child process decodes it
+func DecodeImage(r io.Reader) (img image.Image, format string, err error) {
+	if sandboxDecode {
+		return decodeSandboxed(r)
+	}
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true)) // see readMapped
+	defer func() {
+		if v := recover(); v != nil {
+			img, format, err = nil, "", &PanicError{Op: "decoding image", Value: v}
+		}
+	}()
+
+	r, err = checkImageHeader(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return image.Decode(r)
+}
+
+// checkImageHeader reads the image header to reject empty and oversized
+// images before anything big is allocated. the returned reader still starts
+// at the beginning of the image. a memory-mapped file is seeked back rather
+// than buffered, so the decoders still read it in place
+func checkImageHeader(r io.Reader) (io.Reader, error) {
+	if mapped, ok := r.(*mappedFile); ok {
+		start, err := mapped.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		cfg, _, err := image.DecodeConfig(mapped)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := mapped.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return mapped, checkImageSize(cfg)
+	}
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	return io.MultiReader(&header, r), checkImageSize(cfg)
+}
+
+// checkImageSize rejects images without pixels or with more than
+// maxImagePixels
+func checkImageSize(cfg image.Config) error {
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return fmt.Errorf("image has no pixels (%dx%d)", cfg.Width, cfg.Height)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	return nil
+}
+
+// TryRenderImage is RenderImage that reports a panic as a *PanicError
+// instead of crashing, and refuses images without pixels
+func (r *ImageRenderer) TryRenderImage(img image.Image) (output string, err error) {
+	if img.Bounds().Empty() {
+		return "", errors.New("image has no pixels")
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			output, err = "", &PanicError{Op: "rendering image", Value: v}
+		}
+	}()
+	return r.RenderImage(img), nil
+}
+
+// DecodeGIF decodes every frame of a GIF with the same safeguards as
+// DecodeImage. the pixel limit applies to the sum of all frames
+func DecodeGIF(r io.Reader) (g *gif.GIF, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true)) // see readMapped
+	defer func() {
+		if v := recover(); v != nil {
+			g, err = nil, &PanicError{Op: "decoding GIF", Value: v}
+		}
+	}()
+
+	r, err = checkImageHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	g, err = gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var pixels int64
+	for _, frame := range g.Image {
+		pixels += int64(frame.Bounds().Dx()) * int64(frame.Bounds().Dy())
+	}
+	if pixels > maxImagePixels {
+		return nil, fmt.Errorf("%w: %d frames with %d pixels in total", ErrImageTooLarge, len(g.Image), pixels)
+	}
+	return g, nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// sandboxDecode is --sandbox: images are decoded by a child process that
+// can't touch files or the network, so a decoder bug exploited by an image
+// stays in there
+var sandboxDecode bool
+
+const (
+	// sandboxTimeout is how long the child gets for one image
+	sandboxTimeout = time.Minute
+	// sandboxCPU is how many seconds of CPU time it may use
+	sandboxCPU = 30
+	// sandboxMemory caps its memory, room for the biggest image
+	// maxImagePixels allows at 8 bytes a pixel and the runtime
+	sandboxMemory = maxImagePixels*8 + 256<<20
+)
+
+// sandboxMagic starts the bitmap the child sends back
+var sandboxMagic = [4]byte{'T', 'W', 'B', '1'}
+
+// decodeSandboxed decodes r in a child process of this program, and takes
+// back nothing but the size, format name and pixels of the image
+func decodeSandboxed(r io.Reader) (image.Image, string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't start the sandboxed decoder: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sandboxTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exe, "sandbox-decode")
+	cmd.Stdin = r
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, "", fmt.Errorf("couldn't start the sandboxed decoder: %w", err)
+	}
+	img, format, readErr := readBitmap(bufio.NewReader(stdout))
+	if readErr != nil {
+		io.Copy(io.Discard, stdout) // so Wait isn't stuck on a child still writing
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case ctx.Err() != nil:
+		return nil, "", fmt.Errorf("the sandboxed decoder took over %v", sandboxTimeout)
+	case waitErr != nil:
+		// the child's error, or the first line of what the runtime said dying
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return nil, "", errors.New(msg)
+		}
+		return nil, "", fmt.Errorf("the sandboxed decoder failed: %w", waitErr)
+	case readErr != nil:
+		return nil, "", fmt.Errorf("the sandboxed decoder sent a broken bitmap: %w", readErr)
+	}
+	return img, format, nil
+}
+
+// writeBitmap sends img to the parent: the magic, width, height and format,
+// then the pixels as non-premultiplied RGBA, a row at a time so no second
+// copy of a big image is made
+func writeBitmap(w io.Writer, img image.Image, format string) error {
+	b := img.Bounds()
+	header := append([]byte{}, sandboxMagic[:]...)
+	header = binary.BigEndian.AppendUint32(header, uint32(b.Dx()))
+	header = binary.BigEndian.AppendUint32(header, uint32(b.Dy()))
+	header = append(header, byte(len(format)))
+	header = append(header, format...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	row := make([]byte, 4*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			i := 4 * (x - b.Min.X)
+			row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBitmap reads what writeBitmap wrote, trusting none of it: the size is
+// held to maxImagePixels before anything is allocated
+func readBitmap(r io.Reader) (image.Image, string, error) {
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, "", err
+	}
+	if [4]byte(header[:4]) != sandboxMagic {
+		return nil, "", errors.New("no bitmap header")
+	}
+	w, h := int64(binary.BigEndian.Uint32(header[4:])), int64(binary.BigEndian.Uint32(header[8:]))
+	if w <= 0 || h <= 0 {
+		return nil, "", fmt.Errorf("image has no pixels (%dx%d)", w, h)
+	}
+	if w*h > maxImagePixels {
+		return nil, "", fmt.Errorf("%w: %dx%d", ErrImageTooLarge, w, h)
+	}
+	format := make([]byte, header[12])
+	if _, err := io.ReadFull(r, format); err != nil {
+		return nil, "", err
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, int(w), int(h)))
+	if _, err := io.ReadFull(r, img.Pix); err != nil {
+		return nil, "", err
+	}
+	return img, string(format), nil
+}
+
+var sandboxDecodeCmd = &cobra.Command{
+	Use:    "sandbox-decode",
+	Short:  "Decode an image from stdin for --sandbox",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	// the child reads no config or translations, it only decodes
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		fail := func(err error) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := limitResources(); err != nil {
+			fail(fmt.Errorf("couldn't limit the sandboxed decoder: %w", err))
+		}
+		if err := restrictSyscalls(); err != nil {
+			fail(fmt.Errorf("couldn't sandbox the decoder: %w", err))
+		}
+		img, format, err := DecodeImage(os.Stdin)
+		if err != nil {
+			fail(err)
+		}
+		out := bufio.NewWriter(os.Stdout)
+		if err := writeBitmap(out, img, format); err == nil {
+			err = out.Flush()
+		}
+		if err != nil {
+			fail(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sandboxDecodeCmd)
+}
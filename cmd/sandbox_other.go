@@ -0,0 +1,9 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !zos
+
+package cmd
+
+// limitResources does nothing where there are no rlimits, the decoder is
+// still a process of its own
+func limitResources() error {
+	return nil
+}
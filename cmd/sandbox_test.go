@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestBitmap checks images come back from the sandboxed decoder's bitmaps
+// the same, and that a bitmap claiming a huge size is refused
+func TestBitmap(t *testing.T) {
+	img := image.NewRGBA(image.Rect(3, 5, 8, 9)) // not at 0,0
+	for y := 5; y < 9; y++ {
+		for x := 3; x < 8; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 30), uint8(y * 20), 99, uint8(60 * (x - 3))})
+		}
+	}
+	var buf bytes.Buffer
+	if err := writeBitmap(&buf, img, "png"); err != nil {
+		t.Fatal(err)
+	}
+	got, format, err := readBitmap(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || got.Bounds().Size() != img.Bounds().Size() {
+		t.Fatalf("got a %v %s", got.Bounds(), format)
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			if want := color.NRGBAModel.Convert(img.At(x+3, y+5)); got.At(x, y) != want {
+				t.Errorf("%d,%d is %v, want %v", x, y, got.At(x, y), want)
+			}
+		}
+	}
+
+	huge := append([]byte{}, sandboxMagic[:]...)
+	huge = binary.BigEndian.AppendUint32(huge, 1<<20)
+	huge = binary.BigEndian.AppendUint32(huge, 1<<20)
+	if _, _, err := readBitmap(bytes.NewReader(append(huge, 0))); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("a 1Mx1M bitmap gave %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris || zos
+
+package cmd
+
+import (
+	"runtime/debug"
+
+	"golang.org/x/sys/unix"
+)
+
+// limitResources caps the sandboxed decoder's CPU time and memory, and
+// keeps it from writing files
+func limitResources() error {
+	debug.SetMemoryLimit(sandboxMemory * 3 / 4) // collect before the hard limit hits
+	for _, limit := range []struct {
+		resource int
+		value    unix.Rlimit
+	}{
+		{unix.RLIMIT_CPU, unix.Rlimit{Cur: sandboxCPU, Max: sandboxCPU}},
+		{unix.RLIMIT_DATA, unix.Rlimit{Cur: sandboxMemory, Max: sandboxMemory}},
+		{unix.RLIMIT_FSIZE, unix.Rlimit{}},
+		{unix.RLIMIT_NOFILE, unix.Rlimit{Cur: 16, Max: 16}},
+	} {
+		if err := unix.Setrlimit(limit.resource, &limit.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
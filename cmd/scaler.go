@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Scaler is how images are resampled to the sample grid
+type Scaler int
+
+const (
+	// ScalerAuto picks a sample per cell for text and approximate bilinear
+	// for graphics protocols
+	ScalerAuto Scaler = iota
+	ScalerNearest
+	ScalerBilinear
+	ScalerCatmullRom // sharpest and slowest
+)
+
+func parseScaler(value string) (Scaler, error) {
+	switch strings.ToLower(value) {
+	case "auto", "":
+		return ScalerAuto, nil
+	case "nearest":
+		return ScalerNearest, nil
+	case "bilinear":
+		return ScalerBilinear, nil
+	case "catmull-rom", "catmullrom":
+		return ScalerCatmullRom, nil
+	}
+	return ScalerAuto, fmt.Errorf("unknown scaler %q (use auto, nearest, bilinear or catmull-rom)", value)
+}
+
+// resampler is the x/image interpolator for the smooth scalers, nil when
+// samples are picked from the image as they are
+func (s Scaler) resampler() draw.Interpolator {
+	switch s {
+	case ScalerBilinear:
+		return draw.BiLinear
+	case ScalerCatmullRom:
+		return draw.CatmullRom
+	}
+	return nil
+}
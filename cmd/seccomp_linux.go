@@ -0,0 +1,169 @@
+//go:build linux && (amd64 || arm64)
+
+package cmd
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// allowedSyscalls are all the sandboxed decoder may call besides read and
+// write, clone, kill and tgkill, whose arguments are checked: what the Go
+// runtime needs to run threads, manage memory, sleep and handle signals.
+// everything else fails with EPERM, so there's no opening files, no
+// network, no new file descriptors of any kind (io_uring, pidfds, memfds)
+// and no running programs
+var allowedSyscalls = append([]uintptr{
+	unix.SYS_MMAP, unix.SYS_MUNMAP, unix.SYS_MADVISE, unix.SYS_MPROTECT, unix.SYS_MINCORE,
+	unix.SYS_FUTEX, unix.SYS_SCHED_YIELD, unix.SYS_SCHED_GETAFFINITY, unix.SYS_NANOSLEEP, unix.SYS_CLOCK_GETTIME,
+	unix.SYS_EPOLL_PWAIT, unix.SYS_EPOLL_CTL,
+	unix.SYS_SIGALTSTACK, unix.SYS_RT_SIGACTION, unix.SYS_RT_SIGPROCMASK, unix.SYS_RT_SIGRETURN,
+	unix.SYS_GETPID, unix.SYS_GETTID, unix.SYS_GETRANDOM, unix.SYS_RESTART_SYSCALL,
+	unix.SYS_EXIT, unix.SYS_EXIT_GROUP,
+}, archAllowedSyscalls...)
+
+// cloneThread is the only clone the decoder may make: the runtime's flags
+// for a new thread of the same process
+const cloneThread = unix.CLONE_VM | unix.CLONE_FS | unix.CLONE_FILES | unix.CLONE_SIGHAND | unix.CLONE_SYSVSEM | unix.CLONE_THREAD
+
+// x32Bit marks the syscalls of the x32 ABI, which amd64 kernels take under
+// the same architecture but with numbers no rule above matches
+const x32Bit = 0x40000000
+
+// restrictSyscalls installs a seccomp filter allowing allowedSyscalls on
+// every thread of the process. read and write only work on the descriptors
+// open now, stdin, stdout, stderr and the runtime's poller, and signals only
+// go to the process itself. a syscall for another architecture or the x32
+// ABI, whose numbers would mean different calls, kills it
+func restrictSyscalls() error {
+	maxFD, err := openDescriptors()
+	if err != nil {
+		return err
+	}
+
+	const (
+		load    = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		jumpEq  = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jumpGE  = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		ret     = unix.BPF_RET | unix.BPF_K
+		nr      = 0  // seccomp_data.nr
+		arch    = 4  // seccomp_data.arch
+		argLow  = 16 // seccomp_data.args[0], little-endian
+		argHigh = 20
+	)
+	// jumps name their targets, resolved once every instruction is in
+	type instruction struct {
+		unix.SockFilter
+		label, jt, jf string
+	}
+	var program []instruction
+	add := func(code uint16, k uint32, jt, jf string) {
+		program = append(program, instruction{SockFilter: unix.SockFilter{Code: code, K: k}, jt: jt, jf: jf})
+	}
+	label := func(name string) {
+		program = append(program, instruction{label: name})
+	}
+	// the first argument is exactly value, or below it with below
+	firstArg := func(value uint32, below bool) {
+		add(load, argHigh, "", "")
+		add(jumpEq, 0, "", "deny")
+		add(load, argLow, "", "")
+		if below {
+			add(jumpGE, value, "deny", "allow")
+		} else {
+			add(jumpEq, value, "allow", "deny")
+		}
+	}
+
+	add(load, arch, "", "")
+	add(jumpEq, seccompArch, "", "kill")
+	add(load, nr, "", "")
+	add(jumpGE, x32Bit, "kill", "")
+	for _, call := range allowedSyscalls {
+		add(jumpEq, uint32(call), "allow", "")
+	}
+	add(jumpEq, unix.SYS_READ, "descriptor", "")
+	add(jumpEq, unix.SYS_WRITE, "descriptor", "")
+	add(jumpEq, unix.SYS_CLONE, "clone", "")
+	add(jumpEq, unix.SYS_KILL, "self", "")
+	add(jumpEq, unix.SYS_TGKILL, "self", "deny")
+	label("descriptor")
+	firstArg(uint32(maxFD+1), true)
+	label("clone")
+	firstArg(cloneThread, false)
+	label("self")
+	firstArg(uint32(os.Getpid()), false)
+	label("allow")
+	add(ret, unix.SECCOMP_RET_ALLOW, "", "")
+	label("deny")
+	add(ret, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM), "", "")
+	label("kill")
+	add(ret, unix.SECCOMP_RET_KILL_PROCESS, "", "")
+
+	targets := make(map[string]int)
+	var filter []unix.SockFilter
+	for _, in := range program {
+		if in.label != "" {
+			targets[in.label] = len(filter)
+		} else {
+			filter = append(filter, in.SockFilter)
+		}
+	}
+	pc := 0
+	for _, in := range program {
+		if in.label != "" {
+			continue
+		}
+		for _, jump := range []struct {
+			target string
+			offset *uint8
+		}{{in.jt, &filter[pc].Jt}, {in.jf, &filter[pc].Jf}} {
+			if jump.target != "" {
+				*jump.offset = uint8(targets[jump.target] - pc - 1)
+			}
+		}
+		pc++
+	}
+	fprog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return err
+	}
+	// TSYNC puts the filter on the runtime's other threads too
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER, unix.SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&fprog)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// openDescriptors starts the runtime's poller, so its descriptors exist
+// before the filter stops new ones being made, and returns the highest
+// descriptor the process has open
+func openDescriptors() (int, error) {
+	// a pipe is registered with the poller, which starts it
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	r.Close()
+	w.Close()
+
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	highest := 2
+	for _, entry := range entries {
+		if fd, err := strconv.Atoi(entry.Name()); err == nil {
+			highest = max(highest, fd) // the directory's own is closed by now, and can't be opened again
+		}
+	}
+	return highest, nil
+}
@@ -0,0 +1,9 @@
+package cmd
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_X86_64
+
+// archAllowedSyscalls are the runtime's amd64 only calls: arch_prctl sets
+// up a new thread's TLS, epoll_wait is the older poller call
+var archAllowedSyscalls = []uintptr{unix.SYS_ARCH_PRCTL, unix.SYS_EPOLL_WAIT}
@@ -0,0 +1,8 @@
+package cmd
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_AARCH64
+
+// archAllowedSyscalls is empty, the runtime makes no arm64 only calls
+var archAllowedSyscalls []uintptr
@@ -0,0 +1,112 @@
+//go:build linux && (amd64 || arm64)
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestRestrictSyscalls runs the filter in a child process, which has to
+// still decode images but get nowhere with files, the network, new file
+// descriptors or other processes
+func TestRestrictSyscalls(t *testing.T) {
+	if check := os.Getenv("TERMUWU_SECCOMP_CHILD"); check != "" {
+		seccompChild(check)
+	}
+
+	run := func(check string) (string, error) {
+		child := exec.Command(os.Args[0], "-test.run=^TestRestrictSyscalls$")
+		child.Env = append(os.Environ(), "TERMUWU_SECCOMP_CHILD="+check)
+		out, err := child.CombinedOutput()
+		return string(out), err
+	}
+	if out, err := run("decode"); err != nil || out != "ok\n" {
+		t.Errorf("sandboxed child: %v\n%s", err, out)
+	}
+	if runtime.GOARCH == "amd64" {
+		_, err := run("x32")
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) || exit.Sys().(syscall.WaitStatus).Signal() != syscall.SIGSYS {
+			t.Errorf("an x32 syscall ended the child with %v, want it killed", err)
+		}
+	}
+}
+
+// seccompChild is the sandboxed side of TestRestrictSyscalls, it prints ok
+// or what it managed to do
+func seccompChild(check string) {
+	data, err := os.ReadFile(filepath.Join("testdata", "fixtures", "gradient.png"))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := restrictSyscalls(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if check == "x32" {
+		unix.Syscall(unix.SYS_GETPID|x32Bit, 0, 0, 0)
+		fmt.Println("an x32 syscall went through")
+		os.Exit(1)
+	}
+
+	var failed []string
+	for range 4 { // with threads and garbage collection
+		done := make(chan error)
+		for range 4 {
+			go func() {
+				_, _, err := DecodeImage(bytes.NewReader(data))
+				runtime.GC()
+				done <- err
+			}()
+		}
+		for range 4 {
+			if err := <-done; err != nil {
+				failed = append(failed, "decoding: "+err.Error())
+			}
+		}
+	}
+	denied := func(what string, errno syscall.Errno) {
+		if errno != unix.EPERM {
+			failed = append(failed, fmt.Sprintf("%s gave %v, want EPERM", what, errno))
+		}
+	}
+	if _, err := os.Open("/"); !errors.Is(err, unix.EPERM) {
+		failed = append(failed, fmt.Sprintf("opening / gave %v", err))
+	}
+	_, _, errno := unix.Syscall(unix.SYS_SOCKET, unix.AF_INET, unix.SOCK_STREAM, 0)
+	denied("socket", errno)
+	_, _, errno = unix.Syscall(unix.SYS_IO_URING_SETUP, 8, 0, 0)
+	denied("io_uring_setup", errno)
+	_, _, errno = unix.Syscall(unix.SYS_MEMFD_CREATE, 0, 0, 0)
+	denied("memfd_create", errno)
+	_, _, errno = unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(os.Getppid()), 0, 0)
+	denied("pidfd_open", errno)
+	_, _, errno = unix.Syscall(unix.SYS_KILL, uintptr(os.Getppid()), 0, 0)
+	denied("kill of the parent", errno)
+	_, _, errno = unix.Syscall(unix.SYS_DUP, 1, 0, 0)
+	denied("dup", errno)
+	_, _, errno = unix.Syscall(unix.SYS_WRITE, 100, 0, 0)
+	denied("write to a descriptor opened later", errno)
+	_, _, errno = unix.Syscall(unix.SYS_CLONE, uintptr(unix.SIGCHLD), 0, 0) // fork
+	denied("fork", errno)
+
+	if len(failed) > 0 {
+		for _, f := range failed {
+			fmt.Println(f)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+	os.Exit(0)
+}
@@ -0,0 +1,9 @@
+//go:build !linux || (!amd64 && !arm64)
+
+package cmd
+
+// restrictSyscalls does nothing without seccomp, or on architectures whose
+// syscall numbers the filter doesn't know
+func restrictSyscalls() error {
+	return nil
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -10,10 +12,12 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
+	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
 
@@ -21,76 +25,273 @@ var (
 	useFullBlocks bool
 	useBraille    bool
 	noDither      bool
+	ditherName    string
+	scalerName    string
+	searchDepth   int
 	renderWidth   int
 	renderHeight  int
+	colorMode     string
+	byteBudget    string
+	showStats     bool
+	protocolName  string
+	exportFormat  string
+	ansiOptions   ANSIOptions
+	muxName       string
+	brailleColor  string
+	brailleStyle  string
+	renderStyle   string
+	pixelArt      bool
+	maxColors     int
+	deterministic bool
+	exactColors   bool
+	exactFit      bool
+	atPosition    string
+	transparent   bool
+	chromaKey     string
+	chromaFuzz    string
+	highContrast  bool
+	cellScale     int
+	describeMode  string
+	simulate      string
+	temperature   float64
+	tint          float64
+	autoEnhance   bool
+	useCLAHE      bool
+	denoise       float64
+	blurBack      bool
+	redactRegions []string
+	pixelRegions  []string
+	overlayPath   string
+	overlayPos    string
+	overlayAlpha  float64
+	showMap       bool
+	mapZoom       int
+	mapServer     string
+	inlineRows    int
+	inlineMarkup  string
 )
 
+// statusOut receives the chatty status lines. it switches to stderr when the
+// real output is meant to be piped somewhere
+var statusOut io.Writer = os.Stdout
+
 func loadImage(pathOrURL string) (image.Image, string, error) {
+	return loadImageCopy(pathOrURL, nil)
+}
+
+// loadImageCopy is loadImage also writing the bytes it reads to copy, when
+// it's not nil, for the metadata in them
+func loadImageCopy(pathOrURL string, copy io.Writer) (image.Image, string, error) {
+	reader, err := openSource(pathOrURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+	return decodeSource(reader, copy)
+}
+
+// readSource reads all of a local file or download for the render cache to
+// hash, and returns a reader over the same bytes to decode. a memory-mapped
+// file isn't copied, data is valid until the reader is closed
+func readSource(pathOrURL string) (data []byte, reader io.ReadCloser, err error) {
+	reader, err = openSource(pathOrURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mapped, ok := reader.(*mappedFile); ok {
+		return mapped.Bytes(), mapped, nil
+	}
+	data, err = io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't read image: %w", err)
+	}
+	return data, io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// decodeSource decodes an opened image, writing the bytes it reads to copy
+// when it isn't nil
+func decodeSource(reader io.Reader, copy io.Writer) (image.Image, string, error) {
+	r := reader
+	if copy != nil {
+		r = io.TeeReader(reader, copy)
+	}
+	img, format, decodeErr := DecodeImage(r)
+	if decodeErr != nil {
+		return nil, "", fmt.Errorf("couldn't decode image: %w", decodeErr)
+	}
+	return img, format, nil
+}
+
+// httpGet starts downloading url, failing on anything but 200 OK
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't download image: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("couldn't download image: received status code %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// withProgress shows a progress bar on stderr while resp's body is read,
+// or sends download events with --progress json
+func withProgress(resp *http.Response) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(resp.Body, newDownloadProgress(resp.ContentLength)), resp.Body}
+}
+
+// newDownloadProgress is where a download of total bytes (-1 when unknown)
+// counts them: the progress bar, events with --progress json or nowhere
+// with --plain
+func newDownloadProgress(total int64) byteCounter {
+	if progressFormat == "json" {
+		return &downloadProgress{total: total}
+	}
+	if !showsProgressBar() {
+		return noProgress{}
+	}
+	cyan := localized(themeColor("accent", color.FgCyan))
+	barGreen := themeColor("bar", color.FgGreen).SprintFunc()
+	barLightBlack := themeColor("bar-empty", color.FgHiBlack).SprintFunc()
+	chars := progressTheme(ProgressTheme{Filled: "█", Head: "█", Empty: "░", Start: "|", End: "|"})
+
+	return progressbar.NewOptions64(
+		total,
+		progressbar.OptionSetDescription(cyan("Downloading...")),
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetWidth(25),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionSetItsString("bytes"),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        barGreen(chars.Filled),
+			SaucerHead:    barGreen(chars.Head),
+			SaucerPadding: barLightBlack(chars.Empty),
+			BarStart:      chars.Start,
+			BarEnd:        chars.End,
+		}),
+	)
+}
+
+// openSource opens a local file or starts downloading a URL with a progress
+// bar, announcing it on statusOut
+func openSource(pathOrURL string) (io.ReadCloser, error) {
 	var reader io.ReadCloser
-	cyan := color.New(color.FgCyan).SprintFunc()
-	urlColor := color.New(color.FgBlue, color.Underline).SprintFunc()
-
-	if strings.HasPrefix(pathOrURL, "http://") || strings.HasPrefix(pathOrURL, "https://") {
-		fmt.Printf("📸 %s %s\n", cyan("Downloading image from URL:"), urlColor(pathOrURL))
-		req, reqErr := http.NewRequest("GET", pathOrURL, nil)
-		if reqErr != nil {
-			return nil, "", fmt.Errorf("invalid URL: %w", reqErr)
-		}
-		resp, httpErr := http.DefaultClient.Do(req)
-		if httpErr != nil {
-			return nil, "", fmt.Errorf("couldn't download image: %w", httpErr)
-		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			return nil, "", fmt.Errorf("couldn't download image: received status code %d", resp.StatusCode)
-		}
-
-		barGreen := color.New(color.FgGreen).SprintFunc()
-		barLightBlack := color.New(color.FgHiBlack).SprintFunc()
-
-		bar := progressbar.NewOptions64(
-			resp.ContentLength,
-			progressbar.OptionSetDescription(cyan("Downloading...")),
-			progressbar.OptionSetWriter(os.Stderr),
-			progressbar.OptionSetWidth(25),
-			progressbar.OptionShowBytes(true),
-			progressbar.OptionEnableColorCodes(true),
-			progressbar.OptionSetItsString("bytes"),
-			progressbar.OptionSetTheme(progressbar.Theme{
-				Saucer:        barGreen("█"),
-				SaucerHead:    barGreen("█"),
-				SaucerPadding: barLightBlack("░"),
-				BarStart:      "|",
-				BarEnd:        "|",
-			}),
-		)
-		reader = io.NopCloser(io.TeeReader(resp.Body, bar))
+	cyan := localized(themeColor("accent", color.FgCyan))
+	urlColor := themeColor("url", color.FgBlue, color.Underline).SprintFunc()
+
+	if isDataURI(pathOrURL) {
+		data, mediaType, err := decodeDataURI(pathOrURL)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(statusOut, "📸 %s %s, %s\n", cyan("Loading image from data URI:"), mediaType, formatByteSize(int64(len(data))))
+		reader = io.NopCloser(bytes.NewReader(data))
+	} else if object, ok := gitSource(pathOrURL); ok {
+		fmt.Fprintf(statusOut, "📸 %s %s\n", cyan("Loading image from git:"), object)
+		data, err := readGitBlob(object)
+		if err != nil {
+			return nil, err
+		}
+		reader = io.NopCloser(bytes.NewReader(data))
+	} else if strings.HasPrefix(pathOrURL, "http://") || strings.HasPrefix(pathOrURL, "https://") {
+		fmt.Fprintf(statusOut, "📸 %s %s\n", cyan("Downloading image from URL:"), urlColor(pathOrURL))
+		var err error
+		if reader, err = openDownload(context.Background(), pathOrURL); err != nil {
+			return nil, err
+		}
 	} else {
-		fmt.Printf("📸 %s %s\n", cyan("Loading image from path:"), pathOrURL)
-		file, fileErr := os.Open(pathOrURL)
+		fmt.Fprintf(statusOut, "📸 %s %s\n", cyan("Loading image from path:"), pathOrURL)
+		var file io.ReadCloser
+		var fileErr error
+		if archive, entry, ok := splitArchivePath(pathOrURL); ok {
+			file, fileErr = openArchiveEntry(archive, entry)
+		} else if isEPUB(pathOrURL) {
+			var cover string
+			if cover, fileErr = epubCover(pathOrURL); fileErr == nil {
+				fmt.Fprintf(statusOut, "📚 %s %s\n", cyan("EPUB cover:"), cover)
+				file, fileErr = openArchiveEntry(pathOrURL, cover)
+			}
+		} else {
+			file, fileErr = openLocalFile(pathOrURL)
+		}
 		if fileErr != nil {
-			return nil, "", fmt.Errorf("couldn't open image: %w", fileErr)
+			return nil, fmt.Errorf("couldn't open image: %w", fileErr)
 		}
 		reader = file
 	}
-	defer reader.Close()
+	if verifying() {
+		return verifySource(pathOrURL, reader)
+	}
+	return reader, nil
+}
 
-	img, format, decodeErr := image.Decode(reader)
-	if decodeErr != nil {
-		return nil, "", fmt.Errorf("couldn't decode image: %w", decodeErr)
+// regionFilters builds the redaction filters for --redact and
+// --pixelate-region, where "faces" stands for every detected face
+func regionFilters(redact, pixelate []string) ([]Filter, error) {
+	var filters []Filter
+	for _, spec := range []struct {
+		values []string
+		filter func([]image.Rectangle, bool) Filter
+	}{{pixelate, PixelateFilter}, {redact, RedactFilter}} {
+		if len(spec.values) == 0 {
+			continue
+		}
+		var regions []image.Rectangle
+		faces := false
+		for _, value := range spec.values {
+			if strings.EqualFold(value, "faces") {
+				faces = true
+				continue
+			}
+			region, err := parseRegion(value)
+			if err != nil {
+				return nil, err
+			}
+			regions = append(regions, region)
+		}
+		filters = append(filters, spec.filter(regions, faces))
 	}
-	return img, format, nil
+	return filters, nil
 }
 
-func configureRenderer(useFullBlocksFlag, useBrailleFlag, noDitherFlag bool, widthFlag, heightFlag int) *ImageRenderer {
-	mode := HalfBlockMode
+func parseColorDepth(value string) (ColorDepth, error) {
+	switch strings.ToLower(value) {
+	case "256", "":
+		return Color256, nil
+	case "16":
+		return Color16, nil
+	case "true", "truecolor", "24bit":
+		return TrueColor, nil
+	}
+	return Color256, fmt.Errorf("unknown color mode %q (use 256, 16 or true)", value)
+}
+
+func renderModeFlag(useFullBlocksFlag, useBrailleFlag bool) RenderMode {
 	if useFullBlocksFlag {
-		mode = BlockMode
+		return BlockMode
 	} else if useBrailleFlag {
-		mode = BrailleMode
+		return BrailleMode
 	}
+	return HalfBlockMode
+}
 
-	renderer := NewImageRenderer(mode)
+func configureRenderer(useFullBlocksFlag, useBrailleFlag, noDitherFlag bool, widthFlag, heightFlag int) *ImageRenderer {
+	var renderer *ImageRenderer
+	if deterministic {
+		renderer = NewDeterministicRenderer(renderModeFlag(useFullBlocksFlag, useBrailleFlag))
+	} else {
+		renderer = NewImageRenderer(renderModeFlag(useFullBlocksFlag, useBrailleFlag))
+	}
 	renderer.UseDither = !noDitherFlag
 
 	if widthFlag > 0 {
@@ -102,51 +303,642 @@ func configureRenderer(useFullBlocksFlag, useBrailleFlag, noDitherFlag bool, wid
 	return renderer
 }
 
+// sharedRenderer sets up the renderer from the rendering flags commands
+// share with show (-f, -b, -n, -W, -H, --colors), reporting what's wrong
+// with them
+func sharedRenderer() (*ImageRenderer, bool) {
+	errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+	if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+		fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+		return nil, false
+	}
+	depth, err := parseColorDepth(colorMode)
+	if err != nil {
+		fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+		return nil, false
+	}
+	renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+	renderer.ColorDepth = depth
+	return renderer, true
+}
+
 var showCmd = &cobra.Command{
-	Use:   "show [image_path_or_url]",
+	Use:   "show [image_path_url_or_data_uri]",
 	Short: "Render an image from a local path or URL in the terminal",
-	Args:  cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if protocolName == "list" {
+			return nil
+		}
+		if base64Input {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		imagePathOrURL := args[0]
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		if protocolName == "list" {
+			printBackends()
+			return
+		}
+		var imagePathOrURL string
+		if base64Input {
+			payload, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+			imagePathOrURL = strings.TrimSpace(string(payload))
+			if !isDataURI(imagePathOrURL) {
+				imagePathOrURL = "data:;base64," + imagePathOrURL
+			}
+		} else {
+			imagePathOrURL = args[0]
+		}
+
+		if atPosition != "" {
+			row, col, err := parseCellPosition(atPosition)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --at value:"), err)
+				return
+			}
+			ansiOptions.Row, ansiOptions.Col = row, col
+		}
+		if ansiOptions != (ANSIOptions{}) || deterministic {
+			statusOut = stderrStatus() // embedded output shouldn't carry our chatter
+		}
+		var inline inlineFormat
+		if inlineRows != 0 {
+			var err error
+			if inline, err = parseInlineFormat(inlineMarkup); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --inline-format value:"), err)
+				return
+			}
+			statusOut = errorLines{stderrStatus()} // a prompt would show anything else on stderr too
+			plainChrome = true                     // and the download progress bar
+			if inlineRows < 0 {
+				fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --inline value:"), inlineRows)
+				return
+			}
+			if inline == inlineTmux && inlineRows > 1 {
+				fmt.Fprintln(statusOut, errorColor("❌ tmux status bars show one line, use --inline=1 with --inline-format tmux."))
+				return
+			}
+		}
+		if deterministic {
+			config = Config{} // hooks from the user's config could change what gets rendered
+		}
+		if err := applySelectedPreset(cmd); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid preset:"), err)
+			return
+		}
+
+		if describeMode != "" {
+			if describeMode != "only" && describeMode != "before" {
+				fmt.Fprintf(statusOut, tr("%s %q (use only or before)\n"), errorColor("❌ Invalid --describe value:"), describeMode)
+				return
+			}
+			statusOut = stderrStatus() // the description is the output
+		}
+
+		if exportFormat != "" {
+			statusOut = stderrStatus()
+			if exportFormat != "json" && exportFormat != "ff" && exportFormat != "ppm" {
+				fmt.Fprintf(statusOut, tr("%s %q (supported: json, ff, ppm)\n"), errorColor("❌ Unknown export format:"), exportFormat)
+				return
+			}
+			if byteBudget != "" || protocolName != "ansi" {
+				fmt.Fprintln(statusOut, errorColor("❌ --export can't be combined with --budget or --protocol."))
+				return
+			}
+		}
+
+		backend, found := LookupBackend(protocolName)
+		if !found {
+			fmt.Fprintf(statusOut, tr("%s %q (run with --protocol list to see what's available)\n"), errorColor("❌ Unknown protocol:"), protocolName)
+			return
+		}
+
+		if inlineRows == 0 && ((renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0)) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		if inlineRows != 0 && (backend.Name() != "ansi" || exportFormat != "" || byteBudget != "" || atPosition != "") {
+			fmt.Fprintln(statusOut, errorColor("❌ --inline only works with the ansi protocol, without --export, --budget and --at."))
+			return
+		}
+
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		dither, err := parseDitherMode(ditherName)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --dither value:"), err)
+			return
+		}
+		scaler, err := parseScaler(scalerName)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --scaler value:"), err)
+			return
+		}
 
-		errorColor := color.New(color.FgRed, color.Bold).SprintFunc()
-		successColor := color.New(color.FgGreen).SprintFunc()
-		infoColor := color.New(color.FgYellow).SprintFunc()
+		brailleColorMode, err := parseBrailleColorMode(brailleColor)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --braille-color value:"), err)
+			return
+		}
 
-		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
-			fmt.Println(errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+		brailleStyleMode, err := parseBrailleStyle(brailleStyle)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --braille-style value:"), err)
 			return
 		}
 
-		img, format, err := loadImage(imagePathOrURL)
+		styleMode, err := parseStyle(renderStyle, renderModeFlag(useFullBlocks, useBraille))
 		if err != nil {
-			fmt.Printf("%s %v\n", errorColor("❌ Error loading image:"), err)
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --style value:"), err)
+			return
+		}
+
+		if maxColors < 0 {
+			fmt.Fprintln(statusOut, errorColor("❌ --max-colors can't be negative."))
 			return
 		}
 
-		if strings.HasPrefix(imagePathOrURL, "http://") || strings.HasPrefix(imagePathOrURL, "https://") {
-			fmt.Println()
+		if deterministic && !cmd.Flags().Changed("mux") {
+			muxName = "none"
+		}
+		var filters []Filter
+		if temperature != 0 || tint != 0 {
+			filter, err := WhiteBalanceFilter(temperature, tint)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid white balance:"), err)
+				return
+			}
+			filters = append(filters, filter) // first, the others expect true colors
+		}
+		if autoEnhance {
+			filters = append(filters, AutoEnhanceFilter())
+		}
+		if useCLAHE {
+			filters = append(filters, CLAHEFilter())
+		}
+		if blurBack {
+			filters = append(filters, BackgroundBlurFilter())
+		}
+		if simulate != "" {
+			filter, err := ColorBlindnessFilter(simulate)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --simulate value:"), err)
+				return
+			}
+			filters = append(filters, filter)
+		}
+		if highContrast {
+			filters = append(filters, HighContrastFilter())
+		}
+		if searchDepth < 1 {
+			fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --search-depth value:"), searchDepth)
+			return
+		}
+		if cellScale < 1 {
+			fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --cell-scale value:"), cellScale)
+			return
+		}
+		if chromaKey != "" {
+			r8, g8, b8, err := parseHexColor(chromaKey)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --transparent-color value:"), err)
+				return
+			}
+			fuzz, err := parseFuzz(chromaFuzz)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --transparent-fuzz value:"), err)
+				return
+			}
+			filters = append(filters, ChromaKeyFilter(r8, g8, b8, fuzz))
 		}
 
-		fmt.Printf("✅ %s Format: %s, Size: %dx%d\n",
+		sourceFilters, err := regionFilters(redactRegions, pixelRegions)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid region:"), err)
+			return
+		}
+		if denoise != 0 {
+			filter, err := DenoiseFilter(denoise)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --denoise value:"), err)
+				return
+			}
+			// on the full image, before scaling turns the noise into speckle
+			sourceFilters = append([]Filter{filter}, sourceFilters...)
+		}
+
+		var overlayData []byte // for the render cache, the path alone doesn't say what's in it
+		if overlayPath != "" {
+			data, reader, err := readSource(overlayPath)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading overlay:"), err)
+				return
+			}
+			defer reader.Close()
+			overlay, _, err := decodeSource(reader, nil)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading overlay:"), err)
+				return
+			}
+			overlayData = data
+			filter, err := OverlayFilter(overlay, overlayPos, overlayAlpha)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid overlay:"), err)
+				return
+			}
+			sourceFilters = append(sourceFilters, filter) // after redaction so the logo stays visible
+		}
+
+		mux, err := parseMux(muxName)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --mux value:"), err)
+			return
+		}
+		depth = mux.adaptDepth(depth)
+
+		var budget int64
+		if byteBudget != "" {
+			budget, err = parseByteSize(byteBudget)
+			if err != nil || budget <= 0 {
+				fmt.Fprintf(statusOut, "%s %q\n", errorColor("❌ Invalid --budget value:"), byteBudget)
+				return
+			}
+			if backend.Name() != "ansi" {
+				fmt.Fprintln(statusOut, errorColor("❌ --budget only works with the ansi protocol."))
+				return
+			}
+		}
+		if exactFit && backend.Name() != "ansi" {
+			fmt.Fprintln(statusOut, errorColor("❌ --exact-fit only works with the ansi protocol."))
+			return
+		}
+		if transparent && backend.Name() != "ansi" {
+			fmt.Fprintln(statusOut, errorColor("❌ --transparent only works with the ansi protocol."))
+			return
+		}
+
+		imagePathOrURL, err = runPreFetchHook(imagePathOrURL)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error running hook:"), err)
+			return
+		}
+
+		if mapZoom < 0 || mapZoom > 19 {
+			fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --map-zoom value:"), mapZoom)
+			return
+		}
+
+		// a prepared image has a copy close to the render's size, far quicker to
+		// decode than the original. text cells take at most 2x4 pixels
+		var prepared image.Image
+		var original *pyramid
+		if localImagePath(imagePathOrURL) && !deterministic && !verifying() && exportFormat == "" && describeMode == "" &&
+			!showMap && backend.Name() == "ansi" {
+			size := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+			prepared, original, _ = preparedSource(imagePathOrURL, 2*size.MaxWidth, 4*size.MaxHeight, sourceFilters)
+		}
+
+		// the cache only holds the image, so not when something else is printed or run
+		var cacheKey string
+		var cached []byte // the bytes hashed into it
+		var source io.ReadCloser
+		if prepared == nil && !noRenderCache && !deterministic && exportFormat == "" && describeMode == "" && !showMap && !showStats &&
+			budget == 0 && progressFormat == "" && config.Hooks.PostRender == "" && !setupConsole().legacy {
+			cached, source, err = readSource(imagePathOrURL)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+			defer source.Close()
+			err = readMapped(func() error { cacheKey = renderCacheKey(cached, overlayData, cmd, mux); return nil })
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+			if output, ok := cachedRender(cacheKey); ok {
+				if (strings.HasPrefix(imagePathOrURL, "http://") || strings.HasPrefix(imagePathOrURL, "https://")) && showsProgressBar() {
+					fmt.Fprintln(statusOut) // past the progress bar
+				}
+				fmt.Fprintf(statusOut, "⚡ %s\n", successColor("Rendered from cache"))
+				fmt.Print(output)
+				return
+			}
+		}
+
+		loadStart := time.Now()
+		var raw bytes.Buffer
+		var rawCopy io.Writer
+		if showMap {
+			rawCopy = &raw // the EXIF data with the GPS position
+		}
+		var img image.Image
+		var format string
+		if prepared != nil {
+			img, format = prepared, original.Format
+			fmt.Fprintf(statusOut, "🔺 %s %dx%d (%s %dx%d)\n", infoColor("Prepared copy:"), img.Bounds().Dx(), img.Bounds().Dy(),
+				tr("original"), original.Width, original.Height)
+		} else if source != nil {
+			img, format, err = decodeSource(source, rawCopy)
+		} else {
+			img, format, err = loadImageCopy(imagePathOrURL, rawCopy)
+		}
+		loadTime := time.Since(loadStart)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+
+		if (strings.HasPrefix(imagePathOrURL, "http://") || strings.HasPrefix(imagePathOrURL, "https://")) && showsProgressBar() {
+			fmt.Fprintln(statusOut) // past the progress bar
+		}
+
+		size := img.Bounds().Size()
+		if prepared != nil {
+			size = image.Pt(original.Width, original.Height) // the image asked for, not the copy
+		}
+		emitProgress(ProgressEvent{Event: "decode", Format: format, Width: size.X, Height: size.Y})
+		fmt.Fprintf(statusOut, tr("✅ %s Format: %s, Size: %dx%d\n"),
 			successColor("Image loaded!"),
 			infoColor(format),
-			img.Bounds().Dx(),
-			img.Bounds().Dy())
+			size.X,
+			size.Y)
+
+		if len(sourceFilters) > 0 {
+			img = ApplyFilters(img, sourceFilters...) // before anything can render or export the original
+		}
+
+		if showMap {
+			if location, err := PhotoLocation(&raw); err != nil {
+				fmt.Fprintf(statusOut, "⚠️  %s %v\n", infoColor("No map:"), err)
+			} else {
+				fmt.Fprintf(statusOut, "📍 %s %s (%s)\n", infoColor("Location:"), location, location.DMS())
+				locationMap, err := LocationMap(context.Background(), location, mapZoom, mapServer)
+				if err != nil {
+					fmt.Fprintf(statusOut, "⚠️  %s %v\n", infoColor("No map:"), err)
+				} else {
+					if mapServer == defaultTileServer {
+						fmt.Fprintf(statusOut, "🗺️  %s\n", infoColor("Map data © OpenStreetMap contributors"))
+					}
+					img = besideMap(img, locationMap)
+				}
+			}
+		}
+
+		if describeMode != "" {
+			description := describeImage(img, format)
+			description.Text, err = recognizeText(context.Background(), img)
+			if err != nil {
+				fmt.Fprintf(statusOut, "⚠️  %s %v\n", infoColor("No text recognition:"), err)
+			}
+			if endpoint := config.Describe.CaptionEndpoint; endpoint != "" {
+				description.Caption, err = requestCaption(context.Background(), endpoint, img)
+				if err != nil {
+					fmt.Fprintf(statusOut, "⚠️  %s %v\n", infoColor("No caption:"), err)
+				}
+			}
+			fmt.Print(description)
+			if describeMode == "only" {
+				return
+			}
+		}
 
 		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.Dither = dither
+		if dither == DitherNone {
+			renderer.UseDither = false
+		}
+		renderer.Scaler = scaler
+		renderer.SearchDepth = searchDepth
+		renderer.ColorDepth = depth
+		renderer.ANSI = ansiOptions
+		renderer.BrailleColor = brailleColorMode
+		renderer.BrailleStyle = brailleStyleMode
+		renderer.Mode = styleMode
+		renderer.PixelArt = pixelArt
+		renderer.MaxColors = maxColors
+		renderer.ExactColors = exactColors
+		renderer.ExactFit = exactFit
+		renderer.Transparent = transparent || chromaKey != ""
+		renderer.CellScale = cellScale
+		if highContrast {
+			renderer.UseDither = false // noise only gets in the way of legibility
+		}
+		if inlineRows != 0 {
+			// the rows are a hard limit, the width a budget the image's shape fits in
+			renderer.MaxHeight = inlineRows
+			renderer.MaxWidth = inlineColumnsPerRow * inlineRows
+			if renderWidth > 0 {
+				renderer.MaxWidth = renderWidth
+			}
+			renderer.CellScale = 1
+			if !cmd.Flags().Changed("dither") {
+				renderer.UseDither = false // a handful of cells only turn noisy
+			}
+		}
+		renderer.Filters = filters
+		if backend.Name() == "ansi" {
+			if err := fitGlyphs(renderer); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+				return
+			}
+		}
+		// a prepared original is too big to be pixel art, however small the copy
+		if !cmd.Flags().Changed("pixel-art") && prepared == nil && IsPixelArt(img) {
+			fmt.Fprintf(statusOut, "🕹️  %s\n", infoColor("Pixel art detected, scaling by whole factors (--pixel-art=false to turn off)"))
+			renderer.PixelArt = true
+		}
+
+		var console consoleInfo
+		if !deterministic {
+			console = setupConsole()
+		}
+		if console.legacy && exportFormat == "" {
+			fmt.Fprintf(statusOut, "⚠️  %s\n", infoColor("Legacy Windows console detected, using 16 colors and plain blocks"))
+			renderer.Mode = BlockMode
+			renderer.ColorDepth = Color16
+			backend, _ = LookupBackend("ansi")
+		}
+
+		var stats RenderStats
+		if showStats {
+			renderer.Stats = &stats
+			defer func() {
+				stats.LoadTime = loadTime
+				stats.Pool = poolStats()
+				writeStats(stderrStatus(), stats)
+			}()
+		}
+
+		if exportFormat != "" {
+			var err error
+			scaled := renderer.Scale(img)
+			switch exportFormat {
+			case "json":
+				err = renderer.RasterizeScaled(scaled).WriteJSON(os.Stdout)
+			case "ff":
+				err = WriteFarbfeld(os.Stdout, scaled)
+			case "ppm":
+				err = WritePPM(os.Stdout, scaled)
+			}
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error exporting:"), err)
+			}
+			return
+		}
+
+		if !mux.supports(backend) {
+			hint := ""
+			if mux == muxTmux {
+				hint = " (tmux set -g allow-passthrough on)"
+			}
+			fmt.Fprintf(statusOut, "⚠️  %s\n", infoColor(fmt.Sprintf(tr("%s can't pass %s output through, falling back to ansi cells%s"), mux, backend.Name(), hint)))
+			backend, _ = LookupBackend("ansi")
+		}
 
-		output := renderer.RenderImage(img)
+		noteResult(img)
+		var output string
+		switch {
+		case console.legacy:
+			if err := writeLegacyConsole(renderer.Rasterize(img)); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error rendering image:"), err)
+				return
+			}
+		case budget > 0:
+			var result BudgetResult
+			output, result = renderer.RenderWithinBudget(img, budget)
+			if result.Fits {
+				fmt.Fprintf(statusOut, "📉 %s %s\n", infoColor(fmt.Sprintf(tr("Budget %s:"), formatByteSize(budget))), result)
+			} else {
+				fmt.Fprintf(statusOut, "⚠️  %s %s\n", infoColor(fmt.Sprintf(tr("Couldn't fit budget %s, best effort:"), formatByteSize(budget))), result)
+			}
+		case inlineRows != 0:
+			output = renderer.Rasterize(img).EncodeInline(inline)
+		case backend.Name() == "ansi":
+			renderer.Progress = renderProgress
+			output, err = renderer.TryRenderImage(img)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error rendering image:"), err)
+				return
+			}
+		default:
+			renderStart := time.Now()
+			output, err = backend.Render(img, renderer)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error rendering image:"), err)
+				return
+			}
+			if wrapped, ok := mux.wrap(backend, strings.TrimSuffix(output, "\n")); ok {
+				// the multiplexer doesn't know the image moved the cursor, so reserve its rows
+				_, rows := protocolCells(img, renderer)
+				output = wrapped + strings.Repeat("\n", rows)
+			}
+			output = applyANSIOptions(output, ansiOptions)
+			stats.Bytes = len(output)
+			stats.RenderTime = time.Since(renderStart)
+		}
+		emitProgress(ProgressEvent{Event: "render", Percent: percentOf(1, 1)})
 		fmt.Print(output)
+		emitProgress(ProgressEvent{Event: "done"})
+		if cacheKey != "" && output != "" {
+			// a mapped file is shared and could have changed since it was hashed,
+			// the render is only kept under the key its bytes still have
+			var again string
+			if readMapped(func() error { again = renderCacheKey(cached, overlayData, cmd, mux); return nil }) == nil && again == cacheKey {
+				cacheRender(cacheKey, output)
+			}
+		}
+
+		_, err = runHook(config.Hooks.PostRender, hookEvent{
+			Hook:        "post_render",
+			Source:      imagePathOrURL,
+			Format:      format,
+			ImageWidth:  img.Bounds().Dx(),
+			ImageHeight: img.Bounds().Dy(),
+			Mode:        renderer.Mode.String(),
+			Protocol:    backend.Name(),
+			OutputBytes: len(output),
+		})
+		if err != nil {
+			fmt.Fprintf(stderrStatus(), "%s %v\n", errorColor("⚠️  Hook error:"), err)
+		}
 	},
 }
 
+func printBackends() {
+	nameColor := themeColor("name", color.FgMagenta, color.Bold).SprintFunc()
+	fmt.Println(tr("🔌 Available protocols:"))
+	for _, b := range Backends() {
+		fmt.Printf("   %-10s %s\n", nameColor(b.Name()), b.Description())
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(showCmd)
 
 	showCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
 	showCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
 	showCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	showCmd.Flags().StringVar(&ditherName, "dither", "ordered", "Dithering to use: ordered, blue-noise (stronger, steady across animation frames) or none.")
+	showCmd.Flags().StringVar(&presetName, "preset", "", "Start from a preset: fast, balanced or best, a look-alike from compare-tools or one of your own from the config.")
+	showCmd.Flags().StringVar(&scalerName, "scaler", "auto", "How to resample the image: auto, nearest (fastest), bilinear or catmull-rom (sharpest).")
+	showCmd.Flags().IntVar(&searchDepth, "search-depth", 3, "How many rounds two-color cells (--braille-color cluster) search for their best split.")
 	showCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
 	showCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	showCmd.Flags().StringVar(&brailleColor, "braille-color", "fg", "How braille cells use color: fg (dots only), fgbg (dots and background) or cluster (two best colors per cell).")
+	showCmd.Flags().StringVar(&brailleStyle, "braille-style", "threshold", "Which braille dots are set: threshold (bright areas) or edges (line art from a Sobel edge detector).")
+	showCmd.Flags().StringVar(&renderStyle, "style", "", "Artistic output style: halftone (shade characters) or crosshatch (ASCII hatching).")
+	showCmd.Flags().BoolVar(&pixelArt, "pixel-art", false, "Scale with nearest-neighbor by whole factors to keep sprites crisp (detected automatically for small, few-color images).")
+	showCmd.Flags().IntVar(&maxColors, "max-colors", 0, "Posterize the image to at most N colors (median cut) before rendering text cells, 0 for no limit.")
+	showCmd.Flags().BoolVar(&deterministic, "deterministic", false, "Render independently of the environment (fixed 100x28 terminal, no multiplexer or console detection, no hooks, status on stderr) for golden test files.")
+	showCmd.Flags().BoolVar(&exactFit, "exact-fit", false, "Pad the output to exactly --width x --height cells (or the terminal size), the image centered, for layouts with a fixed region.")
+	showCmd.Flags().BoolVar(&base64Input, "base64", false, "Read the image base64 encoded from stdin, a bare payload or a data: URI, instead of a path.")
+	showCmd.Flags().BoolVar(&exactColors, "exact-colors", false, "Search the palette for every color instead of using the faster 16-bit lookup tables.")
+	showCmd.Flags().Float64Var(&temperature, "temperature", 0, "Correct the white balance from -100 (cooler, for warm indoor light) to 100 (warmer).")
+	showCmd.Flags().Float64Var(&tint, "tint", 0, "Correct the tint from -100 (greener) to 100 (more magenta, for fluorescent light).")
+	showCmd.Flags().BoolVar(&autoEnhance, "auto-enhance", false, "Stretch the contrast to the full range and boost the saturation a little, which most photos need to survive the terminal's colors.")
+	showCmd.Flags().BoolVar(&useCLAHE, "clahe", false, "Raise the contrast locally (adaptive histogram equalization) to bring out detail in dark screenshots, scans and X-rays.")
+	showCmd.Flags().Float64Var(&denoise, "denoise", 0, "Smooth away the noise of low-light photos, with a strength from 1 to 10 (3 without a value).")
+	showCmd.Flags().BoolVar(&blurBack, "blur-background", false, "Find the subject and blur and darken the rest, so it stands out in a small render of a busy photo.")
+	showCmd.Flags().StringVar(&simulate, "simulate", "", "Preview the image as seen with protanopia, deuteranopia or tritanopia.")
+	showCmd.Flags().StringArrayVar(&redactRegions, "redact", nil, "Black out a region x,y,w,h of the image (in pixels), or \"faces\" to find faces automatically. Repeatable.")
+	showCmd.Flags().StringArrayVar(&pixelRegions, "pixelate-region", nil, "Pixelate a region x,y,w,h of the image (in pixels), or \"faces\" to find faces automatically. Repeatable.")
+	showCmd.Flags().StringVar(&overlayPath, "overlay", "", "Composite a second image (path or URL) on top, e.g. a logo for terminal demos")
+	showCmd.Flags().StringVar(&overlayPos, "overlay-pos", "bottom-right", "Where to put the overlay: top-left, top, top-right, left, center, right, bottom-left, bottom or bottom-right")
+	showCmd.Flags().Float64Var(&overlayAlpha, "overlay-opacity", 1, "Opacity of the overlay from 0 to 1")
+	showCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+	showCmd.Flags().StringVar(&byteBudget, "budget", "", "Keep the output under this size (e.g. 200KB) by tuning resolution, colors and dithering.")
+	showCmd.Flags().StringVarP(&protocolName, "protocol", "p", "ansi", "Output protocol: "+strings.Join(compiledBackendNames(), ", ")+", or 'list' to show all (including termuwu-backend-* programs on PATH).")
+	showCmd.Flags().StringVar(&exportFormat, "export", "", "Write the render in a machine-readable format to stdout instead: json (cell grid), ff (farbfeld) or ppm (scaled image, before color quantization).")
+	showCmd.Flags().StringVar(&muxName, "mux", "auto", "Terminal multiplexer to adapt output for: auto, tmux, screen, zellij or none.")
+	showCmd.Flags().BoolVar(&ansiOptions.NoTrailingNewline, "no-trailing-newline", false, "Don't end the output with a newline (for prompts and status lines).")
+	showCmd.Flags().BoolVar(&ansiOptions.NoFinalReset, "no-final-reset", false, "Don't reset colors after the last line.")
+	showCmd.Flags().IntVar(&inlineRows, "inline", 0, "Render a miniature N lines tall (1 without a value) for shell prompts and status bars: no newline at the end, no status output, at most --width columns.")
+	showCmd.Flags().Lookup("inline").NoOptDefVal = "1"
+	showCmd.Flags().StringVar(&inlineMarkup, "inline-format", "ansi", "How to mark up --inline output: ansi, zsh (%{ %} around escapes), bash (\\001 \\002 around escapes) or tmux (#[fg=...] styles).")
+	showCmd.Flags().BoolVar(&transparent, "transparent", false, "Skip fully transparent cells with cursor movements, so what's on screen shows through (stickers and overlays).")
+	showCmd.Flags().StringVar(&describeMode, "describe", "", "Print a text description (size, dominant colors, OCR text with tesseract, caption from the configured endpoint) instead of the picture, or before it with --describe=before.")
+	showCmd.Flags().Lookup("describe").NoOptDefVal = "only"
+	showCmd.Flags().Lookup("denoise").NoOptDefVal = "3"
+	showCmd.Flags().BoolVar(&highContrast, "high-contrast", false, "Stretch the contrast and use only black, white and fully saturated colors, for low vision and small fonts.")
+	showCmd.Flags().IntVar(&cellScale, "cell-scale", 1, "Draw every sample N times as wide and tall (2 for 2x2 cells per sample) for a bigger, easier to read picture.")
+	showCmd.Flags().StringVar(&chromaKey, "transparent-color", "", "Treat this #rrggbb color as transparent (a logo's solid background), see --transparent.")
+	showCmd.Flags().StringVar(&chromaFuzz, "transparent-fuzz", "0%", "How far a color can be from --transparent-color and still count as it, in percent.")
+	showCmd.Flags().StringVar(&atPosition, "at", "", "Draw at ROW,COL (from 1) with cursor addressing instead of at the cursor, without scrolling.")
+	showCmd.Flags().BoolVar(&ansiOptions.RestoreCursor, "restore-cursor", false, "Save the cursor position before drawing and restore it afterwards.")
+	showCmd.Flags().BoolVar(&noRenderCache, "no-render-cache", false, "Render again instead of reusing the output cached from showing the same image with the same options.")
+	showCmd.Flags().BoolVar(&showStats, "stats", false, "Print render statistics (cells, bytes, colors, timings) to stderr.")
+	showCmd.Flags().BoolVar(&showMap, "map", false, "For geotagged photos, render a map of where the photo was taken next to it and print the coordinates.")
+	showCmd.Flags().IntVar(&mapZoom, "map-zoom", 15, "Zoom level of the --map, from 0 (the world) to 19 (single houses).")
+	showCmd.Flags().StringVar(&mapServer, "map-tiles", defaultTileServer, "Tile server for --map, a URL with {z}, {x} and {y}. Tiles are cached for 30 days.")
 }
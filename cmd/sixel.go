@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"image"
+	"strconv"
+
+	"golang.org/x/image/draw"
+)
+
+type sixelBackend struct{}
+
+func (sixelBackend) Name() string { return "sixel" }
+func (sixelBackend) Description() string {
+	return "DEC sixel graphics (foot, mlterm, xterm -ti vt340, WezTerm)"
+}
+func (sixelBackend) Render(img image.Image, r *ImageRenderer) (string, error) {
+	img = r.filtered(img)
+	cols, rows := protocolCells(img, r)
+	bounds := img.Bounds()
+
+	// sixels are shown at native pixel size, so scale to fill the cell area
+	scale := min(float64(cols*cellPixelWidth)/float64(bounds.Dx()), float64(rows*cellPixelHeight)/float64(bounds.Dy()))
+	if r.PixelArt && scale >= 1 {
+		scale = float64(int(scale)) // whole factors keep sprite pixels even
+	}
+	width := max(int(float64(bounds.Dx())*scale), 1)
+	height := max(int(float64(bounds.Dy())*scale), 1)
+	scaled := image.NewNRGBA(image.Rect(0, 0, width, height))
+	r.interpolator().Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+
+	// quantize every pixel to the 256 color palette, -1 marks transparency
+	pixels := make([]int, width*height)
+	var used [256]bool
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := scaled.NRGBAAt(x, y)
+			if c.A < 128 {
+				pixels[y*width+x] = -1
+				continue
+			}
+			r8, g8, b8 := c.R, c.G, c.B
+			if r.UseDither {
+				r8, g8, b8 = r.applySubtleDither(r8, g8, b8, x, y)
+			}
+			index := r.code256(r8, g8, b8)
+			pixels[y*width+x] = index
+			used[index] = true
+		}
+	}
+
+	// P2=1 leaves unset pixels transparent
+	out := append([]byte{}, "\033P0;1q\"1;1;"...)
+	out = strconv.AppendInt(out, int64(width), 10)
+	out = append(out, ';')
+	out = strconv.AppendInt(out, int64(height), 10)
+	for index := range 256 {
+		if used[index] {
+			c := xtermRGB(index)
+			out = append(out, sixelColors[index]...)
+			out = append(out, ";2;"...)
+			out = strconv.AppendInt(out, int64(c.R)*100/255, 10)
+			out = append(out, ';')
+			out = strconv.AppendInt(out, int64(c.G)*100/255, 10)
+			out = append(out, ';')
+			out = strconv.AppendInt(out, int64(c.B)*100/255, 10)
+		}
+	}
+
+	line := make([]byte, width)
+	for band := 0; band < height; band += 6 {
+		var bandColors [256]bool
+		for y := band; y < min(band+6, height); y++ {
+			for _, index := range pixels[y*width : (y+1)*width] {
+				if index >= 0 {
+					bandColors[index] = true
+				}
+			}
+		}
+
+		first := true
+		for index := range 256 {
+			if !bandColors[index] {
+				continue
+			}
+			if !first {
+				out = append(out, '$') // carriage return, overprint the same band
+			}
+			first = false
+			out = append(out, sixelColors[index]...)
+
+			for x := 0; x < width; x++ {
+				var bits byte
+				for k := 0; k < 6 && band+k < height; k++ {
+					if pixels[(band+k)*width+x] == index {
+						bits |= 1 << k
+					}
+				}
+				line[x] = 63 + bits
+			}
+			out = appendSixelRuns(out, line)
+		}
+		out = append(out, '-') // next band
+	}
+	out = append(out, "\033\\\n"...)
+	return string(out), nil
+}
+
+// sixelColors are the color introducers selecting each palette entry
+var sixelColors [256]string
+
+func init() {
+	for i := range sixelColors {
+		sixelColors[i] = "#" + strconv.Itoa(i)
+	}
+}
+
+// appendSixelRuns appends sixel characters using !<count> repeat compression
+func appendSixelRuns(out []byte, line []byte) []byte {
+	for i := 0; i < len(line); {
+		j := i
+		for j < len(line) && line[j] == line[i] {
+			j++
+		}
+		if run := j - i; run > 3 {
+			out = append(out, '!')
+			out = strconv.AppendInt(out, int64(run), 10)
+			out = append(out, line[i])
+		} else {
+			for k := 0; k < run; k++ {
+				out = append(out, line[i])
+			}
+		}
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"os/signal"
+	"path"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	slideInterval   time.Duration
+	slideTransition string
+	slideFade       time.Duration
+	slideColors     string
+	kenBurns        bool
+)
+
+// slideFrameRate is how many frames a second transitions are drawn at
+const slideFrameRate = 30
+
+var slideshowCmd = &cobra.Command{
+	Use:   "slideshow [directory_or_archive]",
+	Short: "Show the images of a directory or archive one after another",
+	Long: `Show every image of a directory or a zip, rar or tar archive full screen,
+ordered by name, moving on every --interval with a transition:
+
+  termuwu slideshow ~/Pictures/holiday
+  termuwu slideshow photos.zip --interval 3s --transition dissolve
+
+--transition is fade (a crossfade dithered over time), wipe, dissolve or
+none. Transitions mix the cells of the two renders, so only the cells that
+change are sent and they stay smooth over SSH. --ken-burns slowly zooms and
+pans across every image while it's shown.
+
+→ and . skip to the next image, ← and , go back, Space pauses and q quits.
+The show goes round until it's quit; without a terminal on stdin it runs
+through once.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		dimColor := themeColor("dim", color.FgHiBlack).SprintFunc()
+
+		if slideInterval <= 0 {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --interval value:"), slideInterval)
+			return
+		}
+		transition, err := parseTransition(slideTransition)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --transition value:"), err)
+			return
+		}
+		depth, err := parseColorDepth(slideColors)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		book, err := openComic(args[0])
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error opening slideshow:"), err)
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		keys, restore := startControls(ctx)
+		defer restore()
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, 0, 0)
+		renderer.ColorDepth = depth
+		renderer.MaxHeight--     // room for the name line
+		renderer.ExactFit = true // every slide the same size, for the transitions
+		if err := fitGlyphs(renderer); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid config:"), err)
+			return
+		}
+		if keys != nil {
+			fmt.Print("\033[?1049h" + hideCursor) // the alternate screen, to leave the shell as it was
+			defer fmt.Print(showCursor + "\033[?1049l")
+		}
+		fmt.Print("\033[H\033[2J")
+
+		// wait sleeps for d, or until a key comes in; a zero d waits for the key
+		wait := func(d time.Duration) (key playKey, ok bool) {
+			var timeout <-chan time.Time
+			if d > 0 {
+				timeout = time.After(d)
+			}
+			select {
+			case key = <-keys:
+			case <-timeout:
+			case <-ctx.Done():
+				return 0, false
+			}
+			return key, true
+		}
+
+		screen := NewIncrementalRenderer(renderer)
+		frame := &CellGrid{}
+		var shown *CellGrid
+		var source image.Image // what the Ken Burns frames are cut from
+		var scaled *image.RGBA
+		var played time.Duration // of the current slide's Ken Burns slot
+		paused := false
+		for index, previous := 0, -1; ; {
+			key := playKey(0)
+			if index != previous {
+				img, err := book.page(index)
+				if err != nil {
+					screen.Invalidate()
+					fmt.Print("\033[H\033[2J")
+					shown, source = nil, nil
+				} else {
+					if kenBurns {
+						source = kenBurnsSource(renderer, img)
+						img = cropImage(source, kenBurnsView(source.Bounds(), 0, index))
+					}
+					grid := renderer.Rasterize(img)
+					if shown != nil && transition != TransitionNone && slideFade > 0 {
+						frames := max(int(slideFade.Seconds()*slideFrameRate), 1)
+						for f := 1; f < frames && key == 0; f++ {
+							transition.Blend(frame, shown, grid, float64(f)/float64(frames), f)
+							fmt.Print(screen.RenderGrid(frame))
+							var ok bool
+							if key, ok = wait(time.Second / slideFrameRate); !ok {
+								return
+							}
+						}
+					}
+					fmt.Print(screen.RenderGrid(grid))
+					shown = grid
+				}
+
+				line := infoColor(path.Base(book.pages[index])) + fmt.Sprintf(" %d/%d", index+1, len(book.pages))
+				if err != nil {
+					line = errorColor(fmt.Sprintf("❌ %v", err))
+				}
+				fmt.Print(string(appendStatusLine(nil, renderer.MaxHeight+1, line)))
+				previous, played = index, 0
+			}
+			if keys != nil {
+				hints := tr("←/→ image  space pause  q quit")
+				if paused {
+					hints = tr("paused") + "  " + hints
+				}
+				fmt.Print("\0337  " + dimColor(hints) + "\033[K\0338") // saved and restored, so it can change on its own
+			}
+
+			// the slot pans and zooms frame by frame with --ken-burns, picking
+			// up where it was after a pause
+			for key == 0 && kenBurns && source != nil && !paused && played < slideInterval {
+				start := time.Now()
+				view := kenBurnsView(source.Bounds(), float64(played)/float64(slideInterval), index)
+				scaled = renderer.scaleInto(scaled, cropImage(source, view))
+				shown = renderer.rasterizeInto(shown, scaled)
+				fmt.Print(screen.RenderGrid(shown))
+				var ok bool
+				if key, ok = wait(max(time.Second/slideFrameRate-time.Since(start), time.Millisecond)); !ok {
+					return
+				}
+				played += time.Since(start)
+			}
+			if key == 0 {
+				interval := max(slideInterval-played, time.Millisecond)
+				if paused {
+					interval = 0
+				}
+				var ok bool
+				if key, ok = wait(interval); !ok {
+					return
+				}
+			}
+			switch key {
+			case keyQuit:
+				return
+			case keyPause:
+				paused = !paused
+			case keyLeft, keyStepBack:
+				index = (index + len(book.pages) - 1) % len(book.pages)
+			default:
+				if keys == nil && index == len(book.pages)-1 {
+					fmt.Println()
+					return // once through without a terminal
+				}
+				index = (index + 1) % len(book.pages)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(slideshowCmd)
+
+	slideshowCmd.Flags().DurationVar(&slideInterval, "interval", 5*time.Second, "How long every image is shown.")
+	slideshowCmd.Flags().StringVar(&slideTransition, "transition", "fade", "How to go to the next image: fade, wipe, dissolve or none.")
+	slideshowCmd.Flags().DurationVar(&slideFade, "transition-time", 800*time.Millisecond, "How long a transition takes.")
+	slideshowCmd.Flags().BoolVar(&kenBurns, "ken-burns", false, "Slowly pan and zoom across every image while it's shown.")
+	slideshowCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	slideshowCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	slideshowCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	slideshowCmd.Flags().StringVar(&slideColors, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// RenderStats summarizes what a render produced and how long it took
+type RenderStats struct {
+	Cols, Rows   int
+	Bytes        int
+	EscapeBytes  int
+	UniqueColors int
+	LoadTime     time.Duration
+	RenderTime   time.Duration
+	Pool         PoolStats
+}
+
+func (s RenderStats) Cells() int {
+	return s.Cols * s.Rows
+}
+
+// writeStats prints a short report meant for stderr so it never mixes with
+// the rendered image
+func writeStats(out io.Writer, s RenderStats) {
+	label := themeColor("accent", color.FgCyan).SprintFunc()
+
+	escapeShare := 0.0
+	if s.Bytes > 0 {
+		escapeShare = 100 * float64(s.EscapeBytes) / float64(s.Bytes)
+	}
+
+	fmt.Fprintf(out, "📊 %s\n", label("Render stats:"))
+	fmt.Fprintf(out, "   cells:         %d (%dx%d)\n", s.Cells(), s.Cols, s.Rows)
+	fmt.Fprintf(out, "   output bytes:  %d (%s)\n", s.Bytes, formatByteSize(int64(s.Bytes)))
+	fmt.Fprintf(out, "   escape bytes:  %d (%.1f%%)\n", s.EscapeBytes, escapeShare)
+	fmt.Fprintf(out, "   unique colors: %d\n", s.UniqueColors)
+	fmt.Fprintf(out, "   load time:     %s\n", s.LoadTime.Round(time.Microsecond))
+	fmt.Fprintf(out, "   render time:   %s\n", s.RenderTime.Round(time.Microsecond))
+	fmt.Fprintf(out, "   pooled grids:  %d reused, %d allocated\n", s.Pool.Grids-s.Pool.GridAllocs, s.Pool.GridAllocs)
+	fmt.Fprintf(out, "   pooled bufs:   %d reused, %d allocated\n", s.Pool.Buffers-s.Pool.BufferAllocs, s.Pool.BufferAllocs)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stitchDirection string
+	stitchOut       string
+)
+
+// Stitch joins images side by side (horizontal) or top to bottom
+// (vertical). smaller images are centered across the direction and the
+// leftover space stays transparent
+func Stitch(images []image.Image, vertical bool) *image.RGBA {
+	var size image.Point
+	for _, img := range images {
+		s := img.Bounds().Size()
+		if vertical {
+			size.X = max(size.X, s.X)
+			size.Y += s.Y
+		} else {
+			size.X += s.X
+			size.Y = max(size.Y, s.Y)
+		}
+	}
+
+	out := image.NewRGBA(image.Rectangle{Max: size})
+	var at image.Point
+	for _, img := range images {
+		s := img.Bounds().Size()
+		dst := image.Rectangle{Min: at, Max: at.Add(s)}
+		if vertical {
+			dst = dst.Add(image.Pt((size.X-s.X)/2, 0))
+			at.Y += s.Y
+		} else {
+			dst = dst.Add(image.Pt(0, (size.Y-s.Y)/2))
+			at.X += s.X
+		}
+		draw.Draw(out, dst, img, img.Bounds().Min, draw.Src)
+	}
+	return out
+}
+
+var stitchCmd = &cobra.Command{
+	Use:   "stitch [path_or_url...]",
+	Short: "Join images side by side or on top of each other",
+	Long: `Join two or more images, e.g. the halves of a screenshot, and either save
+the result with --out (.png, .jpg, .ff or .ppm) or render it right away.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+
+		var vertical bool
+		switch strings.ToLower(stitchDirection) {
+		case "horizontal", "h":
+		case "vertical", "v":
+			vertical = true
+		default:
+			fmt.Fprintf(statusOut, tr("%s %q (use horizontal or vertical)\n"), errorColor("❌ Invalid --direction value:"), stitchDirection)
+			return
+		}
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+
+		images := make([]image.Image, 0, len(args))
+		for _, arg := range args {
+			img, _, err := loadImage(arg)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+			images = append(images, img)
+		}
+		stitched := Stitch(images, vertical)
+
+		if stitchOut != "" {
+			if err := SaveImage(stitchOut, stitched); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error saving image:"), err)
+				return
+			}
+			noteResult(stitched)
+			fmt.Fprintf(statusOut, "✅ %s %s (%dx%d)\n", successColor("Saved"), stitchOut,
+				stitched.Rect.Dx(), stitched.Rect.Dy())
+			return
+		}
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.ColorDepth = depth
+		if setupConsole().legacy {
+			renderer.Mode = BlockMode
+			renderer.ColorDepth = Color16
+			if err := writeLegacyConsole(renderer.Rasterize(stitched)); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error drawing to console:"), err)
+			}
+			return
+		}
+		fmt.Print(renderer.RenderImage(stitched))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stitchCmd)
+
+	stitchCmd.Flags().StringVarP(&stitchDirection, "direction", "d", "horizontal", "Join the images horizontal (side by side) or vertical (top to bottom).")
+	stitchCmd.Flags().StringVarP(&stitchOut, "out", "o", "", "Save the result to this file instead of rendering it (.png, .jpg, .ff or .ppm).")
+	stitchCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	stitchCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	stitchCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	stitchCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	stitchCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	stitchCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
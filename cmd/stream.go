@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxStreamFrame caps the bytes of a single frame in an image stream so a
+// corrupt length can't make us buffer forever
+const maxStreamFrame = 64 << 20
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	jpegSOI      = []byte{0xff, 0xd8}
+)
+
+// streamFormat says which images an image stream carries
+type streamFormat int
+
+const (
+	streamAuto streamFormat = iota // PNG or JPEG, sniffed per frame
+	streamPNG
+	streamJPEG
+)
+
+func (f streamFormat) String() string {
+	switch f {
+	case streamPNG:
+		return "PNG"
+	case streamJPEG:
+		return "JPEG"
+	}
+	return "PNG or JPEG"
+}
+
+func parseStreamFormat(value string) (streamFormat, error) {
+	switch strings.ToLower(value) {
+	case "auto", "":
+		return streamAuto, nil
+	case "png-stream", "png":
+		return streamPNG, nil
+	case "jpeg-stream", "jpeg", "jpg", "mjpeg":
+		return streamJPEG, nil
+	}
+	return streamAuto, fmt.Errorf("unknown format %q (use png-stream, jpeg-stream or auto)", value)
+}
+
+// readStreamFrame reads the bytes of the next image from a stream of
+// concatenated PNGs and/or JPEGs. the decoders can't be pointed at the
+// stream directly since the JPEG one reads past the end of its image
+func readStreamFrame(r *bufio.Reader, format streamFormat) ([]byte, error) {
+	magic, err := r.Peek(2)
+	if err != nil && len(magic) == 0 {
+		return nil, err // io.EOF between frames is the clean end
+	}
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	switch {
+	case magic[0] == pngSignature[0] && format != streamJPEG:
+		return readPNGFrame(r)
+	case bytes.Equal(magic, jpegSOI) && format != streamPNG:
+		return readJPEGFrame(r)
+	}
+	return nil, fmt.Errorf("stream data isn't a %s frame", format)
+}
+
+// readPNGFrame copies chunks up to and including IEND
+func readPNGFrame(r *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, len(pngSignature), 64<<10)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, noEOF(err)
+	}
+	if !bytes.Equal(frame, pngSignature) {
+		return nil, errors.New("broken PNG signature in stream")
+	}
+	for {
+		var header [8]byte // length and type
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, noEOF(err)
+		}
+		length := int(binary.BigEndian.Uint32(header[:4]))
+		if length > maxStreamFrame-len(frame) {
+			return nil, ErrImageTooLarge
+		}
+		frame = append(frame, header[:]...)
+		start := len(frame)
+		frame = append(frame, make([]byte, length+4)...) // data and CRC
+		if _, err := io.ReadFull(r, frame[start:]); err != nil {
+			return nil, noEOF(err)
+		}
+		if string(header[4:]) == "IEND" {
+			return frame, nil
+		}
+	}
+}
+
+// readJPEGFrame copies markers up to EOI. marker segments carry their
+// length, so an embedded EXIF thumbnail can't end the frame early; in the
+// entropy coded data 0xff is always stuffed or followed by a marker
+func readJPEGFrame(r *bufio.Reader) ([]byte, error) {
+	frame := make([]byte, 0, 64<<10)
+	scanning := false // inside entropy coded data, after SOS
+	for len(frame) < maxStreamFrame {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		frame = append(frame, b)
+		if b != 0xff {
+			if !scanning {
+				return nil, errors.New("broken JPEG marker in stream")
+			}
+			continue
+		}
+
+		marker, err := r.ReadByte()
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		frame = append(frame, marker)
+		switch {
+		case marker == 0xd9: // EOI
+			return frame, nil
+		case scanning && (marker == 0x00 || marker == 0xff || marker >= 0xd0 && marker <= 0xd7):
+			continue // stuffed byte, fill or restart marker
+		case marker == 0xd8 || marker == 0x01:
+			continue // SOI and TEM have no payload
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, noEOF(err)
+		}
+		n := int(binary.BigEndian.Uint16(length[:]))
+		if n < 2 {
+			return nil, errors.New("broken JPEG segment length in stream")
+		}
+		frame = append(frame, length[:]...)
+		start := len(frame)
+		frame = append(frame, make([]byte, n-2)...)
+		if _, err := io.ReadFull(r, frame[start:]); err != nil {
+			return nil, noEOF(err)
+		}
+		scanning = marker == 0xda // SOS
+	}
+	return nil, ErrImageTooLarge
+}
+
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// streamFrame is a decoded frame or the error that ended the stream
+type streamFrame struct {
+	img image.Image
+	err error
+}
+
+// streamReopen says what happens once a stream ends
+type streamReopen int
+
+const (
+	reopenNever   streamReopen = iota // stdin: the end is the end
+	reopenOnEOF                       // FIFO: wait for the next writer
+	reopenOnError                     // network: reconnect after drops and errors
+)
+
+// streamRetry is how long to wait before reconnecting a dropped stream
+const streamRetry = time.Second
+
+// streamSource plays images as another process writes them, e.g. a script
+// pushing charts into a FIFO. frames show up as soon as they're decoded;
+// when rendering falls behind, stale frames are dropped for the newest one
+type streamSource struct {
+	ctx         context.Context
+	frames      chan streamFrame
+	minInterval time.Duration // from --fps-cap, 0 for no cap
+	last        time.Time
+	done        chan struct{} // closed once the reader is closed for good
+}
+
+// newStreamSource starts reading frames from open's reader, reopening it
+// as reopen says. fpsCap > 0 limits how many frames a second are shown
+func newStreamSource(ctx context.Context, open func() (io.ReadCloser, error), format streamFormat, reopen streamReopen, fpsCap float64) *streamSource {
+	s := &streamSource{ctx: ctx, frames: make(chan streamFrame, 1), done: make(chan struct{})}
+	if fpsCap > 0 {
+		s.minInterval = time.Duration(float64(time.Second) / fpsCap)
+	}
+	go s.read(open, format, reopen)
+	return s
+}
+
+func (s *streamSource) read(open func() (io.ReadCloser, error), format streamFormat, reopen streamReopen) {
+	defer close(s.done)
+	connected := false
+	for {
+		reader, err := open()
+		if err == nil {
+			connected = true
+			err = s.readFrames(bufio.NewReaderSize(reader, 64<<10), format)
+			reader.Close()
+		}
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case reopen == reopenOnEOF && err == io.EOF:
+			continue
+		case reopen == reopenOnError && connected:
+			// the first connection has to work, after that keep trying
+			select {
+			case <-time.After(streamRetry):
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+		s.send(streamFrame{err: err})
+		return
+	}
+}
+
+func (s *streamSource) readFrames(r *bufio.Reader, format streamFormat) error {
+	for {
+		data, err := readStreamFrame(r, format)
+		if err != nil {
+			return err
+		}
+		img, _, err := DecodeImage(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("couldn't decode frame: %w", err)
+		}
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+
+		// replace a frame nobody picked up yet instead of queueing it
+		select {
+		case <-s.frames:
+		default:
+		}
+		s.frames <- streamFrame{img: img}
+	}
+}
+
+func (s *streamSource) send(frame streamFrame) {
+	select {
+	case s.frames <- frame:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *streamSource) next() (image.Image, time.Duration, error) {
+	if wait := time.Until(s.last.Add(s.minInterval)); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			return nil, 0, io.EOF
+		}
+	}
+	select {
+	case frame := <-s.frames:
+		s.last = time.Now()
+		return frame.img, 0, frame.err
+	case <-s.ctx.Done():
+		return nil, 0, io.EOF
+	}
+}
+
+// parseStreamInput turns --input into an opener: "fifo:PATH" for a named
+// pipe, reopened whenever its writer goes away, or "-" for stdin
+func parseStreamInput(value string) (open func() (io.ReadCloser, error), reopen streamReopen, err error) {
+	switch {
+	case value == "-":
+		return func() (io.ReadCloser, error) { return io.NopCloser(os.Stdin), nil }, reopenNever, nil
+	case strings.HasPrefix(value, "fifo:") && len(value) > len("fifo:"):
+		path := strings.TrimPrefix(value, "fifo:")
+		return func() (io.ReadCloser, error) { return os.Open(path) }, reopenOnEOF, nil
+	}
+	return nil, reopenNever, fmt.Errorf("unknown input %q (use fifo:PATH or - for stdin)", value)
+}
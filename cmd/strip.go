@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stripOut  string
+	stripKeep []string
+)
+
+var stripCmd = &cobra.Command{
+	Use:   "strip [path_or_url]",
+	Short: "Remove EXIF, GPS, XMP and other metadata before sharing an image",
+	Long: `Write a copy of a JPEG, PNG or WebP image without its metadata: EXIF
+(camera, dates and the GPS position), XMP, IPTC, comments, text chunks and
+anything hidden after the image data. The pixels aren't touched, nothing is
+re-encoded; color profiles stay since they change how the image looks.
+
+  termuwu strip photo.jpg --out clean.jpg
+  termuwu strip photo.jpg --out clean.jpg --keep orientation
+  termuwu strip screenshot.png --out - | xclip -selection clipboard -t image/png
+
+--keep orientation keeps only the EXIF orientation, so photos taken on
+their side still show upright. --out - writes to stdout, and --out can be
+the image itself to clean it in place.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+		if stripOut == "-" {
+			statusOut = stderrStatus() // the image is the output
+		}
+
+		if stripOut == "" {
+			fmt.Fprintln(statusOut, errorColor("❌ Give the path to write the clean image to with --out (- for stdout)."))
+			return
+		}
+		keepOrientation := false
+		for _, keep := range stripKeep {
+			if !strings.EqualFold(keep, "orientation") {
+				fmt.Fprintf(statusOut, "%s %q (use orientation)\n", errorColor("❌ Invalid --keep value:"), keep)
+				return
+			}
+			keepOrientation = true
+		}
+
+		reader, err := openSource(args[0])
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+		var data []byte
+		err = readMapped(func() (err error) { data, err = io.ReadAll(reader); return err })
+		reader.Close()
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+		result, err := StripMetadata(data, keepOrientation)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Can't strip the image:"), err)
+			return
+		}
+
+		target := stripOut
+		if stripOut == "-" {
+			target = tr("stdout")
+			_, err = os.Stdout.Write(result.Data)
+		} else {
+			err = writeFileAtomic(stripOut, result.Data)
+		}
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error writing image:"), err)
+			return
+		}
+
+		if len(result.Removed) == 0 {
+			fmt.Fprintf(statusOut, "✅ %s %s\n", successColor("No metadata found, copied to"), target)
+			return
+		}
+		removed := make([]string, len(result.Removed))
+		for i, part := range result.Removed {
+			removed[i] = fmt.Sprintf("%s (%s)", part.Kind, formatByteSize(int64(part.Size)))
+		}
+		fmt.Fprintf(statusOut, "🧹 %s %s\n", infoColor("Removed:"), strings.Join(removed, ", "))
+		if result.Orientation != 0 {
+			fmt.Fprintf(statusOut, "🧹 %s %d\n", infoColor("Kept the orientation:"), result.Orientation)
+		}
+		fmt.Fprintf(statusOut, tr("✅ %s %s, %s smaller\n"), successColor("Saved to"), target, formatByteSize(int64(len(data)-len(result.Data))))
+	},
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, so the original survives a failed write even when path is
+// the file the data came from
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".termuwu-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly after the rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	} else {
+		os.Chmod(tmp.Name(), 0o644)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func init() {
+	rootCmd.AddCommand(stripCmd)
+
+	stripCmd.Flags().StringVarP(&stripOut, "out", "o", "", "Where to write the clean image, - for stdout (can be the image itself).")
+	stripCmd.Flags().StringSliceVar(&stripKeep, "keep", nil, "Metadata to keep: orientation.")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// shade ramps for the artistic modes, from empty to full. brighter samples
+// get denser glyphs since the ink is drawn on a (usually dark) terminal
+var (
+	halftoneRamp   = []rune{' ', '░', '▒', '▓', '█'}
+	crosshatchRamp = []rune{' ', '.', '-', '/', 'X', '#'}
+)
+
+// parseStyle maps a --style value to a render mode. an empty style keeps
+// the mode picked by the other flags
+func parseStyle(value string, fallback RenderMode) (RenderMode, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return fallback, nil
+	case "halftone":
+		return HalftoneMode, nil
+	case "crosshatch":
+		return CrosshatchMode, nil
+	}
+	return fallback, fmt.Errorf("unknown style %q (use halftone or crosshatch)", value)
+}
+
+// renderShaded draws one sample per cell as a glyph from ramp, picked by
+// the sample's luminance and colored with the sample itself
+func (r *ImageRenderer) renderShaded(grid *CellGrid, img *image.RGBA, width, height int, ramp []rune) {
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sampledColor := sampleAt(img, x, y)
+			r8, g8, b8 := sampledColor.R, sampledColor.G, sampledColor.B
+
+			if r.UseDither {
+				r8, g8, b8 = r.applySubtleDither(r8, g8, b8, x, y)
+			}
+
+			level := int(luminance(Color{R: r8, G: g8, B: b8})) * len(ramp) / 256
+			if level == 0 {
+				continue // stays a blank cell
+			}
+			grid.Set(x, y, Cell{Glyph: ramp[level], FG: r.colorCode(r8, g8, b8), BG: NoColor})
+		}
+	}
+}
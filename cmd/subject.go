@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// saliencySize is the longest side of the grid the subject is looked
+	// for on, enough to find it and quick at any image size
+	saliencySize = 48
+	// subjectSpread is how far from the center (as a share of the image)
+	// a subject is still expected, the sigma of the center weighting
+	subjectSpread = 0.3
+	// backgroundDim is how bright the blurred background stays
+	backgroundDim = 0.6
+)
+
+// subjectMask estimates how much every pixel of img belongs to its subject,
+// 0 to 255, on a grid of at most saliencySize on its longest side. a pixel
+// stands out by how far its color is from the image's average, weighted
+// toward the center where photos usually put their subject
+func subjectMask(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	scale := min(1, saliencySize/float64(max(bounds.Dx(), bounds.Dy())))
+	w, h := max(int(float64(bounds.Dx())*scale), 1), max(int(float64(bounds.Dy())*scale), 1)
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(small, small.Bounds(), img, bounds, draw.Src, nil)
+
+	var mean [3]float64
+	for i := 0; i < len(small.Pix); i += 4 {
+		for c := range mean {
+			mean[c] += float64(small.Pix[i+c]) / float64(w*h)
+		}
+	}
+	saliency := make([]float64, w*h)
+	total, peak := 0.0, 0.0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := small.Pix[small.PixOffset(x, y):]
+			dr, dg, db := float64(p[0])-mean[0], float64(p[1])-mean[1], float64(p[2])-mean[2]
+			cx, cy := (float64(x)+0.5)/float64(w)-0.5, (float64(y)+0.5)/float64(h)-0.5
+			center := math.Exp(-(cx*cx + cy*cy) / (2 * subjectSpread * subjectSpread))
+			s := math.Sqrt(dr*dr+dg*dg+db*db) * center
+			saliency[y*w+x] = s
+			total += s
+			peak = max(peak, s)
+		}
+	}
+
+	// everything clearly above average is subject, with a soft ramp from
+	// the average up to halfway to the most salient pixel
+	mask := image.NewGray(image.Rect(0, 0, w, h))
+	low := total / float64(w*h)
+	high := low + (peak-low)/2
+	for i, s := range saliency {
+		if high > low {
+			mask.Pix[i] = uint8(255 * max(0, min((s-low)/(high-low), 1)))
+		}
+	}
+	return blurGray(blurGray(mask))
+}
+
+// blurGray is a 3x3 box blur, softening the mask's edges
+func blurGray(img *image.Gray) *image.Gray {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	out := image.NewGray(img.Rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum, n := 0, 0
+			for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+					sum += int(img.Pix[ny*img.Stride+nx])
+					n++
+				}
+			}
+			out.Pix[y*out.Stride+x] = uint8(sum / n)
+		}
+	}
+	return out
+}
+
+// BackgroundBlurFilter keeps the subject subjectMask finds and blurs and
+// darkens the rest, like a portrait photo's shallow depth of field, so the
+// subject of a busy photo stands out in a small render
+func BackgroundBlurFilter() Filter {
+	return func(img *image.RGBA) {
+		w, h := img.Rect.Dx(), img.Rect.Dy()
+		if w == 0 || h == 0 {
+			return
+		}
+		mask := image.NewGray(image.Rect(0, 0, w, h))
+		small := subjectMask(img)
+		draw.BiLinear.Scale(mask, mask.Bounds(), small, small.Bounds(), draw.Src, nil)
+
+		// shrinking to a tenth and back is a wide blur for little work
+		tiny := image.NewRGBA(image.Rect(0, 0, max(w/10, 1), max(h/10, 1)))
+		draw.ApproxBiLinear.Scale(tiny, tiny.Bounds(), img, img.Rect, draw.Src, nil)
+		background := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.BiLinear.Scale(background, background.Bounds(), tiny, tiny.Bounds(), draw.Src, nil)
+
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i, j := img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y), background.PixOffset(x, y)
+				m := float64(mask.Pix[y*mask.Stride+x]) / 255
+				for c := 0; c < 3; c++ {
+					v := m*float64(img.Pix[i+c]) + (1-m)*backgroundDim*float64(background.Pix[j+c])
+					img.Pix[i+c] = uint8(min(v+0.5, float64(img.Pix[i+3]))) // premultiplied, so no brighter than alpha
+				}
+			}
+		}
+	}
+}
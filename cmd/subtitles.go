@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"image"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// subtitleLines is how many lines a cue gets, longer ones are cut off.
+// below the frame they're always reserved so the layout doesn't jump
+const subtitleLines = 2
+
+// subtitleCue is one timed piece of text
+type subtitleCue struct {
+	start, end time.Duration
+	text       string
+}
+
+// subtitleMarkup matches the tags SRT and WebVTT put in cue text (<i>,
+// <b>, <c.yellow>, <00:01.000>) and ASS style overrides like {\an8}
+var subtitleMarkup = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+
+// parseSubtitles reads SRT or WebVTT cues. both are blocks separated by
+// blank lines where the "-->" line holds the timing and the text follows;
+// blocks without timing (numbers, WEBVTT headers, NOTE, STYLE) are skipped
+func parseSubtitles(r io.Reader) ([]subtitleCue, error) {
+	var cues []subtitleCue
+	var cue *subtitleCue
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		switch {
+		case line == "":
+			cue = nil
+		case strings.Contains(line, "-->"):
+			from, to, _ := strings.Cut(line, "-->")
+			start, err := parseSubtitleTime(from)
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseSubtitleTime(strings.Fields(to + " ")[0])
+			if err != nil {
+				return nil, err
+			}
+			cues = append(cues, subtitleCue{start: start, end: end})
+			cue = &cues[len(cues)-1]
+		case cue != nil:
+			text := stripControl(html.UnescapeString(subtitleMarkup.ReplaceAllString(line, "")))
+			if cue.text != "" {
+				text = "\n" + text
+			}
+			cue.text += text
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].start < cues[j].start })
+	return cues, nil
+}
+
+// stripControl drops control characters, a subtitle file must not be able
+// to send escape sequences to the terminal
+func stripControl(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r >= 0x7f && r < 0xa0 {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// parseSubtitleTime reads "01:02:03,456" (SRT) or "01:02:03.456" and
+// "02:03.456" (WebVTT)
+func parseSubtitleTime(value string) (time.Duration, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", ".")
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("bad subtitle time %q", value)
+	}
+	var d time.Duration
+	for i, part := range parts {
+		unit := time.Minute
+		if len(parts) == 3 && i == 0 {
+			unit = time.Hour
+		}
+		if i == len(parts)-1 {
+			seconds, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0, fmt.Errorf("bad subtitle time %q", value)
+			}
+			d += time.Duration(seconds * float64(time.Second))
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("bad subtitle time %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+// loadSubtitles reads a subtitle file
+func loadSubtitles(path string) ([]subtitleCue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseSubtitles(file)
+}
+
+// findSubtitles looks for subtitles that go with a local video: a .srt or
+// .vtt file next to it with the same name, or else the first subtitle
+// track inside it, pulled out with ffmpeg. no subtitles is not an error
+func findSubtitles(ctx context.Context, video string) []subtitleCue {
+	base := strings.TrimSuffix(video, filepath.Ext(video))
+	for _, ext := range []string{".srt", ".vtt"} {
+		if cues, err := loadSubtitles(base + ext); err == nil {
+			return cues
+		}
+	}
+
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil
+	}
+	output, err := exec.CommandContext(ctx, path, "-v", "quiet", "-nostdin", "-i", video, "-map", "0:s:0", "-f", "srt", "-").Output()
+	if err != nil {
+		return nil // no subtitle track
+	}
+	cues, _ := parseSubtitles(bytes.NewReader(output))
+	return cues
+}
+
+// subtitleStyle says where and in which colors subtitles are drawn. the
+// colors are codes for the frame's color depth, NoColor keeps the
+// terminal's default
+type subtitleStyle struct {
+	over   bool // on top of the frame's bottom rows instead of below it
+	fg, bg int
+}
+
+// subtitledSource adds subtitles to the frames of a video. the clock
+// starts with the first frame, offset by where playback was seeked to
+type subtitledSource struct {
+	frameSource
+	cues    []subtitleCue
+	style   subtitleStyle
+	offset  time.Duration
+	started time.Time
+}
+
+func (s *subtitledSource) next() (image.Image, time.Duration, error) {
+	img, delay, err := s.frameSource.next()
+	if s.started.IsZero() && err == nil {
+		s.started = time.Now()
+	}
+	return img, delay, err
+}
+
+func (s *subtitledSource) unwrap() frameSource {
+	return s.frameSource
+}
+
+// current returns the text of the cues showing right now, going by the
+// video's position when it can seek
+func (s *subtitledSource) current() []string {
+	now := s.offset + time.Since(s.started)
+	if seeker := findSeeker(s.frameSource); seeker != nil {
+		now = seeker.position()
+	}
+	var lines []string
+	for _, cue := range s.cues {
+		if cue.start > now {
+			break
+		}
+		if now < cue.end {
+			lines = append(lines, strings.Split(cue.text, "\n")...)
+		}
+	}
+	return lines
+}
+
+// appendCaption implements captionSource, centering the current lines
+// on the frame
+func (s *subtitledSource) appendCaption(buf []byte, grid *CellGrid) ([]byte, int) {
+	lines := s.current()
+	if len(lines) > subtitleLines {
+		lines = lines[len(lines)-subtitleLines:] // the newest cues
+	}
+
+	if s.style.over {
+		if len(lines) == 0 || grid.Height < len(lines) {
+			return buf, 0
+		}
+		buf = append(buf, "\033["...)
+		buf = strconv.AppendInt(buf, int64(len(lines)), 10)
+		buf = append(buf, 'F') // CPL into the frame's bottom rows
+		for _, line := range lines {
+			buf = s.appendLine(buf, line, grid, false)
+		}
+		return buf, 0
+	}
+
+	for i := 0; i < subtitleLines; i++ {
+		line := ""
+		if i < len(lines) {
+			line = lines[i]
+		}
+		buf = s.appendLine(buf, line, grid, true)
+	}
+	return buf, subtitleLines
+}
+
+// appendLine writes one centered line and moves to the next. clear blanks
+// the rest of the line first, for lines below the frame that hold the
+// previous frame's subtitles
+func (s *subtitledSource) appendLine(buf []byte, line string, grid *CellGrid, clear bool) []byte {
+	if clear {
+		buf = append(buf, "\r\033[K"...)
+	}
+	if line != "" {
+		line = runewidth.Truncate(line, grid.Width, "…")
+		if pad := (grid.Width - runewidth.StringWidth(line)) / 2; pad > 0 {
+			buf = append(buf, "\033["...)
+			buf = strconv.AppendInt(buf, int64(pad+1), 10)
+			buf = append(buf, 'G') // CHA
+		}
+		buf = appendSGRColor(buf, grid.Depth, s.style.fg, false)
+		buf = appendSGRColor(buf, grid.Depth, s.style.bg, true)
+		buf = append(buf, line...)
+		buf = append(buf, "\033[0m"...)
+	}
+	return append(buf, '\n')
+}
+
+// parseSubtitleStyle reads --sub-position, --sub-color and --sub-bg into
+// color codes for r's color depth
+func parseSubtitleStyle(position, fg, bg string, r *ImageRenderer) (subtitleStyle, error) {
+	style := subtitleStyle{fg: NoColor, bg: NoColor}
+	switch strings.ToLower(position) {
+	case "below":
+	case "over":
+		style.over = true
+	default:
+		return style, fmt.Errorf("unknown position %q (use below or over)", position)
+	}
+	for _, c := range []struct {
+		value string
+		code  *int
+	}{{fg, &style.fg}, {bg, &style.bg}} {
+		if c.value == "" {
+			continue
+		}
+		r8, g8, b8, err := parseHexColor(c.value)
+		if err != nil {
+			return style, err
+		}
+		*c.code = r.colorCode(r8, g8, b8)
+	}
+	return style, nil
+}
+
+// parseHexColor reads a "#rrggbb" color
+func parseHexColor(value string) (r, g, b uint8, err error) {
+	hex := strings.TrimPrefix(value, "#")
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return 0, 0, 0, fmt.Errorf("color %q should look like #rrggbb", value)
+	}
+	return uint8(n >> 16), uint8(n >> 8), uint8(n), nil
+}
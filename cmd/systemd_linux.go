@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// sdNotify tells systemd how the service is doing (READY=1, STOPPING=1 and
+// so on) when it runs under Type=notify, doing nothing otherwise
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // an abstract socket
+	}
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return // nothing to be done about it, systemd will say the service didn't start
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// sdReloading is the message saying a reload started, with the time
+// Type=notify-reload wants along with it
+func sdReloading() string {
+	var now unix.Timespec
+	unix.ClockGettime(unix.CLOCK_MONOTONIC, &now)
+	return fmt.Sprintf("RELOADING=1\nMONOTONIC_USEC=%d", now.Nano()/1000)
+}
+
+// sdWatchdog is how often systemd wants to hear WATCHDOG=1, half of
+// WatchdogSec, 0 when the service has no watchdog
+func sdWatchdog() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0 // it's for another process
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
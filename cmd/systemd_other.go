@@ -0,0 +1,17 @@
+//go:build !linux
+
+package cmd
+
+import "time"
+
+// sdNotify does nothing without systemd
+func sdNotify(state string) {}
+
+func sdReloading() string {
+	return "RELOADING=1"
+}
+
+// sdWatchdog is 0 without systemd
+func sdWatchdog() time.Duration {
+	return 0
+}
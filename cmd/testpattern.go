@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var patternName string
+
+var patternHints = map[string]string{
+	"bars":       "the seven bars should be clearly different colors",
+	"gradient":   "look for banding in the hues and the gray ramp",
+	"mandelbrot": "a good demo for the different modes",
+	"grid":       "the cells should be square and the circle round, otherwise your font isn't the 1:2 cell termuwu assumes",
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Render a built-in test pattern",
+	Long: `Render a synthetic test pattern, no input file needed. Use it to check the
+aspect ratio, compare color depths or demo the rendering modes.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		generate, ok := testPatterns[strings.ToLower(patternName)]
+		if !ok {
+			names := make([]string, 0, len(testPatterns))
+			for name := range testPatterns {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Fprintf(statusOut, tr("%s %q (use %s)\n"), errorColor("❌ Unknown pattern:"), patternName, strings.Join(names, ", "))
+			return
+		}
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.ColorDepth = depth
+		if setupConsole().legacy {
+			renderer.Mode = BlockMode
+			renderer.ColorDepth = Color16
+			if err := writeLegacyConsole(renderer.Rasterize(generate())); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error drawing to console:"), err)
+			}
+		} else {
+			fmt.Print(renderer.RenderImage(generate()))
+		}
+		fmt.Fprintf(statusOut, "🧪 %s %s, %s, %s: %s\n", infoColor("Pattern:"), strings.ToLower(patternName),
+			renderer.Mode, renderer.ColorDepth, patternHints[strings.ToLower(patternName)])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+
+	testCmd.Flags().StringVar(&patternName, "pattern", "bars", "Pattern to render: bars, gradient, mandelbrot or grid.")
+	testCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	testCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	testCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	testCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	testCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	testCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	texEngine string
+	texSize   float64
+	texDPI    float64
+	texFG     string
+	texBG     string
+)
+
+var texCmd = &cobra.Command{
+	Use:   "tex [expression]",
+	Short: "Render a LaTeX math snippet",
+	Long: `Typeset a LaTeX math expression and render it like an image, to check a
+formula without leaving the terminal:
+
+  termuwu tex '\frac{a}{b}'
+  termuwu tex '\sqrt{\frac{a+b}{2\pi}}' --size 48
+  echo '\sum_{k=1}^{n} k = \frac{n(n+1)}{2}' | termuwu tex -
+
+The expression is math mode already, no $ needed. The builtin engine draws
+fractions, roots, binomials, greek letters, accents and the big operators
+without anything installed, but no sub- or superscripts yet. --engine latex
+typesets with latex and dvipng instead, for everything else (scripts,
+matrices, \begin{...} environments); auto, the default, falls back to them
+when the builtin engine can't draw the expression and they're installed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		infoColor := localized(themeColor("info", color.FgYellow))
+
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		if texSize <= 0 || texDPI <= 0 {
+			fmt.Fprintln(statusOut, errorColor("❌ --size and --dpi must be positive."))
+			return
+		}
+		fg, err := hexColor(texFG)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --fg value:"), err)
+			return
+		}
+		bg, err := hexColor(texBG)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --bg value:"), err)
+			return
+		}
+
+		expr := args[0]
+		if expr == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error reading stdin:"), err)
+				return
+			}
+			expr = string(data)
+		}
+		expr = strings.TrimSpace(expr)
+		if expr == "" {
+			fmt.Fprintln(statusOut, errorColor("❌ Nothing to render."))
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		img, engine, err := texImage(ctx, expr, strings.ToLower(texEngine), texSize, texDPI, fg, bg)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error rendering math:"), err)
+			return
+		}
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.ColorDepth = depth
+		if setupConsole().legacy {
+			renderer.Mode = BlockMode
+			renderer.ColorDepth = Color16
+			if err := writeLegacyConsole(renderer.Rasterize(img)); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error drawing to console:"), err)
+			}
+		} else {
+			fmt.Print(renderer.RenderImage(img))
+		}
+		fmt.Fprintf(statusOut, "🧮 %s %s\n", infoColor("Engine:"), engine)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(texCmd)
+
+	texCmd.Flags().StringVarP(&texEngine, "engine", "e", "auto", "Typesetting engine: auto, builtin or latex (needs latex and dvipng).")
+	texCmd.Flags().Float64VarP(&texSize, "size", "s", 24, "Font size in points.")
+	texCmd.Flags().Float64Var(&texDPI, "dpi", 144, "Resolution the formula is drawn at before it's scaled to the terminal.")
+	texCmd.Flags().StringVar(&texFG, "fg", "#ffffff", "Formula color as #rrggbb.")
+	texCmd.Flags().StringVar(&texBG, "bg", "#000000", "Background color as #rrggbb.")
+	texCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	texCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	texCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	texCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	texCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	texCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
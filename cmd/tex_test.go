@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestTexBuiltin checks the embedded engine draws a fraction in the
+// formula color and explains what it can't draw
+func TestTexBuiltin(t *testing.T) {
+	fg, bg := image.NewUniform(color.RGBA{255, 255, 255, 255}), image.NewUniform(color.RGBA{0, 0, 0, 255})
+	img, err := texBuiltin(`\frac{a}{b}`, 24, 72, fg, bg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit := 0
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r > 0x8000 {
+				lit++
+			}
+		}
+	}
+	if lit == 0 || bounds.Dy() < bounds.Dx() {
+		t.Errorf("fraction drew %d pixels in %v", lit, bounds)
+	}
+	if _, err := texBuiltin("x^2", 24, 72, fg, bg); err == nil || !strings.Contains(err.Error(), "superscripts") {
+		t.Errorf("x^2 gave %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-latex/latex/drawtex"
+	"github.com/go-latex/latex/mtex"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// texImage draws the math in expr on its own: "builtin" with the embedded
+// mtex rasterizer, "latex" through latex and dvipng, "auto" with mtex and
+// latex, when it's installed, for what mtex can't draw
+func texImage(ctx context.Context, expr, engine string, size, dpi float64, fg, bg *image.Uniform) (img image.Image, used string, err error) {
+	switch engine {
+	case "builtin":
+		img, err = texBuiltin(expr, size, dpi, fg, bg)
+		return img, engine, err
+	case "latex":
+		img, err = texLatex(ctx, expr, size, dpi, fg, bg)
+		return img, engine, err
+	case "auto":
+		img, err = texBuiltin(expr, size, dpi, fg, bg)
+		if err == nil || !haveLatex() {
+			return img, "builtin", err
+		}
+		img, err = texLatex(ctx, expr, size, dpi, fg, bg)
+		return img, "latex", err
+	}
+	return nil, "", fmt.Errorf("unknown engine %q (use auto, builtin or latex)", engine)
+}
+
+// mathMode wraps expr in $...$ unless it already switches into math itself
+func mathMode(expr string) string {
+	if strings.Contains(expr, "$") {
+		return expr
+	}
+	return "$" + expr + "$"
+}
+
+// texCanvas draws mtex's glyphs and rules straight into an RGBA image
+type texCanvas struct {
+	fg, bg *image.Uniform
+	img    *image.RGBA
+}
+
+func (c *texCanvas) Render(width, height, dpi float64, cnv *drawtex.Canvas) error {
+	margin := int(dpi / 12)
+	c.img = image.NewRGBA(image.Rect(0, 0, int(math.Ceil(width*dpi))+2*margin, int(math.Ceil(height*dpi))+2*margin))
+	draw.Draw(c.img, c.img.Bounds(), c.bg, image.Point{}, draw.Src)
+	scale := dpi / 72
+	for _, op := range cnv.Ops() {
+		switch op := op.(type) {
+		case drawtex.GlyphOp:
+			face, err := opentype.NewFace(op.Glyph.Font, &opentype.FaceOptions{Size: op.Glyph.Size, DPI: dpi, Hinting: font.HintingNone})
+			if err != nil {
+				return err
+			}
+			drawer := &font.Drawer{Dst: c.img, Src: c.fg, Face: face,
+				Dot: fixed.Point26_6{X: fixed.Int26_6((op.X*scale + float64(margin)) * 64), Y: fixed.Int26_6((op.Y*scale + float64(margin)) * 64)}}
+			drawer.DrawString(op.Glyph.Symbol)
+			face.Close()
+		case drawtex.RectOp:
+			rect := image.Rect(int(op.X1*scale), int(op.Y1*scale), int(math.Ceil(op.X2*scale)), int(math.Ceil(op.Y2*scale)))
+			if rect.Dy() == 0 {
+				rect.Max.Y++ // fraction bars thinner than a pixel
+			}
+			draw.Draw(c.img, rect.Add(image.Pt(margin, margin)), c.fg, image.Point{}, draw.Src)
+		}
+	}
+	return nil
+}
+
+// texBuiltin renders with mtex, which knows fractions, roots, binomials,
+// greek letters, accents and the big operators but no sub- or superscripts,
+// and panics on some of what it doesn't know
+func texBuiltin(expr string, size, dpi float64, fg, bg *image.Uniform) (img image.Image, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if message := fmt.Sprint(r); strings.Contains(message, "*ast.Sub") || strings.Contains(message, "*ast.Sup") {
+				err = errors.New("the builtin engine can't draw sub- and superscripts yet, latex and dvipng can (--engine latex)")
+			} else {
+				err = fmt.Errorf("the builtin engine can't draw this: %v", r)
+			}
+		}
+	}()
+	canvas := &texCanvas{fg: fg, bg: bg}
+	if err := mtex.Render(canvas, mathMode(expr), size, dpi, nil); err != nil {
+		return nil, err
+	}
+	return canvas.img, nil
+}
+
+func haveLatex() bool {
+	_, latexErr := exec.LookPath("latex")
+	_, dvipngErr := exec.LookPath("dvipng")
+	return latexErr == nil && dvipngErr == nil
+}
+
+// texLatex typesets expr with the installed latex as a standalone display
+// formula and turns it into an image with dvipng
+func texLatex(ctx context.Context, expr string, size, dpi float64, fg, bg *image.Uniform) (image.Image, error) {
+	if !haveLatex() {
+		return nil, errors.New("latex and dvipng need to be installed for --engine latex")
+	}
+	dir, err := os.MkdirTemp("", "termuwu-tex-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	body := strings.Trim(mathMode(expr), "$")
+	document := `\documentclass[border=2pt]{standalone}
+\usepackage{amsmath,amssymb}
+\begin{document}
+$\displaystyle ` + body + `$
+\end{document}
+`
+	if err := os.WriteFile(filepath.Join(dir, "snippet.tex"), []byte(document), 0o644); err != nil {
+		return nil, err
+	}
+	latex := exec.CommandContext(ctx, "latex", "-interaction=nonstopmode", "-halt-on-error", "snippet.tex")
+	latex.Dir = dir
+	if out, err := latex.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("latex failed: %s", latexError(string(out), err))
+	}
+	// standalone typesets at 10pt, dvipng's -D scales it to size
+	dvipng := exec.CommandContext(ctx, "dvipng", "-D", fmt.Sprint(int(dpi*size/10)), "-T", "tight",
+		"-fg", dvipngColor(fg), "-bg", dvipngColor(bg), "-o", "snippet.png", "snippet.dvi")
+	dvipng.Dir = dir
+	if out, err := dvipng.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("dvipng failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	file, err := os.Open(filepath.Join(dir, "snippet.png"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// latexError picks the "! ..." error line out of latex's log
+func latexError(log string, err error) string {
+	for _, line := range strings.Split(log, "\n") {
+		if strings.HasPrefix(line, "! ") {
+			return strings.TrimPrefix(line, "! ")
+		}
+	}
+	return err.Error()
+}
+
+// dvipngColor is a color the way dvipng's -fg and -bg take it
+func dvipngColor(c *image.Uniform) string {
+	rgba := color.RGBAModel.Convert(c.C).(color.RGBA)
+	return fmt.Sprintf("rgb %.3f %.3f %.3f", float64(rgba.R)/255, float64(rgba.G)/255, float64(rgba.B)/255)
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// ThemeConfig styles termuwu's own output around the image: the colors of
+// the status lines by role, the emoji they start with and the progress bars
+type ThemeConfig struct {
+	// Colors maps a role (error, success, info, accent, name, dim, url, bar,
+	// bar-empty) to words like "red bold", "hi-cyan underline" or "#ff8800"
+	Colors   map[string]string `json:"colors"`
+	Emoji    map[string]string `json:"emoji"` // emoji to what's printed instead, "" to leave it out
+	Progress ProgressTheme     `json:"progress"`
+}
+
+// ProgressTheme holds the characters progress bars are drawn with, empty
+// ones keep the bar's own
+type ProgressTheme struct {
+	Filled string `json:"filled"`
+	Head   string `json:"head"`
+	Empty  string `json:"empty"`
+	Start  string `json:"start"`
+	End    string `json:"end"`
+}
+
+// plainChrome is --plain: no colors, no progress bar while downloading and
+// ASCII in place of the status emoji, for logs and CI
+var plainChrome bool
+
+// plainEmoji stands in for the status emoji with --plain, the ones that
+// only decorate are left out
+var plainEmoji = map[string]string{
+	"❌": "[error]", "✅": "[ok]", "⚠️": "[warn]",
+	"📸": "", "💡": "", "🧠": "", "📡": "", "🔎": "", "💬": "", "🎬": "", "🕹️": "", "📉": "", "🔌": "", "📊": "", "🧪": "", "🖼️": "", "📚": "", "🔤": "", "🧮": "", "📍": "", "🗺️": "", "📦": "", "🗑️": "", "🧹": "", "✂️": "", "📈": "", "🔐": "", "⚡": "", "🔺": "", "🗄️": "",
+}
+
+// plainProgress draws the playback line in ASCII with --plain
+var plainProgress = ProgressTheme{Filled: "=", Head: ">", Empty: "-"}
+
+var colorNames = map[string]color.Attribute{
+	"black": color.FgBlack, "red": color.FgRed, "green": color.FgGreen, "yellow": color.FgYellow,
+	"blue": color.FgBlue, "magenta": color.FgMagenta, "cyan": color.FgCyan, "white": color.FgWhite,
+}
+
+var colorAttributes = map[string]color.Attribute{
+	"bold": color.Bold, "faint": color.Faint, "italic": color.Italic, "underline": color.Underline,
+	"blink": color.BlinkSlow, "reverse": color.ReverseVideo,
+}
+
+// parseColorSpec reads a theme color: color names (hi- for the bright
+// ones, bg- for the background), attributes and #rrggbb, or none
+func parseColorSpec(spec string) (*color.Color, error) {
+	c := color.New()
+	for _, word := range strings.Fields(strings.ToLower(spec)) {
+		if word == "none" {
+			continue
+		}
+		if attr, ok := colorAttributes[word]; ok {
+			c.Add(attr)
+			continue
+		}
+		name, background := strings.CutPrefix(word, "bg-")
+		if strings.HasPrefix(name, "#") {
+			var r, g, b int
+			if len(name) != 7 {
+				return nil, fmt.Errorf("invalid color %q, want #rrggbb", word)
+			}
+			if _, err := fmt.Sscanf(name, "#%02x%02x%02x", &r, &g, &b); err != nil {
+				return nil, fmt.Errorf("invalid color %q, want #rrggbb", word)
+			}
+			if background {
+				c.AddBgRGB(r, g, b)
+			} else {
+				c.AddRGB(r, g, b)
+			}
+			continue
+		}
+		name, bright := strings.CutPrefix(name, "hi-")
+		attr, ok := colorNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q", word)
+		}
+		if bright {
+			attr += color.FgHiBlack - color.FgBlack
+		}
+		if background {
+			attr += color.BgBlack - color.FgBlack
+		}
+		c.Add(attr)
+	}
+	return c, nil
+}
+
+// themeColor is the theme's color for role, or defaults when it has none
+func themeColor(role string, defaults ...color.Attribute) *color.Color {
+	if spec, ok := config.Theme.Colors[role]; ok {
+		if c, err := parseColorSpec(spec); err == nil {
+			return c
+		}
+	}
+	return color.New(defaults...)
+}
+
+// progressTheme is the theme's progress bar with the characters it leaves
+// out taken from defaults
+func progressTheme(defaults ProgressTheme) ProgressTheme {
+	p := config.Theme.Progress
+	if plainChrome {
+		p = plainProgress
+	}
+	for _, field := range []struct{ value, fallback *string }{
+		{&p.Filled, &defaults.Filled}, {&p.Head, &defaults.Head}, {&p.Empty, &defaults.Empty},
+		{&p.Start, &defaults.Start}, {&p.End, &defaults.End},
+	} {
+		if *field.value == "" {
+			*field.value = *field.fallback
+		}
+	}
+	return p
+}
+
+// emojiReplacer swaps the status emoji as the theme and --plain say, nil
+// when nothing changes
+var emojiReplacer *strings.Replacer
+
+// setupChrome applies the config's theme and --plain, it runs once the
+// config is loaded
+func setupChrome() error {
+	for role, spec := range config.Theme.Colors {
+		if _, err := parseColorSpec(spec); err != nil {
+			return fmt.Errorf("theme color %s: %w", role, err)
+		}
+	}
+	replacements := make(map[string]string)
+	if plainChrome {
+		color.NoColor = true
+		for emoji, text := range plainEmoji {
+			replacements[emoji] = text
+		}
+	}
+	for emoji, text := range config.Theme.Emoji {
+		replacements[emoji] = text
+	}
+	emojiReplacer = nil
+	if len(replacements) > 0 {
+		emoji := make([]string, 0, len(replacements))
+		for e := range replacements {
+			emoji = append(emoji, e)
+		}
+		sort.Slice(emoji, func(i, j int) bool { return len(emoji[i]) > len(emoji[j]) })
+		var pairs []string
+		for _, e := range emoji {
+			text := replacements[e]
+			if text == "" {
+				// the spaces after a left out emoji go with it
+				pairs = append(pairs, e+"  ", "", e+" ", "", e, "")
+			} else {
+				pairs = append(pairs, e+"  ", text+" ", e, text)
+			}
+		}
+		emojiReplacer = strings.NewReplacer(pairs...)
+	}
+	statusOut = chrome(statusOut)
+	return nil
+}
+
+// chromeWriter writes status lines with the theme's emoji, noting how the
+// command went for --notify
+type chromeWriter struct {
+	w io.Writer
+}
+
+func (c chromeWriter) Write(p []byte) (int, error) {
+	if notifyFlag {
+		noteStatus(p)
+	}
+	if emojiReplacer == nil {
+		return c.w.Write(p)
+	}
+	if _, err := emojiReplacer.WriteString(c.w, string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// chrome wraps a writer for status lines in the theme, w itself when the
+// theme keeps the emoji and nothing is listening for --notify
+func chrome(w io.Writer) io.Writer {
+	if _, ok := w.(chromeWriter); ok || (emojiReplacer == nil && !notifyFlag) {
+		return w
+	}
+	return chromeWriter{w}
+}
+
+// stderrStatus is where status lines go when stdout carries the output
+func stderrStatus() io.Writer {
+	return chrome(os.Stderr)
+}
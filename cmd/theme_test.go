@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// TestPlainChrome checks --plain turns the status emoji into ASCII and
+// drops the decorative ones with their spacing
+func TestPlainChrome(t *testing.T) {
+	defer func(out io.Writer, noColor bool) {
+		statusOut, plainChrome, emojiReplacer, color.NoColor = out, false, nil, noColor
+	}(statusOut, color.NoColor)
+	var buf bytes.Buffer
+	statusOut, plainChrome = &buf, true
+	if err := setupChrome(); err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range map[string]string{
+		"❌ Error loading image: nope\n":  "[error] Error loading image: nope\n",
+		"⚠️  Hook error: exit 1\n":       "[warn] Hook error: exit 1\n",
+		"🕹️  Pixel art detected\n":       "Pixel art detected\n",
+		"📸 Loading image from path: a\n": "Loading image from path: a\n",
+	} {
+		buf.Reset()
+		statusOut.Write([]byte(line))
+		if buf.String() != want {
+			t.Errorf("%q came out as %q, want %q", line, buf.String(), want)
+		}
+	}
+}
+
+// TestNotifyStatus checks --notify keeps the last line saying how the
+// command went, with its colors and emoji taken off
+func TestNotifyStatus(t *testing.T) {
+	defer func(out io.Writer) {
+		statusOut, notifyFlag, emojiReplacer = out, false, nil
+		notice.line, notice.failed = "", false
+	}(statusOut)
+	var buf bytes.Buffer
+	statusOut, notifyFlag = &buf, true
+	if err := setupChrome(); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		line, want string
+		failed     bool
+	}{
+		{"\033[1;31m❌ Error loading image:\033[0m nope\n", "Error loading image: nope", true},
+		{"📸 Loading image from path: a\n", "Error loading image: nope", true},
+		{"\033[1;32m✅ Saved to\033[0m out.png\n", "Saved to out.png", false},
+	} {
+		buf.Reset()
+		statusOut.Write([]byte(step.line))
+		if buf.String() != step.line {
+			t.Errorf("%q came out as %q", step.line, buf.String())
+		}
+		if notice.line != step.want || notice.failed != step.failed {
+			t.Errorf("after %q noted %q (failed %v), want %q (failed %v)", step.line, notice.line, notice.failed, step.want, step.failed)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/image/draw"
+)
+
+var (
+	thumbSize  int
+	thumbOut   string
+	thumbCache bool
+)
+
+// thumbnailSizes are the freedesktop cache directories, smallest first
+var thumbnailSizes = []struct {
+	dir  string
+	size int
+}{
+	{"normal", 128},
+	{"large", 256},
+	{"x-large", 512},
+	{"xx-large", 1024},
+}
+
+// Thumbnail shrinks img to fit a size x size box with the high quality
+// (Catmull-Rom) scaler. smaller images are kept as they are
+func Thumbnail(img image.Image, size int) image.Image {
+	return fitWithin(img, size, size, draw.CatmullRom)
+}
+
+// thumbnailCachePath is where the freedesktop thumbnail spec wants the
+// thumbnail for uri: the md5 of the uri in the cache directory that fits size
+func thumbnailCachePath(uri string, size int) (string, int, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", 0, err
+		}
+		base = filepath.Join(home, ".cache")
+	}
+
+	bucket := thumbnailSizes[len(thumbnailSizes)-1]
+	for _, s := range thumbnailSizes {
+		if size <= s.size {
+			bucket = s
+			break
+		}
+	}
+	sum := md5.Sum([]byte(uri))
+	return filepath.Join(base, "thumbnails", bucket.dir, hex.EncodeToString(sum[:])+".png"), bucket.size, nil
+}
+
+// writeThumbnail saves img as a PNG carrying the Thumb:: text chunks the
+// spec uses to tell whether a thumbnail is stale. it's written to a temp
+// file first so file managers never see half a thumbnail
+func writeThumbnail(path string, img image.Image, text [][2]string) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+
+	// tEXt chunks go right after IHDR: the 8 byte signature, then IHDR's
+	// length, type, 13 bytes of data and CRC
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+	encoded := buf.Bytes()
+	out := append([]byte(nil), encoded[:ihdrEnd]...)
+	for _, kv := range text {
+		out = appendPNGChunk(out, "tEXt", []byte(kv[0]+"\x00"+kv[1]))
+	}
+	out = append(out, encoded[ihdrEnd:]...)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "termuwu-*.png")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly after the rename
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func appendPNGChunk(out []byte, kind string, data []byte) []byte {
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	start := len(out)
+	out = append(out, kind...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start:]))
+}
+
+var thumbCmd = &cobra.Command{
+	Use:   "thumb [path_or_url]",
+	Short: "Make a thumbnail of an image",
+	Long: `Shrink an image to fit --size pixels with a high quality scaler and save it
+with --out, store it in the freedesktop thumbnail cache with --cache, or
+render it when neither is given.
+
+To use termuwu as a thumbnailer for file managers, drop this into
+~/.local/share/thumbnailers/termuwu.thumbnailer:
+
+  [Thumbnailer Entry]
+  Exec=termuwu thumb %i --size %s --out %o
+  MimeType=image/png;image/jpeg;image/gif;image/webp;`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+		successColor := localized(themeColor("success", color.FgGreen, color.Bold))
+
+		if thumbSize <= 0 {
+			fmt.Fprintf(statusOut, "%s %d\n", errorColor("❌ Invalid --size value:"), thumbSize)
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+
+		source := args[0]
+		remote := strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+		var info os.FileInfo
+		if thumbCache {
+			if remote {
+				fmt.Fprintln(statusOut, errorColor("❌ --cache only works with local files."))
+				return
+			}
+			if source, err = filepath.Abs(source); err == nil {
+				info, err = os.Stat(source)
+			}
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+				return
+			}
+		}
+
+		img, format, err := loadImage(source)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error loading image:"), err)
+			return
+		}
+
+		if thumbCache {
+			uri := (&url.URL{Scheme: "file", Path: source}).String()
+			path, size, err := thumbnailCachePath(uri, thumbSize)
+			if err == nil {
+				err = writeThumbnail(path, Thumbnail(img, size), [][2]string{
+					{"Thumb::URI", uri},
+					{"Thumb::MTime", strconv.FormatInt(info.ModTime().Unix(), 10)},
+					{"Thumb::Size", strconv.FormatInt(info.Size(), 10)},
+					{"Thumb::Mime", "image/" + format},
+					{"Software", "termuwu"},
+				})
+			}
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error writing thumbnail:"), err)
+				return
+			}
+			fmt.Fprintf(statusOut, "✅ %s %s\n", successColor("Cached"), path)
+		}
+
+		thumb := Thumbnail(img, thumbSize)
+		if thumbOut != "" {
+			if err := SaveImage(thumbOut, thumb); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error saving image:"), err)
+				return
+			}
+			noteResult(thumb)
+			fmt.Fprintf(statusOut, "✅ %s %s (%dx%d)\n", successColor("Saved"), thumbOut,
+				thumb.Bounds().Dx(), thumb.Bounds().Dy())
+		}
+		if thumbOut != "" || thumbCache {
+			return
+		}
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, 0, 0)
+		renderer.ColorDepth = depth
+		if setupConsole().legacy {
+			renderer.Mode = BlockMode
+			renderer.ColorDepth = Color16
+			if err := writeLegacyConsole(renderer.Rasterize(thumb)); err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Error drawing to console:"), err)
+			}
+			return
+		}
+		fmt.Print(renderer.RenderImage(thumb))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(thumbCmd)
+
+	thumbCmd.Flags().IntVarP(&thumbSize, "size", "s", 256, "Longest side of the thumbnail in pixels (smaller images are kept as is).")
+	thumbCmd.Flags().StringVarP(&thumbOut, "out", "o", "", "Save the thumbnail to this file (.png, .jpg, .ff or .ppm).")
+	thumbCmd.Flags().BoolVar(&thumbCache, "cache", false, "Store the thumbnail in the freedesktop cache (~/.cache/thumbnails) at the standard size that fits --size.")
+	thumbCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	thumbCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	thumbCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	thumbCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Transition is how slideshow goes from one image to the next, in cell
+// space: every frame of it is made of cells of the two renders, so it costs
+// no rendering and the frame diff only sends the cells that switch
+type Transition int
+
+const (
+	// TransitionFade crossfades by temporal dithering: every frame shows a
+	// different scattering of cells from the new image, more of them as it
+	// goes on, which the eye blends into a fade
+	TransitionFade Transition = iota
+	// TransitionWipe sweeps the new image in from the left
+	TransitionWipe
+	// TransitionDissolve switches cells over one by one in a fixed random
+	// order
+	TransitionDissolve
+	// TransitionNone cuts straight to the new image
+	TransitionNone
+)
+
+func parseTransition(value string) (Transition, error) {
+	switch strings.ToLower(value) {
+	case "fade", "":
+		return TransitionFade, nil
+	case "wipe":
+		return TransitionWipe, nil
+	case "dissolve":
+		return TransitionDissolve, nil
+	case "none":
+		return TransitionNone, nil
+	}
+	return TransitionFade, fmt.Errorf("unknown transition %q (use fade, wipe, dissolve or none)", value)
+}
+
+// Blend fills dst with the cells of from and to that the transition shows
+// progress (0 to 1) of the way through. frame counts the frames, which the
+// fade changes its dither pattern with. from and to have to be the same size
+func (t Transition) Blend(dst, from, to *CellGrid, progress float64, frame int) {
+	dst.Reset(to.Width, to.Height, to.Depth)
+	for y := 0; y < to.Height; y++ {
+		for x := 0; x < to.Width; x++ {
+			var threshold float64
+			switch t {
+			case TransitionFade:
+				// the blue noise moved along by the golden ratio every frame
+				// visits every threshold evenly without a visible pattern
+				_, threshold = math.Modf(blueNoiseOffset(x, y) + 0.5 + float64(frame)*0.618034)
+			case TransitionWipe:
+				threshold = (float64(x) + 0.5) / float64(to.Width)
+			case TransitionDissolve:
+				threshold = blueNoiseOffset(x, y) + 0.5
+			}
+			cell := from.Cells[y*to.Width+x]
+			if t == TransitionNone || threshold < progress {
+				cell = to.Cells[y*to.Width+x]
+			}
+			dst.Cells[y*to.Width+x] = cell
+		}
+	}
+}
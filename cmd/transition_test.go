@@ -0,0 +1,26 @@
+package cmd
+
+import "testing"
+
+func TestTransitionBlend(t *testing.T) {
+	from, to := NewCellGrid(16, 16, Color256), NewCellGrid(16, 16, Color256)
+	for i := range to.Cells {
+		from.Cells[i] = Cell{Glyph: ' ', FG: NoColor, BG: 21}
+		to.Cells[i] = Cell{Glyph: ' ', FG: NoColor, BG: 196}
+	}
+	dst := &CellGrid{}
+	for _, transition := range []Transition{TransitionFade, TransitionWipe, TransitionDissolve} {
+		for _, progress := range []float64{0, 0.5, 1} {
+			transition.Blend(dst, from, to, progress, 3)
+			switched := 0
+			for _, cell := range dst.Cells {
+				if cell.BG == 196 {
+					switched++
+				}
+			}
+			if want := int(progress * float64(len(dst.Cells))); switched < want-len(dst.Cells)/8 || switched > want+len(dst.Cells)/8 {
+				t.Errorf("transition %d at %g: %d of %d cells switched, want about %d", transition, progress, switched, len(dst.Cells), want)
+			}
+		}
+	}
+}
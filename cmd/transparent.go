@@ -0,0 +1,49 @@
+package cmd
+
+import "image"
+
+// clearTransparent turns the cells whose samples are all fully transparent
+// into TransparentGlyph cells. a half-block cell with one transparent half
+// keeps the other half and leaves the background at the terminal default
+func (r *ImageRenderer) clearTransparent(grid *CellGrid, scaled *image.RGBA) {
+	cellWidth, cellHeight := r.sampleResolution(1, 1)
+	bounds := scaled.Bounds()
+	// samples past the image's edge, in a partly filled braille cell, are
+	// transparent too
+	transparent := func(x0, y0, x1, y1 int) bool {
+		for y := y0; y < min(y1, bounds.Dy()); y++ {
+			for x := x0; x < min(x1, bounds.Dx()); x++ {
+				if scaled.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y).A != 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for y := 0; y < grid.Height; y++ {
+		row := grid.Row(y)
+		for x, c := range row {
+			sx, sy := x*cellWidth, y*cellHeight
+			if r.Mode != HalfBlockMode {
+				if transparent(sx, sy, sx+cellWidth, sy+cellHeight) {
+					row[x] = Cell{Glyph: TransparentGlyph, FG: NoColor, BG: NoColor}
+				}
+				continue
+			}
+
+			top, bottom := c.BG, c.BG
+			if c.Glyph == '▀' {
+				top = c.FG
+			}
+			switch clearTop, clearBottom := transparent(sx, sy, sx+1, sy+1), transparent(sx, sy+1, sx+1, sy+2); {
+			case clearTop && clearBottom:
+				row[x] = Cell{Glyph: TransparentGlyph, FG: NoColor, BG: NoColor}
+			case clearTop:
+				row[x] = Cell{Glyph: '▄', FG: bottom, BG: NoColor}
+			case clearBottom:
+				row[x] = Cell{Glyph: '▀', FG: top, BG: NoColor}
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	verifySHA256  string
+	verifyKey     string
+	verifySigPath string
+)
+
+// minisigMaxSize bounds how much of a signature file is read, they're
+// a few hundred bytes
+const minisigMaxSize = 64 << 10
+
+// verifying says whether what openSource reads has to pass --sha256 or
+// --verify-sig before anything decodes it
+func verifying() bool {
+	return verifySHA256 != "" || verifyKey != ""
+}
+
+// verifySource reads all of r and checks it against --sha256 and
+// --verify-sig, returning a reader over the same bytes when it passes, so
+// what's decoded is what was checked. ReadAll is bounded by --max-download
+// for downloads, openDownload stops their bodies there unless the user said
+// to go past it; local files aren't mapped while checks are on, see
+// openLocalFile
+func verifySource(pathOrURL string, r io.ReadCloser) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read image: %w", err)
+	}
+	infoColor := localized(themeColor("info", color.FgYellow))
+
+	if verifySHA256 != "" {
+		want, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(verifySHA256), "sha256:"))
+		if err != nil || len(want) != sha256.Size {
+			return nil, fmt.Errorf("--sha256 %q isn't a SHA-256 hash (64 hex digits)", verifySHA256)
+		}
+		if sum := sha256.Sum256(data); !bytes.Equal(sum[:], want) {
+			return nil, fmt.Errorf("checksum mismatch: the image's SHA-256 is %x, --sha256 wants %x", sum, want)
+		}
+		fmt.Fprintf(statusOut, "🔐 %s %x\n", infoColor("SHA-256 verified:"), want)
+	}
+
+	if verifyKey != "" {
+		key, err := loadMinisignKey(verifyKey)
+		if err != nil {
+			return nil, err
+		}
+		sigFile, err := readMinisig(pathOrURL)
+		if err != nil {
+			return nil, err
+		}
+		sig, err := parseMinisig(sigFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := key.verify(data, sig); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(statusOut, "🔐 %s %s\n", infoColor("Signature verified:"), sig.trustedComment)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// minisignKey is a minisign public key
+type minisignKey struct {
+	id  uint64
+	key ed25519.PublicKey
+}
+
+// minisignSignature is a minisign .minisig file
+type minisignSignature struct {
+	algorithm       string // Ed signs the file, ED its BLAKE2b-512 hash
+	id              uint64 // of the key that signed it
+	signature       []byte
+	trustedComment  string
+	globalSignature []byte // of signature and the trusted comment
+}
+
+// loadMinisignKey reads --verify-sig: the key itself, as minisign -G prints
+// it, or the path of its .pub file
+func loadMinisignKey(value string) (minisignKey, error) {
+	encoded := value
+	if data, err := os.ReadFile(value); err == nil {
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		encoded = lines[len(lines)-1] // after the untrusted comment
+	} else if strings.HasSuffix(value, ".pub") {
+		return minisignKey{}, fmt.Errorf("couldn't read public key: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return minisignKey{}, fmt.Errorf("--verify-sig %q isn't a minisign public key or its .pub file", value)
+	}
+	return minisignKey{id: binary.LittleEndian.Uint64(raw[2:10]), key: raw[10:]}, nil
+}
+
+// parseMinisig reads a signature file: an untrusted comment, the
+// signature, a trusted comment and the signature over that comment
+func parseMinisig(data []byte) (minisignSignature, error) {
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n")), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return minisignSignature{}, errors.New("the signature file isn't a minisign signature")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return minisignSignature{}, errors.New("the signature file has a broken signature")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return minisignSignature{}, errors.New("the signature file has a broken trusted comment signature")
+	}
+	return minisignSignature{
+		algorithm:       string(raw[:2]),
+		id:              binary.LittleEndian.Uint64(raw[2:10]),
+		signature:       raw[10:],
+		trustedComment:  strings.TrimPrefix(lines[2], "trusted comment: "),
+		globalSignature: global,
+	}, nil
+}
+
+// verify checks sig is k's signature of data, and that its trusted comment
+// is the one k signed
+func (k minisignKey) verify(data []byte, sig minisignSignature) error {
+	if sig.id != k.id {
+		return fmt.Errorf("signed with key %016X, --verify-sig is key %016X", sig.id, k.id)
+	}
+	message := data
+	switch sig.algorithm {
+	case "ED":
+		sum := blake2b.Sum512(data)
+		message = sum[:]
+	case "Ed":
+	default:
+		return fmt.Errorf("unknown signature algorithm %q", sig.algorithm)
+	}
+	if !ed25519.Verify(k.key, message, sig.signature) {
+		return errors.New("signature mismatch: the image was changed, or the signature is another file's")
+	}
+	if !ed25519.Verify(k.key, append(append([]byte{}, sig.signature...), sig.trustedComment...), sig.globalSignature) {
+		return errors.New("signature mismatch: the trusted comment was changed")
+	}
+	return nil
+}
+
+// readMinisig reads the signature of the image at pathOrURL: --sig, or the
+// .minisig file next to it the way minisign names them
+func readMinisig(pathOrURL string) ([]byte, error) {
+	path := verifySigPath
+	if path == "" {
+		if _, ok := gitSource(pathOrURL); ok || isDataURI(pathOrURL) {
+			return nil, errors.New("give the image's signature with --sig")
+		}
+		path = pathOrURL + ".minisig"
+		if u, err := url.Parse(pathOrURL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			u.Path += ".minisig" // not after the query
+			path = u.String()
+		}
+	}
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		resp, err := http.Get(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't download signature: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("couldn't download signature %s: received status code %d", path, resp.StatusCode)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, minisigMaxSize))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read signature: %w", err)
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, minisigMaxSize))
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// TestMinisign signs the way minisign does, with and without hashing the
+// file first, and checks changed files and comments are caught
+func TestMinisign(t *testing.T) {
+	private := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	id := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	pub := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), private.Public().(ed25519.PublicKey)...))
+	key, err := loadMinisignKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.id != binary.LittleEndian.Uint64(id) {
+		t.Errorf("key id %016X", key.id)
+	}
+
+	data := []byte("not really a PNG")
+	sign := func(algorithm, comment string) minisignSignature {
+		message := data
+		if algorithm == "ED" {
+			sum := blake2b.Sum512(data)
+			message = sum[:]
+		}
+		signature := ed25519.Sign(private, message)
+		file := strings.Join([]string{
+			"untrusted comment: signature from minisign secret key",
+			base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), id...), signature...)),
+			"trusted comment: " + comment,
+			base64.StdEncoding.EncodeToString(ed25519.Sign(private, append(signature, comment...))),
+		}, "\n") + "\n"
+		sig, err := parseMinisig([]byte(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	for _, algorithm := range []string{"Ed", "ED"} {
+		sig := sign(algorithm, "timestamp:1700000000\tfile:cat.png")
+		if err := key.verify(data, sig); err != nil {
+			t.Errorf("%s: %v", algorithm, err)
+		}
+		if err := key.verify([]byte("not really a GIF"), sig); err == nil {
+			t.Errorf("%s: a changed file passed", algorithm)
+		}
+		sig.trustedComment = "timestamp:1800000000\tfile:cat.png"
+		if err := key.verify(data, sig); err == nil {
+			t.Errorf("%s: a changed trusted comment passed", algorithm)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"math/cmplx"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// vizWindow is the number of samples each frame looks at, about 46ms at 44.1kHz
+const vizWindow = 2048
+
+// pcmRing keeps the most recent mono samples for the render loop
+type pcmRing struct {
+	mu      sync.Mutex
+	samples [vizWindow]float64
+	pos     int
+}
+
+func (r *pcmRing) write(s float64) {
+	r.mu.Lock()
+	r.samples[r.pos] = s
+	r.pos = (r.pos + 1) % len(r.samples)
+	r.mu.Unlock()
+}
+
+// latest copies the ring into dst, oldest sample first
+func (r *pcmRing) latest(dst []float64) {
+	r.mu.Lock()
+	n := copy(dst, r.samples[r.pos:])
+	copy(dst[n:], r.samples[:r.pos])
+	r.mu.Unlock()
+}
+
+// readPCM decodes interleaved little-endian PCM (s16le or f32le), mixes the
+// channels down to mono and feeds the ring until the input ends
+func readPCM(in io.Reader, format string, channels int, ring *pcmRing) error {
+	width := 2
+	if format == "f32le" {
+		width = 4
+	}
+	frame := make([]byte, width*channels)
+	reader := bufio.NewReader(in)
+	for {
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		var sum float64
+		for c := 0; c < channels; c++ {
+			b := frame[c*width:]
+			if width == 4 {
+				sum += float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			} else {
+				sum += float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+			}
+		}
+		ring.write(sum / float64(channels))
+	}
+}
+
+// pcmCommand builds the recorder for --input pulse/pipewire, both write
+// s16le to stdout
+func pcmCommand(input string, rate, channels int) (*exec.Cmd, error) {
+	switch input {
+	case "pulse":
+		return exec.Command("parec", "--format=s16le", "--rate="+strconv.Itoa(rate), "--channels="+strconv.Itoa(channels)), nil
+	case "pipewire":
+		return exec.Command("pw-record", "--format=s16", "--rate="+strconv.Itoa(rate), "--channels="+strconv.Itoa(channels), "-"), nil
+	}
+	return nil, fmt.Errorf("unknown input %q (use stdin, pulse or pipewire)", input)
+}
+
+// vizPalettes map a position in [0, 1] (bar height or amplitude) to a color
+var vizPalettes = map[string]func(t float64) color.RGBA{
+	"rainbow": func(t float64) color.RGBA {
+		return hsvToRGBA(240-240*t, 1, 1) // blue at the bottom to red at the top
+	},
+	"fire": func(t float64) color.RGBA {
+		return gradientRGBA(t, color.RGBA{80, 0, 0, 255}, color.RGBA{255, 60, 0, 255}, color.RGBA{255, 220, 0, 255}, color.RGBA{255, 255, 255, 255})
+	},
+	"ice": func(t float64) color.RGBA {
+		return gradientRGBA(t, color.RGBA{0, 0, 90, 255}, color.RGBA{0, 140, 255, 255}, color.RGBA{160, 255, 255, 255}, color.RGBA{255, 255, 255, 255})
+	},
+	"mono": func(t float64) color.RGBA {
+		return gradientRGBA(t, color.RGBA{90, 90, 90, 255}, color.RGBA{255, 255, 255, 255})
+	},
+}
+
+func hsvToRGBA(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 255}
+}
+
+// gradientRGBA interpolates linearly between evenly spaced stops
+func gradientRGBA(t float64, stops ...color.RGBA) color.RGBA {
+	t = max(0, min(t, 1)) * float64(len(stops)-1)
+	i := min(int(t), len(stops)-2)
+	f := t - float64(i)
+	a, b := stops[i], stops[i+1]
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*f) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// vizSource draws the visualization as a frame source for playFrames
+type vizSource struct {
+	ring     *pcmRing
+	done     <-chan struct{}
+	interval time.Duration
+	rate     int
+	style    string
+	palette  func(t float64) color.RGBA
+
+	canvas   *image.RGBA
+	samples  []float64
+	window   []float64
+	spectrum []complex128
+	levels   []float64 // smoothed bar heights
+}
+
+func (v *vizSource) prescaled() {}
+
+func (v *vizSource) next() (image.Image, time.Duration, error) {
+	select {
+	case <-v.done:
+		return nil, 0, io.EOF
+	default:
+	}
+
+	v.ring.latest(v.samples)
+	clear(v.canvas.Pix)
+	if v.style == "waveform" {
+		v.drawWaveform()
+	} else {
+		v.drawSpectrum()
+	}
+	return v.canvas, v.interval, nil
+}
+
+func (v *vizSource) drawWaveform() {
+	width, height := v.canvas.Rect.Dx(), v.canvas.Rect.Dy()
+	mid := float64(height-1) / 2
+	prevY := -1
+	for x := 0; x < width; x++ {
+		s := v.samples[x*len(v.samples)/width]
+		y := int(math.Round(mid - s*mid))
+		y = max(0, min(y, height-1))
+		if prevY < 0 {
+			prevY = y
+		}
+		// connect to the previous column so steep slopes stay a line
+		for yy := min(y, prevY); yy <= max(y, prevY); yy++ {
+			v.canvas.SetRGBA(x, yy, v.palette(math.Abs(float64(yy)-mid)/mid))
+		}
+		prevY = y
+	}
+}
+
+func (v *vizSource) drawSpectrum() {
+	width, height := v.canvas.Rect.Dx(), v.canvas.Rect.Dy()
+	for i, s := range v.samples {
+		v.spectrum[i] = complex(s*v.window[i], 0)
+	}
+	fft(v.spectrum)
+
+	// bars two samples wide with a one sample gap, on a log frequency axis
+	bars := max(width/3, 1)
+	if len(v.levels) != bars {
+		v.levels = make([]float64, bars)
+	}
+	nyquist := float64(v.rate) / 2
+	binHz := float64(v.rate) / float64(len(v.spectrum))
+	for bar := 0; bar < bars; bar++ {
+		lo := 20 * math.Pow(nyquist/20, float64(bar)/float64(bars))
+		hi := 20 * math.Pow(nyquist/20, float64(bar+1)/float64(bars))
+		from := max(int(lo/binHz), 1)
+		to := max(int(hi/binHz), from+1)
+
+		var peak float64
+		for k := from; k < to && k < len(v.spectrum)/2; k++ {
+			peak = max(peak, cmplx.Abs(v.spectrum[k]))
+		}
+		// -60dB..0dB relative to a full scale sine through the Hann window
+		db := 20 * math.Log10(peak/(float64(len(v.spectrum))/4)+1e-12)
+		level := max(0, min((db+60)/60, 1))
+		v.levels[bar] = max(level, v.levels[bar]*0.85) // let bars fall gently
+
+		top := height - int(v.levels[bar]*float64(height))
+		for y := top; y < height; y++ {
+			c := v.palette(float64(height-y) / float64(height))
+			for dx := 0; dx < 2 && bar*3+dx < width; dx++ {
+				v.canvas.SetRGBA(bar*3+dx, y, c)
+			}
+		}
+	}
+}
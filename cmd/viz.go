@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	vizStyle    string
+	vizPalette  string
+	vizInput    string
+	vizFormat   string
+	vizRate     int
+	vizChannels int
+	vizFPS      int
+)
+
+var vizCmd = &cobra.Command{
+	Use:   "viz",
+	Short: "Visualize audio from stdin, PulseAudio or PipeWire",
+	Long: `Visualize audio as a spectrum or waveform.
+
+Raw PCM is read from stdin, for example:
+	ffmpeg -i song.mp3 -f s16le -ac 2 -ar 44100 - | termuwu viz
+or recorded with --input pulse (parec) or --input pipewire (pw-record).`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		errorColor := localized(themeColor("error", color.FgRed, color.Bold))
+
+		palette, ok := vizPalettes[strings.ToLower(vizPalette)]
+		if !ok {
+			fmt.Fprintf(statusOut, tr("%s %q (use rainbow, fire, ice or mono)\n"), errorColor("❌ Unknown palette:"), vizPalette)
+			return
+		}
+		if vizStyle != "spectrum" && vizStyle != "waveform" {
+			fmt.Fprintf(statusOut, tr("%s %q (use spectrum or waveform)\n"), errorColor("❌ Unknown style:"), vizStyle)
+			return
+		}
+		if vizFormat != "s16le" && vizFormat != "f32le" {
+			fmt.Fprintf(statusOut, tr("%s %q (use s16le or f32le)\n"), errorColor("❌ Unknown sample format:"), vizFormat)
+			return
+		}
+		if vizRate <= 0 || vizChannels <= 0 || vizFPS <= 0 {
+			fmt.Fprintln(statusOut, errorColor("❌ --rate, --channels and --fps must be positive."))
+			return
+		}
+		if (renderWidth > 0 && renderHeight == 0) || (renderHeight > 0 && renderWidth == 0) {
+			fmt.Fprintln(statusOut, errorColor("❌ If specifying custom dimensions, both --width (-W) and --height (-H) must be provided."))
+			return
+		}
+		depth, err := parseColorDepth(colorMode)
+		if err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --colors value:"), err)
+			return
+		}
+		if setupConsole().legacy {
+			fmt.Fprintln(statusOut, errorColor("❌ Visualization needs a console with VT support (Windows 10 or later)."))
+			return
+		}
+
+		var input io.Reader = os.Stdin
+		format := vizFormat
+		if vizInput != "stdin" {
+			recorder, err := pcmCommand(vizInput, vizRate, vizChannels)
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Invalid --input value:"), err)
+				return
+			}
+			recorder.Stderr = os.Stderr
+			stdout, err := recorder.StdoutPipe()
+			if err == nil {
+				err = recorder.Start()
+			}
+			if err != nil {
+				fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Couldn't start the recorder:"), err)
+				return
+			}
+			defer recorder.Process.Kill()
+			input, format = stdout, "s16le"
+		}
+
+		ring := &pcmRing{}
+		done := make(chan struct{})
+		go func() {
+			if err := readPCM(input, format, vizChannels, ring); err != nil {
+				fmt.Fprintf(stderrStatus(), "%s %v\n", errorColor("❌ Error reading audio:"), err)
+			}
+			close(done)
+		}()
+
+		renderer := configureRenderer(useFullBlocks, useBraille, noDither, renderWidth, renderHeight)
+		renderer.ColorDepth = depth
+		// the canvas is drawn at sample resolution and fills the whole render area
+		width, height := renderer.sampleResolution(renderer.MaxWidth, renderer.MaxHeight)
+
+		source := &vizSource{
+			ring:     ring,
+			done:     done,
+			interval: time.Second / time.Duration(vizFPS),
+			rate:     vizRate,
+			style:    vizStyle,
+			palette:  palette,
+			canvas:   image.NewRGBA(image.Rect(0, 0, width, height)),
+			samples:  make([]float64, vizWindow),
+			window:   hannWindow(vizWindow),
+			spectrum: make([]complex128, vizWindow),
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := playFrames(ctx, os.Stdout, source, NewFrameRenderer(renderer), !noSync, nil); err != nil {
+			fmt.Fprintf(statusOut, "%s %v\n", errorColor("❌ Visualization failed:"), err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vizCmd)
+
+	vizCmd.Flags().StringVar(&vizStyle, "style", "spectrum", "What to draw: spectrum (log frequency bars) or waveform.")
+	vizCmd.Flags().StringVar(&vizPalette, "palette", "rainbow", "Colors: rainbow, fire, ice or mono.")
+	vizCmd.Flags().StringVar(&vizInput, "input", "stdin", "Where audio comes from: stdin (raw PCM), pulse (parec) or pipewire (pw-record).")
+	vizCmd.Flags().StringVar(&vizFormat, "format", "s16le", "Sample format of PCM on stdin: s16le or f32le.")
+	vizCmd.Flags().IntVar(&vizRate, "rate", 44100, "Sample rate of the audio in Hz.")
+	vizCmd.Flags().IntVar(&vizChannels, "channels", 2, "Number of interleaved channels, mixed down to mono.")
+	vizCmd.Flags().IntVar(&vizFPS, "fps", 30, "Frames per second.")
+	vizCmd.Flags().BoolVarP(&useFullBlocks, "full", "f", false, "Use full character blocks (less detail).")
+	vizCmd.Flags().BoolVarP(&useBraille, "braille", "b", false, "Use Braille patterns (experimental, more detail).")
+	vizCmd.Flags().BoolVarP(&noDither, "no-dither", "n", false, "Disable dithering (can reduce color noise but might cause banding).")
+	vizCmd.Flags().IntVarP(&renderWidth, "width", "W", 0, "Set the width of the rendered image in characters (0 for auto).")
+	vizCmd.Flags().IntVarP(&renderHeight, "height", "H", 0, "Set the height of the rendered image in lines (0 for auto).")
+	vizCmd.Flags().StringVar(&colorMode, "colors", "256", "Color depth to render with: 256, 16 or true.")
+	vizCmd.Flags().BoolVar(&noSync, "no-sync", false, "Don't wrap frames in synchronized updates (DEC mode 2026).")
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// whiteBalanceStops is how far the ends of --temperature and --tint push a
+// channel, in stops (doublings) of linear light
+const whiteBalanceStops = 0.75
+
+// WhiteBalanceFilter returns a Filter shifting the colors along the
+// blue-yellow axis by temperature and the green-magenta axis by tint, both
+// -100 to 100: a negative temperature cools a photo shot under warm indoor
+// light, a negative tint takes out the green cast of fluorescent tubes. the
+// channels are scaled in linear light like a camera's white balance, then
+// evened out so the overall brightness stays
+func WhiteBalanceFilter(temperature, tint float64) (Filter, error) {
+	if temperature < -100 || temperature > 100 {
+		return nil, fmt.Errorf("temperature %g is out of range (use -100 to 100)", temperature)
+	}
+	if tint < -100 || tint > 100 {
+		return nil, fmt.Errorf("tint %g is out of range (use -100 to 100)", tint)
+	}
+	t, m := temperature/100*whiteBalanceStops, tint/100*whiteBalanceStops
+	gains := [3]float64{math.Exp2(t + m/2), math.Exp2(-m), math.Exp2(-t + m/2)}
+	white := 0.2126*gains[0] + 0.7152*gains[1] + 0.0722*gains[2]
+	var tables [3][256]uint8
+	for c := range tables {
+		for v := range tables[c] {
+			tables[c][v] = linearToSRGB(srgbToLinear[v] * gains[c] / white)
+		}
+	}
+
+	return func(img *image.RGBA) {
+		for i := 0; i+3 < len(img.Pix); i += 4 {
+			switch alpha := uint32(img.Pix[i+3]); alpha {
+			case 0:
+			case 255:
+				img.Pix[i] = tables[0][img.Pix[i]]
+				img.Pix[i+1] = tables[1][img.Pix[i+1]]
+				img.Pix[i+2] = tables[2][img.Pix[i+2]]
+			default:
+				// the pixels are premultiplied, the curves work on the colors
+				for c := 0; c < 3; c++ {
+					v := min(uint32(img.Pix[i+c])*255/alpha, 255)
+					img.Pix[i+c] = uint8(uint32(tables[c][v]) * alpha / 255)
+				}
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ytdlpFormat picks the smallest rendition that still has video in one
+// file, a terminal can't show more than that anyway
+const ytdlpFormat = "worst[vcodec!=none][height>=144]/worst[vcodec!=none]/worst"
+
+// ytdlpResolve asks yt-dlp for a direct URL of videoURL's stream, or of its
+// thumbnail with thumbnail set
+func ytdlpResolve(ctx context.Context, videoURL string, thumbnail bool) (string, error) {
+	path, err := exec.LookPath("yt-dlp")
+	if err != nil {
+		return "", fmt.Errorf("--ytdlp needs yt-dlp installed: %w", err)
+	}
+
+	args := []string{"--no-warnings", "--no-playlist"}
+	if thumbnail {
+		args = append(args, "--get-thumbnail")
+	} else {
+		args = append(args, "--get-url", "--format", ytdlpFormat)
+	}
+	cmd := exec.CommandContext(ctx, path, append(args, "--", videoURL)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("yt-dlp %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	resolved, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if resolved == "" {
+		return "", errors.New("yt-dlp didn't find anything to play")
+	}
+	return resolved, nil
+}
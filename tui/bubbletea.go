@@ -0,0 +1,96 @@
+// Package tui provides ready-made widgets for showing termuwu renders inside
+// Bubble Tea and tview applications.
+package tui
+
+import (
+	"image"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/coffeeboi0811/termuwu/cmd"
+)
+
+// Model is a Bubble Tea model that displays an image. it re-renders only
+// when the image or its size changes, so calling View on every frame is cheap.
+type Model struct {
+	// AutoSize makes the model follow tea.WindowSizeMsg. turn it off when
+	// a parent layout assigns the size through SetSize instead
+	AutoSize bool
+
+	renderer *cmd.ImageRenderer
+	img      image.Image
+	view     string
+	dirty    bool
+}
+
+// NewModel shows img once it has a size, from a tea.WindowSizeMsg or SetSize
+func NewModel(img image.Image, mode cmd.RenderMode) Model {
+	return Model{
+		AutoSize: true,
+		renderer: newRenderer(mode),
+		img:      img,
+		dirty:    true,
+	}
+}
+
+// newRenderer is a renderer sized 0x0 rather than to the terminal the
+// program runs in: a widget is only as big as its layout says
+func newRenderer(mode cmd.RenderMode) *cmd.ImageRenderer {
+	r := cmd.NewDeterministicRenderer(mode)
+	r.MaxWidth, r.MaxHeight = 0, 0
+	return r
+}
+
+// Renderer exposes the underlying renderer for tweaking dither, colors, etc.
+// call Refresh afterwards so the next View picks the changes up
+func (m *Model) Renderer() *cmd.ImageRenderer {
+	return m.renderer
+}
+
+func (m *Model) SetImage(img image.Image) {
+	m.img = img
+	m.dirty = true
+}
+
+// SetSize sets the area available to the image in columns and rows
+func (m *Model) SetSize(width, height int) {
+	if width == m.renderer.MaxWidth && height == m.renderer.MaxHeight {
+		return
+	}
+	m.renderer.MaxWidth = width
+	m.renderer.MaxHeight = height
+	m.dirty = true
+}
+
+func (m *Model) Refresh() {
+	m.dirty = true
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok && m.AutoSize {
+		m.SetSize(size.Width, size.Height)
+	}
+	if m.dirty {
+		m.render()
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	if m.dirty {
+		m.render() // value receiver: the cache only sticks once Update runs
+	}
+	return m.view
+}
+
+func (m *Model) render() {
+	m.dirty = false
+	if m.img == nil || m.renderer.MaxWidth <= 0 || m.renderer.MaxHeight <= 0 {
+		m.view = ""
+		return
+	}
+	m.view = m.renderer.RenderStyled(m.img)
+}
@@ -0,0 +1,85 @@
+package tui
+
+import (
+	"image"
+	"image/color"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/coffeeboi0811/termuwu/cmd"
+)
+
+// testImage is 16x16 pixels, its left half transparent and its right half red
+func testImage() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := 8; x < 16; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{220, 30, 40, 255})
+		}
+	}
+	return img
+}
+
+var sgr = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// viewSize is how many columns and rows a view takes
+func viewSize(view string) (width, height int) {
+	lines := strings.Split(sgr.ReplaceAllString(view, ""), "\n")
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(line))
+	}
+	return width, len(lines)
+}
+
+// TestModelSize checks a model shows nothing until it's given a size, then
+// fits the image in it, rendering again when it changes
+func TestModelSize(t *testing.T) {
+	var model tea.Model = NewModel(testImage(), cmd.HalfBlockMode)
+	if view := model.View(); view != "" {
+		t.Errorf("a model without a size shows %q", view)
+	}
+	for _, size := range []tea.WindowSizeMsg{{Width: 20, Height: 6}, {Width: 40, Height: 12}} {
+		model, _ = model.Update(size)
+		width, height := viewSize(model.View())
+		if width == 0 || width > size.Width || height > size.Height {
+			t.Errorf("in %dx%d the image is %dx%d", size.Width, size.Height, width, height)
+		}
+		if height != size.Height && width != size.Width {
+			t.Errorf("in %dx%d the image is %dx%d, filling neither", size.Width, size.Height, width, height)
+		}
+	}
+
+	m := NewModel(testImage(), cmd.HalfBlockMode)
+	m.AutoSize = false
+	m.SetSize(20, 6)
+	model, _ = m.Update(tea.WindowSizeMsg{Width: 40, Height: 12})
+	if width, height := viewSize(model.View()); width > 20 || height > 6 {
+		t.Errorf("a model without AutoSize followed the window to %dx%d", width, height)
+	}
+}
+
+// TestModelViewCached checks View doesn't render again until something
+// says the image or the renderer changed
+func TestModelViewCached(t *testing.T) {
+	m := NewModel(testImage(), cmd.HalfBlockMode)
+	m.SetSize(20, 6)
+	model, _ := m.Update(nil)
+	m = model.(Model)
+	view := m.View()
+
+	m.Renderer().MaxWidth, m.Renderer().MaxHeight = 40, 12 // not through SetSize
+	if m.View() != view {
+		t.Error("View rendered again without Refresh")
+	}
+	m.Refresh()
+	if m.View() == view {
+		t.Error("View didn't render again after Refresh")
+	}
+	m.SetImage(image.NewNRGBA(image.Rect(0, 0, 16, 16)))
+	if m.View() == view {
+		t.Error("View didn't render again after SetImage")
+	}
+}
@@ -0,0 +1,81 @@
+package tui
+
+import (
+	"image"
+
+	"github.com/coffeeboi0811/termuwu/cmd"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// ImageView is a tview primitive that displays an image inside its box.
+// the cell grid is cached and only rebuilt when the inner area changes size.
+type ImageView struct {
+	*tview.Box
+
+	renderer *cmd.ImageRenderer
+	img      image.Image
+	grid     *cmd.CellGrid
+}
+
+func NewImageView(img image.Image, mode cmd.RenderMode) *ImageView {
+	return &ImageView{
+		Box:      tview.NewBox(),
+		renderer: newRenderer(mode),
+		img:      img,
+	}
+}
+
+// Renderer exposes the underlying renderer. call Refresh after changing it
+func (v *ImageView) Renderer() *cmd.ImageRenderer {
+	return v.renderer
+}
+
+func (v *ImageView) SetImage(img image.Image) *ImageView {
+	v.img = img
+	v.grid = nil
+	return v
+}
+
+func (v *ImageView) Refresh() {
+	v.grid = nil
+}
+
+func (v *ImageView) Draw(screen tcell.Screen) {
+	v.Box.DrawForSubclass(screen, v)
+	x, y, width, height := v.GetInnerRect()
+	if v.img == nil || width <= 0 || height <= 0 {
+		return
+	}
+
+	if v.grid == nil || v.renderer.MaxWidth != width || v.renderer.MaxHeight != height {
+		v.renderer.MaxWidth = width
+		v.renderer.MaxHeight = height
+		v.grid = v.renderer.Rasterize(v.img)
+	}
+
+	// center the image in the box
+	offsetX := x + (width-v.grid.Width)/2
+	offsetY := y + (height-v.grid.Height)/2
+	for row := 0; row < v.grid.Height; row++ {
+		for col, c := range v.grid.Row(row) {
+			if c.Glyph == cmd.TransparentGlyph {
+				continue // the box's background shows through
+			}
+			style := tcell.StyleDefault.
+				Foreground(tcellColor(v.grid, c.FG)).
+				Background(tcellColor(v.grid, c.BG))
+			screen.SetContent(offsetX+col, offsetY+row, c.Glyph, nil, style)
+		}
+	}
+}
+
+func tcellColor(grid *cmd.CellGrid, code int) tcell.Color {
+	if code == cmd.NoColor {
+		return tcell.ColorDefault
+	}
+	if grid.Depth == cmd.TrueColor {
+		return tcell.NewHexColor(int32(code))
+	}
+	return tcell.PaletteColor(code)
+}
@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/coffeeboi0811/termuwu/cmd"
+	"github.com/gdamore/tcell/v2"
+)
+
+// TestImageViewDraw checks the image is centered in the box and its
+// transparent cells leave the box's background showing
+func TestImageViewDraw(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(50, 30)
+
+	view := NewImageView(testImage(), cmd.HalfBlockMode)
+	view.Renderer().Transparent = true
+	view.SetBackgroundColor(tcell.ColorNavy)
+	for _, box := range []struct{ x, y, width, height int }{{2, 1, 30, 10}, {0, 0, 20, 24}} {
+		view.SetRect(box.x, box.y, box.width, box.height)
+		view.Draw(screen)
+
+		renderer := *view.Renderer()
+		if renderer.MaxWidth != box.width || renderer.MaxHeight != box.height {
+			t.Fatalf("in a %dx%d box the renderer is %dx%d", box.width, box.height, renderer.MaxWidth, renderer.MaxHeight)
+		}
+		grid := renderer.Rasterize(testImage())
+		offsetX := box.x + (box.width-grid.Width)/2
+		offsetY := box.y + (box.height-grid.Height)/2
+		transparent := 0
+		for y := box.y; y < box.y+box.height; y++ {
+			for x := box.x; x < box.x+box.width; x++ {
+				glyph, _, style, _ := screen.GetContent(x, y)
+				col, row := x-offsetX, y-offsetY
+				if col >= 0 && col < grid.Width && row >= 0 && row < grid.Height {
+					if c := grid.At(col, row); c.Glyph != cmd.TransparentGlyph {
+						if glyph != c.Glyph {
+							t.Errorf("cell %d,%d is %q, want %q", x, y, glyph, c.Glyph)
+						}
+						continue
+					}
+					transparent++
+				}
+				if _, bg, _ := style.Decompose(); glyph != ' ' || bg != tcell.ColorNavy {
+					t.Errorf("cell %d,%d is %q on %v, want the box's background", x, y, glyph, bg)
+				}
+			}
+		}
+		if transparent == 0 || grid.Width == box.width && grid.Height == box.height {
+			t.Fatalf("in a %dx%d box the %dx%d image doesn't test centering and transparency", box.width, box.height, grid.Width, grid.Height)
+		}
+	}
+}